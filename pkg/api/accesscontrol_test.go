@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/database"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/ossaccesscontrol"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/folder/foldertest"
+	"github.com/grafana/grafana/pkg/services/licensing"
+	"github.com/grafana/grafana/pkg/services/ngalert"
+	samanager "github.com/grafana/grafana/pkg/services/serviceaccounts/manager"
+	"github.com/grafana/grafana/pkg/services/team/teamtest"
+	"github.com/grafana/grafana/pkg/services/user/usertest"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// TestDeclareFixedRoles_resourcePermissions checks that the actions of the permission levels of every resource
+// registered in-tree are granted by the fixed roles declared in-tree
+func TestDeclareFixedRoles_resourcePermissions(t *testing.T) {
+	sql := db.InitTestDB(t)
+	features := featuremgmt.WithFeatures()
+	license := &licensing.OSSLicensingService{}
+
+	setup := func(t *testing.T) *acimpl.Service {
+		t.Helper()
+		cfg := setting.NewCfg()
+		acService := acimpl.ProvideOSSService(cfg, database.ProvideService(sql), localcache.ProvideService(), features)
+		hs := &HTTPServer{Cfg: cfg, Features: features, License: license, accesscontrolService: acService}
+		require.NoError(t, hs.declareFixedRoles())
+		require.NoError(t, ngalert.DeclareFixedRoles(acService))
+		require.NoError(t, samanager.RegisterRoles(acService))
+		return acService
+	}
+	ac := acimpl.ProvideAccessControl(setting.NewCfg())
+
+	t.Run("teams", func(t *testing.T) {
+		acService := setup(t)
+		_, err := ossaccesscontrol.ProvideTeamPermissions(features, routing.NewRouteRegister(), sql, ac, license, acService,
+			teamtest.NewFakeService(), usertest.NewUserServiceFake(), prometheus.NewRegistry(), nil)
+		require.NoError(t, err)
+		require.NoError(t, acService.RegisterFixedRoles(context.Background()))
+	})
+
+	t.Run("dashboards", func(t *testing.T) {
+		acService := setup(t)
+		_, err := ossaccesscontrol.ProvideDashboardPermissions(features, routing.NewRouteRegister(), sql, ac, license, &dashboards.FakeDashboardStore{},
+			foldertest.NewFakeService(), acService, teamtest.NewFakeService(), usertest.NewUserServiceFake(), prometheus.NewRegistry(), nil, nil)
+		require.NoError(t, err)
+		require.NoError(t, acService.RegisterFixedRoles(context.Background()))
+	})
+
+	t.Run("folders", func(t *testing.T) {
+		acService := setup(t)
+		_, err := ossaccesscontrol.ProvideFolderPermissions(features, routing.NewRouteRegister(), sql, ac, license, &dashboards.FakeDashboardStore{},
+			foldertest.NewFakeService(), acService, teamtest.NewFakeService(), usertest.NewUserServiceFake(), prometheus.NewRegistry(), nil, nil)
+		require.NoError(t, err)
+		require.NoError(t, acService.RegisterFixedRoles(context.Background()))
+	})
+
+	t.Run("serviceaccounts", func(t *testing.T) {
+		acService := setup(t)
+		_, err := ossaccesscontrol.ProvideServiceAccountPermissions(features, routing.NewRouteRegister(), sql, ac, license, nil,
+			acService, teamtest.NewFakeService(), usertest.NewUserServiceFake(), prometheus.NewRegistry(), nil)
+		require.NoError(t, err)
+		require.NoError(t, acService.RegisterFixedRoles(context.Background()))
+	})
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/resourcepermissions"
 	"github.com/grafana/grafana/pkg/services/auth/identity"
 	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
 	"github.com/grafana/grafana/pkg/services/dashboards"
@@ -161,6 +163,12 @@ func (hs *HTTPServer) UpdateDashboardPermissions(c *contextmodel.ReqContext) res
 	items = append(items, hs.filterHiddenACL(c.SignedInUser, acl)...)
 
 	if err := hs.updateDashboardAccessControl(c.Req.Context(), dash.OrgID, dash.UID, false, items, acl); err != nil {
+		if errors.Is(err, resourcepermissions.ErrInvalidPermission) || errors.Is(err, resourcepermissions.ErrInvalidAssignment) {
+			return response.Error(http.StatusBadRequest, "Failed to update permissions", err)
+		}
+		if errors.Is(err, resourcepermissions.ErrAssigneeNotFound) {
+			return response.Error(http.StatusNotFound, "Failed to update permissions", err)
+		}
 		return response.Error(http.StatusInternalServerError, "Failed to update permissions", err)
 	}
 
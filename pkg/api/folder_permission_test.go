@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strings"
@@ -10,7 +11,9 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
-	"github.com/grafana/grafana/pkg/services/accesscontrol/actest"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/ossaccesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/resourcepermissions"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/resourcepermissions/resourcepermissionstest"
 	"github.com/grafana/grafana/pkg/services/dashboards"
 	"github.com/grafana/grafana/pkg/services/folder"
 	"github.com/grafana/grafana/pkg/services/folder/foldertest"
@@ -31,9 +34,7 @@ func TestHTTPServer_GetFolderPermissionList(t *testing.T) {
 	t.Run("should be able to list acl with correct permission", func(t *testing.T) {
 		server := SetupAPITestServer(t, func(hs *HTTPServer) {
 			hs.folderService = &foldertest.FakeService{ExpectedFolder: &folder.Folder{UID: "1"}}
-			hs.folderPermissionsService = &actest.FakePermissionsService{
-				ExpectedPermissions: []accesscontrol.ResourcePermission{},
-			}
+			hs.folderPermissionsService, _ = setupTestFolderPermissionsService(t)
 		})
 
 		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/folders/1/permissions"), userWithPermissions(1, []accesscontrol.Permission{
@@ -52,12 +53,12 @@ func TestHTTPServer_GetFolderPermissionList(t *testing.T) {
 			hs.Cfg = cfg
 			hs.folderService = &foldertest.FakeService{ExpectedFolder: &folder.Folder{UID: "1"}}
 
-			hs.folderPermissionsService = &actest.FakePermissionsService{
-				ExpectedPermissions: []accesscontrol.ResourcePermission{
-					{UserId: 1, UserLogin: "regular", IsManaged: true},
-					{UserId: 2, UserLogin: "hidden", IsManaged: true},
-				},
-			}
+			service, seeder := setupTestFolderPermissionsService(t)
+			seeder.Seed(1,
+				accesscontrol.ResourcePermission{UserId: 1, UserLogin: "regular", Scope: "folders:uid:1", Actions: ossaccesscontrol.FolderViewActions},
+				accesscontrol.ResourcePermission{UserId: 2, UserLogin: "hidden", Scope: "folders:uid:1", Actions: ossaccesscontrol.FolderViewActions},
+			)
+			hs.folderPermissionsService = service
 		})
 
 		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/folders/1/permissions"), userWithPermissions(1, []accesscontrol.Permission{
@@ -89,7 +90,7 @@ func TestHTTPServer_UpdateFolderPermissions(t *testing.T) {
 	t.Run("should be able to update acl with correct permissions", func(t *testing.T) {
 		server := SetupAPITestServer(t, func(hs *HTTPServer) {
 			hs.folderService = &foldertest.FakeService{ExpectedFolder: &folder.Folder{UID: "1"}}
-			hs.folderPermissionsService = &actest.FakePermissionsService{}
+			hs.folderPermissionsService, _ = setupTestFolderPermissionsService(t)
 		})
 
 		body := `{"items": []}`
@@ -102,10 +103,39 @@ func TestHTTPServer_UpdateFolderPermissions(t *testing.T) {
 		require.NoError(t, res.Body.Close())
 	})
 
+	t.Run("should remove permissions not included in the acl", func(t *testing.T) {
+		service, seeder := setupTestFolderPermissionsService(t)
+		seeder.SeedUserPermission(1, 2, "folders:uid:1", ossaccesscontrol.FolderViewActions...)
+		seeder.SeedTeamPermission(1, 3, "folders:uid:1", ossaccesscontrol.FolderEditActions...)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.folderService = &foldertest.FakeService{ExpectedFolder: &folder.Folder{UID: "1"}}
+			hs.folderPermissionsService = service
+		})
+
+		body := `{"items": [{ "teamId": 3, "permission": 1 }]}`
+		res, err := server.SendJSON(webtest.RequestWithSignedInUser(server.NewPostRequest("/api/folders/1/permissions", strings.NewReader(body)), userWithPermissions(1, []accesscontrol.Permission{
+			{Action: dashboards.ActionFoldersPermissionsWrite, Scope: "folders:uid:1"},
+		})))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		require.NoError(t, res.Body.Close())
+
+		permissions, err := service.GetPermissions(context.Background(), userWithPermissions(1, []accesscontrol.Permission{
+			{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+			{Action: accesscontrol.ActionTeamsRead, Scope: accesscontrol.ScopeTeamsAll},
+		}), "1")
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, int64(3), permissions[0].TeamId)
+		assert.Equal(t, "View", service.MapActions(permissions[0]))
+	})
+
 	t.Run("should not be able to specify team and user in same acl", func(t *testing.T) {
 		server := SetupAPITestServer(t, func(hs *HTTPServer) {
 			hs.folderService = &foldertest.FakeService{ExpectedFolder: &folder.Folder{UID: "1"}}
-			hs.folderPermissionsService = &actest.FakePermissionsService{}
+			hs.folderPermissionsService, _ = setupTestFolderPermissionsService(t)
 		})
 
 		body := `{"items": [{ userId:1, teamId: 2 }]}`
@@ -121,7 +151,7 @@ func TestHTTPServer_UpdateFolderPermissions(t *testing.T) {
 	t.Run("should not be able to specify team and role in same acl", func(t *testing.T) {
 		server := SetupAPITestServer(t, func(hs *HTTPServer) {
 			hs.folderService = &foldertest.FakeService{ExpectedFolder: &folder.Folder{UID: "1"}}
-			hs.folderPermissionsService = &actest.FakePermissionsService{}
+			hs.folderPermissionsService, _ = setupTestFolderPermissionsService(t)
 		})
 
 		body := `{"items": [{ teamId:1, role: "Admin" }]}`
@@ -137,7 +167,7 @@ func TestHTTPServer_UpdateFolderPermissions(t *testing.T) {
 	t.Run("should not be able to specify user and role in same acl", func(t *testing.T) {
 		server := SetupAPITestServer(t, func(hs *HTTPServer) {
 			hs.folderService = &foldertest.FakeService{ExpectedFolder: &folder.Folder{UID: "1"}}
-			hs.folderPermissionsService = &actest.FakePermissionsService{}
+			hs.folderPermissionsService, _ = setupTestFolderPermissionsService(t)
 		})
 
 		body := `{"items": [{ userId:1, role: "Admin" }]}`
@@ -150,3 +180,21 @@ func TestHTTPServer_UpdateFolderPermissions(t *testing.T) {
 		require.NoError(t, res.Body.Close())
 	})
 }
+
+func setupTestFolderPermissionsService(t *testing.T) (*resourcepermissions.Service, *resourcepermissionstest.Seeder) {
+	return resourcepermissionstest.NewTestService(t, resourcepermissions.Options{
+		Resource:          "folders",
+		ResourceAttribute: "uid",
+		Assignments: resourcepermissions.Assignments{
+			Users:           true,
+			Teams:           true,
+			BuiltInRoles:    true,
+			ServiceAccounts: true,
+		},
+		PermissionsToActions: map[string][]string{
+			"View":  append(ossaccesscontrol.DashboardViewActions, ossaccesscontrol.FolderViewActions...),
+			"Edit":  append(ossaccesscontrol.DashboardEditActions, ossaccesscontrol.FolderEditActions...),
+			"Admin": append(ossaccesscontrol.DashboardAdminActions, ossaccesscontrol.FolderAdminActions...),
+		},
+	})
+}
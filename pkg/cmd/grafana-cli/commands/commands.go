@@ -143,6 +143,23 @@ var adminCommands = []*cli.Command{
 				Usage:  "Migrates passwords from unsecured fields to secure_json_data field. Return ok unless there is an error. Safe to execute multiple times.",
 				Action: runDbCommand(datamigrations.EncryptDatasourcePasswords),
 			},
+			{
+				Name:   "migrate-legacy-dashboard-acl",
+				Usage:  "Converts the legacy dashboard and folder ACL entries of an organization into managed permissions and removes them. Managed permissions are kept when a legacy entry contradicts them. Safe to execute multiple times.",
+				Action: runRunnerCommand(datamigrations.MigrateLegacyDashboardACL),
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "org-id",
+						Usage: "The organization to migrate",
+						Value: 1,
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the planned changes without writing them",
+						Value: false,
+					},
+				},
+			},
 		},
 	},
 	{
@@ -0,0 +1,62 @@
+package datamigrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/server"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/resourcepermissions"
+)
+
+// MigrateLegacyDashboardACL converts the legacy dashboard ACL entries of an organization into managed permissions.
+// With --dry-run the planned changes are printed and nothing is written.
+func MigrateLegacyDashboardACL(c utils.CommandLine, runner server.Runner) error {
+	orgID := int64(c.Int("org-id"))
+	dryRun := c.Bool("dry-run")
+
+	migrator := resourcepermissions.NewLegacyACLMigrator(runner.SQLStore, runner.DashboardPermissions, runner.FolderPermissions)
+	migrations, err := migrator.MigrateOrg(context.Background(), orgID, dryRun)
+	// the migrations done before a failure are still reported
+	for _, m := range migrations {
+		printLegacyACLMigration(m, dryRun)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(migrations) == 0 {
+		logger.Infof("No legacy dashboard ACL entries in org %d\n", orgID)
+	}
+	return nil
+}
+
+func printLegacyACLMigration(m resourcepermissions.LegacyACLMigration, dryRun bool) {
+	set, remove := "Set", "Removed"
+	if dryRun {
+		set, remove = "Would set", "Would remove"
+	}
+
+	logger.Infof("%s %s:\n", m.Resource, m.ResourceID)
+	for _, cmd := range m.Applied {
+		logger.Infof("  %s %s permission for %s\n", set, cmd.Permission, legacyACLAssignee(cmd))
+	}
+	for _, c := range m.Conflicts {
+		logger.Infof("  Conflict: keeping managed %s permission for %s, legacy entry grants %s\n", c.Managed, legacyACLAssignee(c.Legacy), c.Legacy.Permission)
+	}
+	for _, cmd := range m.Skipped {
+		logger.Infof("  Skipped %s permission for %s, the assignee no longer exists\n", cmd.Permission, legacyACLAssignee(cmd))
+	}
+	logger.Infof("  %s %d legacy entries\n", remove, m.Removed)
+}
+
+func legacyACLAssignee(cmd accesscontrol.SetResourcePermissionCommand) string {
+	if cmd.UserID != 0 {
+		return fmt.Sprintf("user %d", cmd.UserID)
+	} else if cmd.TeamID != 0 {
+		return fmt.Sprintf("team %d", cmd.TeamID)
+	}
+	return fmt.Sprintf("role %s", cmd.BuiltinRole)
+}
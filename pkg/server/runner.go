@@ -2,6 +2,7 @@ package server
 
 import (
 	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/ossaccesscontrol"
 	"github.com/grafana/grafana/pkg/services/encryption"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/secrets"
@@ -11,29 +12,34 @@ import (
 )
 
 type Runner struct {
-	Cfg               *setting.Cfg
-	SQLStore          db.DB
-	SettingsProvider  setting.Provider
-	Features          featuremgmt.FeatureToggles
-	EncryptionService encryption.Internal
-	SecretsService    *manager.SecretsService
-	SecretsMigrator   secrets.Migrator
-	UserService       user.Service
+	Cfg                  *setting.Cfg
+	SQLStore             db.DB
+	SettingsProvider     setting.Provider
+	Features             featuremgmt.FeatureToggles
+	EncryptionService    encryption.Internal
+	SecretsService       *manager.SecretsService
+	SecretsMigrator      secrets.Migrator
+	UserService          user.Service
+	DashboardPermissions *ossaccesscontrol.DashboardPermissionsService
+	FolderPermissions    *ossaccesscontrol.FolderPermissionsService
 }
 
 func NewRunner(cfg *setting.Cfg, sqlStore db.DB, settingsProvider setting.Provider,
 	encryptionService encryption.Internal, features featuremgmt.FeatureToggles,
 	secretsService *manager.SecretsService, secretsMigrator secrets.Migrator,
-	userService user.Service,
+	userService user.Service, dashboardPermissions *ossaccesscontrol.DashboardPermissionsService,
+	folderPermissions *ossaccesscontrol.FolderPermissionsService,
 ) Runner {
 	return Runner{
-		Cfg:               cfg,
-		SQLStore:          sqlStore,
-		SettingsProvider:  settingsProvider,
-		EncryptionService: encryptionService,
-		SecretsService:    secretsService,
-		SecretsMigrator:   secretsMigrator,
-		Features:          features,
-		UserService:       userService,
+		Cfg:                  cfg,
+		SQLStore:             sqlStore,
+		SettingsProvider:     settingsProvider,
+		EncryptionService:    encryptionService,
+		SecretsService:       secretsService,
+		SecretsMigrator:      secretsMigrator,
+		Features:             features,
+		UserService:          userService,
+		DashboardPermissions: dashboardPermissions,
+		FolderPermissions:    folderPermissions,
 	}
 }
@@ -28,20 +28,40 @@ type Service interface {
 	SearchUsersPermissions(ctx context.Context, user identity.Requester, options SearchOptions) (map[int64][]Permission, error)
 	// ClearUserPermissionCache removes the permission cache entry for the given user
 	ClearUserPermissionCache(user identity.Requester)
+	// ClearUsersPermissionCache removes the permission cache entries for the given users and service accounts in an organization
+	ClearUsersPermissionCache(orgID int64, userIDs ...int64)
+	// ClearOrgPermissionCache removes the permission cache entries of every identity in an organization
+	ClearOrgPermissionCache(orgID int64)
 	// SearchUserPermissions returns single user's permissions filtered by an action prefix or an action
 	SearchUserPermissions(ctx context.Context, orgID int64, filterOptions SearchOptions) ([]Permission, error)
 	// DeleteUserPermissions removes all permissions user has in org and all permission to that user
 	// If orgID is set to 0 remove permissions from all orgs
 	DeleteUserPermissions(ctx context.Context, orgID, userID int64) error
+	// RegisterUserRemovedHandler registers a handler called by DeleteUserPermissions before the permissions are removed
+	RegisterUserRemovedHandler(handler UserRemovedHandler)
+	// RegisterManagedRolePrefixes registers prefixes of managed roles other than ManagedRolePrefix, the permissions of
+	// the roles named with them are granted to their assignees like the ones of the roles named with ManagedRolePrefix
+	RegisterManagedRolePrefixes(prefixes ...string)
 	// DeclareFixedRoles allows the caller to declare, to the service, fixed roles and their
 	// assignments to organization roles ("Viewer", "Editor", "Admin") or "Grafana Admin"
 	DeclareFixedRoles(registrations ...RoleRegistration) error
+	// RegisterActionsValidator registers a validator called by RegisterFixedRoles once every declared role is
+	// registered, the registration fails with the errors of the validators
+	RegisterActionsValidator(validator ActionsValidator)
 	// SaveExternalServiceRole creates or updates an external service's role and assigns it to a given service account id.
 	SaveExternalServiceRole(ctx context.Context, cmd SaveExternalServiceRoleCommand) error
 	// DeleteExternalServiceRole removes an external service's role and its assignment.
 	DeleteExternalServiceRole(ctx context.Context, externalServiceID string) error
 }
 
+// UserRemovedHandler is called when a user is removed from an organization, or from every organization when orgID is
+// GlobalOrgID, e.g. to clean up what the user was granted in it
+type UserRemovedHandler func(ctx context.Context, orgID, userID int64) error
+
+// ActionsValidator checks the actions a service relies on once the fixed roles are registered, registered reports
+// whether an action is granted by a declared role
+type ActionsValidator func(registered func(action string) bool) error
+
 type RoleRegistry interface {
 	// RegisterFixedRoles registers all roles declared to AccessControl
 	RegisterFixedRoles(ctx context.Context) error
@@ -90,10 +110,14 @@ type PermissionsService interface {
 	SetBuiltInRolePermission(ctx context.Context, orgID int64, builtInRole string, resourceID string, permission string) (*ResourcePermission, error)
 	// SetPermissions sets several permissions on resource for either built-in role, team or user
 	SetPermissions(ctx context.Context, orgID int64, resourceID string, commands ...SetResourcePermissionCommand) ([]ResourcePermission, error)
+	// SetDefaultPermissions sets the permissions configured for new resources, creator is the identity creating the resource
+	SetDefaultPermissions(ctx context.Context, orgID int64, resourceID string, creator identity.Requester) ([]ResourcePermission, error)
 	// MapActions will map actions for a ResourcePermissions to it's "friendly" name configured in PermissionsToActions map.
 	MapActions(permission ResourcePermission) string
 	// DeleteResourcePermissions removes all permissions for a resource
 	DeleteResourcePermissions(ctx context.Context, orgID int64, resourceID string) error
+	// HandleResourceMoved applies policy to the managed permissions of a resource moved from oldParentScope to newParentScope
+	HandleResourceMoved(ctx context.Context, orgID int64, resourceID, oldParentScope, newParentScope string, policy ResourceMovePolicy) error
 }
 
 type User struct {
@@ -2,9 +2,12 @@ package acimpl
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -94,6 +97,15 @@ type Service struct {
 	registrations accesscontrol.RegistrationList
 	roles         map[string]*accesscontrol.RoleDTO
 	features      *featuremgmt.FeatureManager
+
+	userRemovedMu       sync.RWMutex
+	userRemovedHandlers []accesscontrol.UserRemovedHandler
+
+	managedPrefixesMu sync.RWMutex
+	managedPrefixes   []string
+
+	actionsValidatorsMu sync.RWMutex
+	actionsValidators   []accesscontrol.ActionsValidator
 }
 
 func (s *Service) GetUsageStats(_ context.Context) map[string]any {
@@ -136,7 +148,7 @@ func (s *Service) getUserPermissions(ctx context.Context, user identity.Requeste
 		UserID:       userID,
 		Roles:        accesscontrol.GetOrgRoles(user),
 		TeamIDs:      user.GetTeams(),
-		RolePrefixes: []string{accesscontrol.ManagedRolePrefix, accesscontrol.ExternalServiceRolePrefix},
+		RolePrefixes: s.rolePrefixes(),
 	})
 	if err != nil {
 		return nil, err
@@ -171,8 +183,64 @@ func (s *Service) ClearUserPermissionCache(user identity.Requester) {
 	s.cache.Delete(permissionCacheKey(user))
 }
 
+func (s *Service) ClearUsersPermissionCache(orgID int64, userIDs ...int64) {
+	for _, userID := range userIDs {
+		// the id can either belong to a user or to a service account
+		s.cache.Delete(permissionCacheKey(&user.SignedInUser{OrgID: orgID, UserID: userID}))
+		s.cache.Delete(permissionCacheKey(&user.SignedInUser{OrgID: orgID, UserID: userID, IsServiceAccount: true}))
+	}
+}
+
+func (s *Service) ClearOrgPermissionCache(orgID int64) {
+	prefix := fmt.Sprintf("rbac-permissions-%d-", orgID)
+	for key := range s.cache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			s.cache.Delete(key)
+		}
+	}
+}
+
 func (s *Service) DeleteUserPermissions(ctx context.Context, orgID int64, userID int64) error {
-	return s.store.DeleteUserPermissions(ctx, orgID, userID)
+	s.userRemovedMu.RLock()
+	handlers := s.userRemovedHandlers
+	s.userRemovedMu.RUnlock()
+
+	// the handlers may read what the user is granted, they are called before it is removed
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, orgID, userID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := s.store.DeleteUserPermissions(ctx, orgID, userID); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) RegisterUserRemovedHandler(handler accesscontrol.UserRemovedHandler) {
+	s.userRemovedMu.Lock()
+	defer s.userRemovedMu.Unlock()
+	s.userRemovedHandlers = append(s.userRemovedHandlers, handler)
+}
+
+func (s *Service) RegisterManagedRolePrefixes(prefixes ...string) {
+	s.managedPrefixesMu.Lock()
+	defer s.managedPrefixesMu.Unlock()
+	for _, prefix := range prefixes {
+		if prefix != accesscontrol.ManagedRolePrefix && !slices.Contains(s.managedPrefixes, prefix) {
+			s.managedPrefixes = append(s.managedPrefixes, prefix)
+		}
+	}
+}
+
+// rolePrefixes returns the prefixes of the names of the roles granting permissions to their assignees, besides
+// basic and fixed roles
+func (s *Service) rolePrefixes() []string {
+	s.managedPrefixesMu.RLock()
+	defer s.managedPrefixesMu.RUnlock()
+	return append([]string{accesscontrol.ManagedRolePrefix, accesscontrol.ExternalServiceRolePrefix}, s.managedPrefixes...)
 }
 
 // DeclareFixedRoles allow the caller to declare, to the service, fixed roles and their assignments
@@ -195,9 +263,13 @@ func (s *Service) DeclareFixedRoles(registrations ...accesscontrol.RoleRegistrat
 	return nil
 }
 
-// RegisterFixedRoles registers all declared roles in RAM
+// RegisterFixedRoles registers all declared roles in RAM, then calls the actions validators
 func (s *Service) RegisterFixedRoles(ctx context.Context) error {
+	actions := map[string]struct{}{}
 	s.registrations.Range(func(registration accesscontrol.RoleRegistration) bool {
+		for _, p := range registration.Role.Permissions {
+			actions[p.Action] = struct{}{}
+		}
 		for br := range accesscontrol.BuiltInRolesWithParents(registration.Grants) {
 			if basicRole, ok := s.roles[br]; ok {
 				basicRole.Permissions = append(basicRole.Permissions, registration.Role.Permissions...)
@@ -207,7 +279,26 @@ func (s *Service) RegisterFixedRoles(ctx context.Context) error {
 		}
 		return true
 	})
-	return nil
+
+	registered := func(action string) bool {
+		_, ok := actions[action]
+		return ok
+	}
+	s.actionsValidatorsMu.RLock()
+	defer s.actionsValidatorsMu.RUnlock()
+	var errs []error
+	for _, validator := range s.actionsValidators {
+		if err := validator(registered); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) RegisterActionsValidator(validator accesscontrol.ActionsValidator) {
+	s.actionsValidatorsMu.Lock()
+	defer s.actionsValidatorsMu.Unlock()
+	s.actionsValidators = append(s.actionsValidators, validator)
 }
 
 func permissionCacheKey(user identity.Requester) string {
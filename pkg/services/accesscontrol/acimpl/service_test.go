@@ -2,6 +2,7 @@ package acimpl
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -364,6 +365,40 @@ func TestService_RegisterFixedRoles(t *testing.T) {
 	}
 }
 
+func TestService_RegisterActionsValidator(t *testing.T) {
+	ac := setupTestEnv(t)
+	require.NoError(t, ac.DeclareFixedRoles(
+		accesscontrol.RoleRegistration{
+			Role:   accesscontrol.RoleDTO{Name: "fixed:test:test", Permissions: []accesscontrol.Permission{{Action: "test:test"}}},
+			Grants: []string{"Editor"},
+		},
+		accesscontrol.RoleRegistration{
+			Role: accesscontrol.RoleDTO{Name: "fixed:test:ungranted", Permissions: []accesscontrol.Permission{{Action: "test:ungranted"}}},
+		},
+	))
+
+	var checked map[string]bool
+	validatorErr := errors.New("unknown action")
+	ac.RegisterActionsValidator(func(registered func(action string) bool) error {
+		checked = map[string]bool{}
+		for _, action := range []string{"test:test", "test:ungranted", "test:unknown"} {
+			checked[action] = registered(action)
+		}
+		if !checked["test:unknown"] {
+			return validatorErr
+		}
+		return nil
+	})
+
+	err := ac.RegisterFixedRoles(context.Background())
+	require.ErrorIs(t, err, validatorErr)
+	assert.Equal(t, map[string]bool{
+		"test:test":      true,
+		"test:ungranted": true,
+		"test:unknown":   false,
+	}, checked, "the actions of every declared role should be registered")
+}
+
 func TestService_SearchUsersPermissions(t *testing.T) {
 	searchOption := accesscontrol.SearchOptions{ActionPrefix: "teams"}
 	ctx := context.Background()
@@ -884,3 +919,30 @@ func TestService_DeleteExternalServiceRole(t *testing.T) {
 		})
 	}
 }
+
+func TestService_DeleteUserPermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	var called []int64
+	ac.RegisterUserRemovedHandler(func(ctx context.Context, orgID, userID int64) error {
+		called = append(called, orgID, userID)
+		return nil
+	})
+	errHandler := errors.New("handler failed")
+	ac.RegisterUserRemovedHandler(func(ctx context.Context, orgID, userID int64) error {
+		return errHandler
+	})
+
+	err := ac.DeleteUserPermissions(context.Background(), 1, 2)
+	assert.ErrorIs(t, err, errHandler)
+	assert.Equal(t, []int64{1, 2}, called, "every handler should be called with the organization and the user")
+}
+
+func TestService_RegisterManagedRolePrefixes(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	ac.RegisterManagedRolePrefixes(accesscontrol.ManagedRolePrefix, "rbac:")
+	ac.RegisterManagedRolePrefixes("rbac:", "blue:")
+	assert.Equal(t, []string{accesscontrol.ManagedRolePrefix, accesscontrol.ExternalServiceRolePrefix, "rbac:", "blue:"}, ac.rolePrefixes(),
+		"the registered prefixes should be read once next to the default ones")
+}
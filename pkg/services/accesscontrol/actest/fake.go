@@ -36,10 +36,20 @@ func (f FakeService) SearchUserPermissions(ctx context.Context, orgID int64, sea
 
 func (f FakeService) ClearUserPermissionCache(user identity.Requester) {}
 
+func (f FakeService) ClearUsersPermissionCache(orgID int64, userIDs ...int64) {}
+
+func (f FakeService) ClearOrgPermissionCache(orgID int64) {}
+
 func (f FakeService) DeleteUserPermissions(ctx context.Context, orgID, userID int64) error {
 	return f.ExpectedErr
 }
 
+func (f FakeService) RegisterUserRemovedHandler(handler accesscontrol.UserRemovedHandler) {}
+
+func (f FakeService) RegisterManagedRolePrefixes(prefixes ...string) {}
+
+func (f FakeService) RegisterActionsValidator(validator accesscontrol.ActionsValidator) {}
+
 func (f FakeService) DeclareFixedRoles(registrations ...accesscontrol.RoleRegistration) error {
 	return f.ExpectedErr
 }
@@ -130,10 +140,18 @@ func (f *FakePermissionsService) SetPermissions(ctx context.Context, orgID int64
 	return f.ExpectedPermissions, f.ExpectedErr
 }
 
+func (f *FakePermissionsService) SetDefaultPermissions(ctx context.Context, orgID int64, resourceID string, creator identity.Requester) ([]accesscontrol.ResourcePermission, error) {
+	return f.ExpectedPermissions, f.ExpectedErr
+}
+
 func (f *FakePermissionsService) DeleteResourcePermissions(ctx context.Context, orgID int64, resourceID string) error {
 	return f.ExpectedErr
 }
 
+func (f *FakePermissionsService) HandleResourceMoved(ctx context.Context, orgID int64, resourceID, oldParentScope, newParentScope string, policy accesscontrol.ResourceMovePolicy) error {
+	return f.ExpectedErr
+}
+
 func (f *FakePermissionsService) MapActions(permission accesscontrol.ResourcePermission) string {
 	return f.ExpectedMappedAction
 }
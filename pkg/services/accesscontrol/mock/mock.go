@@ -22,12 +22,17 @@ type Calls struct {
 	Evaluate                       []interface{}
 	GetUserPermissions             []interface{}
 	ClearUserPermissionCache       []interface{}
+	ClearUsersPermissionCache      []interface{}
+	ClearOrgPermissionCache        []interface{}
 	DeclareFixedRoles              []interface{}
 	DeclarePluginRoles             []interface{}
 	GetUserBuiltInRoles            []interface{}
 	RegisterFixedRoles             []interface{}
 	RegisterAttributeScopeResolver []interface{}
 	DeleteUserPermissions          []interface{}
+	RegisterUserRemovedHandler     []interface{}
+	RegisterManagedRolePrefixes    []interface{}
+	RegisterActionsValidator       []interface{}
 	SearchUsersPermissions         []interface{}
 	SearchUserPermissions          []interface{}
 	SaveExternalServiceRole        []interface{}
@@ -47,6 +52,8 @@ type Mock struct {
 	EvaluateFunc                       func(context.Context, identity.Requester, accesscontrol.Evaluator) (bool, error)
 	GetUserPermissionsFunc             func(context.Context, identity.Requester, accesscontrol.Options) ([]accesscontrol.Permission, error)
 	ClearUserPermissionCacheFunc       func(identity.Requester)
+	ClearUsersPermissionCacheFunc      func(int64, ...int64)
+	ClearOrgPermissionCacheFunc        func(int64)
 	DeclareFixedRolesFunc              func(...accesscontrol.RoleRegistration) error
 	DeclarePluginRolesFunc             func(context.Context, string, string, []plugins.RoleRegistration) error
 	GetUserBuiltInRolesFunc            func(user identity.Requester) []string
@@ -145,6 +152,22 @@ func (m *Mock) ClearUserPermissionCache(user identity.Requester) {
 	}
 }
 
+func (m *Mock) ClearUsersPermissionCache(orgID int64, userIDs ...int64) {
+	m.Calls.ClearUsersPermissionCache = append(m.Calls.ClearUsersPermissionCache, []interface{}{orgID, userIDs})
+	// Use override if provided
+	if m.ClearUsersPermissionCacheFunc != nil {
+		m.ClearUsersPermissionCacheFunc(orgID, userIDs...)
+	}
+}
+
+func (m *Mock) ClearOrgPermissionCache(orgID int64) {
+	m.Calls.ClearOrgPermissionCache = append(m.Calls.ClearOrgPermissionCache, []interface{}{orgID})
+	// Use override if provided
+	if m.ClearOrgPermissionCacheFunc != nil {
+		m.ClearOrgPermissionCacheFunc(orgID)
+	}
+}
+
 // DeclareFixedRoles allow the caller to declare, to the service, fixed roles and their
 // assignments to organization roles ("Viewer", "Editor", "Admin") or "Grafana Admin"
 // This mock returns no error unless an override is provided.
@@ -198,6 +221,18 @@ func (m *Mock) DeleteUserPermissions(ctx context.Context, orgID, userID int64) e
 	return nil
 }
 
+func (m *Mock) RegisterUserRemovedHandler(handler accesscontrol.UserRemovedHandler) {
+	m.Calls.RegisterUserRemovedHandler = append(m.Calls.RegisterUserRemovedHandler, []interface{}{handler})
+}
+
+func (m *Mock) RegisterManagedRolePrefixes(prefixes ...string) {
+	m.Calls.RegisterManagedRolePrefixes = append(m.Calls.RegisterManagedRolePrefixes, []interface{}{prefixes})
+}
+
+func (m *Mock) RegisterActionsValidator(validator accesscontrol.ActionsValidator) {
+	m.Calls.RegisterActionsValidator = append(m.Calls.RegisterActionsValidator, []interface{}{validator})
+}
+
 // SearchUsersPermissions returns all users' permissions filtered by an action prefix
 func (m *Mock) SearchUsersPermissions(ctx context.Context, usr identity.Requester, options accesscontrol.SearchOptions) (map[int64][]accesscontrol.Permission, error) {
 	user := usr.(*user.SignedInUser)
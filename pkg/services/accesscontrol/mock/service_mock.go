@@ -44,11 +44,21 @@ func (m *MockPermissionsService) SetPermissions(ctx context.Context, orgID int64
 	return mockedArgs.Get(0).([]accesscontrol.ResourcePermission), mockedArgs.Error(1)
 }
 
+func (m *MockPermissionsService) SetDefaultPermissions(ctx context.Context, orgID int64, resourceID string, creator identity.Requester) ([]accesscontrol.ResourcePermission, error) {
+	mockedArgs := m.Called(ctx, orgID, resourceID, creator)
+	return mockedArgs.Get(0).([]accesscontrol.ResourcePermission), mockedArgs.Error(1)
+}
+
 func (m *MockPermissionsService) DeleteResourcePermissions(ctx context.Context, orgID int64, resourceID string) error {
 	mockedArgs := m.Called(ctx, orgID, resourceID)
 	return mockedArgs.Error(1)
 }
 
+func (m *MockPermissionsService) HandleResourceMoved(ctx context.Context, orgID int64, resourceID, oldParentScope, newParentScope string, policy accesscontrol.ResourceMovePolicy) error {
+	mockedArgs := m.Called(ctx, orgID, resourceID, oldParentScope, newParentScope, policy)
+	return mockedArgs.Error(0)
+}
+
 func (m *MockPermissionsService) MapActions(permission accesscontrol.ResourcePermission) string {
 	mockedArgs := m.Called(permission)
 	return mockedArgs.Get(0).(string)
@@ -1,6 +1,7 @@
 package accesscontrol
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -246,8 +247,19 @@ type ResourcePermission struct {
 	IsManaged        bool
 	IsInherited      bool
 	IsServiceAccount bool
-	Created          time.Time
-	Updated          time.Time
+	// AssigneeKind and AssigneeID identify the assignee of permissions granted to a custom assignment kind
+	AssigneeKind string
+	AssigneeID   string
+	// GrantedBy is the user who last granted the permission, or one of the synthetic grantors, e.g. GrantorProvisioning
+	GrantedBy int64
+	// Reason is the justification given when the permission was granted, such as a ticket reference
+	Reason string
+	// ViaTeamID and ViaTeam identify the team a user is granted the permission through, on the permissions derived
+	// from the permission of a team for each of its members
+	ViaTeamID int64
+	ViaTeam   string
+	Created   time.Time
+	Updated   time.Time
 }
 
 func (p *ResourcePermission) Contains(targetActions []string) bool {
@@ -278,6 +290,44 @@ type SetResourcePermissionCommand struct {
 	TeamID      int64  `json:"teamId,omitempty"`
 	BuiltinRole string `json:"builtInRole,omitempty"`
 	Permission  string `json:"permission"`
+	// Reason is the justification of the permission, such as a ticket reference
+	Reason string `json:"reason,omitempty"`
+}
+
+// ResourceMovePolicy is what happens to the managed permissions of a resource moved to another parent, e.g. a
+// dashboard moved to another folder
+type ResourceMovePolicy string
+
+const (
+	// MovePolicyKeep leaves the managed permissions of the resource untouched
+	MovePolicyKeep ResourceMovePolicy = "keep"
+	// MovePolicyDropRedundant removes the managed permissions already granted by the new parent and its ancestors
+	MovePolicyDropRedundant ResourceMovePolicy = "dropRedundant"
+	// MovePolicyClear removes every managed permission of the resource, so that it only inherits permissions
+	MovePolicyClear ResourceMovePolicy = "clear"
+)
+
+// GrantorUnknown, GrantorService and GrantorProvisioning are the grantors recorded for managed permissions that were
+// not granted by a user: the ones stored before grantors were recorded, the ones written by Grafana without a signed
+// in user, and the ones written by provisioning
+const (
+	GrantorUnknown      int64 = 0
+	GrantorService      int64 = -1
+	GrantorProvisioning int64 = -2
+)
+
+type grantorKey struct{}
+
+// WithGrantor returns a context recording grantor as the grantor of the managed permissions written with it,
+// instead of the signed in user of the context
+func WithGrantor(ctx context.Context, grantor int64) context.Context {
+	return context.WithValue(ctx, grantorKey{}, grantor)
+}
+
+// GrantorFromContext returns the grantor set with WithGrantor, if any
+func GrantorFromContext(ctx context.Context) (int64, bool) {
+	grantor, ok := ctx.Value(grantorKey{}).(int64)
+	return grantor, ok
 }
 
 type SaveExternalServiceRoleCommand struct {
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/grafana/grafana/pkg/api/routing"
 	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/usagestats"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/accesscontrol/resourcepermissions"
 	"github.com/grafana/grafana/pkg/services/auth/identity"
@@ -17,11 +20,14 @@ import (
 	"github.com/grafana/grafana/pkg/services/folder"
 	"github.com/grafana/grafana/pkg/services/libraryelements"
 	"github.com/grafana/grafana/pkg/services/licensing"
+	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts/retriever"
 	"github.com/grafana/grafana/pkg/services/team"
 	"github.com/grafana/grafana/pkg/services/team/teamimpl"
 	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
 )
 
 type TeamPermissionsService struct {
@@ -45,11 +51,13 @@ var (
 func ProvideTeamPermissions(
 	features featuremgmt.FeatureToggles, router routing.RouteRegister, sql db.DB,
 	ac accesscontrol.AccessControl, license licensing.Licensing, service accesscontrol.Service,
-	teamService team.Service, userService user.Service,
+	teamService team.Service, userService user.Service, reg prometheus.Registerer, usageStats usagestats.Service,
 ) (*TeamPermissionsService, error) {
 	options := resourcepermissions.Options{
 		Resource:          "teams",
 		ResourceAttribute: "id",
+		UsageStats:        usageStats,
+		Registerer:        reg,
 		OnlyManaged:       true,
 		ResourceValidator: func(ctx context.Context, orgID int64, resourceID string) error {
 			id, err := strconv.ParseInt(resourceID, 10, 64)
@@ -61,11 +69,10 @@ func ProvideTeamPermissions(
 				OrgID: orgID,
 				ID:    id,
 			})
-			if err != nil {
-				return err
+			if errors.Is(err, team.ErrTeamNotFound) {
+				return fmt.Errorf("%w: %w", resourcepermissions.ErrResourceNotFound, err)
 			}
-
-			return nil
+			return err
 		},
 		Assignments: resourcepermissions.Assignments{
 			Users:        true,
@@ -112,6 +119,14 @@ type DashboardPermissionsService struct {
 	*resourcepermissions.Service
 }
 
+// dashboardDefaultPermissions are assigned to new dashboards and folders, the creator gets Admin and dashboards
+// and folders outside of a folder are shared with editors and viewers
+var dashboardDefaultPermissions = []resourcepermissions.DefaultPermission{
+	{Creator: true, Permission: dashboardaccess.PERMISSION_ADMIN.String()},
+	{BuiltInRole: string(org.RoleEditor), Permission: dashboardaccess.PERMISSION_EDIT.String(), RootOnly: true},
+	{BuiltInRole: string(org.RoleViewer), Permission: dashboardaccess.PERMISSION_VIEW.String(), RootOnly: true},
+}
+
 var DashboardViewActions = []string{dashboards.ActionDashboardsRead}
 var DashboardEditActions = append(DashboardViewActions, []string{dashboards.ActionDashboardsWrite, dashboards.ActionDashboardsDelete}...)
 var DashboardAdminActions = append(DashboardEditActions, []string{dashboards.ActionDashboardsPermissionsRead, dashboards.ActionDashboardsPermissionsWrite}...)
@@ -119,7 +134,8 @@ var DashboardAdminActions = append(DashboardEditActions, []string{dashboards.Act
 func ProvideDashboardPermissions(
 	features featuremgmt.FeatureToggles, router routing.RouteRegister, sql db.DB, ac accesscontrol.AccessControl,
 	license licensing.Licensing, dashboardStore dashboards.Store, folderService folder.Service, service accesscontrol.Service,
-	teamService team.Service, userService user.Service,
+	teamService team.Service, userService user.Service, reg prometheus.Registerer, usageStats usagestats.Service,
+	settingsProvider setting.Provider,
 ) (*DashboardPermissionsService, error) {
 	getDashboard := func(ctx context.Context, orgID int64, resourceID string) (*dashboards.Dashboard, error) {
 		query := &dashboards.GetDashboardQuery{UID: resourceID, OrgID: orgID}
@@ -133,14 +149,19 @@ func ProvideDashboardPermissions(
 	options := resourcepermissions.Options{
 		Resource:          "dashboards",
 		ResourceAttribute: "uid",
+		UsageStats:        usageStats,
+		Registerer:        reg,
+		Settings:          settingsProvider,
 		ResourceValidator: func(ctx context.Context, orgID int64, resourceID string) error {
 			dashboard, err := getDashboard(ctx, orgID, resourceID)
-			if err != nil {
+			if errors.Is(err, dashboards.ErrDashboardNotFound) {
+				return fmt.Errorf("%w: %w", resourcepermissions.ErrResourceNotFound, err)
+			} else if err != nil {
 				return err
 			}
 
 			if dashboard.IsFolder {
-				return errors.New("not found")
+				return resourcepermissions.ErrResourceNotFound
 			}
 
 			return nil
@@ -163,10 +184,20 @@ func ProvideDashboardPermissions(
 				if err != nil {
 					return nil, err
 				}
-				return append([]string{parentScope}, nestedScopes...), nil
+				// nested scopes are ordered from the root folder, ancestors are expected nearest first
+				return append([]string{parentScope}, util.Reverse(nestedScopes)...), nil
 			}
 			return []string{dashboards.ScopeFoldersProvider.GetResourceScopeUID(folder.GeneralFolderUID)}, nil
 		},
+		IsRootResource: func(ctx context.Context, orgID int64, resourceID string) (bool, error) {
+			dashboard, err := getDashboard(ctx, orgID, resourceID)
+			if err != nil {
+				return false, err
+			}
+			// nolint:staticcheck
+			return dashboard.FolderID == 0, nil
+		},
+		DefaultPermissions: dashboardDefaultPermissions,
 		Assignments: resourcepermissions.Assignments{
 			Users:           true,
 			Teams:           true,
@@ -211,27 +242,46 @@ var FolderAdminActions = append(FolderEditActions, []string{dashboards.ActionFol
 func ProvideFolderPermissions(
 	features featuremgmt.FeatureToggles, router routing.RouteRegister, sql db.DB, accesscontrol accesscontrol.AccessControl,
 	license licensing.Licensing, dashboardStore dashboards.Store, folderService folder.Service, service accesscontrol.Service,
-	teamService team.Service, userService user.Service,
+	teamService team.Service, userService user.Service, reg prometheus.Registerer, usageStats usagestats.Service,
+	settingsProvider setting.Provider,
 ) (*FolderPermissionsService, error) {
 	options := resourcepermissions.Options{
 		Resource:          "folders",
 		ResourceAttribute: "uid",
+		UsageStats:        usageStats,
+		Registerer:        reg,
+		Settings:          settingsProvider,
 		ResourceValidator: func(ctx context.Context, orgID int64, resourceID string) error {
 			query := &dashboards.GetDashboardQuery{UID: resourceID, OrgID: orgID}
 			queryResult, err := dashboardStore.GetDashboard(ctx, query)
-			if err != nil {
+			if errors.Is(err, dashboards.ErrDashboardNotFound) {
+				return fmt.Errorf("%w: %w", resourcepermissions.ErrResourceNotFound, err)
+			} else if err != nil {
 				return err
 			}
 
 			if !queryResult.IsFolder {
-				return errors.New("not found")
+				return resourcepermissions.ErrResourceNotFound
 			}
 
 			return nil
 		},
 		InheritedScopesSolver: func(ctx context.Context, orgID int64, resourceID string) ([]string, error) {
-			return dashboards.GetInheritedScopes(ctx, orgID, resourceID, folderService)
+			scopes, err := dashboards.GetInheritedScopes(ctx, orgID, resourceID, folderService)
+			if err != nil {
+				return nil, err
+			}
+			return util.Reverse(scopes), nil
+		},
+		IsRootResource: func(ctx context.Context, orgID int64, resourceID string) (bool, error) {
+			queryResult, err := dashboardStore.GetDashboard(ctx, &dashboards.GetDashboardQuery{UID: resourceID, OrgID: orgID})
+			if err != nil {
+				return false, err
+			}
+			// nolint:staticcheck
+			return queryResult.FolderID == 0, nil
 		},
+		DefaultPermissions: dashboardDefaultPermissions,
 		Assignments: resourcepermissions.Assignments{
 			Users:           true,
 			Teams:           true,
@@ -282,11 +332,19 @@ func (e DatasourcePermissionsService) SetPermissions(ctx context.Context, orgID
 	return nil, nil
 }
 
+func (e DatasourcePermissionsService) SetDefaultPermissions(ctx context.Context, orgID int64, resourceID string, creator identity.Requester) ([]accesscontrol.ResourcePermission, error) {
+	return nil, nil
+}
+
 func (e DatasourcePermissionsService) DeleteResourcePermissions(ctx context.Context, orgID int64, resourceID string) error {
 	// TODO: implement
 	return nil
 }
 
+func (e DatasourcePermissionsService) HandleResourceMoved(ctx context.Context, orgID int64, resourceID, oldParentScope, newParentScope string, policy accesscontrol.ResourceMovePolicy) error {
+	return nil
+}
+
 func (e DatasourcePermissionsService) MapActions(permission accesscontrol.ResourcePermission) string {
 	return ""
 }
@@ -312,11 +370,13 @@ type ServiceAccountPermissionsService struct {
 func ProvideServiceAccountPermissions(
 	features featuremgmt.FeatureToggles, router routing.RouteRegister, sql db.DB, ac accesscontrol.AccessControl,
 	license licensing.Licensing, serviceAccountRetrieverService *retriever.Service, service accesscontrol.Service,
-	teamService team.Service, userService user.Service,
+	teamService team.Service, userService user.Service, reg prometheus.Registerer, usageStats usagestats.Service,
 ) (*ServiceAccountPermissionsService, error) {
 	options := resourcepermissions.Options{
 		Resource:          "serviceaccounts",
 		ResourceAttribute: "id",
+		UsageStats:        usageStats,
+		Registerer:        reg,
 		ResourceValidator: func(ctx context.Context, orgID int64, resourceID string) error {
 			id, err := strconv.ParseInt(resourceID, 10, 64)
 			if err != nil {
@@ -0,0 +1,54 @@
+package ossaccesscontrol
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/actest"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/folder/foldertest"
+	"github.com/grafana/grafana/pkg/services/licensing/licensingtest"
+	"github.com/grafana/grafana/pkg/services/team/teamtest"
+	"github.com/grafana/grafana/pkg/services/user/usertest"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// TestProvidePermissions checks that the options of every resource registered in-tree pass Options.Validate
+func TestProvidePermissions(t *testing.T) {
+	sql := db.InitTestDB(t)
+	features := featuremgmt.WithFeatures()
+	ac := acimpl.ProvideAccessControl(setting.NewCfg())
+	license := licensingtest.NewFakeLicensing()
+	license.On("FeatureEnabled", "accesscontrol.enforcement").Return(true).Maybe()
+	reg := prometheus.NewRegistry()
+
+	t.Run("teams", func(t *testing.T) {
+		_, err := ProvideTeamPermissions(features, routing.NewRouteRegister(), sql, ac, license, &actest.FakeService{},
+			teamtest.NewFakeService(), usertest.NewUserServiceFake(), reg, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("dashboards", func(t *testing.T) {
+		_, err := ProvideDashboardPermissions(features, routing.NewRouteRegister(), sql, ac, license, &dashboards.FakeDashboardStore{},
+			foldertest.NewFakeService(), &actest.FakeService{}, teamtest.NewFakeService(), usertest.NewUserServiceFake(), reg, nil, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("folders", func(t *testing.T) {
+		_, err := ProvideFolderPermissions(features, routing.NewRouteRegister(), sql, ac, license, &dashboards.FakeDashboardStore{},
+			foldertest.NewFakeService(), &actest.FakeService{}, teamtest.NewFakeService(), usertest.NewUserServiceFake(), reg, nil, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("serviceaccounts", func(t *testing.T) {
+		_, err := ProvideServiceAccountPermissions(features, routing.NewRouteRegister(), sql, ac, license, nil,
+			&actest.FakeService{}, teamtest.NewFakeService(), usertest.NewUserServiceFake(), reg, nil)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,48 @@
+package resourcepermissions
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+// registerScopeResolver resolves the scopes of the resource to the scopes the permissions granting access to it are
+// stored with: their translation by Options.ScopeTranslator and their equivalents under each alias, so that
+// permissions stored with the scope of an alias keep granting access to the resource. The resolver replaces any other resolver registered for
+// the scope prefix of the resource.
+func (s *Service) registerScopeResolver() {
+	if len(s.options.ResourceAliases) == 0 && s.options.ScopeTranslator == nil {
+		return
+	}
+
+	prefix := accesscontrol.Scope(s.options.Resource, s.options.ResourceAttribute, "")
+	s.ac.RegisterScopeAttributeResolver(prefix, accesscontrol.ScopeAttributeResolverFunc(func(ctx context.Context, orgID int64, scope string) ([]string, error) {
+		scopes := []string{scope}
+		if s.options.ScopeTranslator != nil {
+			stored, err := s.options.ScopeTranslator.ToStored(ctx, orgID, scope)
+			if err != nil {
+				return nil, err
+			}
+			scopes = []string{stored}
+		}
+
+		for _, resolved := range scopes {
+			if resourceID, ok := strings.CutPrefix(resolved, prefix); ok {
+				scopes = append(scopes, aliasScopes(s.options.ResourceAliases, s.options.ResourceAttribute, resourceID)...)
+			}
+		}
+		return scopes, nil
+	}))
+}
+
+// NormalizeResourceAliases rewrites the permissions stored with the scopes of the aliases of the resource to the
+// scopes of the resource, in every organization, and returns the number of permissions rewritten. It is meant to be
+// run once the aliases are no longer written to, e.g. from a migration, the permissions grant the same access
+// before and after they are rewritten.
+func (s *Service) NormalizeResourceAliases(ctx context.Context) (int64, error) {
+	if len(s.options.ResourceAliases) == 0 {
+		return 0, nil
+	}
+	return s.store.NormalizeResourceScopes(ctx, s.options.Resource, s.options.ResourceAliases)
+}
@@ -0,0 +1,160 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+var aliasTestOptions = Options{
+	Resource:          "reporting",
+	ResourceAliases:   []string{"reports"},
+	ResourceAttribute: "uid",
+	Assignments:       Assignments{Users: true, Teams: true, BuiltInRoles: true},
+	PermissionsToActions: map[string][]string{
+		"View": {"reports:read"},
+		"Edit": {"reports:read", "reports:write"},
+	},
+}
+
+// setAliasPermission writes a managed permission for a user the way it was written before the resource was renamed
+func setAliasPermission(t *testing.T, service *Service, userID int64, resourceID string, actions ...string) {
+	t.Helper()
+	_, err := service.store.SetUserResourcePermission(context.Background(), 1, accesscontrol.User{ID: userID}, SetResourcePermissionCommand{
+		Actions:           actions,
+		Resource:          "reports",
+		ResourceID:        resourceID,
+		ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+}
+
+func TestService_ResourceAliases(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, aliasTestOptions)
+	usr := createOwnerTestUser(t, sql, "user", false)
+	setAliasPermission(t, service, usr.ID, "1", "reports:read")
+
+	t.Run("should read permissions written under the alias", func(t *testing.T) {
+		permissions, err := service.GetPermissions(context.Background(), &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{
+			1: {accesscontrol.ActionOrgUsersRead: {accesscontrol.ScopeUsersAll}},
+		}}, "1")
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "reporting:uid:1", permissions[0].Scope)
+		assert.True(t, permissions[0].IsManaged)
+		assert.Equal(t, "View", service.MapActions(permissions[0]))
+		assertManagedPermissions(t, service, "1", map[int64]string{usr.ID: "View"})
+	})
+
+	t.Run("should evaluate permissions written under the alias", func(t *testing.T) {
+		ok, _, err := service.Evaluate(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", nil, "reports:read")
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		users, err := service.ListUsersWithAccess(context.Background(), 1, "1", "reports:read")
+		require.NoError(t, err)
+		assert.Equal(t, []int64{usr.ID}, users)
+
+		signedIn := &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {"reports:read": {"reports:uid:1"}}}}
+		ok, err = service.ac.Evaluate(context.Background(), signedIn, accesscontrol.EvalPermission("reports:read", "reporting:uid:1"))
+		require.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = service.ac.Evaluate(context.Background(), signedIn, accesscontrol.EvalPermission("reports:read", "reporting:uid:2"))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("should replace permissions written under the alias", func(t *testing.T) {
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+		require.NoError(t, err)
+		assertManagedPermissions(t, service, "1", map[int64]string{usr.ID: "Edit"})
+		for _, a := range retrieveAssignmentsHelper(t, sql) {
+			assert.Equal(t, "reporting:uid:1", a.Scope)
+		}
+	})
+
+	t.Run("should delete permissions written under the alias", func(t *testing.T) {
+		setAliasPermission(t, service, usr.ID, "2", "reports:read")
+		require.NoError(t, service.DeleteResourcePermissions(context.Background(), 1, "2"))
+		assertManagedPermissions(t, service, "2", map[int64]string{})
+		for _, a := range retrieveAssignmentsHelper(t, sql) {
+			assert.Equal(t, "reporting:uid:1", a.Scope)
+		}
+	})
+}
+
+func TestService_NormalizeResourceAliases(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, aliasTestOptions)
+	usr := createOwnerTestUser(t, sql, "user", false)
+	other := createOwnerTestUser(t, sql, "other", false)
+
+	setAliasPermission(t, service, usr.ID, "1", "reports:read", "reports:write")
+	setAliasPermission(t, service, other.ID, "1", "reports:read")
+	// a permission granted under both names is kept once
+	_, err := service.store.SetUserResourcePermission(context.Background(), 1, accesscontrol.User{ID: other.ID}, SetResourcePermissionCommand{
+		Actions: []string{"reports:read"}, Resource: "reporting", ResourceID: "2", ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+	setAliasPermission(t, service, other.ID, "2", "reports:read")
+
+	rows, err := service.NormalizeResourceAliases(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), rows)
+
+	assert.Equal(t, []storedAssignment{
+		{RoleName: accesscontrol.ManagedUserRoleName(usr.ID), UserID: usr.ID, Action: "reports:read", Scope: "reporting:uid:1"},
+		{RoleName: accesscontrol.ManagedUserRoleName(usr.ID), UserID: usr.ID, Action: "reports:write", Scope: "reporting:uid:1"},
+		{RoleName: accesscontrol.ManagedUserRoleName(other.ID), UserID: other.ID, Action: "reports:read", Scope: "reporting:uid:1"},
+		{RoleName: accesscontrol.ManagedUserRoleName(other.ID), UserID: other.ID, Action: "reports:read", Scope: "reporting:uid:2"},
+	}, retrieveAssignmentsHelper(t, sql))
+	assertManagedPermissions(t, service, "1", map[int64]string{usr.ID: "Edit", other.ID: "View"})
+
+	rows, err = service.NormalizeResourceAliases(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, rows)
+}
+
+func TestApi_ResourceAliases(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, aliasTestOptions)
+	usr := createOwnerTestUser(t, sql, "user", false)
+	setAliasPermission(t, service, usr.ID, "1", "reports:read")
+
+	// the caller was granted the permissions read action on the scope of the alias
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {
+		"reporting.permissions:read":     {"reports:uid:1"},
+		accesscontrol.ActionOrgUsersRead: {accesscontrol.ScopeUsersAll},
+	}}}, service)
+
+	for _, resource := range []string{"reporting", "reports"} {
+		t.Run("should get permissions under "+resource, func(t *testing.T) {
+			permissions, recorder := getPermission(t, server, resource, "1")
+			require.Equal(t, http.StatusOK, recorder.Code)
+			require.Len(t, permissions, 1)
+			assert.Equal(t, usr.ID, permissions[0].UserID)
+			assert.Equal(t, "View", permissions[0].Permission)
+
+			_, recorder = getPermission(t, server, resource, "2")
+			assert.Equal(t, http.StatusForbidden, recorder.Code)
+		})
+	}
+
+	t.Run("should describe the resource under its name", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/api/access-control/reports/description", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var description Description
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&description))
+		assert.Equal(t, "reporting", description.Resource)
+	})
+}
@@ -1,59 +1,152 @@
 package resourcepermissions
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/middleware"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
-	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/user"
 	"github.com/grafana/grafana/pkg/web"
 )
 
+// permissionsLockedHeader is set on the permissions of a resource whose permissions are locked
+const permissionsLockedHeader = "X-Grafana-Permissions-Locked"
+
 type api struct {
-	ac          accesscontrol.AccessControl
-	router      routing.RouteRegister
-	service     *Service
-	permissions []string
+	ac     accesscontrol.AccessControl
+	router routing.RouteRegister
+	// service holds the configuration of the resource, permissions are read and written through manager
+	// so that they go through the decorators of the service
+	service     ResourceService
+	manager     Manager
+	userService user.Service
+	metrics     *serviceMetrics
+	log         log.Logger
+	// options are the options of the resource, they do not change once the service is created
+	options Options
 }
 
-func newApi(ac accesscontrol.AccessControl, router routing.RouteRegister, manager *Service) *api {
-	permissions := make([]string, 0, len(manager.permissions))
-	// reverse the permissions order for display
-	for i := len(manager.permissions) - 1; i >= 0; i-- {
-		permissions = append(permissions, manager.permissions[i])
+func newApi(ac accesscontrol.AccessControl, router routing.RouteRegister, service ResourceService, manager Manager, userService user.Service, metrics *serviceMetrics) *api {
+	return &api{
+		ac:          ac,
+		router:      router,
+		service:     service,
+		manager:     manager,
+		userService: userService,
+		metrics:     metrics,
+		log:         log.New("resourcepermissions"),
+		options:     service.Options(),
 	}
-	return &api{ac, router, manager, permissions}
 }
 
 func (a *api) registerEndpoints() {
 	auth := accesscontrol.Middleware(a.ac)
-	licenseMW := a.service.options.LicenseMW
+	licenseMW := a.options.LicenseMW
 	if licenseMW == nil {
 		licenseMW = nopMiddleware
 	}
 
-	a.router.Group(fmt.Sprintf("/api/access-control/%s", a.service.options.Resource), func(r routing.RouteRegister) {
-		actionRead := fmt.Sprintf("%s.permissions:read", a.service.options.Resource)
-		actionWrite := fmt.Sprintf("%s.permissions:write", a.service.options.Resource)
-		scope := accesscontrol.Scope(a.service.options.Resource, a.service.options.ResourceAttribute, accesscontrol.Parameter(":resourceID"))
+	// the endpoints are registered under the former names of the resource as well, they authorize the actions and the
+	// scopes of the resource
+	for _, name := range append([]string{a.options.Resource}, a.options.ResourceAliases...) {
+		a.registerResourceEndpoints(name, auth, licenseMW)
+	}
+}
+
+// registerResourceEndpoints registers the endpoints of the resource under /api/access-control/<name>, and its server
+// admin endpoints under /api/admin/access-control/<name>
+func (a *api) registerResourceEndpoints(name string, auth func(accesscontrol.Evaluator) web.Handler, licenseMW web.Handler) {
+	a.router.Group(fmt.Sprintf("/api/access-control/%s", name), func(r routing.RouteRegister) {
+		actionRead := fmt.Sprintf("%s.permissions:read", a.options.Resource)
+		actionWrite := fmt.Sprintf("%s.permissions:write", a.options.Resource)
+		scope := accesscontrol.Scope(a.options.Resource, a.options.ResourceAttribute, accesscontrol.Parameter(":resourceID"))
+		writeAuth := a.authorizeWrite(actionWrite, scope)
 		r.Get("/description", auth(accesscontrol.EvalPermission(actionRead)), routing.Wrap(a.getDescription))
 		r.Get("/:resourceID", auth(accesscontrol.EvalPermission(actionRead, scope)), routing.Wrap(a.getPermissions))
-		r.Post("/:resourceID", licenseMW, auth(accesscontrol.EvalPermission(actionWrite, scope)), routing.Wrap(a.setPermissions))
-		if a.service.options.Assignments.Users {
-			r.Post("/:resourceID/users/:userID", licenseMW, auth(accesscontrol.EvalPermission(actionWrite, scope)), routing.Wrap(a.setUserPermission))
+		r.Post("/:resourceID", licenseMW, writeAuth, routing.Wrap(a.setPermissions))
+		r.Post("/:resourceID/snapshot", licenseMW, writeAuth, routing.Wrap(a.snapshotPermissions))
+		r.Post("/:resourceID/restore", licenseMW, writeAuth, routing.Wrap(a.restorePermissions))
+		r.Post("/:resourceID/lock", licenseMW, middleware.ReqGrafanaAdmin, routing.Wrap(a.lockPermissions))
+		r.Delete("/:resourceID/lock", licenseMW, middleware.ReqGrafanaAdmin, routing.Wrap(a.unlockPermissions))
+		if a.options.EnableOwner {
+			r.Post("/:resourceID/owner", licenseMW, writeAuth, routing.Wrap(a.setOwner))
 		}
-		if a.service.options.Assignments.Teams {
-			r.Post("/:resourceID/teams/:teamID", licenseMW, auth(accesscontrol.EvalPermission(actionWrite, scope)), routing.Wrap(a.setTeamPermission))
+		if a.options.Assignments.Users {
+			// the write action is evaluated against the scope of each resource by the handler
+			r.Post("/users/:userID/resources", licenseMW, a.requireAssignmentKind(assignmentKindUsers), auth(accesscontrol.EvalPermission(actionWrite)), routing.Wrap(a.setUserPermissionForResources))
 		}
-		if a.service.options.Assignments.BuiltInRoles {
-			r.Post("/:resourceID/builtInRoles/:builtInRole", licenseMW, auth(accesscontrol.EvalPermission(actionWrite, scope)), routing.Wrap(a.setBuiltinRolePermission))
+		for _, kind := range a.service.AssignmentKinds() {
+			param, handler := a.assignmentHandler(kind)
+			r.Post(fmt.Sprintf("/:resourceID/%s/%s", kind, param), licenseMW, a.requireAssignmentKind(kind), writeAuth, routing.Wrap(handler))
 		}
 	})
+
+	// the server admin endpoints are registered outside of the resource paths, where they could be resource ids
+	a.router.Group(fmt.Sprintf("/api/admin/access-control/%s", name), func(r routing.RouteRegister) {
+		r.Post("/reconcile", middleware.ReqGrafanaAdmin, routing.Wrap(a.reconcile))
+		r.Get("/stats", middleware.ReqGrafanaAdmin, routing.Wrap(a.getUsageStats))
+		r.Get("/default-permissions", middleware.ReqGrafanaAdmin, routing.Wrap(a.getDefaultPermissions))
+	})
+}
+
+// assignmentHandler returns the route parameter holding the assignee and the handler setting permissions for kind
+func (a *api) assignmentHandler(kind string) (string, func(c *contextmodel.ReqContext) response.Response) {
+	switch kind {
+	case assignmentKindUsers:
+		return ":userID", a.setUserPermission
+	case assignmentKindTeams:
+		return ":teamID", a.setTeamPermission
+	case assignmentKindBuiltInRoles:
+		return ":builtInRole", a.setBuiltinRolePermission
+	default:
+		return ":assigneeID", func(c *contextmodel.ReqContext) response.Response {
+			return a.setAssignmentPermission(c, kind)
+		}
+	}
+}
+
+// requireAssignmentKind returns a middleware responding with 404 when kind is disabled in the organization of the
+// caller, the endpoints of a kind are registered for every organization
+func (a *api) requireAssignmentKind(kind string) web.Handler {
+	return func(c *contextmodel.ReqContext) {
+		if !a.service.AssignmentKindEnabled(c.Req.Context(), c.SignedInUser.GetOrgID(), kind) {
+			c.JsonApiErr(http.StatusNotFound, fmt.Sprintf("%s assignments are disabled", kind), nil)
+		}
+	}
+}
+
+// authorizeWrite returns a middleware allowing users that have the write action on the resource or on any of its ancestors
+func (a *api) authorizeWrite(action, scope string) web.Handler {
+	auth := accesscontrol.Middleware(a.ac)
+	if a.options.InheritedScopesSolver == nil {
+		return auth(accesscontrol.EvalPermission(action, scope))
+	}
+
+	return func(c *contextmodel.ReqContext) {
+		scopes := []string{scope}
+		// if the ancestors cannot be resolved only the scope of the resource itself is accepted
+		inherited, err := a.service.InheritedScopes(c.Req.Context(), c.SignedInUser.GetOrgID(), web.Params(c.Req)[":resourceID"])
+		if err == nil {
+			scopes = append(scopes, inherited...)
+		}
+
+		handler, ok := auth(accesscontrol.EvalPermission(action, scopes...)).(func(*contextmodel.ReqContext))
+		if !ok {
+			c.JsonApiErr(http.StatusInternalServerError, "Internal server error", nil)
+			return
+		}
+		handler(c)
+	}
 }
 
 type Assignments struct {
@@ -71,23 +164,53 @@ type DescriptionResponse struct {
 }
 
 type Description struct {
+	// Resource is the name of the resource, also when the description is requested under one of its former names
+	Resource    string      `json:"resource"`
 	Assignments Assignments `json:"assignments"`
-	Permissions []string    `json:"permissions"`
+	// Permissions lists the permission levels that can be granted on this instance, from the lowest to the highest
+	Permissions []string `json:"permissions"`
+	// AssignmentKinds lists the custom kinds permissions can be assigned to, next to Assignments
+	AssignmentKinds []string `json:"assignmentKinds,omitempty"`
+	// DefaultPermissions lists the permissions assigned to new resources
+	DefaultPermissions []DefaultPermission `json:"defaultPermissions,omitempty"`
+	// Locked is set when the description is requested for a resource with `resourceId` and its permissions are locked
+	Locked bool `json:"locked,omitempty"`
+	// RequireReason is set when permissions cannot be granted without a reason
+	RequireReason bool `json:"requireReason,omitempty"`
 }
 
 // swagger:route POST /access-control/:resource/description enterprise,access_control getResourceDescription
 //
 // Get a description of a resource's access control properties.
 //
+// When `resourceId` is passed the description also reports whether the permissions of that resource are locked,
+// which requires the permissions read action on the resource.
+//
 // Responses:
 // 200: resourcePermissionsDescription
 // 403: forbiddenError
 // 500: internalServerError
 func (a *api) getDescription(c *contextmodel.ReqContext) response.Response {
-	return response.JSON(http.StatusOK, &Description{
-		Permissions: a.permissions,
-		Assignments: a.service.options.Assignments,
-	})
+	description := a.service.Description(c.Req.Context(), c.SignedInUser.GetOrgID())
+
+	if resourceID := c.Query("resourceId"); resourceID != "" {
+		scope := accesscontrol.Scope(a.options.Resource, a.options.ResourceAttribute, resourceID)
+		ok, err := a.ac.Evaluate(c.Req.Context(), c.SignedInUser, accesscontrol.EvalPermission(fmt.Sprintf("%s.permissions:read", a.options.Resource), scope))
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "failed to evaluate permissions", err)
+		}
+		if !ok {
+			return response.Error(http.StatusForbidden, fmt.Sprintf("not allowed to read permissions of %s", resourceID), nil)
+		}
+
+		lock, err := a.manager.GetPermissionsLock(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID)
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "failed to get permissions lock", err)
+		}
+		description.Locked = lock != nil
+	}
+
+	return response.JSON(http.StatusOK, description)
 }
 
 type resourcePermissionDTO struct {
@@ -96,6 +219,7 @@ type resourcePermissionDTO struct {
 	IsManaged        bool     `json:"isManaged"`
 	IsInherited      bool     `json:"isInherited"`
 	IsServiceAccount bool     `json:"isServiceAccount"`
+	IsOwner          bool     `json:"isOwner,omitempty"`
 	UserID           int64    `json:"userId,omitempty"`
 	UserLogin        string   `json:"userLogin,omitempty"`
 	UserAvatarUrl    string   `json:"userAvatarUrl,omitempty"`
@@ -103,8 +227,19 @@ type resourcePermissionDTO struct {
 	TeamID           int64    `json:"teamId,omitempty"`
 	TeamAvatarUrl    string   `json:"teamAvatarUrl,omitempty"`
 	BuiltInRole      string   `json:"builtInRole,omitempty"`
+	Kind             string   `json:"kind,omitempty"`
+	AssigneeID       string   `json:"assigneeId,omitempty"`
 	Actions          []string `json:"actions"`
 	Permission       string   `json:"permission"`
+	Reason           string   `json:"reason,omitempty"`
+	// GrantedByID and GrantedByLogin identify who last granted the permission, they are only returned with
+	// `expand=grantedBy`
+	GrantedByID    int64  `json:"grantedById,omitempty"`
+	GrantedByLogin string `json:"grantedByLogin,omitempty"`
+	// ViaTeamID and ViaTeam identify the team of the permissions of its members, they are only returned with
+	// `expandTeams=true`
+	ViaTeamID int64  `json:"viaTeamId,omitempty"`
+	ViaTeam   string `json:"viaTeam,omitempty"`
 }
 
 // swagger:response getResourcePermissionsResponse
@@ -114,6 +249,20 @@ type getResourcePermissionsResponse []resourcePermissionDTO
 //
 // Get permissions for a resource.
 //
+// Permissions whose actions do not match any permission level are left out unless `includeUnmapped=true` is passed,
+// in which case they are returned with an empty permission and their actions.
+//
+// When the permissions of the resource are locked the response has the `X-Grafana-Permissions-Locked: true` header.
+// The managed permission of the owner of the resource, if any, is flagged with `isOwner`.
+//
+// With `expand=grantedBy` the managed permissions include who last granted them with `grantedById` and
+// `grantedByLogin`. Permissions granted by Grafana itself have a negative `grantedById`: -1 for the permissions granted
+// without a signed in user and -2 for the provisioned ones. Permissions granted before grantors were recorded have none.
+//
+// With `expandTeams=true` the permission of each team is followed by a permission for each of its members, in user id
+// order, with `viaTeamId` and `viaTeam` set and `isManaged` false. Users granted a permission directly and through
+// teams are listed once for each of them.
+//
 // Responses:
 // 200: getResourcePermissionsResponse
 // 403: forbiddenError
@@ -121,27 +270,52 @@ type getResourcePermissionsResponse []resourcePermissionDTO
 func (a *api) getPermissions(c *contextmodel.ReqContext) response.Response {
 	resourceID := web.Params(c.Req)[":resourceID"]
 
-	permissions, err := a.service.GetPermissions(c.Req.Context(), c.SignedInUser, resourceID)
+	ctx := c.Req.Context()
+	if c.QueryBool("expandTeams") {
+		ctx = WithExpandTeams(ctx)
+	}
+	permissions, err := a.manager.GetPermissions(ctx, c.SignedInUser, resourceID)
 	if err != nil {
 		return response.Error(http.StatusInternalServerError, "failed to get permissions", err)
 	}
 
-	if a.service.options.Assignments.BuiltInRoles && !a.service.license.FeatureEnabled("accesscontrol.enforcement") {
-		permissions = append(permissions, accesscontrol.ResourcePermission{
-			Actions:     a.service.actions,
-			Scope:       "*",
-			BuiltInRole: string(org.RoleAdmin),
-		})
+	lock, err := a.manager.GetPermissionsLock(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to get permissions lock", err)
+	}
+
+	owner, err := a.manager.GetOwner(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to get owner", err)
+	}
+
+	permissions = append(permissions, a.service.ImplicitPermissions(c.Req.Context(), c.SignedInUser.GetOrgID())...)
+
+	var grantors map[int64]string
+	if slices.Contains(c.QueryStrings("expand"), expandGrantedBy) {
+		grantors = a.grantorLogins(c.Req.Context(), permissions)
 	}
 
+	includeUnmapped := c.QueryBool("includeUnmapped")
 	dto := make(getResourcePermissionsResponse, 0, len(permissions))
 	for _, p := range permissions {
-		if permission := a.service.MapActions(p); permission != "" {
+		permission := a.manager.MapActions(p)
+		if permission == "" {
+			a.metrics.unmappedPermissions.WithLabelValues(a.options.Resource).Inc()
+			a.log.Debug("Permission does not match any permission level", "resource", a.options.Resource, "resourceID", resourceID, "roleName", p.RoleName, "actions", p.Actions)
+		}
+		if permission != "" || includeUnmapped {
 			teamAvatarUrl := ""
 			if p.TeamId != 0 {
 				teamAvatarUrl = dtos.GetGravatarUrlWithDefault(p.TeamEmail, p.Team)
 			}
 
+			var grantedByID int64
+			var grantedByLogin string
+			if grantors != nil {
+				grantedByID, grantedByLogin = p.GrantedBy, grantors[p.GrantedBy]
+			}
+
 			dto = append(dto, resourcePermissionDTO{
 				ID:               p.ID,
 				RoleName:         p.RoleName,
@@ -152,20 +326,59 @@ func (a *api) getPermissions(c *contextmodel.ReqContext) response.Response {
 				TeamID:           p.TeamId,
 				TeamAvatarUrl:    teamAvatarUrl,
 				BuiltInRole:      p.BuiltInRole,
+				Kind:             p.AssigneeKind,
+				AssigneeID:       p.AssigneeID,
 				Actions:          p.Actions,
 				Permission:       permission,
 				IsManaged:        p.IsManaged,
 				IsInherited:      p.IsInherited,
 				IsServiceAccount: p.IsServiceAccount,
+				IsOwner:          owner != nil && p.IsManaged && !p.IsInherited && p.UserId == owner.UserID,
+				Reason:           p.Reason,
+				GrantedByID:      grantedByID,
+				GrantedByLogin:   grantedByLogin,
+				ViaTeamID:        p.ViaTeamID,
+				ViaTeam:          p.ViaTeam,
 			})
 		}
 	}
 
-	return response.JSON(http.StatusOK, dto)
+	resp := response.JSON(http.StatusOK, dto)
+	if lock != nil {
+		resp.SetHeader(permissionsLockedHeader, "true")
+	}
+	return resp
+}
+
+// expandGrantedBy is the value of the expand parameter of getPermissions returning the grantors of the permissions
+const expandGrantedBy = "grantedBy"
+
+// grantorLogins returns the logins of the grantors of permissions, the grantors that no longer exist are left out
+func (a *api) grantorLogins(ctx context.Context, permissions []accesscontrol.ResourcePermission) map[int64]string {
+	logins := map[int64]string{
+		accesscontrol.GrantorService:      "grafana",
+		accesscontrol.GrantorProvisioning: "grafana_provisioning",
+	}
+	for _, p := range permissions {
+		if _, ok := logins[p.GrantedBy]; ok || p.GrantedBy <= 0 {
+			continue
+		}
+		usr, err := a.userService.GetByID(ctx, &user.GetUserByIDQuery{ID: p.GrantedBy})
+		if err != nil {
+			a.log.Debug("Failed to get the grantor of a permission", "grantedBy", p.GrantedBy, "error", err)
+			logins[p.GrantedBy] = ""
+			continue
+		}
+		logins[p.GrantedBy] = usr.Login
+	}
+	return logins
 }
 
 type setPermissionCommand struct {
+	// Permission is one of the levels listed by the description of the resource, or empty to remove the permission
 	Permission string `json:"permission"`
+	// Reason is the justification of the permission, such as a ticket reference
+	Reason string `json:"reason,omitempty"`
 }
 
 type setPermissionsCommand struct {
@@ -178,12 +391,14 @@ type setPermissionsCommand struct {
 //
 // Assigns permissions for a resource by a given type (`:resource`) and `:resourceID` to a user or a service account.
 // Allowed resources are `datasources`, `teams`, `dashboards`, `folders`, and `serviceaccounts`.
-// Refer to the `/access-control/:resource/description` endpoint for allowed Permissions.
+// Refer to the `/access-control/:resource/description` endpoint for allowed Permissions and whether a `reason`, such
+// as a ticket reference, is required to grant them.
 //
 // Responses:
 // 200: okRespoonse
 // 400: badRequestError
 // 403: forbiddenError
+// 404: notFoundError
 // 500: internalServerError
 func (a *api) setUserPermission(c *contextmodel.ReqContext) response.Response {
 	userID, err := strconv.ParseInt(web.Params(c.Req)[":userID"], 10, 64)
@@ -197,26 +412,121 @@ func (a *api) setUserPermission(c *contextmodel.ReqContext) response.Response {
 		return response.Error(http.StatusBadRequest, "bad request data", err)
 	}
 
-	_, err = a.service.SetUserPermission(c.Req.Context(), c.SignedInUser.GetOrgID(), accesscontrol.User{ID: userID}, resourceID, cmd.Permission)
+	ctx := WithReason(c.Req.Context(), cmd.Reason)
+	_, err = a.manager.SetUserPermission(ctx, c.SignedInUser.GetOrgID(), accesscontrol.User{ID: userID}, resourceID, cmd.Permission)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "failed to set user permission", err)
+		return setPermissionErrorResponse("failed to set user permission", err)
 	}
 
 	return permissionSetResponse(cmd)
 }
 
+type setUserPermissionForResourcesCommand struct {
+	ResourceIDs []string `json:"resourceIds"`
+	Permission  string   `json:"permission"`
+	Reason      string   `json:"reason,omitempty"`
+}
+
+type resourcePermissionResultDTO struct {
+	ResourceID string `json:"resourceId"`
+	Status     int    `json:"status"`
+	Message    string `json:"message,omitempty"`
+	Unchanged  bool   `json:"unchanged,omitempty"`
+}
+
+// swagger:response setResourcePermissionsForUserOnResourcesResponse
+type setUserPermissionForResourcesResponse []resourcePermissionResultDTO
+
+// swagger:route POST /access-control/:resource/users/:userID/resources enterprise,access_control setResourcePermissionsForUserOnResources
+//
+// Set resource permissions for a user on several resources.
+//
+// Assigns the same permission to a user or a service account on every resource in `resourceIds`. The result of each
+// resource is returned with a status code, resources the caller cannot write are rejected with 403 while the others
+// are written. With `atomic=true` nothing is written when the caller cannot write any of the resources.
+//
+// Responses:
+// 200: setResourcePermissionsForUserOnResourcesResponse
+// 400: badRequestError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (a *api) setUserPermissionForResources(c *contextmodel.ReqContext) response.Response {
+	userID, err := strconv.ParseInt(web.Params(c.Req)[":userID"], 10, 64)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "userID is invalid", err)
+	}
+
+	var cmd setUserPermissionForResourcesCommand
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+
+	ctx := WithReason(c.Req.Context(), cmd.Reason)
+
+	dto := make(setUserPermissionForResourcesResponse, len(cmd.ResourceIDs))
+	allowed := make([]string, 0, len(cmd.ResourceIDs))
+	var allowedIndexes []int
+	for i, resourceID := range cmd.ResourceIDs {
+		dto[i].ResourceID = resourceID
+		ok, err := a.canWrite(c, resourceID)
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "failed to evaluate permissions", err)
+		}
+		if !ok {
+			if c.QueryBool("atomic") {
+				return response.Error(http.StatusForbidden, fmt.Sprintf("not allowed to set permissions on %s", resourceID), nil)
+			}
+			dto[i].Status = http.StatusForbidden
+			dto[i].Message = "forbidden"
+			continue
+		}
+		allowed = append(allowed, resourceID)
+		allowedIndexes = append(allowedIndexes, i)
+	}
+
+	results, err := a.manager.SetUserPermissionForResources(ctx, c.SignedInUser.GetOrgID(), accesscontrol.User{ID: userID}, allowed, cmd.Permission)
+	if err != nil {
+		return setPermissionErrorResponse("failed to set user permissions", err)
+	}
+
+	for i, result := range results {
+		item := &dto[allowedIndexes[i]]
+		item.Status = http.StatusOK
+		item.Unchanged = result.Unchanged
+		if result.Err != nil {
+			item.Status = permissionErrorStatus(result.Err)
+			item.Message = result.Err.Error()
+		}
+	}
+
+	return response.JSON(http.StatusOK, dto)
+}
+
+// canWrite evaluates the write action against the scope of the resource and of its ancestors
+func (a *api) canWrite(c *contextmodel.ReqContext, resourceID string) (bool, error) {
+	scopes := []string{accesscontrol.Scope(a.options.Resource, a.options.ResourceAttribute, resourceID)}
+	// if the ancestors cannot be resolved only the scope of the resource itself is accepted
+	if inherited, err := a.service.InheritedScopes(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID); err == nil {
+		scopes = append(scopes, inherited...)
+	}
+	return a.ac.Evaluate(c.Req.Context(), c.SignedInUser, accesscontrol.EvalPermission(fmt.Sprintf("%s.permissions:write", a.options.Resource), scopes...))
+}
+
 // swagger:route POST /access-control/:resource/:resourceID/teams/:teamID enterprise,access_control setResourcePermissionsForTeam
 //
 // Set resource permissions for a team.
 //
 // Assigns permissions for a resource by a given type (`:resource`) and `:resourceID` to a team.
 // Allowed resources are `datasources`, `teams`, `dashboards`, `folders`, and `serviceaccounts`.
-// Refer to the `/access-control/:resource/description` endpoint for allowed Permissions.
+// Refer to the `/access-control/:resource/description` endpoint for allowed Permissions and whether a `reason`, such
+// as a ticket reference, is required to grant them.
 //
 // Responses:
 // 200: okRespoonse
 // 400: badRequestError
 // 403: forbiddenError
+// 404: notFoundError
 // 500: internalServerError
 func (a *api) setTeamPermission(c *contextmodel.ReqContext) response.Response {
 	teamID, err := strconv.ParseInt(web.Params(c.Req)[":teamID"], 10, 64)
@@ -230,9 +540,10 @@ func (a *api) setTeamPermission(c *contextmodel.ReqContext) response.Response {
 		return response.Error(http.StatusBadRequest, "bad request data", err)
 	}
 
-	_, err = a.service.SetTeamPermission(c.Req.Context(), c.SignedInUser.GetOrgID(), teamID, resourceID, cmd.Permission)
+	ctx := WithReason(c.Req.Context(), cmd.Reason)
+	_, err = a.manager.SetTeamPermission(ctx, c.SignedInUser.GetOrgID(), teamID, resourceID, cmd.Permission)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "failed to set team permission", err)
+		return setPermissionErrorResponse("failed to set team permission", err)
 	}
 
 	return permissionSetResponse(cmd)
@@ -244,12 +555,14 @@ func (a *api) setTeamPermission(c *contextmodel.ReqContext) response.Response {
 //
 // Assigns permissions for a resource by a given type (`:resource`) and `:resourceID` to a built-in role.
 // Allowed resources are `datasources`, `teams`, `dashboards`, `folders`, and `serviceaccounts`.
-// Refer to the `/access-control/:resource/description` endpoint for allowed Permissions.
+// Refer to the `/access-control/:resource/description` endpoint for allowed Permissions and whether a `reason`, such
+// as a ticket reference, is required to grant them.
 //
 // Responses:
 // 200: okRespoonse
 // 400: badRequestError
 // 403: forbiddenError
+// 404: notFoundError
 // 500: internalServerError
 func (a *api) setBuiltinRolePermission(c *contextmodel.ReqContext) response.Response {
 	builtInRole := web.Params(c.Req)[":builtInRole"]
@@ -260,9 +573,42 @@ func (a *api) setBuiltinRolePermission(c *contextmodel.ReqContext) response.Resp
 		return response.Error(http.StatusBadRequest, "bad request data", err)
 	}
 
-	_, err := a.service.SetBuiltInRolePermission(c.Req.Context(), c.SignedInUser.GetOrgID(), builtInRole, resourceID, cmd.Permission)
+	ctx := WithReason(c.Req.Context(), cmd.Reason)
+	_, err := a.manager.SetBuiltInRolePermission(ctx, c.SignedInUser.GetOrgID(), builtInRole, resourceID, cmd.Permission)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "failed to set role permission", err)
+		return setPermissionErrorResponse("failed to set role permission", err)
+	}
+
+	return permissionSetResponse(cmd)
+}
+
+// swagger:route POST /access-control/:resource/:resourceID/:kind/:assigneeID enterprise,access_control setResourcePermissionsForAssignee
+//
+// Set resource permissions for an assignee of a custom kind.
+//
+// Assigns permissions for a resource by a given type (`:resource`) and `:resourceID` to an assignee of a kind registered
+// by the resource, such as a provisioned group. Refer to the `/access-control/:resource/description` endpoint for
+// allowed Permissions and assignment kinds.
+//
+// Responses:
+// 200: okRespoonse
+// 400: badRequestError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (a *api) setAssignmentPermission(c *contextmodel.ReqContext, kind string) response.Response {
+	assigneeID := web.Params(c.Req)[":assigneeID"]
+	resourceID := web.Params(c.Req)[":resourceID"]
+
+	cmd := setPermissionCommand{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+
+	ctx := WithReason(c.Req.Context(), cmd.Reason)
+	_, err := a.manager.SetAssignmentPermission(ctx, c.SignedInUser.GetOrgID(), kind, assigneeID, resourceID, cmd.Permission)
+	if err != nil {
+		return setPermissionErrorResponse(fmt.Sprintf("failed to set %s permission", kind), err)
 	}
 
 	return permissionSetResponse(cmd)
@@ -273,13 +619,15 @@ func (a *api) setBuiltinRolePermission(c *contextmodel.ReqContext) response.Resp
 // Set resource permissions.
 //
 // Assigns permissions for a resource by a given type (`:resource`) and `:resourceID` to one or many
-// assignment types. Allowed resources are `datasources`, `teams`, `dashboards`, `folders`, and `serviceaccounts`.
-// Refer to the `/access-control/:resource/description` endpoint for allowed Permissions.
+// assignment types. Custom assignment kinds are set through their own endpoint. Allowed resources are `datasources`, `teams`, `dashboards`, `folders`, and `serviceaccounts`.
+// Refer to the `/access-control/:resource/description` endpoint for allowed Permissions and whether a `reason`, such
+// as a ticket reference, is required to grant them.
 //
 // Responses:
 // 200: okRespoonse
 // 400: badRequestError
 // 403: forbiddenError
+// 404: notFoundError
 // 500: internalServerError
 func (a *api) setPermissions(c *contextmodel.ReqContext) response.Response {
 	resourceID := web.Params(c.Req)[":resourceID"]
@@ -289,14 +637,267 @@ func (a *api) setPermissions(c *contextmodel.ReqContext) response.Response {
 		return response.Error(http.StatusBadRequest, "bad request data", err)
 	}
 
-	_, err := a.service.SetPermissions(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID, cmd.Permissions...)
+	_, err := a.manager.SetPermissions(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID, cmd.Permissions...)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "failed to set permissions", err)
+		return setPermissionErrorResponse("failed to set permissions", err)
 	}
 
 	return response.Success("Permissions updated")
 }
 
+// swagger:response resourcePermissionsSnapshotResponse
+type permissionsSnapshotResponse struct {
+	// in:body
+	// required:true
+	Body PermissionsSnapshot `json:"body"`
+}
+
+// swagger:route POST /access-control/:resource/:resourceID/snapshot enterprise,access_control snapshotResourcePermissions
+//
+// Take a snapshot of resource permissions.
+//
+// Returns the managed permissions of users, teams and built-in roles assigned on the resource. The snapshot can be
+// passed to the `/access-control/:resource/:resourceID/restore` endpoint to roll back later changes.
+//
+// Responses:
+// 200: resourcePermissionsSnapshotResponse
+// 403: forbiddenError
+// 500: internalServerError
+func (a *api) snapshotPermissions(c *contextmodel.ReqContext) response.Response {
+	resourceID := web.Params(c.Req)[":resourceID"]
+
+	snapshot, err := a.manager.SnapshotPermissions(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to snapshot permissions", err)
+	}
+
+	return response.JSON(http.StatusOK, snapshot)
+}
+
+// swagger:route POST /access-control/:resource/:resourceID/restore enterprise,access_control restoreResourcePermissions
+//
+// Restore a snapshot of resource permissions.
+//
+// Replaces the managed permissions of users, teams and built-in roles on the resource with the ones of a snapshot
+// taken on the same resource. Users and teams that were deleted since the snapshot was taken are skipped.
+//
+// Responses:
+// 200: okRespoonse
+// 400: badRequestError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (a *api) restorePermissions(c *contextmodel.ReqContext) response.Response {
+	resourceID := web.Params(c.Req)[":resourceID"]
+
+	var snapshot PermissionsSnapshot
+	if err := web.Bind(c.Req, &snapshot); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+
+	if _, err := a.manager.RestorePermissions(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID, snapshot); err != nil {
+		return setPermissionErrorResponse("failed to restore permissions", err)
+	}
+
+	return response.Success("Permissions restored")
+}
+
+// swagger:route POST /access-control/:resource/:resourceID/lock enterprise,access_control lockResourcePermissions
+//
+// Lock the permissions of a resource.
+//
+// While locked, the permissions of the resource cannot be changed by anyone but Grafana server admins, provisioning
+// included, until the lock is removed. Only Grafana server admins can lock and unlock permissions.
+//
+// Responses:
+// 200: okRespoonse
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (a *api) lockPermissions(c *contextmodel.ReqContext) response.Response {
+	resourceID := web.Params(c.Req)[":resourceID"]
+
+	if err := a.manager.LockPermissions(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID, c.SignedInUser.UserID); err != nil {
+		return setPermissionErrorResponse("failed to lock permissions", err)
+	}
+
+	return response.Success("Permissions locked")
+}
+
+// swagger:route DELETE /access-control/:resource/:resourceID/lock enterprise,access_control unlockResourcePermissions
+//
+// Unlock the permissions of a resource.
+//
+// Responses:
+// 200: okRespoonse
+// 403: forbiddenError
+// 500: internalServerError
+func (a *api) unlockPermissions(c *contextmodel.ReqContext) response.Response {
+	resourceID := web.Params(c.Req)[":resourceID"]
+
+	if err := a.manager.UnlockPermissions(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID); err != nil {
+		return setPermissionErrorResponse("failed to unlock permissions", err)
+	}
+
+	return response.Success("Permissions unlocked")
+}
+
+type setOwnerCommand struct {
+	UserID int64 `json:"userId"`
+}
+
+// swagger:route POST /access-control/:resource/:resourceID/owner enterprise,access_control setResourceOwner
+//
+// Transfer the ownership of a resource.
+//
+// Makes a user or a service account the owner of the resource and grants it the highest permission level. The
+// permission of the previous owner is removed in the same transaction. The permission of the owner cannot be changed
+// or removed until the ownership is transferred.
+//
+// Responses:
+// 200: okRespoonse
+// 400: badRequestError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (a *api) setOwner(c *contextmodel.ReqContext) response.Response {
+	resourceID := web.Params(c.Req)[":resourceID"]
+
+	var cmd setOwnerCommand
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+
+	if err := a.manager.SetOwner(c.Req.Context(), c.SignedInUser.GetOrgID(), resourceID, accesscontrol.User{ID: cmd.UserID}); err != nil {
+		return setPermissionErrorResponse("failed to set owner", err)
+	}
+
+	return response.Success("Owner updated")
+}
+
+// swagger:route GET /admin/access-control/:resource/stats enterprise,access_control getResourcePermissionsUsageStats
+//
+// Get usage statistics of the managed permissions of a kind of resource.
+//
+// Reports, for every organization, the number of resources with managed permissions, the number of managed
+// permissions by kind of assignee and the 95th percentile of the number of managed permissions per resource. Only
+// Grafana server admins can read the statistics.
+//
+// Responses:
+// 200: resourcePermissionsUsageStatsResponse
+// 403: forbiddenError
+// 500: internalServerError
+func (a *api) getUsageStats(c *contextmodel.ReqContext) response.Response {
+	stats, err := a.service.UsageStats(c.Req.Context())
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to get usage stats", err)
+	}
+
+	return response.JSON(http.StatusOK, stats)
+}
+
+// swagger:route GET /admin/access-control/:resource/default-permissions enterprise,access_control getResourceDefaultPermissions
+//
+// Get the default permissions of new resources of a kind in an organization.
+//
+// Lists the permissions assigned to the resources created in the organization of the orgId query parameter, the
+// organization of the signed in user when it is not set, and whether the permissions of built-in roles come from the
+// options of the resource or from its settings. Only Grafana server admins can read the default permissions.
+//
+// Responses:
+// 200: resourceDefaultPermissionsResponse
+// 400: badRequestError
+// 403: forbiddenError
+func (a *api) getDefaultPermissions(c *contextmodel.ReqContext) response.Response {
+	orgID := c.SignedInUser.GetOrgID()
+	if raw := c.Query("orgId"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return response.Error(http.StatusBadRequest, "orgId is invalid", err)
+		}
+		orgID = id
+	}
+
+	return response.JSON(http.StatusOK, a.service.EffectiveDefaultPermissions(c.Req.Context(), orgID))
+}
+
+// swagger:response resourceDefaultPermissionsResponse
+type defaultPermissionsResponse struct {
+	// in:body
+	// required:true
+	Body EffectiveDefaultPermissions `json:"body"`
+}
+
+// swagger:response resourcePermissionsUsageStatsResponse
+type usageStatsResponse struct {
+	// in:body
+	// required:true
+	Body UsageStats `json:"body"`
+}
+
+// swagger:response resourcePermissionsReconciliationResponse
+type reconciliationResponse struct {
+	// in:body
+	// required:true
+	Body ReconciliationReport `json:"body"`
+}
+
+// swagger:route POST /admin/access-control/:resource/reconcile enterprise,access_control reconcileResourcePermissions
+//
+// Remove the permissions of deleted users and teams.
+//
+// Removes the managed permissions on resources of every organization that belong to users or teams that no longer
+// exist, and returns what was removed. Service accounts and disabled users keep their permissions. Only Grafana
+// server admins can reconcile permissions.
+//
+// Responses:
+// 200: resourcePermissionsReconciliationResponse
+// 403: forbiddenError
+// 500: internalServerError
+func (a *api) reconcile(c *contextmodel.ReqContext) response.Response {
+	report, err := a.manager.Reconcile(c.Req.Context(), 0)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to reconcile permissions", err)
+	}
+
+	return response.JSON(http.StatusOK, report)
+}
+
+func setPermissionErrorResponse(message string, err error) response.Response {
+	var unlicensed *UnlicensedPermissionError
+	if errors.As(err, &unlicensed) {
+		// tell the client which license feature the permission level requires
+		return response.Error(http.StatusForbidden, unlicensed.Error(), err)
+	}
+
+	resp := response.Error(permissionErrorStatus(err), message, err)
+	if errors.Is(err, ErrConcurrentWrite) {
+		// the store already retried the write, let the client try again shortly
+		resp.SetHeader("Retry-After", "1")
+	}
+	return resp
+}
+
+func permissionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidPermission), errors.Is(err, ErrInvalidAssignment), errors.Is(err, ErrInvalidSnapshot),
+		errors.Is(err, ErrInvalidReason), errors.Is(err, ErrReasonRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrAssigneeNotFound), errors.Is(err, ErrResourceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPermissionsLocked):
+		return http.StatusLocked
+	case errors.Is(err, ErrUnlicensedPermission):
+		return http.StatusForbidden
+	case errors.Is(err, ErrOwnerPermission):
+		return http.StatusConflict
+	case errors.Is(err, ErrConcurrentWrite):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func permissionSetResponse(cmd setPermissionCommand) response.Response {
 	message := "Permission updated"
 	if cmd.Permission == "" {
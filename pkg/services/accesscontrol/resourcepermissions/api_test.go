@@ -1,9 +1,11 @@
 package resourcepermissions
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"path"
@@ -11,9 +13,12 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/db"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/contexthandler/ctxkey"
@@ -59,6 +64,7 @@ func TestApi_getDescription(t *testing.T) {
 				{Action: "dashboards.permissions:read"},
 			},
 			expected: Description{
+				Resource: "dashboards",
 				Assignments: Assignments{
 					Users:        true,
 					Teams:        true,
@@ -86,6 +92,7 @@ func TestApi_getDescription(t *testing.T) {
 				{Action: "dashboards.permissions:read"},
 			},
 			expected: Description{
+				Resource: "dashboards",
 				Assignments: Assignments{
 					Users:        true,
 					Teams:        false,
@@ -95,6 +102,35 @@ func TestApi_getDescription(t *testing.T) {
 			},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			desc: "should only return default permissions of enabled assignments",
+			options: Options{
+				Resource:          "dashboards",
+				ResourceAttribute: "uid",
+				Assignments: Assignments{
+					Users: true,
+				},
+				PermissionsToActions: map[string][]string{
+					"View": {"dashboards:read"},
+				},
+				DefaultPermissions: []DefaultPermission{
+					{Creator: true, Permission: "View"},
+					{BuiltInRole: "Viewer", Permission: "View", RootOnly: true},
+				},
+			},
+			permissions: []accesscontrol.Permission{
+				{Action: "dashboards.permissions:read"},
+			},
+			expected: Description{
+				Resource: "dashboards",
+				Assignments: Assignments{
+					Users: true,
+				},
+				Permissions:        []string{"View"},
+				DefaultPermissions: []DefaultPermission{{Creator: true, Permission: "View"}},
+			},
+			expectedStatus: http.StatusOK,
+		},
 		{
 			desc: "should return 403 when missing read permission",
 			options: Options{
@@ -179,6 +215,39 @@ func TestApi_getPermissions(t *testing.T) {
 	}
 }
 
+func TestApi_getPermissions_includeUnmapped(t *testing.T) {
+	service, _, _ := setupTestEnvironment(t, testOptions)
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+	})}}, service)
+
+	// a managed role granting only part of the lowest permission level
+	_, err := service.store.SetBuiltInResourcePermission(context.Background(), 1, "Viewer", SetResourcePermissionCommand{
+		Actions:           []string{"dashboards:write"},
+		Resource:          testOptions.Resource,
+		ResourceID:        "1",
+		ResourceAttribute: testOptions.ResourceAttribute,
+	}, nil)
+	require.NoError(t, err)
+
+	permissions, recorder := getPermission(t, server, testOptions.Resource, "1")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, permissions, 0)
+
+	req, err := http.NewRequest(http.MethodGet, "/api/access-control/dashboards/1?includeUnmapped=true", nil)
+	require.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&permissions))
+	require.Len(t, permissions, 1)
+	assert.Equal(t, "", permissions[0].Permission)
+	assert.Equal(t, "Viewer", permissions[0].BuiltInRole)
+	assert.Equal(t, []string{"dashboards:write"}, permissions[0].Actions)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(service.metrics.unmappedPermissions.WithLabelValues(testOptions.Resource)))
+}
+
 type setBuiltinPermissionTestCase struct {
 	desc           string
 	resourceID     string
@@ -295,10 +364,10 @@ func TestApi_setTeamPermission(t *testing.T) {
 			},
 		},
 		{
-			desc:           "should set return http 400 when team does not exist",
+			desc:           "should set return http 404 when team does not exist",
 			teamID:         2,
 			resourceID:     "1",
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusNotFound,
 			permission:     "View",
 			permissions: []accesscontrol.Permission{
 				{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
@@ -378,10 +447,10 @@ func TestApi_setUserPermission(t *testing.T) {
 			},
 		},
 		{
-			desc:           "should set return http 400 when user does not exist",
+			desc:           "should set return http 404 when user does not exist",
 			userID:         2,
 			resourceID:     "1",
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusNotFound,
 			permission:     "View",
 			permissions: []accesscontrol.Permission{
 				{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
@@ -430,11 +499,526 @@ func TestApi_setUserPermission(t *testing.T) {
 	}
 }
 
+type setUserPermissionForResourcesTestCase struct {
+	desc              string
+	resourceIDs       []string
+	atomic            bool
+	expectedStatus    int
+	expectedResults   []resourcePermissionResultDTO
+	expectedWritten   []string
+	expectedUntouched []string
+}
+
+func TestApi_setUserPermissionForResources(t *testing.T) {
+	tests := []setUserPermissionForResourcesTestCase{
+		{
+			desc:           "should set permission on permitted resources and reject the others",
+			resourceIDs:    []string{"1", "3", "2"},
+			expectedStatus: http.StatusOK,
+			expectedResults: []resourcePermissionResultDTO{
+				{ResourceID: "1", Status: http.StatusOK},
+				{ResourceID: "3", Status: http.StatusForbidden, Message: "forbidden"},
+				{ResourceID: "2", Status: http.StatusOK, Unchanged: true},
+			},
+			expectedWritten:   []string{"1", "2"},
+			expectedUntouched: []string{"3"},
+		},
+		{
+			desc:              "should reject all resources with atomic when one is forbidden",
+			resourceIDs:       []string{"1", "3"},
+			atomic:            true,
+			expectedStatus:    http.StatusForbidden,
+			expectedWritten:   []string{"2"},
+			expectedUntouched: []string{"1", "3"},
+		},
+		{
+			desc:           "should set permission on all resources with atomic when all are permitted",
+			resourceIDs:    []string{"1", "2"},
+			atomic:         true,
+			expectedStatus: http.StatusOK,
+			expectedResults: []resourcePermissionResultDTO{
+				{ResourceID: "1", Status: http.StatusOK},
+				{ResourceID: "2", Status: http.StatusOK, Unchanged: true},
+			},
+			expectedWritten: []string{"1", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			service, sql, _ := setupTestEnvironment(t, testOptions)
+			server := setupTestServer(t, &user.SignedInUser{
+				OrgID: 1,
+				Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+					{Action: "dashboards.permissions:read", Scope: "dashboards:*"},
+					{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+					{Action: "dashboards.permissions:write", Scope: "dashboards:id:2"},
+					{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+				})},
+			}, service)
+
+			// seed user with View on resource 2
+			orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+			require.NoError(t, err)
+			usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+			require.NoError(t, err)
+			usr, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "test", OrgID: 1})
+			require.NoError(t, err)
+			_, err = service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "2", "View")
+			require.NoError(t, err)
+
+			body, err := json.Marshal(setUserPermissionForResourcesCommand{ResourceIDs: tt.resourceIDs, Permission: "View"})
+			require.NoError(t, err)
+			url := fmt.Sprintf("/api/access-control/dashboards/users/%d/resources", usr.ID)
+			if tt.atomic {
+				url += "?atomic=true"
+			}
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			require.Equal(t, tt.expectedStatus, recorder.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				var results []resourcePermissionResultDTO
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&results))
+				assert.Equal(t, tt.expectedResults, results)
+			}
+
+			for _, resourceID := range tt.expectedWritten {
+				permissions, _ := getPermission(t, server, testOptions.Resource, resourceID)
+				require.Len(t, permissions, 1)
+				assert.Equal(t, "View", permissions[0].Permission)
+			}
+			for _, resourceID := range tt.expectedUntouched {
+				permissions, _ := getPermission(t, server, testOptions.Resource, resourceID)
+				assert.Len(t, permissions, 0)
+			}
+		})
+	}
+}
+
+type setAssignmentPermissionTestCase struct {
+	desc           string
+	assigneeID     string
+	resourceID     string
+	permission     string
+	permissions    []accesscontrol.Permission
+	expectedStatus int
+}
+
+func TestApi_setAssignmentPermission(t *testing.T) {
+	tests := []setAssignmentPermissionTestCase{
+		{
+			desc:           "should set Edit permission for group 1",
+			assigneeID:     "1",
+			resourceID:     "1",
+			expectedStatus: 200,
+			permission:     "Edit",
+			permissions: []accesscontrol.Permission{
+				{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+				{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+			},
+		},
+		{
+			desc:           "should set return http 404 when group does not exist",
+			assigneeID:     "2",
+			resourceID:     "1",
+			expectedStatus: http.StatusNotFound,
+			permission:     "View",
+			permissions: []accesscontrol.Permission{
+				{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+				{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+			},
+		},
+		{
+			desc:           "should set return http 400 when permission is invalid",
+			assigneeID:     "1",
+			resourceID:     "1",
+			expectedStatus: http.StatusBadRequest,
+			permission:     "Admin",
+			permissions: []accesscontrol.Permission{
+				{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+				{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+			},
+		},
+		{
+			desc:           "should set return http 403 when missing permissions",
+			assigneeID:     "1",
+			resourceID:     "1",
+			expectedStatus: http.StatusForbidden,
+			permission:     "View",
+			permissions: []accesscontrol.Permission{
+				{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var bound []int64
+			options := testOptions
+			options.AssignmentKinds = []AssignmentKind{{
+				Name: "groups",
+				Resolve: func(ctx context.Context, orgID int64, assigneeID string) error {
+					if assigneeID != "1" {
+						return ErrAssigneeNotFound
+					}
+					return nil
+				},
+				BindRole: func(session *db.Session, orgID int64, assigneeID string, roleID int64) error {
+					bound = append(bound, roleID)
+					return nil
+				},
+			}}
+
+			service, _, _ := setupTestEnvironment(t, options)
+			server := setupTestServer(t, &user.SignedInUser{
+				OrgID:       1,
+				Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction(tt.permissions)},
+			}, service)
+
+			recorder := setPermission(t, server, options.Resource, tt.resourceID, tt.permission, "groups", tt.assigneeID)
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				require.Len(t, bound, 1)
+				permissions, _ := getPermission(t, server, options.Resource, tt.resourceID)
+				require.Len(t, permissions, 1)
+				assert.Equal(t, tt.permission, permissions[0].Permission)
+				assert.Equal(t, "groups", permissions[0].Kind)
+				assert.Equal(t, tt.assigneeID, permissions[0].AssigneeID)
+				assert.Equal(t, "managed:groups:1:permissions", permissions[0].RoleName)
+				assert.True(t, permissions[0].IsManaged)
+
+				// setting the permission again does not bind the role twice
+				recorder = setPermission(t, server, options.Resource, tt.resourceID, "View", "groups", tt.assigneeID)
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Len(t, bound, 1)
+			}
+		})
+	}
+}
+
+func TestApi_lockPermissions(t *testing.T) {
+	service, _, _ := setupTestEnvironment(t, testOptions)
+	permissions := map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+	})}
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, UserID: 1, Permissions: permissions}, service)
+	adminServer := setupTestServer(t, &user.SignedInUser{OrgID: 1, UserID: 2, IsGrafanaAdmin: true, Permissions: permissions}, service)
+
+	lock := func(server *web.Mux, method string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/api/access-control/dashboards/1/lock", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	getDescription := func() Description {
+		req, err := http.NewRequest(http.MethodGet, "/api/access-control/dashboards/description?resourceId=1", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var description Description
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&description))
+		return description
+	}
+
+	t.Run("should only allow server admins to lock permissions", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, lock(server, http.MethodPost).Code)
+		assert.Equal(t, http.StatusOK, lock(adminServer, http.MethodPost).Code)
+		assert.Equal(t, http.StatusForbidden, lock(server, http.MethodDelete).Code)
+	})
+
+	t.Run("should surface the lock state", func(t *testing.T) {
+		_, recorder := getPermission(t, server, "dashboards", "1")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "true", recorder.Header().Get(permissionsLockedHeader))
+		assert.True(t, getDescription().Locked)
+	})
+
+	t.Run("should reject writes with 423 while locked", func(t *testing.T) {
+		assert.Equal(t, http.StatusLocked, setPermission(t, server, "dashboards", "1", "Edit", "builtInRoles", "Editor").Code)
+	})
+
+	t.Run("should allow writes of server admins while locked", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, setPermission(t, adminServer, "dashboards", "1", "View", "builtInRoles", "Viewer").Code)
+	})
+
+	t.Run("should allow writes after unlock", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, lock(adminServer, http.MethodDelete).Code)
+
+		_, recorder := getPermission(t, server, "dashboards", "1")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(permissionsLockedHeader))
+		assert.False(t, getDescription().Locked)
+
+		assert.Equal(t, http.StatusOK, setPermission(t, server, "dashboards", "1", "Edit", "builtInRoles", "Editor").Code)
+	})
+}
+
+func TestApi_reconcile(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, testOptions)
+	seedPermissions(t, "1", sql, service)
+	permissions := map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:*"},
+		{Action: "dashboards.permissions:write", Scope: "dashboards:*"},
+		{Action: accesscontrol.ActionTeamsRead, Scope: accesscontrol.ScopeTeamsAll},
+		{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+	})}
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, UserID: 1, Permissions: permissions}, service)
+	adminServer := setupTestServer(t, &user.SignedInUser{OrgID: 1, UserID: 2, IsGrafanaAdmin: true, Permissions: permissions}, service)
+
+	seeded, recorder := getPermission(t, server, "dashboards", "1")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	checkSeededPermissions(t, seeded)
+	var teamID int64
+	for _, p := range seeded {
+		if p.TeamID != 0 {
+			teamID = p.TeamID
+		}
+	}
+	// delete the team without cleaning up its permissions
+	err := sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		_, err := sess.Exec("DELETE FROM team WHERE id = ?", teamID)
+		return err
+	})
+	require.NoError(t, err)
+
+	reconcile := func(server *web.Mux) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/api/admin/access-control/dashboards/reconcile", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should only allow server admins to reconcile permissions", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, reconcile(server).Code)
+	})
+
+	t.Run("should report and remove the permissions of deleted teams", func(t *testing.T) {
+		recorder := reconcile(adminServer)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var report ReconciliationReport
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&report))
+		assert.Equal(t, ReconciliationReport{
+			Resource: "dashboards",
+			Removed:  []DanglingAssignment{{OrgID: 1, TeamID: teamID, ResourceID: "1"}},
+		}, report)
+
+		remaining, recorder := getPermission(t, server, "dashboards", "1")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Len(t, remaining, 2)
+		for _, p := range remaining {
+			assert.Zero(t, p.TeamID)
+		}
+	})
+}
+
+func TestApi_setOwner(t *testing.T) {
+	options := testOptions
+	options.EnableOwner = true
+	service, sql, _ := setupTestEnvironment(t, options)
+	owner := createOwnerTestUser(t, sql, "owner", false)
+	other := createOwnerTestUser(t, sql, "other", false)
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+		{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+	})}}, service)
+
+	setOwner := func(resourceID string, userID int64) *httptest.ResponseRecorder {
+		body, err := json.Marshal(setOwnerCommand{UserID: userID})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/access-control/dashboards/%s/owner", resourceID), bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+	owners := func() map[int64]bool {
+		permissions, recorder := getPermission(t, server, "dashboards", "1")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		result := map[int64]bool{}
+		for _, p := range permissions {
+			result[p.UserID] = p.IsOwner
+		}
+		return result
+	}
+
+	t.Run("should flag the permission of the owner", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, setOwner("1", owner.ID).Code)
+		assert.Equal(t, http.StatusOK, setPermission(t, server, "dashboards", "1", "View", "users", strconv.FormatInt(other.ID, 10)).Code)
+		assert.Equal(t, map[int64]bool{owner.ID: true, other.ID: false}, owners())
+	})
+
+	t.Run("should not remove the permission of the owner", func(t *testing.T) {
+		recorder := setPermission(t, server, "dashboards", "1", "", "users", strconv.FormatInt(owner.ID, 10))
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Equal(t, map[int64]bool{owner.ID: true, other.ID: false}, owners())
+	})
+
+	t.Run("should transfer the ownership", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, setOwner("1", other.ID).Code)
+		assert.Equal(t, map[int64]bool{other.ID: true}, owners())
+	})
+
+	t.Run("should not transfer the ownership of resources the caller cannot write", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, setOwner("2", owner.ID).Code)
+	})
+
+	t.Run("should not transfer the ownership to a missing user", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, setOwner("1", 1000).Code)
+	})
+}
+
+// concurrentWriteManager fails every built-in role write as if the store kept hitting deadlocks
+type concurrentWriteManager struct {
+	Manager
+}
+
+func (m concurrentWriteManager) SetBuiltInRolePermission(ctx context.Context, orgID int64, builtInRole, resourceID, permission string) (*accesscontrol.ResourcePermission, error) {
+	return nil, fmt.Errorf("%w: deadlock", ErrConcurrentWrite)
+}
+
+func TestApi_assignmentsResolver(t *testing.T) {
+	options := testOptions
+	// teams are disabled in org 2
+	options.AssignmentsResolver = func(ctx context.Context, orgID int64) Assignments {
+		return Assignments{Users: true, Teams: orgID != 2, BuiltInRoles: true}
+	}
+	service, _, teamSvc := setupTestEnvironment(t, options)
+
+	servers := map[int64]*web.Mux{}
+	teamIDs := map[int64]int64{}
+	for _, orgID := range []int64{1, 2} {
+		servers[orgID] = setupTestServer(t, &user.SignedInUser{
+			OrgID: orgID,
+			Permissions: map[int64]map[string][]string{orgID: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+				{Action: "dashboards.permissions:read", Scope: "dashboards:id:*"},
+				{Action: "dashboards.permissions:write", Scope: "dashboards:id:*"},
+			})},
+		}, service)
+		tm, err := teamSvc.CreateTeam(fmt.Sprintf("team-%d", orgID), "", orgID)
+		require.NoError(t, err)
+		teamIDs[orgID] = tm.ID
+	}
+
+	t.Run("should describe the assignments of the org of the caller", func(t *testing.T) {
+		for orgID, expected := range map[int64]bool{1: true, 2: false} {
+			req, err := http.NewRequest(http.MethodGet, "/api/access-control/dashboards/description", nil)
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			servers[orgID].ServeHTTP(recorder, req)
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			var description Description
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&description))
+			assert.Equal(t, expected, description.Assignments.Teams)
+			assert.True(t, description.Assignments.BuiltInRoles)
+		}
+	})
+
+	t.Run("should return 404 for team assignments in org 2 only", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, setPermission(t, servers[1], "dashboards", "1", "Edit", "teams", strconv.FormatInt(teamIDs[1], 10)).Code)
+		assert.Equal(t, http.StatusNotFound, setPermission(t, servers[2], "dashboards", "1", "Edit", "teams", strconv.FormatInt(teamIDs[2], 10)).Code)
+		assert.Equal(t, http.StatusOK, setPermission(t, servers[2], "dashboards", "1", "Edit", "builtInRoles", "Editor").Code)
+	})
+
+	t.Run("should return 400 when setting team permissions in org 2", func(t *testing.T) {
+		for orgID, expected := range map[int64]int{1: http.StatusOK, 2: http.StatusBadRequest} {
+			body := strings.NewReader(fmt.Sprintf(`{"permissions": [{"teamId": %d, "permission": "View"}]}`, teamIDs[orgID]))
+			req, err := http.NewRequest(http.MethodPost, "/api/access-control/dashboards/2", body)
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			servers[orgID].ServeHTTP(recorder, req)
+			assert.Equal(t, expected, recorder.Code)
+		}
+	})
+
+	t.Run("should reject team permissions set through the service in org 2", func(t *testing.T) {
+		_, err := service.SetTeamPermission(context.Background(), 2, teamIDs[2], "1", "View")
+		assert.ErrorIs(t, err, ErrDisabledAssignment)
+	})
+}
+
+func TestApi_setPermissionConcurrentWrite(t *testing.T) {
+	options := testOptions
+	options.Decorators = []Decorator{func(next Manager) Manager { return concurrentWriteManager{next} }}
+	service, _, _ := setupTestEnvironment(t, options)
+	server := setupTestServer(t, &user.SignedInUser{
+		OrgID: 1,
+		Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+			{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+		})},
+	}, service)
+
+	recorder := setPermission(t, server, "dashboards", "1", "Edit", "builtInRoles", "Editor")
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+}
+
+func TestApi_snapshotAndRestorePermissions(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, testOptions)
+	server := setupTestServer(t, &user.SignedInUser{
+		OrgID: 1,
+		Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+			{Action: "dashboards.permissions:read", Scope: "dashboards:id:*"},
+			{Action: "dashboards.permissions:write", Scope: "dashboards:id:*"},
+			{Action: accesscontrol.ActionTeamsRead, Scope: accesscontrol.ScopeTeamsAll},
+			{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+		})},
+	}, service)
+	seedPermissions(t, "1", sql, service)
+
+	post := func(path string, body io.Reader) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/api/access-control/dashboards/"+path, body)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := post("1/snapshot", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	snapshot := recorder.Body.String()
+
+	recorder = setPermission(t, server, "dashboards", "1", "", "builtInRoles", "Admin")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	permissions, _ := getPermission(t, server, "dashboards", "1")
+	require.Len(t, permissions, 2)
+
+	recorder = post("1/restore", strings.NewReader(snapshot))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	permissions, _ = getPermission(t, server, "dashboards", "1")
+	checkSeededPermissions(t, permissions)
+
+	// a snapshot cannot be restored on another resource
+	recorder = post("2/restore", strings.NewReader(snapshot))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
 func setupTestServer(t *testing.T, user *user.SignedInUser, service *Service) *web.Mux {
+	return setupTestRouter(t, user, service.api.router)
+}
+
+// setupTestRouter returns a server serving the endpoints registered with router to user
+func setupTestRouter(t *testing.T, user *user.SignedInUser, router routing.RouteRegister) *web.Mux {
 	server := web.New()
 	server.UseMiddleware(web.Renderer(path.Join(setting.StaticRootPath, "views"), "[[", "]]"))
 	server.Use(contextProvider(&testContext{user}))
-	service.api.router.Register(server)
+	router.Register(server)
 	return server
 }
 
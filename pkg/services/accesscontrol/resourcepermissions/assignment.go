@@ -0,0 +1,250 @@
+package resourcepermissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/team"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+const (
+	assignmentKindUsers        = "users"
+	assignmentKindTeams        = "teams"
+	assignmentKindBuiltInRoles = "builtInRoles"
+)
+
+// reservedAssignmentKinds cannot be used by custom kinds, either because they are built in or because
+// managed roles of built-in kinds already use them as name prefix
+var reservedAssignmentKinds = map[string]struct{}{
+	assignmentKindUsers:        {},
+	assignmentKindTeams:        {},
+	assignmentKindBuiltInRoles: {},
+	"builtins":                 {},
+	"serviceaccounts":          {},
+}
+
+var assignmentKindNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*$`)
+
+// registerAssignmentKinds builds the kinds of assignees permissions can be granted to, in the order their endpoints
+// are registered. Users, teams and built-in roles are registered the same way as custom kinds, but their managed roles
+// are bound through the user_role, team_role and builtin_role tables by the store and they have no BindRole.
+func (s *Service) registerAssignmentKinds() error {
+	s.assignmentKinds = map[string]AssignmentKind{}
+
+	var kinds []AssignmentKind
+	if s.options.Assignments.Users {
+		kinds = append(kinds, AssignmentKind{Name: assignmentKindUsers, Resolve: s.resolveUser})
+	}
+	if s.options.Assignments.Teams {
+		kinds = append(kinds, AssignmentKind{Name: assignmentKindTeams, Resolve: s.resolveTeam})
+	}
+	if s.options.Assignments.BuiltInRoles {
+		kinds = append(kinds, AssignmentKind{Name: assignmentKindBuiltInRoles, Resolve: s.resolveBuiltInRole})
+	}
+
+	for _, kind := range s.options.AssignmentKinds {
+		if !assignmentKindNameRegex.MatchString(kind.Name) {
+			return fmt.Errorf("invalid assignment kind name %q", kind.Name)
+		}
+		if _, ok := reservedAssignmentKinds[kind.Name]; ok {
+			return fmt.Errorf("assignment kind %q is reserved", kind.Name)
+		}
+		if kind.Resolve == nil || kind.BindRole == nil {
+			return fmt.Errorf("assignment kind %q requires Resolve and BindRole", kind.Name)
+		}
+		kinds = append(kinds, kind)
+	}
+
+	for _, kind := range kinds {
+		if _, ok := s.assignmentKinds[kind.Name]; ok {
+			return fmt.Errorf("assignment kind %q is registered twice", kind.Name)
+		}
+		s.assignmentKinds[kind.Name] = kind
+		s.assignmentKindNames = append(s.assignmentKindNames, kind.Name)
+	}
+
+	return nil
+}
+
+func isBuiltInAssignmentKind(name string) bool {
+	return name == assignmentKindUsers || name == assignmentKindTeams || name == assignmentKindBuiltInRoles
+}
+
+// AssignmentKinds returns the names of the kinds of assignees permissions can be granted to, users, teams and built-in
+// roles first when enabled in Options.Assignments
+func (s *Service) AssignmentKinds() []string {
+	return s.assignmentKindNames
+}
+
+// customAssignmentKinds returns the names of the registered kinds that are not users, teams or built-in roles
+func (s *Service) customAssignmentKinds() []string {
+	var names []string
+	for _, name := range s.assignmentKindNames {
+		if !isBuiltInAssignmentKind(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// SetAssignmentPermission sets the permission of an assignee of any registered kind on a resource
+func (s *Service) SetAssignmentPermission(ctx context.Context, orgID int64, kind, assigneeID, resourceID, permission string) (*accesscontrol.ResourcePermission, error) {
+	switch kind {
+	case assignmentKindUsers:
+		userID, err := parseAssigneeID(kind, assigneeID)
+		if err != nil {
+			return nil, err
+		}
+		return s.SetUserPermission(ctx, orgID, accesscontrol.User{ID: userID}, resourceID, permission)
+	case assignmentKindTeams:
+		teamID, err := parseAssigneeID(kind, assigneeID)
+		if err != nil {
+			return nil, err
+		}
+		return s.SetTeamPermission(ctx, orgID, teamID, resourceID, permission)
+	case assignmentKindBuiltInRoles:
+		return s.SetBuiltInRolePermission(ctx, orgID, assigneeID, resourceID, permission)
+	}
+
+	actions, err := s.mapPermission(permission)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := s.reason(ctx, permission, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.resolveAssignee(ctx, orgID, kind, assigneeID); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateResource(ctx, orgID, resourceID); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkUnlocked(ctx, orgID, resourceID); err != nil {
+		return nil, err
+	}
+
+	resourcePermission, err := s.store.SetAssignmentResourcePermission(ctx, orgID, kind, assigneeID, SetResourcePermissionCommand{
+		Actions:           actions,
+		Permission:        permission,
+		Resource:          s.options.Resource,
+		ResourceID:        resourceID,
+		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
+		Reason:            reason,
+		KeepReason:        s.keepReason(),
+	}, s.assignmentKinds[kind].BindRole)
+	if err != nil {
+		return nil, err
+	}
+
+	// the members of a custom kind are unknown to the service
+	s.service.ClearOrgPermissionCache(orgID)
+	return resourcePermission, nil
+}
+
+// getAssignmentPermissions returns the permissions on a resource granted to assignees of custom kinds
+func (s *Service) getAssignmentPermissions(ctx context.Context, orgID int64, query GetResourcePermissionsQuery) ([]accesscontrol.ResourcePermission, error) {
+	var result []accesscontrol.ResourcePermission
+	for _, kind := range s.customAssignmentKinds() {
+		permissions, err := s.store.GetAssignmentResourcePermissions(ctx, orgID, kind, query)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, permissions...)
+	}
+	return result, nil
+}
+
+// assignments returns the assignment types enabled in an organization
+func (s *Service) assignments(ctx context.Context, orgID int64) Assignments {
+	if s.options.AssignmentsResolver == nil {
+		return s.options.Assignments
+	}
+
+	resolved := s.options.AssignmentsResolver(ctx, orgID)
+	return Assignments{
+		Users:           s.options.Assignments.Users && resolved.Users,
+		ServiceAccounts: s.options.Assignments.ServiceAccounts && resolved.ServiceAccounts,
+		Teams:           s.options.Assignments.Teams && resolved.Teams,
+		BuiltInRoles:    s.options.Assignments.BuiltInRoles && resolved.BuiltInRoles,
+	}
+}
+
+// AssignmentKindEnabled reports whether kind is registered and, for users, teams and built-in roles, enabled in the organization
+func (s *Service) AssignmentKindEnabled(ctx context.Context, orgID int64, kind string) bool {
+	if _, ok := s.assignmentKinds[kind]; !ok {
+		return false
+	}
+
+	switch kind {
+	case assignmentKindUsers:
+		return s.assignments(ctx, orgID).Users
+	case assignmentKindTeams:
+		return s.assignments(ctx, orgID).Teams
+	case assignmentKindBuiltInRoles:
+		return s.assignments(ctx, orgID).BuiltInRoles
+	}
+	return true
+}
+
+// resolveAssignee checks that kind is enabled for the resource in the organization and that the assignee exists
+func (s *Service) resolveAssignee(ctx context.Context, orgID int64, kind, assigneeID string) error {
+	if !s.AssignmentKindEnabled(ctx, orgID, kind) {
+		return fmt.Errorf("%w: %s", ErrDisabledAssignment, kind)
+	}
+	return s.assignmentKinds[kind].Resolve(ctx, orgID, assigneeID)
+}
+
+func (s *Service) resolveUser(ctx context.Context, orgID int64, assigneeID string) error {
+	userID, err := parseAssigneeID(assignmentKindUsers, assigneeID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.userService.GetSignedInUser(ctx, &user.GetSignedInUserQuery{OrgID: orgID, UserID: userID})
+	if errors.Is(err, user.ErrUserNotFound) {
+		return fmt.Errorf("%w: user %d: %w", ErrAssigneeNotFound, userID, err)
+	}
+	return err
+}
+
+func (s *Service) resolveTeam(ctx context.Context, orgID int64, assigneeID string) error {
+	teamID, err := parseAssigneeID(assignmentKindTeams, assigneeID)
+	if err != nil {
+		return err
+	}
+
+	exists, err := s.teamExists(ctx, orgID, teamID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: team %d: %w", ErrAssigneeNotFound, teamID, team.ErrTeamNotFound)
+	}
+	return nil
+}
+
+func (s *Service) resolveBuiltInRole(ctx context.Context, orgID int64, assigneeID string) error {
+	if err := accesscontrol.ValidateBuiltInRoles([]string{assigneeID}); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidAssignment, err)
+	}
+	return nil
+}
+
+func parseAssigneeID(kind, assigneeID string) (int64, error) {
+	id, err := strconv.ParseInt(assigneeID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid %s id %s", ErrInvalidAssignment, kind, assigneeID)
+	}
+	return id, nil
+}
@@ -0,0 +1,95 @@
+package resourcepermissions
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+var _ Manager = new(Service)
+
+// Manager is the set of operations of a Service. Wrappers adding behavior around them, such as auditing,
+// implement it by embedding the Manager they wrap and are installed with Options.Decorators.
+type Manager interface {
+	accesscontrol.PermissionsService
+
+	// MapActionsExact returns the permission level of a permission and whether it grants exactly the actions of the level
+	MapActionsExact(permission accesscontrol.ResourcePermission) (string, bool)
+	// SetUserPermissionForResources sets permission on several resources for a user
+	SetUserPermissionForResources(ctx context.Context, orgID int64, user accesscontrol.User, resourceIDs []string, permission string) ([]ResourcePermissionResult, error)
+	// SetAssignmentPermission sets permission on resource for an assignee of any registered kind
+	SetAssignmentPermission(ctx context.Context, orgID int64, kind, assigneeID, resourceID, permission string) (*accesscontrol.ResourcePermission, error)
+	// SnapshotPermissions returns the managed permissions of a resource
+	SnapshotPermissions(ctx context.Context, orgID int64, resourceID string) (*PermissionsSnapshot, error)
+	// RestorePermissions replaces the managed permissions of a resource with the ones of a snapshot
+	RestorePermissions(ctx context.Context, orgID int64, resourceID string, snapshot PermissionsSnapshot) ([]accesscontrol.ResourcePermission, error)
+	// ListUsersWithAccess returns the ids of the users granted action on a resource
+	ListUsersWithAccess(ctx context.Context, orgID int64, resourceID, action string) ([]int64, error)
+	// StreamUsersWithAccess calls fn with the id of every user granted action on a resource
+	StreamUsersWithAccess(ctx context.Context, orgID int64, resourceID, action string, fn func(userID int64) error) error
+	// Evaluate reports whether a user would be granted action on a resource if hypothetical permissions were set
+	Evaluate(ctx context.Context, orgID int64, user accesscontrol.User, resourceID string, hypothetical []accesscontrol.SetResourcePermissionCommand, action string) (bool, *Explanation, error)
+	// Reconcile removes the managed permissions of deleted users and teams
+	Reconcile(ctx context.Context, orgID int64) (*ReconciliationReport, error)
+	// NormalizeResourceAliases rewrites the permissions stored with the scopes of the aliases of the resource
+	NormalizeResourceAliases(ctx context.Context) (int64, error)
+	// TranslateStoredScopes rewrites the permissions stored with untranslated scopes of the resource
+	TranslateStoredScopes(ctx context.Context) (int64, error)
+	// LockPermissions locks the permissions of a resource
+	LockPermissions(ctx context.Context, orgID int64, resourceID string, lockedBy int64) error
+	// UnlockPermissions removes the lock of a resource
+	UnlockPermissions(ctx context.Context, orgID int64, resourceID string) error
+	// GetPermissionsLock returns the lock of a resource, or nil if its permissions are not locked
+	GetPermissionsLock(ctx context.Context, orgID int64, resourceID string) (*PermissionsLock, error)
+	// SetOwner transfers the ownership of a resource to a user or a service account
+	SetOwner(ctx context.Context, orgID int64, resourceID string, owner accesscontrol.User) error
+	// GetOwner returns the owner of a resource, or nil if it has no owner
+	GetOwner(ctx context.Context, orgID int64, resourceID string) (*ResourceOwner, error)
+}
+
+var _ ResourceService = new(Service)
+
+// ResourceService is what the HTTP API of a resource needs from its service: the Manager operations, which the API
+// calls through the decorators of the service, and the configuration of the resource. Service implements it, an
+// implementation backed by another system can serve the same API.
+type ResourceService interface {
+	Manager
+
+	// Options returns the options the resource was registered with
+	Options() Options
+	// Description returns the access control properties of the resource in an organization
+	Description(ctx context.Context, orgID int64) Description
+	// AssignmentKinds returns the names of the kinds of assignees, their endpoints are registered in this order
+	AssignmentKinds() []string
+	// AssignmentKindEnabled reports whether permissions can be assigned to kind in an organization
+	AssignmentKindEnabled(ctx context.Context, orgID int64, kind string) bool
+	// InheritedScopes returns the scopes of the ancestors of a resource, from the nearest ancestor to the root
+	InheritedScopes(ctx context.Context, orgID int64, resourceID string) ([]string, error)
+	// ImplicitPermissions returns the permissions granted on every resource of an organization without being stored,
+	// they are listed with the permissions of each resource
+	ImplicitPermissions(ctx context.Context, orgID int64) []accesscontrol.ResourcePermission
+	// EffectiveDefaultPermissions returns the permissions assigned to the new resources of an organization
+	EffectiveDefaultPermissions(ctx context.Context, orgID int64) EffectiveDefaultPermissions
+	// CountPermissions returns the numbers of assignees with a managed permission on a resource by kind of assignee, or
+	// on any resource of the kind when resourceID is empty
+	CountPermissions(ctx context.Context, orgID int64, resourceID string) (*PermissionCounts, error)
+	// UsageStats returns the usage statistics of the managed permissions on the resource
+	UsageStats(ctx context.Context) (*UsageStats, error)
+}
+
+// Decorator returns a Manager adding behavior around next
+type Decorator func(next Manager) Manager
+
+// Decorate wraps m with decorators, the first decorator is the outermost and is called first
+func Decorate(m Manager, decorators ...Decorator) Manager {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		m = decorators[i](m)
+	}
+	return m
+}
+
+// Manager returns the service wrapped with Options.Decorators. Calls made by Service methods to other Service
+// methods, such as RestorePermissions setting permissions, are not decorated.
+func (s *Service) Manager() Manager {
+	return s.manager
+}
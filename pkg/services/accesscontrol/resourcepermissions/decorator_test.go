@@ -0,0 +1,73 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+// loggingManager records the set calls made through it
+type loggingManager struct {
+	Manager
+	name string
+	log  *[]string
+}
+
+func (m loggingManager) SetBuiltInRolePermission(ctx context.Context, orgID int64, builtInRole, resourceID, permission string) (*accesscontrol.ResourcePermission, error) {
+	*m.log = append(*m.log, fmt.Sprintf("%s:%s:%s:%s", m.name, builtInRole, resourceID, permission))
+	return m.Manager.SetBuiltInRolePermission(ctx, orgID, builtInRole, resourceID, permission)
+}
+
+func loggingDecorator(name string, log *[]string) Decorator {
+	return func(next Manager) Manager {
+		return loggingManager{Manager: next, name: name, log: log}
+	}
+}
+
+func TestService_Decorators(t *testing.T) {
+	var log []string
+	options := testOptions
+	options.Decorators = []Decorator{loggingDecorator("outer", &log), loggingDecorator("inner", &log)}
+	service, _, _ := setupTestEnvironment(t, options)
+
+	t.Run("should call decorators from the outermost for the service manager", func(t *testing.T) {
+		log = nil
+		_, err := service.Manager().SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"outer:Viewer:1:View", "inner:Viewer:1:View"}, log)
+	})
+
+	t.Run("should call decorators for http api writes", func(t *testing.T) {
+		log = nil
+		server := setupTestServer(t, &user.SignedInUser{
+			OrgID: 1,
+			Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+				{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+			})},
+		}, service)
+
+		recorder := setPermission(t, server, "dashboards", "1", "Edit", "builtInRoles", "Editor")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, []string{"outer:Editor:1:Edit", "inner:Editor:1:Edit"}, log)
+	})
+
+	t.Run("should not decorate direct service calls", func(t *testing.T) {
+		log = nil
+		_, err := service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "")
+		require.NoError(t, err)
+		assert.Empty(t, log)
+	})
+}
+
+func TestDecorate(t *testing.T) {
+	service, _, _ := setupTestEnvironment(t, testOptions)
+	assert.Same(t, service, Decorate(service))
+	assert.Same(t, service, service.Manager())
+}
@@ -0,0 +1,153 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// settingsDefaultsKey is the key of the settings section of a resource holding the permissions of built-in roles
+// assigned to new resources, as a json object mapping an organization id, or * for the organizations without their
+// own entry, to default permissions, e.g.
+// {"*": [{"builtInRole": "Viewer", "permission": "View"}], "2": [{"builtInRole": "Editor", "permission": "Edit"}]}
+const settingsDefaultsKey = "default_builtin_permissions"
+
+// allOrgsDefaults is the key of the default permissions of settingsDefaultsKey applying to every organization
+const allOrgsDefaults = "*"
+
+// DefaultPermissionsSource is where the default permissions of the built-in roles of an organization come from
+type DefaultPermissionsSource string
+
+const (
+	// DefaultPermissionsSourceOptions are the built-in role permissions of Options.DefaultPermissions
+	DefaultPermissionsSourceOptions DefaultPermissionsSource = "options"
+	// DefaultPermissionsSourceSettings are the permissions of the * entry of the settings
+	DefaultPermissionsSourceSettings DefaultPermissionsSource = "settings"
+	// DefaultPermissionsSourceOrg are the permissions of the entry of the organization in the settings
+	DefaultPermissionsSourceOrg DefaultPermissionsSource = "org"
+)
+
+// EffectiveDefaultPermissions are the permissions assigned to the new resources of an organization
+type EffectiveDefaultPermissions struct {
+	OrgID int64 `json:"orgId"`
+	// Source is where the permissions of the built-in roles come from, the permissions of creators always come from
+	// the options
+	Source             DefaultPermissionsSource `json:"source"`
+	DefaultPermissions []DefaultPermission      `json:"defaultPermissions"`
+}
+
+// builtInDefaults are the default permissions of built-in roles read from the settings of the resource. They are never
+// modified once created, a reload replaces them as a whole.
+type builtInDefaults struct {
+	// all applies to the organizations without their own entry, when hasAll is set
+	all    []DefaultPermission
+	hasAll bool
+	orgs   map[int64][]DefaultPermission
+}
+
+// parseBuiltInDefaults parses the value of settingsDefaultsKey and checks that it only grants levels to built-in roles,
+// it returns nil when raw is empty
+func parseBuiltInDefaults(raw string, levels map[string][]string) (*builtInDefaults, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var byOrg map[string][]DefaultPermission
+	if err := json.Unmarshal([]byte(raw), &byOrg); err != nil {
+		return nil, fmt.Errorf("%w: invalid %s: %w", ErrInvalidPermission, settingsDefaultsKey, err)
+	}
+
+	defaults := &builtInDefaults{orgs: make(map[int64][]DefaultPermission, len(byOrg))}
+	for key, permissions := range byOrg {
+		for _, d := range permissions {
+			if d.Creator || accesscontrol.ValidateBuiltInRoles([]string{d.BuiltInRole}) != nil {
+				return nil, fmt.Errorf("%w: %s of %s: only built-in roles can be granted default permissions, got %+v", ErrInvalidPermission, settingsDefaultsKey, key, d)
+			}
+			if _, ok := levels[d.Permission]; !ok {
+				return nil, fmt.Errorf("%w: %s of %s: %q is not a permission level", ErrInvalidPermission, settingsDefaultsKey, key, d.Permission)
+			}
+		}
+
+		if key == allOrgsDefaults {
+			defaults.all, defaults.hasAll = permissions, true
+			continue
+		}
+		orgID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil || orgID <= 0 {
+			return nil, fmt.Errorf("%w: %s: invalid organization %q", ErrInvalidPermission, settingsDefaultsKey, key)
+		}
+		defaults.orgs[orgID] = permissions
+	}
+	return defaults, nil
+}
+
+// settingsBuiltInDefaults returns the default permissions of built-in roles of a settings section, they can only be
+// configured when built-in role assignments are enabled
+func (s *Service) settingsBuiltInDefaults(section setting.Section, levels map[string][]string) (*builtInDefaults, error) {
+	raw := section.KeyValue(settingsDefaultsKey).Value()
+	if raw != "" && !s.options.Assignments.BuiltInRoles {
+		return nil, fmt.Errorf("%w: %s requires built-in role assignments", ErrInvalidPermission, settingsDefaultsKey)
+	}
+	return parseBuiltInDefaults(raw, levels)
+}
+
+// getBuiltInDefaults returns the default permissions of built-in roles read from the settings, or nil
+func (s *Service) getBuiltInDefaults() *builtInDefaults {
+	s.levelsMu.RLock()
+	defer s.levelsMu.RUnlock()
+	return s.builtInDefaults
+}
+
+// builtInRoleDefaults returns the default permissions of the built-in roles of an organization and where they come
+// from. The entry of the organization in the settings comes first, then the * entry and then Options.DefaultPermissions
+func (s *Service) builtInRoleDefaults(orgID int64) ([]DefaultPermission, DefaultPermissionsSource) {
+	if defaults := s.getBuiltInDefaults(); defaults != nil {
+		if permissions, ok := defaults.orgs[orgID]; ok {
+			return permissions, DefaultPermissionsSourceOrg
+		}
+		if defaults.hasAll {
+			return defaults.all, DefaultPermissionsSourceSettings
+		}
+	}
+
+	var permissions []DefaultPermission
+	for _, d := range s.options.DefaultPermissions {
+		if d.BuiltInRole != "" {
+			permissions = append(permissions, d)
+		}
+	}
+	return permissions, DefaultPermissionsSourceOptions
+}
+
+// defaultPermissions returns the default permissions of an organization whose assignment type is enabled in
+// assignments, the permissions of creators first
+func (s *Service) defaultPermissions(orgID int64, assignments Assignments) []DefaultPermission {
+	var defaults []DefaultPermission
+	if assignments.Users {
+		for _, d := range s.options.DefaultPermissions {
+			if d.Creator {
+				defaults = append(defaults, d)
+			}
+		}
+	}
+	if assignments.BuiltInRoles {
+		builtIns, _ := s.builtInRoleDefaults(orgID)
+		defaults = append(defaults, builtIns...)
+	}
+	return defaults
+}
+
+// EffectiveDefaultPermissions returns the permissions assigned to the new resources of an organization by
+// SetDefaultPermissions
+func (s *Service) EffectiveDefaultPermissions(ctx context.Context, orgID int64) EffectiveDefaultPermissions {
+	_, source := s.builtInRoleDefaults(orgID)
+	return EffectiveDefaultPermissions{
+		OrgID:              orgID,
+		Source:             source,
+		DefaultPermissions: s.defaultPermissions(orgID, s.assignments(ctx, orgID)),
+	}
+}
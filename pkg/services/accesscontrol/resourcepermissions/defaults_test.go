@@ -0,0 +1,183 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/actest"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/licensing/licensingtest"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func defaultsTestOptions(defaults string) (Options, *setting.Cfg) {
+	cfg := setting.NewCfg()
+	cfg.Raw.Section(settingsSection("dashboards")).Key(settingsDefaultsKey).SetValue(defaults)
+	options := testOptions
+	options.Settings = &setting.OSSImpl{Cfg: cfg}
+	options.DefaultPermissions = []DefaultPermission{
+		{Creator: true, Permission: "Edit"},
+		{BuiltInRole: "Viewer", Permission: "View"},
+	}
+	return options, cfg
+}
+
+func TestService_EffectiveDefaultPermissions(t *testing.T) {
+	options, _ := defaultsTestOptions(`{"*": [{"builtInRole": "Editor", "permission": "View"}], "2": [{"builtInRole": "Editor", "permission": "Edit"}, {"builtInRole": "Viewer", "permission": "View"}]}`)
+	service, _, _ := setupTestEnvironment(t, options)
+
+	org2 := service.EffectiveDefaultPermissions(context.Background(), 2)
+	assert.Equal(t, DefaultPermissionsSourceOrg, org2.Source)
+	assert.Equal(t, []DefaultPermission{
+		{Creator: true, Permission: "Edit"},
+		{BuiltInRole: "Editor", Permission: "Edit"},
+		{BuiltInRole: "Viewer", Permission: "View"},
+	}, org2.DefaultPermissions)
+
+	org1 := service.EffectiveDefaultPermissions(context.Background(), 1)
+	assert.Equal(t, DefaultPermissionsSourceSettings, org1.Source)
+	assert.Equal(t, []DefaultPermission{
+		{Creator: true, Permission: "Edit"},
+		{BuiltInRole: "Editor", Permission: "View"},
+	}, org1.DefaultPermissions)
+
+	permissions, err := service.SetDefaultPermissions(context.Background(), 1, "1", nil)
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	assert.Equal(t, "Editor", permissions[0].BuiltInRole)
+	assert.Equal(t, "View", service.MapActions(permissions[0]))
+}
+
+func TestService_EffectiveDefaultPermissionsWithoutOverrides(t *testing.T) {
+	options, _ := defaultsTestOptions(`{"2": [{"builtInRole": "Editor", "permission": "Edit"}]}`)
+	service, _, _ := setupTestEnvironment(t, options)
+
+	org1 := service.EffectiveDefaultPermissions(context.Background(), 1)
+	assert.Equal(t, DefaultPermissionsSourceOptions, org1.Source)
+	assert.Equal(t, options.DefaultPermissions, org1.DefaultPermissions)
+
+	// an empty list removes the default permissions of built-in roles of an organization
+	options, _ = defaultsTestOptions(`{"2": []}`)
+	service, _, _ = setupTestEnvironment(t, options)
+	org2 := service.EffectiveDefaultPermissions(context.Background(), 2)
+	assert.Equal(t, DefaultPermissionsSourceOrg, org2.Source)
+	assert.Equal(t, []DefaultPermission{{Creator: true, Permission: "Edit"}}, org2.DefaultPermissions)
+}
+
+func TestService_invalidDefaultPermissions(t *testing.T) {
+	tests := []struct {
+		desc     string
+		defaults string
+	}{
+		{desc: "should reject malformed json", defaults: `{"*": `},
+		{desc: "should reject an invalid organization", defaults: `{"main": [{"builtInRole": "Viewer", "permission": "View"}]}`},
+		{desc: "should reject organization 0", defaults: `{"0": [{"builtInRole": "Viewer", "permission": "View"}]}`},
+		{desc: "should reject an unknown permission level", defaults: `{"*": [{"builtInRole": "Viewer", "permission": "Admin"}]}`},
+		{desc: "should reject an unknown built-in role", defaults: `{"*": [{"builtInRole": "Owner", "permission": "View"}]}`},
+		{desc: "should reject creator permissions", defaults: `{"*": [{"creator": true, "permission": "View"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			options, _ := defaultsTestOptions(tt.defaults)
+			_, err := New(options, featuremgmt.WithFeatures(), routing.NewRouteRegister(), licensingtest.NewFakeLicensing(),
+				acimpl.ProvideAccessControl(setting.NewCfg()), &actest.FakeService{}, nil, nil, nil)
+			assert.ErrorIs(t, err, ErrInvalidOptions)
+			assert.ErrorIs(t, err, ErrInvalidPermission)
+		})
+	}
+
+	t.Run("should reject defaults without built-in role assignments", func(t *testing.T) {
+		options, _ := defaultsTestOptions(`{"*": [{"builtInRole": "Viewer", "permission": "View"}]}`)
+		options.Assignments.BuiltInRoles = false
+		_, err := New(options, featuremgmt.WithFeatures(), routing.NewRouteRegister(), licensingtest.NewFakeLicensing(),
+			acimpl.ProvideAccessControl(setting.NewCfg()), &actest.FakeService{}, nil, nil, nil)
+		assert.ErrorIs(t, err, ErrInvalidOptions)
+	})
+}
+
+func TestService_ReloadDefaultPermissions(t *testing.T) {
+	options, cfg := defaultsTestOptions("")
+	service, _, _ := setupTestEnvironment(t, options)
+	section := options.Settings.Section(settingsSection("dashboards"))
+	assert.Equal(t, DefaultPermissionsSourceOptions, service.EffectiveDefaultPermissions(context.Background(), 1).Source)
+
+	// the defaults are checked against the levels of the same section
+	cfg.Raw.Section(settingsSection("dashboards")).Key(settingsLevelsKey).SetValue(`{"View": ["dashboards:read"], "Annotate": ["dashboards:read", "annotations:write"]}`)
+	cfg.Raw.Section(settingsSection("dashboards")).Key(settingsDefaultsKey).SetValue(`{"*": [{"builtInRole": "Editor", "permission": "Edit"}]}`)
+	assert.ErrorIs(t, service.Validate(section), ErrInvalidPermission)
+	assert.ErrorIs(t, service.Reload(section), ErrInvalidPermission)
+	assert.Equal(t, []string{"View", "Edit"}, service.getLevels().display())
+	assert.Equal(t, DefaultPermissionsSourceOptions, service.EffectiveDefaultPermissions(context.Background(), 1).Source)
+
+	cfg.Raw.Section(settingsSection("dashboards")).Key(settingsDefaultsKey).SetValue(`{"*": [{"builtInRole": "Editor", "permission": "Annotate"}]}`)
+	require.NoError(t, service.Validate(section))
+	require.NoError(t, service.Reload(section))
+	assert.Equal(t, []string{"View", "Annotate"}, service.getLevels().display())
+	effective := service.EffectiveDefaultPermissions(context.Background(), 1)
+	assert.Equal(t, DefaultPermissionsSourceSettings, effective.Source)
+	assert.Contains(t, effective.DefaultPermissions, DefaultPermission{BuiltInRole: "Editor", Permission: "Annotate"})
+
+	// without defaults the defaults of the options are restored
+	cfg.Raw.Section(settingsSection("dashboards")).Key(settingsDefaultsKey).SetValue("")
+	require.NoError(t, service.Reload(section))
+	assert.Equal(t, DefaultPermissionsSourceOptions, service.EffectiveDefaultPermissions(context.Background(), 1).Source)
+}
+
+func TestApi_getDefaultPermissions(t *testing.T) {
+	options, _ := defaultsTestOptions(`{"2": [{"builtInRole": "Editor", "permission": "Edit"}]}`)
+	service, _, _ := setupTestEnvironment(t, options)
+
+	getDefaults := func(t *testing.T, signedIn *user.SignedInUser, query string) (EffectiveDefaultPermissions, *httptest.ResponseRecorder) {
+		server := setupTestServer(t, signedIn, service)
+		req, err := http.NewRequest(http.MethodGet, "/api/admin/access-control/dashboards/default-permissions"+query, nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+
+		var effective EffectiveDefaultPermissions
+		if recorder.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&effective))
+		}
+		return effective, recorder
+	}
+
+	admin := &user.SignedInUser{OrgID: 1, IsGrafanaAdmin: true, Permissions: map[int64]map[string][]string{1: {}}}
+
+	t.Run("should return the defaults of the organization of the user", func(t *testing.T) {
+		effective, recorder := getDefaults(t, admin, "")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, int64(1), effective.OrgID)
+		assert.Equal(t, DefaultPermissionsSourceOptions, effective.Source)
+	})
+
+	t.Run("should return the defaults of another organization", func(t *testing.T) {
+		effective, recorder := getDefaults(t, admin, "?orgId=2")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, int64(2), effective.OrgID)
+		assert.Equal(t, DefaultPermissionsSourceOrg, effective.Source)
+		assert.Equal(t, []DefaultPermission{{Creator: true, Permission: "Edit"}, {BuiltInRole: "Editor", Permission: "Edit"}}, effective.DefaultPermissions)
+	})
+
+	t.Run("should reject an invalid organization", func(t *testing.T) {
+		_, recorder := getDefaults(t, admin, "?orgId=main")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("should only be readable by server admins", func(t *testing.T) {
+		_, recorder := getDefaults(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+			{Action: "dashboards.permissions:read"},
+		})}}, "")
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
@@ -1,8 +1,95 @@
 package resourcepermissions
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrInvalidPermission = errors.New("invalid permission")
 	ErrInvalidAssignment = errors.New("invalid assignment")
+	// ErrDisabledAssignment is returned when permissions are assigned to a kind of assignee the service does not support,
+	// it is also an ErrInvalidAssignment
+	ErrDisabledAssignment = fmt.Errorf("%w: assignment is disabled", ErrInvalidAssignment)
+	ErrAssigneeNotFound   = errors.New("assignee not found")
+	// ErrResourceNotFound should be returned by an Options.ResourceValidator when the resource does not exist
+	ErrResourceNotFound = errors.New("resource not found")
+	// ErrConcurrentWrite is returned when a write kept failing because of concurrent writes to the same permissions,
+	// such as a deadlock or a lock timeout, after the store retried it. The write can be retried later
+	ErrConcurrentWrite = errors.New("concurrent resource permission write")
+	// ErrTooManyUsers is returned when listing more users than a single result can hold
+	ErrTooManyUsers = errors.New("too many users")
+	// ErrInvalidSnapshot is returned when restoring a snapshot taken on another resource
+	ErrInvalidSnapshot = errors.New("invalid permissions snapshot")
+	// ErrInvalidOptions is returned when creating a service with inconsistent options
+	ErrInvalidOptions = errors.New("invalid resource permissions options")
+	// ErrPermissionsLocked is returned when setting permissions on a resource whose permissions are locked
+	ErrPermissionsLocked = errors.New("resource permissions are locked")
+	// ErrOwnerPermission is returned when changing or removing the permission of the owner of a resource, the
+	// ownership has to be transferred first
+	ErrOwnerPermission = errors.New("the permission of the resource owner cannot be changed")
+	// ErrInvalidMove is returned by HandleResourceMoved for an unknown policy, or when the resource is not under its
+	// new parent
+	ErrInvalidMove = errors.New("invalid resource move")
+	// ErrInvalidScopeTranslation is returned when an Options.ScopeTranslator translates the scope of a resource to
+	// a scope of another kind of resource
+	ErrInvalidScopeTranslation = errors.New("invalid scope translation")
+	// ErrInvalidReason is returned when the reason of a permission is too long
+	ErrInvalidReason = errors.New("invalid permission reason")
+	// ErrReasonRequired is returned when a permission is granted without a reason on a resource with
+	// Options.RequireReason, see ReasonRequiredError
+	ErrReasonRequired = errors.New("a reason is required to grant a permission")
+	// ErrUnlicensedPermission is returned when granting a permission level whose license feature is not enabled, see
+	// UnlicensedPermissionError
+	ErrUnlicensedPermission = errors.New("permission level is not licensed")
+	// ErrHookFailed is returned when a hook of Options called in the transaction of a write fails, the write is then
+	// rolled back, see HookError
+	ErrHookFailed = errors.New("resource permission hook failed")
+	// ErrInvalidRoleRename is returned when renaming managed roles from or to an invalid prefix, see RenameManagedRolesCmd
+	ErrInvalidRoleRename = errors.New("invalid managed role rename")
 )
+
+// UnlicensedPermissionError is returned when granting a permission level of Options.PermissionLicenses whose license
+// feature is not enabled, it is also an ErrUnlicensedPermission
+type UnlicensedPermissionError struct {
+	Permission string
+	Feature    string
+}
+
+func (e *UnlicensedPermissionError) Error() string {
+	return fmt.Sprintf("permission %s requires the license feature %s", e.Permission, e.Feature)
+}
+
+func (e *UnlicensedPermissionError) Unwrap() error {
+	return ErrUnlicensedPermission
+}
+
+// ReasonRequiredError is returned when granting a permission without a reason on a resource with
+// Options.RequireReason, it is also an ErrReasonRequired
+type ReasonRequiredError struct {
+	Resource   string
+	Permission string
+}
+
+func (e *ReasonRequiredError) Error() string {
+	return fmt.Sprintf("a reason is required to grant the permission %s on %s", e.Permission, e.Resource)
+}
+
+func (e *ReasonRequiredError) Unwrap() error {
+	return ErrReasonRequired
+}
+
+// HookError is returned when a hook of Options called in the transaction of a write, such as OnSetUser, fails. It is
+// also an ErrHookFailed and the error returned by the hook.
+type HookError struct {
+	Hook string
+	Err  error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("hook %s failed: %s", e.Hook, e.Err)
+}
+
+func (e *HookError) Unwrap() []error {
+	return []error{ErrHookFailed, e.Err}
+}
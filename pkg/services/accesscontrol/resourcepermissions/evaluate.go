@@ -0,0 +1,246 @@
+package resourcepermissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	"github.com/grafana/grafana/pkg/services/team"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+// Explanation lists the permission entries an evaluation was decided on
+type Explanation struct {
+	// Granted are the entries granting the action to the user once the hypothetical permissions are applied
+	Granted []ExplanationEntry `json:"granted"`
+	// Replaced are the existing entries granting the action to the user that the hypothetical permissions replace
+	Replaced []ExplanationEntry `json:"replaced,omitempty"`
+}
+
+// ExplanationEntry is a permission on the resource, or on one of its ancestors, of the user, one of their teams or
+// one of their roles
+type ExplanationEntry struct {
+	// Hypothetical is set for entries of the hypothetical permissions
+	Hypothetical bool   `json:"hypothetical"`
+	UserID       int64  `json:"userId,omitempty"`
+	TeamID       int64  `json:"teamId,omitempty"`
+	BuiltInRole  string `json:"builtInRole,omitempty"`
+	RoleName     string `json:"roleName,omitempty"`
+	Scope        string `json:"scope"`
+	// Permission is the permission level of the entry, it is empty for entries that do not match a level
+	Permission  string `json:"permission,omitempty"`
+	IsManaged   bool   `json:"isManaged"`
+	IsInherited bool   `json:"isInherited"`
+}
+
+// Evaluate reports whether a user would be granted action on a resource if the hypothetical commands were set,
+// without writing anything. The commands replace the managed permissions of their assignees on the resource, like
+// SetPermissions does, and a command with an empty permission removes them. Permissions granted to custom
+// assignment kinds are not taken into account since their members are unknown to the service.
+func (s *Service) Evaluate(
+	ctx context.Context, orgID int64, usr accesscontrol.User, resourceID string,
+	hypothetical []accesscontrol.SetResourcePermissionCommand, action string,
+) (bool, *Explanation, error) {
+	if err := s.validateResource(ctx, orgID, resourceID); err != nil {
+		return false, nil, err
+	}
+
+	signedInUser, err := s.userService.GetSignedInUser(ctx, &user.GetSignedInUserQuery{OrgID: orgID, UserID: usr.ID})
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return false, nil, fmt.Errorf("%w: user %d: %w", ErrAssigneeNotFound, usr.ID, err)
+		}
+		return false, nil, err
+	}
+	teamIDs, err := s.teamService.GetTeamIDsByUser(ctx, &team.GetTeamIDsByUserQuery{OrgID: orgID, UserID: usr.ID})
+	if err != nil {
+		return false, nil, err
+	}
+
+	entries, err := s.getEvaluationEntries(ctx, orgID, resourceID, action)
+	if err != nil {
+		return false, nil, err
+	}
+	entries, replaced, err := s.overlay(entries, resourceID, hypothetical)
+	if err != nil {
+		return false, nil, err
+	}
+
+	teams := make(map[int64]bool, len(teamIDs))
+	for _, id := range teamIDs {
+		teams[id] = true
+	}
+	roles := map[string]bool{}
+	for _, role := range accesscontrol.GetOrgRoles(signedInUser) {
+		roles[role] = true
+	}
+	appliesToUser := func(e evaluationEntry) bool {
+		return (e.UserId != 0 && e.UserId == usr.ID) || (e.TeamId != 0 && teams[e.TeamId]) || (e.BuiltInRole != "" && roles[e.BuiltInRole])
+	}
+
+	explanation := &Explanation{}
+	for _, e := range entries {
+		if appliesToUser(e) && e.grants(action, resourceID, s.options) {
+			explanation.Granted = append(explanation.Granted, s.explain(e))
+		}
+	}
+	for _, e := range replaced {
+		if appliesToUser(e) && e.grants(action, resourceID, s.options) {
+			explanation.Replaced = append(explanation.Replaced, s.explain(e))
+		}
+	}
+
+	return len(explanation.Granted) > 0, explanation, nil
+}
+
+// evaluationEntry is a permission entry of an evaluation, existing or hypothetical
+type evaluationEntry struct {
+	accesscontrol.ResourcePermission
+	hypothetical bool
+}
+
+// grants reports whether the entry grants action on the resource. The scopes of inherited entries are the scopes of
+// the ancestors of the resource, they grant the action on the resource.
+func (e evaluationEntry) grants(action, resourceID string, options Options) bool {
+	scopes := []string{accesscontrol.Scope(options.Resource, options.ResourceAttribute, resourceID)}
+	if e.IsInherited {
+		scopes = append(scopes, e.Scope)
+	}
+
+	permissions := make(map[string][]string, len(e.Actions))
+	for _, a := range e.Actions {
+		permissions[a] = append(permissions[a], e.Scope)
+	}
+	return accesscontrol.EvalPermission(action, scopes...).Evaluate(permissions)
+}
+
+// getEvaluationEntries returns the permissions on the resource and its ancestors of the roles granting action, with
+// their actions of the permission levels, regardless of the users and teams the caller can see
+func (s *Service) getEvaluationEntries(ctx context.Context, orgID int64, resourceID, action string) ([]evaluationEntry, error) {
+	levels := s.getLevels()
+	permissions, err := s.getEvaluationPermissions(ctx, orgID, resourceID, action, levels)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]evaluationEntry, 0, len(permissions)+1)
+	for _, p := range permissions {
+		entries = append(entries, evaluationEntry{ResourcePermission: p})
+	}
+	// without enforcement organization admins are granted every action of the resource, see getPermissions
+	if s.assignments(ctx, orgID).BuiltInRoles && !s.license.FeatureEnabled("accesscontrol.enforcement") {
+		entries = append(entries, evaluationEntry{ResourcePermission: accesscontrol.ResourcePermission{
+			Actions:     levels.actions,
+			Scope:       "*",
+			BuiltInRole: string(org.RoleAdmin),
+		}})
+	}
+	return entries, nil
+}
+
+// getEvaluationPermissions returns the permissions of the roles granting action on the resource and its ancestors
+func (s *Service) getEvaluationPermissions(ctx context.Context, orgID int64, resourceID, action string, levels *permissionLevels) ([]accesscontrol.ResourcePermission, error) {
+	inheritedScopes, err := s.InheritedScopes(ctx, orgID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := levels.actions
+	if !containsAction(actions, action) {
+		actions = append(append(make([]string, 0, len(actions)+1), actions...), action)
+	}
+
+	return s.store.GetResourcePermissions(ctx, orgID, GetResourcePermissionsQuery{
+		User: accesscontrol.BackgroundUser("resource_permissions_evaluation", orgID, org.RoleAdmin, []accesscontrol.Permission{
+			{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+			{Action: accesscontrol.ActionTeamsRead, Scope: accesscontrol.ScopeTeamsAll},
+			{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll},
+		}),
+		Actions:           actions,
+		AnyAction:         []string{action},
+		Resource:          s.options.Resource,
+		ResourceID:        resourceID,
+		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
+		InheritedScopes:   inheritedScopes,
+	})
+}
+
+// overlay applies the hypothetical commands to entries. The managed entries of the assignees of the commands on the
+// resource itself are returned as replaced, entries inherited from ancestors and entries of other roles are kept.
+func (s *Service) overlay(entries []evaluationEntry, resourceID string, commands []accesscontrol.SetResourcePermissionCommand) ([]evaluationEntry, []evaluationEntry, error) {
+	scope := accesscontrol.Scope(s.options.Resource, s.options.ResourceAttribute, resourceID)
+
+	assignees := make(map[accesscontrol.SetResourcePermissionCommand]bool, len(commands))
+	var hypothetical []evaluationEntry
+	for _, cmd := range commands {
+		if cmd.UserID == 0 && cmd.TeamID == 0 && cmd.BuiltinRole == "" {
+			return nil, nil, fmt.Errorf("%w: a user, a team or a built-in role is required", ErrInvalidAssignment)
+		}
+		actions, err := s.mapPermission(cmd.Permission)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		assignees[accesscontrol.SetResourcePermissionCommand{UserID: cmd.UserID, TeamID: cmd.TeamID, BuiltinRole: cmd.BuiltinRole}] = true
+		if len(actions) == 0 {
+			continue
+		}
+		roleName := s.roleNames.builtInRole(cmd.BuiltinRole)
+		if cmd.UserID != 0 {
+			roleName = s.roleNames.user(cmd.UserID)
+		} else if cmd.TeamID != 0 {
+			roleName = s.roleNames.team(cmd.TeamID)
+		}
+		hypothetical = append(hypothetical, evaluationEntry{
+			ResourcePermission: accesscontrol.ResourcePermission{
+				RoleName:    roleName,
+				Actions:     actions,
+				Scope:       scope,
+				UserId:      cmd.UserID,
+				TeamId:      cmd.TeamID,
+				BuiltInRole: cmd.BuiltinRole,
+				IsManaged:   true,
+			},
+			hypothetical: true,
+		})
+	}
+
+	kept := make([]evaluationEntry, 0, len(entries)+len(hypothetical))
+	var replaced []evaluationEntry
+	for _, e := range entries {
+		key := accesscontrol.SetResourcePermissionCommand{UserID: e.UserId, TeamID: e.TeamId, BuiltinRole: e.BuiltInRole}
+		if e.IsManaged && !e.IsInherited && e.Scope == scope && assignees[key] {
+			replaced = append(replaced, e)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return append(kept, hypothetical...), replaced, nil
+}
+
+func (s *Service) explain(e evaluationEntry) ExplanationEntry {
+	return ExplanationEntry{
+		Hypothetical: e.hypothetical,
+		UserID:       e.UserId,
+		TeamID:       e.TeamId,
+		BuiltInRole:  e.BuiltInRole,
+		RoleName:     e.RoleName,
+		Scope:        e.Scope,
+		Permission:   s.MapActions(e.ResourcePermission),
+		IsManaged:    e.IsManaged,
+		IsInherited:  e.IsInherited,
+	}
+}
+
+func containsAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package resourcepermissions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/org/orgimpl"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/services/supportbundles/supportbundlestest"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/services/user/userimpl"
+)
+
+func TestService_Evaluate(t *testing.T) {
+	service, sql, teamSvc := setupTestEnvironment(t, testOptions)
+
+	orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	// the first user creates the organization and is its admin, the next ones join it
+	_, err = usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "admin", OrgID: 1})
+	require.NoError(t, err)
+	sql.Cfg.AutoAssignOrg = true
+	t.Cleanup(func() { sql.Cfg.AutoAssignOrg = false })
+	usr, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "user", OrgID: 1, DefaultOrgRole: string(org.RoleViewer)})
+	require.NoError(t, err)
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+	require.NoError(t, teamSvc.AddTeamMember(usr.ID, 1, tm.ID, false, 0))
+
+	_, err = service.SetPermissions(context.Background(), 1, "1",
+		accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "Edit"},
+		accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View"},
+	)
+	require.NoError(t, err)
+
+	type testCase struct {
+		desc             string
+		hypothetical     []accesscontrol.SetResourcePermissionCommand
+		action           string
+		expectedGranted  bool
+		expectedEntries  []ExplanationEntry
+		expectedReplaced []ExplanationEntry
+	}
+
+	teamEdit := ExplanationEntry{TeamID: tm.ID, RoleName: accesscontrol.ManagedTeamRoleName(tm.ID), Scope: "dashboards:id:1", Permission: "Edit", IsManaged: true}
+	tests := []testCase{
+		{
+			desc:            "should be granted through the team of the user",
+			action:          "dashboards:write",
+			expectedGranted: true,
+			expectedEntries: []ExplanationEntry{teamEdit},
+		},
+		{
+			desc:             "should not be granted once the team permission the user relies on is removed",
+			hypothetical:     []accesscontrol.SetResourcePermissionCommand{{TeamID: tm.ID, Permission: ""}},
+			action:           "dashboards:write",
+			expectedGranted:  false,
+			expectedReplaced: []ExplanationEntry{teamEdit},
+		},
+		{
+			desc:             "should still be granted through the role of the user once the team permission is removed",
+			hypothetical:     []accesscontrol.SetResourcePermissionCommand{{TeamID: tm.ID, Permission: ""}},
+			action:           "dashboards:read",
+			expectedGranted:  true,
+			expectedEntries:  []ExplanationEntry{{BuiltInRole: "Viewer", RoleName: accesscontrol.ManagedBuiltInRoleName("Viewer"), Scope: "dashboards:id:1", Permission: "View", IsManaged: true}},
+			expectedReplaced: []ExplanationEntry{teamEdit},
+		},
+		{
+			desc:             "should be granted by a hypothetical user permission",
+			hypothetical:     []accesscontrol.SetResourcePermissionCommand{{TeamID: tm.ID, Permission: ""}, {UserID: usr.ID, Permission: "Edit"}},
+			action:           "dashboards:write",
+			expectedGranted:  true,
+			expectedEntries:  []ExplanationEntry{{Hypothetical: true, UserID: usr.ID, RoleName: accesscontrol.ManagedUserRoleName(usr.ID), Scope: "dashboards:id:1", Permission: "Edit", IsManaged: true}},
+			expectedReplaced: []ExplanationEntry{teamEdit},
+		},
+		{
+			desc:             "should not be granted by permissions of other roles",
+			hypothetical:     []accesscontrol.SetResourcePermissionCommand{{TeamID: tm.ID, Permission: ""}, {BuiltinRole: "Editor", Permission: "Edit"}},
+			action:           "dashboards:write",
+			expectedGranted:  false,
+			expectedReplaced: []ExplanationEntry{teamEdit},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			granted, explanation, err := service.Evaluate(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", tt.hypothetical, tt.action)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedGranted, granted)
+			assert.ElementsMatch(t, tt.expectedEntries, explanation.Granted)
+			assert.ElementsMatch(t, tt.expectedReplaced, explanation.Replaced)
+		})
+	}
+
+	t.Run("should reject unknown permissions", func(t *testing.T) {
+		_, _, err := service.Evaluate(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1",
+			[]accesscontrol.SetResourcePermissionCommand{{UserID: usr.ID, Permission: "Unknown"}}, "dashboards:read")
+		assert.ErrorIs(t, err, ErrInvalidPermission)
+	})
+
+	t.Run("should not write the hypothetical permissions", func(t *testing.T) {
+		snapshot, err := service.SnapshotPermissions(context.Background(), 1, "1")
+		require.NoError(t, err)
+		assert.Equal(t, []accesscontrol.SetResourcePermissionCommand{
+			{BuiltinRole: "Viewer", Permission: "View"},
+			{TeamID: tm.ID, Permission: "Edit"},
+		}, snapshot.Permissions)
+	})
+}
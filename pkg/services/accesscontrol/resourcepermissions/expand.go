@@ -0,0 +1,69 @@
+package resourcepermissions
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/auth/identity"
+)
+
+type expandTeamsKey struct{}
+
+// WithExpandTeams returns a context making GetPermissions expand the permissions of teams into permissions of their
+// members, see Service.ExpandTeams
+func WithExpandTeams(ctx context.Context) context.Context {
+	return context.WithValue(ctx, expandTeamsKey{}, true)
+}
+
+func expandTeamsFromContext(ctx context.Context) bool {
+	expand, _ := ctx.Value(expandTeamsKey{}).(bool)
+	return expand
+}
+
+// ExpandTeams returns permissions with, after the permission of each team, a permission derived from it for each
+// member of the team user can read, in user id order. The derived permissions are not managed and identify the team
+// with ViaTeamID and ViaTeam. Users granted a permission directly and through teams get a permission for each of
+// them so that where they are granted it from remains visible.
+//
+// Permissions read a page at a time are expanded once paginated, a page holds the same assignees whether it is
+// expanded or not.
+func (s *Service) ExpandTeams(ctx context.Context, user identity.Requester, permissions []accesscontrol.ResourcePermission) ([]accesscontrol.ResourcePermission, error) {
+	var teamIDs []int64
+	seen := map[int64]struct{}{}
+	for _, p := range permissions {
+		if _, ok := seen[p.TeamId]; ok || p.TeamId == 0 {
+			continue
+		}
+		seen[p.TeamId] = struct{}{}
+		teamIDs = append(teamIDs, p.TeamId)
+	}
+	if len(teamIDs) == 0 {
+		return permissions, nil
+	}
+
+	members, err := s.store.GetTeamsMembers(ctx, user.GetOrgID(), GetTeamsMembersQuery{
+		TeamIDs:              teamIDs,
+		User:                 user,
+		EnforceAccessControl: s.license.FeatureEnabled("accesscontrol.enforcement"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := make([]accesscontrol.ResourcePermission, 0, len(permissions))
+	for _, p := range permissions {
+		expanded = append(expanded, p)
+		for _, m := range members[p.TeamId] {
+			derived := p
+			derived.ID = 0
+			derived.RoleName = ""
+			derived.TeamId, derived.Team, derived.TeamEmail = 0, "", ""
+			derived.UserId, derived.UserLogin, derived.UserEmail = m.UserID, m.Login, m.Email
+			derived.IsServiceAccount = m.IsServiceAccount
+			derived.IsManaged = false
+			derived.ViaTeamID, derived.ViaTeam = p.TeamId, p.Team
+			expanded = append(expanded, derived)
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,147 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_ExpandTeams(t *testing.T) {
+	service, sql, teamSvc := setupTestEnvironment(t, testOptions)
+	first := createOwnerTestUser(t, sql, "first", false)
+	second := createOwnerTestUser(t, sql, "second", false)
+	editors, err := teamSvc.CreateTeam("editors", "", 1)
+	require.NoError(t, err)
+	viewers, err := teamSvc.CreateTeam("viewers", "", 1)
+	require.NoError(t, err)
+	require.NoError(t, teamSvc.AddTeamMember(second.ID, 1, editors.ID, false, 0))
+	require.NoError(t, teamSvc.AddTeamMember(first.ID, 1, editors.ID, false, 0))
+	require.NoError(t, teamSvc.AddTeamMember(first.ID, 1, viewers.ID, false, 0))
+
+	_, err = service.SetPermissions(context.Background(), 1, "1",
+		accesscontrol.SetResourcePermissionCommand{TeamID: editors.ID, Permission: "Edit"},
+		accesscontrol.SetResourcePermissionCommand{TeamID: viewers.ID, Permission: "View"},
+		accesscontrol.SetResourcePermissionCommand{UserID: first.ID, Permission: "View"},
+	)
+	require.NoError(t, err)
+
+	signedIn := &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {
+		accesscontrol.ActionTeamsRead:    {accesscontrol.ScopeTeamsAll},
+		accesscontrol.ActionOrgUsersRead: {accesscontrol.ScopeUsersAll},
+	}}}
+
+	// describe returns the assignee of each permission, the members of a team are prefixed with the team
+	describe := func(permissions []accesscontrol.ResourcePermission) []string {
+		described := make([]string, 0, len(permissions))
+		for _, p := range permissions {
+			switch {
+			case p.ViaTeamID != 0:
+				assert.False(t, p.IsManaged)
+				assert.Zero(t, p.TeamId)
+				described = append(described, p.ViaTeam+"/"+p.UserLogin+":"+service.MapActions(p))
+			case p.TeamId != 0:
+				described = append(described, p.Team+":"+service.MapActions(p))
+			default:
+				described = append(described, p.UserLogin+":"+service.MapActions(p))
+			}
+		}
+		return described
+	}
+
+	t.Run("should not expand teams by default", func(t *testing.T) {
+		permissions, err := service.GetPermissions(context.Background(), signedIn, "1")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"editors:Edit", "viewers:View", "first:View"}, describe(permissions))
+	})
+
+	t.Run("should follow each team with its members without merging the users", func(t *testing.T) {
+		permissions, err := service.GetPermissions(WithExpandTeams(context.Background()), signedIn, "1")
+		require.NoError(t, err)
+		described := describe(permissions)
+		require.Len(t, described, 6)
+		assert.Contains(t, described, "first:View")
+		assert.Subset(t, described, []string{"editors:Edit", "editors/first:Edit", "editors/second:Edit", "viewers:View", "viewers/first:View"})
+
+		for i, d := range described {
+			switch d {
+			case "editors:Edit":
+				assert.Equal(t, []string{"editors/first:Edit", "editors/second:Edit"}, described[i+1:i+3], "members follow their team in id order")
+			case "viewers:View":
+				assert.Equal(t, "viewers/first:View", described[i+1])
+			}
+		}
+	})
+
+	t.Run("should only expand into the users the signed in user can read", func(t *testing.T) {
+		restricted := &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {
+			accesscontrol.ActionTeamsRead:    {accesscontrol.ScopeTeamsAll},
+			accesscontrol.ActionOrgUsersRead: {accesscontrol.Scope("users", "id", strconv.FormatInt(second.ID, 10))},
+		}}}
+		permissions, err := service.GetPermissions(WithExpandTeams(context.Background()), restricted, "1")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"editors:Edit", "editors/second:Edit", "viewers:View"}, describe(permissions))
+	})
+
+	t.Run("should expand a page once paginated", func(t *testing.T) {
+		page, err := service.store.GetResourcePermissionsPage(context.Background(), 1, GetResourcePermissionsQuery{
+			User:              signedIn,
+			Actions:           service.getLevels().actions,
+			Resource:          "dashboards",
+			ResourceID:        "1",
+			ResourceAttribute: "id",
+			OnlyManaged:       true,
+		}, ResourcePermissionsQueryOptions{Kind: AssigneeKindTeam, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, page.Permissions, 1)
+		assert.Equal(t, int64(2), page.TotalCount, "the total counts the assignees before the expansion")
+
+		expanded, err := service.ExpandTeams(context.Background(), signedIn, page.Permissions)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"editors:Edit", "editors/first:Edit", "editors/second:Edit"}, describe(expanded))
+	})
+}
+
+func TestApi_getPermissionsExpandTeams(t *testing.T) {
+	service, sql, teamSvc := setupTestEnvironment(t, testOptions)
+	member := createOwnerTestUser(t, sql, "member", false)
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+	require.NoError(t, teamSvc.AddTeamMember(member.ID, 1, tm.ID, false, 0))
+	_, err = service.SetTeamPermission(context.Background(), 1, tm.ID, "1", "View")
+	require.NoError(t, err)
+
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: accesscontrol.ActionTeamsRead, Scope: accesscontrol.ScopeTeamsAll},
+		{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+	})}}, service)
+
+	permissions, recorder := getPermission(t, server, "dashboards", "1")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, permissions, 1)
+
+	req, err := http.NewRequest(http.MethodGet, "/api/access-control/dashboards/1?expandTeams=true", nil)
+	require.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&permissions))
+
+	require.Len(t, permissions, 2)
+	assert.Equal(t, tm.ID, permissions[0].TeamID)
+	assert.True(t, permissions[0].IsManaged)
+	assert.Equal(t, member.ID, permissions[1].UserID)
+	assert.Equal(t, tm.ID, permissions[1].ViaTeamID)
+	assert.Equal(t, "team", permissions[1].ViaTeam)
+	assert.Equal(t, "View", permissions[1].Permission)
+	assert.False(t, permissions[1].IsManaged)
+}
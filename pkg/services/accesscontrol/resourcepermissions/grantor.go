@@ -0,0 +1,20 @@
+package resourcepermissions
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/infra/appcontext"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+// grantor returns the grantor recorded for the permissions written with ctx: the one set with
+// accesscontrol.WithGrantor, else the signed in user, else the Grafana service
+func grantor(ctx context.Context) int64 {
+	if grantedBy, ok := accesscontrol.GrantorFromContext(ctx); ok {
+		return grantedBy
+	}
+	if usr, err := appcontext.User(ctx); err == nil && usr.UserID > 0 {
+		return usr.UserID
+	}
+	return accesscontrol.GrantorService
+}
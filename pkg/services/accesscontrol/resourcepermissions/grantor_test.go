@@ -0,0 +1,168 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/appcontext"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+// retrieveGrantorsHelper returns the grantor of each managed role by scope
+func retrieveGrantorsHelper(t *testing.T, store db.DB) map[string]int64 {
+	t.Helper()
+	var rows []struct {
+		Name      string
+		Scope     string
+		GrantedBy int64
+	}
+	err := store.WithDbSession(context.Background(), func(sess *db.Session) error {
+		return sess.SQL("SELECT DISTINCT r.name, p.scope, p.granted_by FROM permission p INNER JOIN role r ON r.id = p.role_id WHERE r.name LIKE 'managed:%'").Find(&rows)
+	})
+	require.NoError(t, err)
+
+	result := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		key := r.Name + " " + r.Scope
+		_, ok := result[key]
+		require.False(t, ok, "permissions of %s have several grantors", key)
+		result[key] = r.GrantedBy
+	}
+	return result
+}
+
+func TestService_Grantor(t *testing.T) {
+	options := testOptions
+	options.EnableOwner = true
+	options.DefaultPermissions = []DefaultPermission{
+		{BuiltInRole: "Viewer", Permission: "View"},
+		{BuiltInRole: "Editor", Permission: "Edit"},
+	}
+	service, sql, _ := setupTestEnvironment(t, options)
+	granter := createOwnerTestUser(t, sql, "granter", false)
+	other := createOwnerTestUser(t, sql, "other", false)
+	usr := createOwnerTestUser(t, sql, "user", false)
+
+	signedIn := func(u *user.User) context.Context {
+		return appcontext.WithUser(context.Background(), &user.SignedInUser{UserID: u.ID, OrgID: 1, Login: u.Login})
+	}
+	userRole := accesscontrol.ManagedUserRoleName(usr.ID)
+	viewerRole := accesscontrol.ManagedBuiltInRoleName("Viewer")
+
+	t.Run("should record the signed in user as the grantor", func(t *testing.T) {
+		_, err := service.SetUserPermission(signedIn(granter), 1, accesscontrol.User{ID: usr.ID}, "1", "View")
+		require.NoError(t, err)
+		assert.Equal(t, granter.ID, retrieveGrantorsHelper(t, sql)[userRole+" dashboards:id:1"])
+	})
+
+	t.Run("should keep the grantor of an unchanged permission", func(t *testing.T) {
+		_, err := service.SetUserPermission(signedIn(other), 1, accesscontrol.User{ID: usr.ID}, "1", "View")
+		require.NoError(t, err)
+		assert.Equal(t, granter.ID, retrieveGrantorsHelper(t, sql)[userRole+" dashboards:id:1"])
+
+		_, err = service.SetUserPermission(signedIn(other), 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+		require.NoError(t, err)
+		assert.Equal(t, other.ID, retrieveGrantorsHelper(t, sql)[userRole+" dashboards:id:1"])
+	})
+
+	t.Run("should record the service without a signed in user", func(t *testing.T) {
+		_, err := service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+		require.NoError(t, err)
+		assert.Equal(t, accesscontrol.GrantorService, retrieveGrantorsHelper(t, sql)[viewerRole+" dashboards:id:1"])
+	})
+
+	t.Run("should record the grantor of the context", func(t *testing.T) {
+		ctx := accesscontrol.WithGrantor(signedIn(granter), accesscontrol.GrantorProvisioning)
+		_, err := service.SetDefaultPermissions(ctx, 1, "2", nil)
+		require.NoError(t, err)
+		grantors := retrieveGrantorsHelper(t, sql)
+		assert.Equal(t, accesscontrol.GrantorProvisioning, grantors[viewerRole+" dashboards:id:2"])
+		assert.Equal(t, accesscontrol.GrantorProvisioning, grantors[accesscontrol.ManagedBuiltInRoleName("Editor")+" dashboards:id:2"])
+	})
+
+	t.Run("should record the grantor of bulk writes", func(t *testing.T) {
+		_, err := service.SetPermissions(signedIn(granter), 1, "3",
+			accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "Edit"},
+			accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View"},
+		)
+		require.NoError(t, err)
+		_, err = service.SetPermissions(signedIn(other), 1, "3",
+			accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "Edit"},
+			accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "Edit"},
+		)
+		require.NoError(t, err)
+
+		grantors := retrieveGrantorsHelper(t, sql)
+		assert.Equal(t, granter.ID, grantors[userRole+" dashboards:id:3"])
+		assert.Equal(t, other.ID, grantors[viewerRole+" dashboards:id:3"])
+	})
+
+	t.Run("should record the grantor of writes on several resources", func(t *testing.T) {
+		_, err := service.SetUserPermissionForResources(signedIn(granter), 1, accesscontrol.User{ID: usr.ID}, []string{"4", "5"}, "View")
+		require.NoError(t, err)
+		grantors := retrieveGrantorsHelper(t, sql)
+		assert.Equal(t, granter.ID, grantors[userRole+" dashboards:id:4"])
+		assert.Equal(t, granter.ID, grantors[userRole+" dashboards:id:5"])
+	})
+
+	t.Run("should record the grantor of the owner permission", func(t *testing.T) {
+		require.NoError(t, service.SetOwner(signedIn(other), 1, "6", accesscontrol.User{ID: granter.ID}))
+		assert.Equal(t, other.ID, retrieveGrantorsHelper(t, sql)[accesscontrol.ManagedUserRoleName(granter.ID)+" dashboards:id:6"])
+	})
+}
+
+func TestApi_getPermissions_expandGrantedBy(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, testOptions)
+	granter := createOwnerTestUser(t, sql, "granter", false)
+	usr := createOwnerTestUser(t, sql, "user", false)
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+	})}}, service)
+
+	ctx := appcontext.WithUser(context.Background(), &user.SignedInUser{UserID: granter.ID, OrgID: 1})
+	_, err := service.SetUserPermission(ctx, 1, accesscontrol.User{ID: usr.ID}, "1", "View")
+	require.NoError(t, err)
+	_, err = service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+	require.NoError(t, err)
+
+	get := func(url string) []resourcePermissionDTO {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var permissions []resourcePermissionDTO
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&permissions))
+		return permissions
+	}
+
+	t.Run("should not return the grantors by default", func(t *testing.T) {
+		for _, p := range get("/api/access-control/dashboards/1") {
+			assert.Zero(t, p.GrantedByID)
+			assert.Empty(t, p.GrantedByLogin)
+		}
+	})
+
+	t.Run("should return the grantors with expand", func(t *testing.T) {
+		grantors := map[string]string{}
+		for _, p := range get("/api/access-control/dashboards/1?expand=grantedBy") {
+			grantors[p.RoleName] = p.GrantedByLogin
+			if p.UserID == usr.ID {
+				assert.Equal(t, granter.ID, p.GrantedByID)
+			}
+		}
+		assert.Equal(t, map[string]string{
+			accesscontrol.ManagedUserRoleName(usr.ID):      "granter",
+			accesscontrol.ManagedBuiltInRoleName("Viewer"): "grafana",
+		}, grantors)
+	})
+}
@@ -1,6 +1,8 @@
 package resourcepermissions
 
 import (
+	"context"
+
 	"github.com/grafana/grafana/pkg/infra/db"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
 )
@@ -9,13 +11,124 @@ type ResourceHooks struct {
 	User        UserResourceHookFunc
 	Team        TeamResourceHookFunc
 	BuiltInRole BuiltinResourceHookFunc
+	// UserRemoved, TeamRemoved and BuiltInRoleRemoved are called by DeleteResourcePermissions for each assignee
+	// whose managed permissions on the resource are deleted
+	UserRemoved        UserResourceRemovedHookFunc
+	TeamRemoved        TeamResourceRemovedHookFunc
+	BuiltInRoleRemoved BuiltinResourceRemovedHookFunc
 }
 
 type UserResourceHookFunc func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error
 type TeamResourceHookFunc func(session *db.Session, orgID, teamID int64, resourceID, permission string) error
 type BuiltinResourceHookFunc func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error
+type UserResourceRemovedHookFunc func(session *db.Session, orgID int64, user accesscontrol.User, resourceID string) error
+type TeamResourceRemovedHookFunc func(session *db.Session, orgID, teamID int64, resourceID string) error
+type BuiltinResourceRemovedHookFunc func(session *db.Session, orgID int64, builtInRole, resourceID string) error
+type BulkResourceHookFunc func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error
+type AssignmentRoleBinderFunc func(session *db.Session, orgID int64, assigneeID string, roleID int64) error
 
 type User struct {
 	ID         int64
 	IsExternal bool
 }
+
+// the names of the hooks of Options in the hook metrics and errors
+const (
+	hookSetUser            = "set_user"
+	hookSetTeam            = "set_team"
+	hookSetBuiltInRole     = "set_builtin_role"
+	hookUserRemoved        = "user_removed"
+	hookTeamRemoved        = "team_removed"
+	hookBuiltInRoleRemoved = "builtin_role_removed"
+)
+
+// hookError counts the failure of a hook called in the transaction of a write and wraps its error in a HookError, it
+// returns nil when err is nil
+func (s *Service) hookError(hook string, err error) error {
+	if err == nil {
+		return nil
+	}
+	s.metrics.hookFailures.WithLabelValues(s.options.Resource, hook).Inc()
+	return &HookError{Hook: hook, Err: err}
+}
+
+// userHook returns the hook called when the permission of a user is written, it calls set and, when the permission
+// is removed, OnUserPermissionRemoved
+func (s *Service) userHook(set UserResourceHookFunc) UserResourceHookFunc {
+	removed := s.options.OnUserPermissionRemoved
+	if set == nil && removed == nil {
+		return nil
+	}
+	return func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+		if set != nil {
+			if err := set(session, orgID, user, resourceID, permission); err != nil {
+				return s.hookError(hookSetUser, err)
+			}
+		}
+		if permission == "" && removed != nil {
+			return s.hookError(hookUserRemoved, removed(session, orgID, user, resourceID))
+		}
+		return nil
+	}
+}
+
+// teamHook returns the hook called when the permission of a team is written, it calls set and, when the permission
+// is removed, OnTeamPermissionRemoved
+func (s *Service) teamHook(set TeamResourceHookFunc) TeamResourceHookFunc {
+	removed := s.options.OnTeamPermissionRemoved
+	if set == nil && removed == nil {
+		return nil
+	}
+	return func(session *db.Session, orgID, teamID int64, resourceID, permission string) error {
+		if set != nil {
+			if err := set(session, orgID, teamID, resourceID, permission); err != nil {
+				return s.hookError(hookSetTeam, err)
+			}
+		}
+		if permission == "" && removed != nil {
+			return s.hookError(hookTeamRemoved, removed(session, orgID, teamID, resourceID))
+		}
+		return nil
+	}
+}
+
+// builtInRoleHook returns the hook called when the permission of a built-in role is written, it calls set and, when
+// the permission is removed, OnBuiltInRolePermissionRemoved
+func (s *Service) builtInRoleHook(set BuiltinResourceHookFunc) BuiltinResourceHookFunc {
+	removed := s.options.OnBuiltInRolePermissionRemoved
+	if set == nil && removed == nil {
+		return nil
+	}
+	return func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error {
+		if set != nil {
+			if err := set(session, orgID, builtInRole, resourceID, permission); err != nil {
+				return s.hookError(hookSetBuiltInRole, err)
+			}
+		}
+		if permission == "" && removed != nil {
+			return s.hookError(hookBuiltInRoleRemoved, removed(session, orgID, builtInRole, resourceID))
+		}
+		return nil
+	}
+}
+
+// removedHooks returns the hooks called when the permissions of a resource are deleted
+func (s *Service) removedHooks() ResourceHooks {
+	var hooks ResourceHooks
+	if removed := s.options.OnUserPermissionRemoved; removed != nil {
+		hooks.UserRemoved = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID string) error {
+			return s.hookError(hookUserRemoved, removed(session, orgID, user, resourceID))
+		}
+	}
+	if removed := s.options.OnTeamPermissionRemoved; removed != nil {
+		hooks.TeamRemoved = func(session *db.Session, orgID, teamID int64, resourceID string) error {
+			return s.hookError(hookTeamRemoved, removed(session, orgID, teamID, resourceID))
+		}
+	}
+	if removed := s.options.OnBuiltInRolePermissionRemoved; removed != nil {
+		hooks.BuiltInRoleRemoved = func(session *db.Session, orgID int64, builtInRole, resourceID string) error {
+			return s.hookError(hookBuiltInRoleRemoved, removed(session, orgID, builtInRole, resourceID))
+		}
+	}
+	return hooks
+}
@@ -0,0 +1,365 @@
+package resourcepermissions
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+const (
+	defaultHookQueueSize    = 1000
+	defaultHookWorkers      = 4
+	defaultHookDrainTimeout = 30 * time.Second
+	defaultHookBackoff      = 100 * time.Millisecond
+	defaultHookMaxBackoff   = 5 * time.Second
+)
+
+// hookBulkSet is the name of OnBulkSet in the hook metrics
+const hookBulkSet = "bulk_set"
+
+// AsyncHooks configures the asynchronous execution of the hooks called once the permissions are committed, that is
+// OnBulkSet, OnSetUser, OnSetTeam and OnSetBuiltInRole. The set hooks are then called after the write, each in a
+// transaction of its own, and their errors no longer roll the write back. The removal hooks, such as
+// OnUserPermissionRemoved, are always called in the transaction of the write.
+type AsyncHooks struct {
+	// QueueSize is the number of hook calls that can wait for a worker, defaultHookQueueSize when not configured
+	QueueSize int
+	// Workers is the number of goroutines calling the hooks, defaultHookWorkers when not configured. The calls for
+	// the same resource are made by the same worker, in the order they were queued
+	Workers int
+	// Overflow decides what happens to a hook call when the queue is full, HookOverflowBlock when not configured
+	Overflow HookOverflowPolicy
+	// DrainTimeout is how long Service.Run waits for the queued hook calls when it stops, defaultHookDrainTimeout
+	// when not configured
+	DrainTimeout time.Duration
+}
+
+// HookRetry configures the retries of the hooks called once the permissions are committed, that is OnBulkSet and the
+// asynchronous set hooks
+type HookRetry struct {
+	// Retries is the number of times a failed hook is called again
+	Retries int
+	// Backoff is how long the first retry waits, defaultHookBackoff when not configured. The wait doubles with each
+	// retry up to MaxBackoff
+	Backoff time.Duration
+	// MaxBackoff is the longest wait between two retries, defaultHookMaxBackoff when not configured
+	MaxBackoff time.Duration
+}
+
+// HookOverflowPolicy is what happens to an asynchronous hook call when the queue is full
+type HookOverflowPolicy string
+
+const (
+	// HookOverflowBlock makes the write wait until there is room in the queue
+	HookOverflowBlock HookOverflowPolicy = "block"
+	// HookOverflowDrop drops the hook call, it is logged and counted in the dropped hooks metric
+	HookOverflowDrop HookOverflowPolicy = "drop"
+)
+
+// hookCall is a call of an after-commit hook for a resource
+type hookCall struct {
+	ctx        context.Context
+	hook       string
+	orgID      int64
+	resourceID string
+	// assignees describes the assignees of the permissions the hook is called for, they are logged when it fails
+	assignees []string
+	fn        func(ctx context.Context) error
+}
+
+// hookQueue calls the after-commit hooks of a service with a pool of workers. Each worker drains its own part of
+// the queue, the calls are assigned to a worker by resource so that the calls for a resource are made in order.
+type hookQueue struct {
+	overflow HookOverflowPolicy
+	call     func(c hookCall)
+	dropped  func(c hookCall)
+
+	// mu guards stopped and the queues, which are closed when the queue is stopped
+	mu      sync.RWMutex
+	stopped bool
+	queues  []chan hookCall
+	done    sync.WaitGroup
+}
+
+// newHookQueue starts the workers of the queue, call is called by the workers for each queued call and dropped for
+// each call dropped because of an overflow
+func newHookQueue(options AsyncHooks, call, dropped func(c hookCall)) *hookQueue {
+	size, workers := options.QueueSize, options.Workers
+	if size <= 0 {
+		size = defaultHookQueueSize
+	}
+	if workers <= 0 {
+		workers = defaultHookWorkers
+	}
+	if workers > size {
+		workers = size
+	}
+
+	q := &hookQueue{overflow: options.Overflow, call: call, dropped: dropped, queues: make([]chan hookCall, workers)}
+	for i := range q.queues {
+		// the capacity of the queue is shared between the workers
+		q.queues[i] = make(chan hookCall, (size+workers-1)/workers)
+		q.done.Add(1)
+		go q.work(q.queues[i])
+	}
+	return q
+}
+
+func (q *hookQueue) work(queue chan hookCall) {
+	defer q.done.Done()
+	for c := range queue {
+		q.call(c)
+	}
+}
+
+// enqueue queues a hook call, it returns false without queueing it once the queue is stopped. With
+// HookOverflowBlock it waits for room in the queue until ctx is done.
+func (q *hookQueue) enqueue(ctx context.Context, c hookCall) (bool, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.stopped {
+		return false, nil
+	}
+
+	// the hook is called after the request, it keeps the values of its context but not its deadline
+	c.ctx = context.WithoutCancel(ctx)
+	queue := q.queues[q.worker(c.orgID, c.resourceID)]
+	if q.overflow == HookOverflowDrop {
+		select {
+		case queue <- c:
+		default:
+			q.dropped(c)
+		}
+		return true, nil
+	}
+
+	select {
+	case queue <- c:
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+func (q *hookQueue) worker(orgID int64, resourceID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strconv.FormatInt(orgID, 10) + ":" + resourceID))
+	return int(h.Sum32() % uint32(len(q.queues)))
+}
+
+// stop stops queueing hook calls and waits until the queued calls are made or ctx is done
+func (q *hookQueue) stop(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.stopped {
+		q.stopped = true
+		for _, queue := range q.queues {
+			close(queue)
+		}
+	}
+	q.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		q.done.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// callHook calls a hook, retrying it when it fails with Options.HookRetry, and records the duration and outcome of
+// each call. The last error is logged.
+func (s *Service) callHook(c hookCall) {
+	var retry HookRetry
+	if s.options.HookRetry != nil {
+		retry = *s.options.HookRetry
+	}
+	backoff, maxBackoff := retry.Backoff, retry.MaxBackoff
+	if backoff <= 0 {
+		backoff = defaultHookBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultHookMaxBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err := c.fn(c.ctx)
+		s.metrics.observeHook(s.options.Resource, c.hook, start, err)
+		if err == nil {
+			return
+		}
+
+		if attempt >= retry.Retries || !sleep(c.ctx, min(backoff, maxBackoff)) {
+			s.hookFailed(c, attempt+1, err)
+			return
+		}
+		s.metrics.hookRetries.WithLabelValues(s.options.Resource, c.hook).Inc()
+		backoff *= 2
+	}
+}
+
+// sleep waits for d, it returns false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *Service) hookFailed(c hookCall, attempts int, err error) {
+	s.metrics.hookFailures.WithLabelValues(s.options.Resource, c.hook).Inc()
+	s.log.Error("Failed to call hook", "resource", s.options.Resource, "hook", c.hook, "orgID", c.orgID,
+		"resourceID", c.resourceID, "assignees", c.assignees, "attempts", attempts, "error", err)
+}
+
+// runAfterCommitHook calls a hook once the permissions of a resource are committed, synchronously or through the
+// queue of asynchronous hooks when Options.AsyncHooks is configured. The permissions are committed, the errors of the
+// hook are logged but never returned. Within Service.inTransaction the call waits for the transaction to commit.
+func (s *Service) runAfterCommitHook(ctx context.Context, hook string, orgID int64, resourceID string, assignees []string, fn func(ctx context.Context) error) {
+	c := hookCall{ctx: ctx, hook: hook, orgID: orgID, resourceID: resourceID, assignees: assignees, fn: fn}
+	if deferred, ok := ctx.Value(afterCommitKey{}).(*[]hookCall); ok {
+		*deferred = append(*deferred, c)
+		return
+	}
+	s.runHookCall(c)
+}
+
+// runHookCall queues c when the hooks are asynchronous and the queue is not stopped, otherwise it calls it
+func (s *Service) runHookCall(c hookCall) {
+	if s.hooks != nil {
+		queued, err := s.hooks.enqueue(c.ctx, c)
+		if err != nil {
+			s.hookFailed(c, 0, err)
+			return
+		}
+		if queued {
+			return
+		}
+	}
+	s.callHook(c)
+}
+
+// afterCommitKey is the context key of the after-commit hooks deferred until the transaction of Service.inTransaction
+// commits
+type afterCommitKey struct{}
+
+// inTransaction calls fn in a transaction placed on its context, the reads and writes of the store made with that
+// context share it. The after-commit hooks of the writes are called once the transaction commits, or never when it is
+// rolled back.
+func (s *Service) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(afterCommitKey{}).(*[]hookCall); ok {
+		return s.sql.InTransaction(ctx, fn)
+	}
+
+	var deferred []hookCall
+	err := s.sql.InTransaction(ctx, func(ctx context.Context) error {
+		// the transaction is started again when sqlite is locked
+		deferred = deferred[:0]
+		return fn(context.WithValue(ctx, afterCommitKey{}, &deferred))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range deferred {
+		// the hooks are called with their own transactions
+		c.ctx = ctx
+		s.runHookCall(c)
+	}
+	return nil
+}
+
+// StopHooks stops queueing the asynchronous hooks and waits until the queued ones are called or ctx is done. The hooks
+// are called synchronously afterwards. Service.Run calls it when it stops.
+func (s *Service) StopHooks(ctx context.Context) error {
+	if s.hooks == nil {
+		return nil
+	}
+	return s.hooks.stop(ctx)
+}
+
+// setUserHook returns OnSetUser to call in the transaction of a write, nil when it is called from the queue of
+// asynchronous hooks by queueSetUserHook
+func (s *Service) setUserHook() UserResourceHookFunc {
+	if s.hooks != nil {
+		return nil
+	}
+	return s.options.OnSetUser
+}
+
+// setTeamHook returns OnSetTeam to call in the transaction of a write, nil when it is called from the queue of
+// asynchronous hooks by queueSetTeamHook
+func (s *Service) setTeamHook() TeamResourceHookFunc {
+	if s.hooks != nil {
+		return nil
+	}
+	return s.options.OnSetTeam
+}
+
+// setBuiltInRoleHook returns OnSetBuiltInRole to call in the transaction of a write, nil when it is called from the
+// queue of asynchronous hooks by queueSetBuiltInRoleHook
+func (s *Service) setBuiltInRoleHook() BuiltinResourceHookFunc {
+	if s.hooks != nil {
+		return nil
+	}
+	return s.options.OnSetBuiltInRole
+}
+
+// queueSetUserHook queues OnSetUser once the permission of a user is committed when the hooks are asynchronous
+func (s *Service) queueSetUserHook(ctx context.Context, orgID int64, user accesscontrol.User, resourceID, permission string) {
+	if s.hooks == nil || s.options.OnSetUser == nil {
+		return
+	}
+	s.runAfterCommitHook(ctx, hookSetUser, orgID, resourceID, []string{"user:" + strconv.FormatInt(user.ID, 10)}, func(ctx context.Context) error {
+		return s.sql.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+			return s.options.OnSetUser(sess, orgID, user, resourceID, permission)
+		})
+	})
+}
+
+// queueSetTeamHook queues OnSetTeam once the permission of a team is committed when the hooks are asynchronous
+func (s *Service) queueSetTeamHook(ctx context.Context, orgID, teamID int64, resourceID, permission string) {
+	if s.hooks == nil || s.options.OnSetTeam == nil {
+		return
+	}
+	s.runAfterCommitHook(ctx, hookSetTeam, orgID, resourceID, []string{"team:" + strconv.FormatInt(teamID, 10)}, func(ctx context.Context) error {
+		return s.sql.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+			return s.options.OnSetTeam(sess, orgID, teamID, resourceID, permission)
+		})
+	})
+}
+
+// queueSetBuiltInRoleHook queues OnSetBuiltInRole once the permission of a built-in role is committed when the hooks
+// are asynchronous
+func (s *Service) queueSetBuiltInRoleHook(ctx context.Context, orgID int64, builtInRole, resourceID, permission string) {
+	if s.hooks == nil || s.options.OnSetBuiltInRole == nil {
+		return
+	}
+	s.runAfterCommitHook(ctx, hookSetBuiltInRole, orgID, resourceID, []string{"builtInRole:" + builtInRole}, func(ctx context.Context) error {
+		return s.sql.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+			return s.options.OnSetBuiltInRole(sess, orgID, builtInRole, resourceID, permission)
+		})
+	})
+}
+
+func (s *Service) newHookQueue(options AsyncHooks) *hookQueue {
+	return newHookQueue(options, s.callHook, func(c hookCall) {
+		s.metrics.droppedHooks.WithLabelValues(s.options.Resource, c.hook).Inc()
+		s.log.Warn("Dropped hook, the queue is full", "resource", s.options.Resource, "hook", c.hook, "orgID", c.orgID, "resourceID", c.resourceID)
+	})
+}
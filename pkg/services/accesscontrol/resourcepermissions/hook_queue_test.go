@@ -0,0 +1,344 @@
+package resourcepermissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_AsyncHooks(t *testing.T) {
+	setPermissions := func(t *testing.T, service *Service, resourceID string) {
+		t.Helper()
+		_, err := service.SetPermissions(context.Background(), 1, resourceID, accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View"})
+		require.NoError(t, err)
+	}
+
+	t.Run("should not fail the write when a synchronous hook fails", func(t *testing.T) {
+		options := testOptions
+		options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+			return errors.New("hook failed")
+		}
+		service, _, _ := setupTestEnvironment(t, options)
+
+		setPermissions(t, service, "1")
+		assert.Equal(t, 1, testutil.CollectAndCount(service.metrics.hookDuration))
+		assert.Equal(t, float64(1), testutil.ToFloat64(service.metrics.hookFailures.WithLabelValues("dashboards", hookBulkSet)))
+	})
+
+	t.Run("should call the hooks of a resource in order", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := map[string][]int{}
+		options := testOptions
+		options.AsyncHooks = &AsyncHooks{Workers: 3}
+		options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[resourceID] = append(calls[resourceID], len(calls[resourceID]))
+			return nil
+		}
+		service, _, _ := setupTestEnvironment(t, options)
+
+		resources := []string{"1", "2", "3", "4"}
+		for i := 0; i < 10; i++ {
+			for _, resourceID := range resources {
+				setPermissions(t, service, resourceID)
+			}
+		}
+		require.NoError(t, service.StopHooks(context.Background()))
+
+		// each call records how many calls of its resource were made before it
+		expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+		for _, resourceID := range resources {
+			assert.Equal(t, expected, calls[resourceID])
+		}
+	})
+
+	t.Run("should wait for the queued hooks when stopping", func(t *testing.T) {
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var called []string
+		options := testOptions
+		options.AsyncHooks = &AsyncHooks{Workers: 1}
+		options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+			<-release
+			mu.Lock()
+			defer mu.Unlock()
+			called = append(called, resourceID)
+			return nil
+		}
+		service, _, _ := setupTestEnvironment(t, options)
+
+		setPermissions(t, service, "1")
+		setPermissions(t, service, "2")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, service.StopHooks(ctx), context.DeadlineExceeded, "the hooks are still blocked")
+
+		close(release)
+		require.NoError(t, service.StopHooks(context.Background()))
+		assert.Equal(t, []string{"1", "2"}, called)
+
+		setPermissions(t, service, "3")
+		assert.Equal(t, []string{"1", "2", "3"}, called, "hooks should be called synchronously once stopped")
+	})
+
+	t.Run("should drain the hooks when Run stops", func(t *testing.T) {
+		release := make(chan struct{})
+		called := make(chan string, 1)
+		options := testOptions
+		options.AsyncHooks = &AsyncHooks{}
+		options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+			<-release
+			called <- resourceID
+			return nil
+		}
+		service, _, _ := setupTestEnvironment(t, options)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error)
+		go func() { stopped <- service.Run(ctx) }()
+
+		setPermissions(t, service, "1")
+		cancel()
+		close(release)
+		assert.ErrorIs(t, <-stopped, context.Canceled)
+		select {
+		case resourceID := <-called:
+			assert.Equal(t, "1", resourceID)
+		default:
+			t.Fatal("Run should wait for the queued hooks")
+		}
+	})
+
+	t.Run("should drop the hooks when the queue is full", func(t *testing.T) {
+		release := make(chan struct{})
+		options := testOptions
+		options.AsyncHooks = &AsyncHooks{QueueSize: 1, Overflow: HookOverflowDrop}
+		options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+			<-release
+			return nil
+		}
+		service, _, _ := setupTestEnvironment(t, options)
+
+		// the first call blocks the worker, the second one fills the queue
+		for i := 0; i < 4; i++ {
+			setPermissions(t, service, "1")
+		}
+		close(release)
+		require.NoError(t, service.StopHooks(context.Background()))
+
+		dropped := testutil.ToFloat64(service.metrics.droppedHooks.WithLabelValues(testOptions.Resource, hookBulkSet))
+		assert.GreaterOrEqual(t, dropped, float64(2))
+		assert.LessOrEqual(t, dropped, float64(3))
+	})
+
+	t.Run("should block the writes when the queue is full", func(t *testing.T) {
+		release := make(chan struct{})
+		options := testOptions
+		options.AsyncHooks = &AsyncHooks{QueueSize: 1}
+		options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+			<-release
+			return nil
+		}
+		service, _, _ := setupTestEnvironment(t, options)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		// the third write waits for room in the queue until its context is done, the permissions are committed and the
+		// hook call is counted as failed
+		for i := 0; i < 3; i++ {
+			_, err := service.SetPermissions(ctx, 1, "1", accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View"})
+			require.NoError(t, err)
+		}
+		assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+		assert.Equal(t, float64(1), testutil.ToFloat64(service.metrics.hookFailures.WithLabelValues(testOptions.Resource, hookBulkSet)))
+
+		close(release)
+		require.NoError(t, service.StopHooks(context.Background()))
+		assert.Zero(t, testutil.ToFloat64(service.metrics.droppedHooks.WithLabelValues(testOptions.Resource, hookBulkSet)))
+	})
+
+	t.Run("should queue the set hooks", func(t *testing.T) {
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var called []string
+		record := func(call string) error {
+			<-release
+			mu.Lock()
+			defer mu.Unlock()
+			called = append(called, call)
+			return nil
+		}
+		options := testOptions
+		options.AsyncHooks = &AsyncHooks{Workers: 1}
+		options.OnSetUser = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+			return record(fmt.Sprintf("user %s %s", resourceID, permission))
+		}
+		options.OnSetTeam = func(session *db.Session, orgID, teamID int64, resourceID, permission string) error {
+			return record(fmt.Sprintf("team %s %s", resourceID, permission))
+		}
+		options.OnSetBuiltInRole = func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error {
+			return errors.Join(record(fmt.Sprintf("%s %s %s", builtInRole, resourceID, permission)), errors.New("hook failed"))
+		}
+		service, sql, teamSvc := setupTestEnvironment(t, options)
+		usr := createOwnerTestUser(t, sql, "user", false)
+		tm, err := teamSvc.CreateTeam("team", "", 1)
+		require.NoError(t, err)
+
+		// the hooks are blocked, the writes return without waiting for them
+		ctx := context.Background()
+		_, err = service.SetUserPermission(ctx, 1, accesscontrol.User{ID: usr.ID}, "1", "View")
+		require.NoError(t, err)
+		_, err = service.SetTeamPermission(ctx, 1, tm.ID, "1", "Edit")
+		require.NoError(t, err)
+		_, err = service.SetBuiltInRolePermission(ctx, 1, "Viewer", "1", "View")
+		require.NoError(t, err, "a failed asynchronous hook should not fail the write")
+		_, err = service.SetPermissions(ctx, 1, "2",
+			accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "Edit"},
+			accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: ""},
+		)
+		require.NoError(t, err)
+		_, err = service.SetUserPermissionForResources(ctx, 1, accesscontrol.User{ID: usr.ID}, []string{"3", "4"}, "View")
+		require.NoError(t, err)
+
+		close(release)
+		require.NoError(t, service.StopHooks(context.Background()))
+		assert.ElementsMatch(t, []string{
+			"user 1 View", "team 1 Edit", "Viewer 1 View", "user 2 Edit", "team 2 ", "user 3 View", "user 4 View",
+		}, called)
+		assert.Equal(t, float64(1), testutil.ToFloat64(service.metrics.hookFailures.WithLabelValues(testOptions.Resource, hookSetBuiltInRole)))
+	})
+
+	t.Run("should queue the set hooks of owners and removed users", func(t *testing.T) {
+		var mu sync.Mutex
+		var called []string
+		options := testOptions
+		options.EnableOwner = true
+		options.AsyncHooks = &AsyncHooks{Workers: 1}
+		options.OnSetUser = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			called = append(called, fmt.Sprintf("user:%d %s %s", user.ID, resourceID, permission))
+			return nil
+		}
+		service, sql, _ := setupTestEnvironment(t, options)
+		previous := createOwnerTestUser(t, sql, "previous", false)
+		owner := createOwnerTestUser(t, sql, "owner", false)
+
+		ctx := context.Background()
+		require.NoError(t, service.SetOwner(ctx, 1, "1", accesscontrol.User{ID: previous.ID}))
+		require.NoError(t, service.SetOwner(ctx, 1, "1", accesscontrol.User{ID: owner.ID}))
+		require.NoError(t, service.RemoveUserAssignments(ctx, 1, owner.ID))
+
+		require.NoError(t, service.StopHooks(context.Background()))
+		assert.Equal(t, []string{
+			fmt.Sprintf("user:%d 1 Edit", previous.ID),
+			fmt.Sprintf("user:%d 1 ", previous.ID),
+			fmt.Sprintf("user:%d 1 Edit", owner.ID),
+			fmt.Sprintf("user:%d 1 ", owner.ID),
+		}, called, "the previous owner and the removed user should be given an empty permission")
+	})
+
+	t.Run("should drain the set hooks when Run stops", func(t *testing.T) {
+		release := make(chan struct{})
+		called := make(chan string, 1)
+		options := testOptions
+		options.AsyncHooks = &AsyncHooks{}
+		options.OnSetBuiltInRole = func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error {
+			<-release
+			called <- resourceID
+			return nil
+		}
+		service, _, _ := setupTestEnvironment(t, options)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error)
+		go func() { stopped <- service.Run(ctx) }()
+
+		_, err := service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+		require.NoError(t, err)
+		cancel()
+		close(release)
+		assert.ErrorIs(t, <-stopped, context.Canceled)
+		select {
+		case resourceID := <-called:
+			assert.Equal(t, "1", resourceID)
+		default:
+			t.Fatal("Run should wait for the queued set hooks")
+		}
+	})
+}
+
+func TestService_HookRetry(t *testing.T) {
+	setupRetriedHook := func(t *testing.T, failures int) (*Service, *int) {
+		calls := 0
+		options := testOptions
+		options.HookRetry = &HookRetry{Retries: 2, Backoff: time.Millisecond}
+		options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+			calls++
+			if calls <= failures {
+				return errors.New("hook failed")
+			}
+			return nil
+		}
+		service, _, _ := setupTestEnvironment(t, options)
+		return service, &calls
+	}
+
+	t.Run("should retry a hook until it succeeds", func(t *testing.T) {
+		service, calls := setupRetriedHook(t, 2)
+
+		_, err := service.SetPermissions(context.Background(), 1, "1", accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, *calls)
+		assert.Equal(t, float64(2), testutil.ToFloat64(service.metrics.hookRetries.WithLabelValues("dashboards", hookBulkSet)))
+		assert.Equal(t, float64(0), testutil.ToFloat64(service.metrics.hookFailures.WithLabelValues("dashboards", hookBulkSet)))
+	})
+
+	t.Run("should give up on a hook that always fails", func(t *testing.T) {
+		service, calls := setupRetriedHook(t, 10)
+
+		_, err := service.SetPermissions(context.Background(), 1, "1", accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, *calls)
+		assert.Equal(t, float64(2), testutil.ToFloat64(service.metrics.hookRetries.WithLabelValues("dashboards", hookBulkSet)))
+		assert.Equal(t, float64(1), testutil.ToFloat64(service.metrics.hookFailures.WithLabelValues("dashboards", hookBulkSet)))
+
+		permissions, err := service.GetPermissions(context.Background(), &user.SignedInUser{OrgID: 1}, "1")
+		require.NoError(t, err)
+		assert.Len(t, permissions, 1, "the permissions should be committed")
+	})
+}
+
+func TestService_HookError(t *testing.T) {
+	hookErr := errors.New("hook failed")
+	options := testOptions
+	options.OnSetBuiltInRole = func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error {
+		return hookErr
+	}
+	service, _, _ := setupTestEnvironment(t, options)
+
+	_, err := service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+	var hookError *HookError
+	require.ErrorAs(t, err, &hookError)
+	assert.Equal(t, hookSetBuiltInRole, hookError.Hook)
+	assert.ErrorIs(t, err, ErrHookFailed)
+	assert.ErrorIs(t, err, hookErr)
+	assert.Equal(t, float64(1), testutil.ToFloat64(service.metrics.hookFailures.WithLabelValues("dashboards", hookSetBuiltInRole)))
+
+	permissions, err := service.GetPermissions(context.Background(), &user.SignedInUser{OrgID: 1}, "1")
+	require.NoError(t, err)
+	assert.Empty(t, permissions, "the write should be rolled back")
+}
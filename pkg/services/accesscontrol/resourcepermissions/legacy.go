@@ -0,0 +1,233 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/dashboards/dashboardaccess"
+)
+
+// LegacyACLMigration describes the conversion of the legacy ACL entries of a dashboard or a folder into
+// managed permissions
+type LegacyACLMigration struct {
+	// Resource is dashboards or folders
+	Resource   string
+	ResourceID string
+	// Applied are the legacy entries set as managed permissions because the assignee had no managed permission
+	Applied []accesscontrol.SetResourcePermissionCommand
+	// Conflicts are the legacy entries contradicting the managed permission of their assignee, which is kept
+	Conflicts []LegacyACLConflict
+	// Skipped are the legacy entries of users and teams that no longer exist
+	Skipped []accesscontrol.SetResourcePermissionCommand
+	// Removed is the number of legacy entries removed, it is the number of entries that will be removed on a dry run
+	Removed int
+}
+
+// LegacyACLConflict is a legacy entry whose permission differs from the managed permission of the same assignee
+type LegacyACLConflict struct {
+	Legacy  accesscontrol.SetResourcePermissionCommand
+	Managed string
+}
+
+// LegacyACLMigrator converts the entries of the legacy dashboard_acl table that were never converted into managed
+// permissions of dashboards and folders, and removes them.
+type LegacyACLMigrator struct {
+	sql        db.DB
+	dashboards Manager
+	folders    Manager
+	log        log.Logger
+}
+
+func NewLegacyACLMigrator(sql db.DB, dashboardPermissions, folderPermissions Manager) *LegacyACLMigrator {
+	return &LegacyACLMigrator{
+		sql:        sql,
+		dashboards: dashboardPermissions,
+		folders:    folderPermissions,
+		log:        log.New("resourcepermissions.legacyacl"),
+	}
+}
+
+// legacyACL is an entry of the dashboard_acl table with the dashboard or folder it applies to. FoundUserID and
+// FoundTeamID are zero when the user or team of the entry no longer exists.
+type legacyACL struct {
+	ID          int64                          `xorm:"id"`
+	DashboardID int64                          `xorm:"dashboard_id"`
+	UID         string                         `xorm:"uid"`
+	IsFolder    bool                           `xorm:"is_folder"`
+	UserID      int64                          `xorm:"user_id"`
+	FoundUserID int64                          `xorm:"found_user_id"`
+	TeamID      int64                          `xorm:"team_id"`
+	FoundTeamID int64                          `xorm:"found_team_id"`
+	Role        string                         `xorm:"role"`
+	Permission  dashboardaccess.PermissionType `xorm:"permission"`
+}
+
+// MigrateOrg converts the legacy ACL entries of every dashboard and folder of an organization. With dryRun nothing is
+// written and the returned migrations describe the planned changes.
+func (m *LegacyACLMigrator) MigrateOrg(ctx context.Context, orgID int64, dryRun bool) ([]LegacyACLMigration, error) {
+	entries, err := m.getLegacyACL(ctx, orgID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []LegacyACLMigration
+	for start := 0; start < len(entries); {
+		end := start
+		for end < len(entries) && entries[end].DashboardID == entries[start].DashboardID {
+			end++
+		}
+
+		migration, err := m.migrate(ctx, orgID, entries[start:end], dryRun)
+		if err != nil {
+			return migrations, err
+		}
+		migrations = append(migrations, *migration)
+		start = end
+	}
+	return migrations, nil
+}
+
+// Migrate converts the legacy ACL entries of the dashboard or folder with uid. It returns nil if there is none.
+func (m *LegacyACLMigrator) Migrate(ctx context.Context, orgID int64, uid string, dryRun bool) (*LegacyACLMigration, error) {
+	entries, err := m.getLegacyACL(ctx, orgID, uid)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return m.migrate(ctx, orgID, entries, dryRun)
+}
+
+// migrate converts the legacy entries of a single dashboard or folder. Assignees with a managed permission keep it,
+// the legacy entries are removed once the managed permissions are set.
+func (m *LegacyACLMigrator) migrate(ctx context.Context, orgID int64, entries []legacyACL, dryRun bool) (*LegacyACLMigration, error) {
+	resource, manager := "dashboards", m.dashboards
+	if entries[0].IsFolder {
+		resource, manager = "folders", m.folders
+	}
+	migration := &LegacyACLMigration{Resource: resource, ResourceID: entries[0].UID}
+
+	snapshot, err := manager.SnapshotPermissions(ctx, orgID, migration.ResourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get managed permissions of %s %s: %w", resource, migration.ResourceID, err)
+	}
+	managed := make(map[accesscontrol.SetResourcePermissionCommand]string, len(snapshot.Permissions))
+	for _, p := range snapshot.Permissions {
+		managed[accesscontrol.SetResourcePermissionCommand{UserID: p.UserID, TeamID: p.TeamID, BuiltinRole: p.BuiltinRole}] = p.Permission
+	}
+
+	// built-in roles can have several legacy entries on the same resource, the highest permission is kept
+	legacy := map[accesscontrol.SetResourcePermissionCommand]dashboardaccess.PermissionType{}
+	ids := make([]int64, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+		key := accesscontrol.SetResourcePermissionCommand{UserID: e.UserID, TeamID: e.TeamID, BuiltinRole: e.Role}
+		if (e.UserID != 0 && e.FoundUserID == 0) || (e.TeamID != 0 && e.FoundTeamID == 0) {
+			key.Permission = e.Permission.String()
+			migration.Skipped = append(migration.Skipped, key)
+			continue
+		}
+		if e.Permission > legacy[key] {
+			legacy[key] = e.Permission
+		}
+	}
+
+	for key, level := range legacy {
+		cmd := key
+		cmd.Permission = level.String()
+		if cmd.Permission == "" {
+			m.log.Warn("Skipping legacy ACL entry with unknown permission", "resource", resource, "resourceID", migration.ResourceID, "permission", int(level))
+			migration.Skipped = append(migration.Skipped, cmd)
+			continue
+		}
+
+		current, ok := managed[key]
+		if !ok {
+			migration.Applied = append(migration.Applied, cmd)
+		} else if current != cmd.Permission {
+			migration.Conflicts = append(migration.Conflicts, LegacyACLConflict{Legacy: cmd, Managed: current})
+		}
+	}
+	sortCommands(migration.Applied)
+	sortCommands(migration.Skipped)
+	sort.Slice(migration.Conflicts, func(i, j int) bool {
+		return lessCommand(migration.Conflicts[i].Legacy, migration.Conflicts[j].Legacy)
+	})
+	migration.Removed = len(ids)
+
+	if dryRun {
+		return migration, nil
+	}
+
+	if len(migration.Applied) > 0 {
+		if _, err := manager.SetPermissions(ctx, orgID, migration.ResourceID, migration.Applied...); err != nil {
+			return nil, fmt.Errorf("failed to set permissions of %s %s: %w", resource, migration.ResourceID, err)
+		}
+	}
+
+	err = m.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		query := []any{"DELETE FROM dashboard_acl WHERE org_id = ? AND id IN (?" + strings.Repeat(",?", len(ids)-1) + ")", orgID}
+		for _, id := range ids {
+			query = append(query, id)
+		}
+		_, err := sess.Exec(query...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove legacy ACL of %s %s: %w", resource, migration.ResourceID, err)
+	}
+
+	for _, c := range migration.Conflicts {
+		m.log.Warn("Legacy ACL entry contradicts managed permission, keeping the managed permission",
+			"resource", resource, "resourceID", migration.ResourceID, "userID", c.Legacy.UserID, "teamID", c.Legacy.TeamID,
+			"builtInRole", c.Legacy.BuiltinRole, "legacy", c.Legacy.Permission, "managed", c.Managed)
+	}
+	return migration, nil
+}
+
+// getLegacyACL returns the legacy entries of the dashboards and folders of an organization ordered by dashboard,
+// restricted to the dashboard or folder with uid if set. The default entries of the table are not returned.
+func (m *LegacyACLMigrator) getLegacyACL(ctx context.Context, orgID int64, uid string) ([]legacyACL, error) {
+	rawSQL := `
+	SELECT acl.id, acl.dashboard_id, d.uid, d.is_folder, acl.permission,
+		COALESCE(acl.user_id, 0) AS user_id, COALESCE(u.id, 0) AS found_user_id,
+		COALESCE(acl.team_id, 0) AS team_id, COALESCE(t.id, 0) AS found_team_id,
+		COALESCE(acl.role, '') AS role
+	FROM dashboard_acl acl
+		INNER JOIN dashboard d ON d.id = acl.dashboard_id AND d.org_id = acl.org_id
+		LEFT JOIN ` + m.sql.GetDialect().Quote("user") + ` u ON u.id = acl.user_id
+		LEFT JOIN team t ON t.id = acl.team_id AND t.org_id = acl.org_id
+	WHERE acl.org_id = ?`
+	args := []any{orgID}
+	if uid != "" {
+		rawSQL += ` AND d.uid = ?`
+		args = append(args, uid)
+	}
+	rawSQL += `
+	ORDER BY acl.dashboard_id, acl.id`
+
+	var entries []legacyACL
+	err := m.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		return sess.SQL(rawSQL, args...).Find(&entries)
+	})
+	return entries, err
+}
+
+func sortCommands(commands []accesscontrol.SetResourcePermissionCommand) {
+	sort.Slice(commands, func(i, j int) bool {
+		return lessCommand(commands[i], commands[j])
+	})
+}
+
+func lessCommand(a, b accesscontrol.SetResourcePermissionCommand) bool {
+	if a.UserID != b.UserID {
+		return a.UserID < b.UserID
+	}
+	if a.TeamID != b.TeamID {
+		return a.TeamID < b.TeamID
+	}
+	return a.BuiltinRole < b.BuiltinRole
+}
@@ -0,0 +1,226 @@
+package resourcepermissions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/actest"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/dashboards/dashboardaccess"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/licensing/licensingtest"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/org/orgimpl"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/services/supportbundles/supportbundlestest"
+	"github.com/grafana/grafana/pkg/services/team/teamimpl"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/services/user/userimpl"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestIntegrationLegacyACLMigrator(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	levels := map[string][]string{
+		"View":  {"dashboards:read"},
+		"Edit":  {"dashboards:read", "dashboards:write", "dashboards:delete"},
+		"Admin": {"dashboards:read", "dashboards:write", "dashboards:delete", "dashboards.permissions:read", "dashboards.permissions:write"},
+	}
+	options := Options{Resource: "dashboards", ResourceAttribute: "uid", Assignments: testOptions.Assignments, PermissionsToActions: levels}
+	dashboardPermissions, sql, teamSvc := setupTestEnvironment(t, options)
+	options.Resource = "folders"
+	folderPermissions := setupTestServiceWithStore(t, options, sql)
+
+	orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	var userIDs []int64
+	for _, login := range []string{"viewer", "editor", "admin"} {
+		u, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: login, OrgID: 1})
+		require.NoError(t, err)
+		userIDs = append(userIDs, u.ID)
+	}
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+
+	dashboardID := insertLegacyDashboard(t, sql, "dash", false)
+	folderID := insertLegacyDashboard(t, sql, "folder", true)
+	viewer, editor := org.RoleViewer, org.RoleEditor
+	insertLegacyACL(t, sql,
+		// one entry of each legacy permission level
+		dashboards.DashboardACL{DashboardID: dashboardID, UserID: userIDs[0], Permission: dashboardaccess.PERMISSION_VIEW},
+		dashboards.DashboardACL{DashboardID: dashboardID, TeamID: tm.ID, Permission: dashboardaccess.PERMISSION_EDIT},
+		dashboards.DashboardACL{DashboardID: dashboardID, UserID: userIDs[2], Permission: dashboardaccess.PERMISSION_ADMIN},
+		// contradicts the managed permission of the user
+		dashboards.DashboardACL{DashboardID: dashboardID, UserID: userIDs[1], Permission: dashboardaccess.PERMISSION_VIEW},
+		// matches the managed permission of the role
+		dashboards.DashboardACL{DashboardID: dashboardID, Role: &editor, Permission: dashboardaccess.PERMISSION_EDIT},
+		// the user was deleted
+		dashboards.DashboardACL{DashboardID: dashboardID, UserID: 999, Permission: dashboardaccess.PERMISSION_EDIT},
+		dashboards.DashboardACL{DashboardID: folderID, Role: &viewer, Permission: dashboardaccess.PERMISSION_VIEW},
+		dashboards.DashboardACL{DashboardID: folderID, TeamID: tm.ID, Permission: dashboardaccess.PERMISSION_ADMIN},
+	)
+
+	_, err = dashboardPermissions.SetPermissions(context.Background(), 1, "dash",
+		accesscontrol.SetResourcePermissionCommand{UserID: userIDs[1], Permission: "Edit"},
+		accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Editor", Permission: "Edit"},
+	)
+	require.NoError(t, err)
+
+	migrator := NewLegacyACLMigrator(sql, dashboardPermissions, folderPermissions)
+	expected := []LegacyACLMigration{
+		{
+			Resource:   "dashboards",
+			ResourceID: "dash",
+			Applied: []accesscontrol.SetResourcePermissionCommand{
+				{TeamID: tm.ID, Permission: "Edit"},
+				{UserID: userIDs[0], Permission: "View"},
+				{UserID: userIDs[2], Permission: "Admin"},
+			},
+			Conflicts: []LegacyACLConflict{
+				{Legacy: accesscontrol.SetResourcePermissionCommand{UserID: userIDs[1], Permission: "View"}, Managed: "Edit"},
+			},
+			Skipped: []accesscontrol.SetResourcePermissionCommand{{UserID: 999, Permission: "Edit"}},
+			Removed: 6,
+		},
+		{
+			Resource:   "folders",
+			ResourceID: "folder",
+			Applied: []accesscontrol.SetResourcePermissionCommand{
+				{BuiltinRole: "Viewer", Permission: "View"},
+				{TeamID: tm.ID, Permission: "Admin"},
+			},
+			Removed: 2,
+		},
+	}
+
+	t.Run("should plan the migration without writing on dry run", func(t *testing.T) {
+		migrations, err := migrator.MigrateOrg(context.Background(), 1, true)
+		require.NoError(t, err)
+		assert.Equal(t, expected, migrations)
+		assert.Equal(t, int64(8), countLegacyACL(t, sql))
+
+		snapshot, err := folderPermissions.SnapshotPermissions(context.Background(), 1, "folder")
+		require.NoError(t, err)
+		assert.Empty(t, snapshot.Permissions)
+	})
+
+	t.Run("should set managed permissions and remove legacy entries", func(t *testing.T) {
+		migrations, err := migrator.MigrateOrg(context.Background(), 1, false)
+		require.NoError(t, err)
+		assert.Equal(t, expected, migrations)
+		assert.Zero(t, countLegacyACL(t, sql))
+
+		snapshot, err := dashboardPermissions.SnapshotPermissions(context.Background(), 1, "dash")
+		require.NoError(t, err)
+		assert.Equal(t, []accesscontrol.SetResourcePermissionCommand{
+			{BuiltinRole: "Editor", Permission: "Edit"},
+			{TeamID: tm.ID, Permission: "Edit"},
+			{UserID: userIDs[0], Permission: "View"},
+			{UserID: userIDs[1], Permission: "Edit"},
+			{UserID: userIDs[2], Permission: "Admin"},
+		}, snapshot.Permissions)
+
+		snapshot, err = folderPermissions.SnapshotPermissions(context.Background(), 1, "folder")
+		require.NoError(t, err)
+		assert.Equal(t, []accesscontrol.SetResourcePermissionCommand{
+			{BuiltinRole: "Viewer", Permission: "View"},
+			{TeamID: tm.ID, Permission: "Admin"},
+		}, snapshot.Permissions)
+	})
+
+	t.Run("should do nothing once migrated", func(t *testing.T) {
+		migrations, err := migrator.MigrateOrg(context.Background(), 1, false)
+		require.NoError(t, err)
+		assert.Empty(t, migrations)
+
+		migration, err := migrator.Migrate(context.Background(), 1, "dash", false)
+		require.NoError(t, err)
+		assert.Nil(t, migration)
+	})
+}
+
+// setupTestServiceWithStore creates a service for another resource backed by the same database as setupTestEnvironment
+func setupTestServiceWithStore(t *testing.T, options Options, sql db.DB) *Service {
+	t.Helper()
+
+	cfg := setting.NewCfg()
+	teamSvc := teamimpl.ProvideService(sql, cfg)
+	userSvc, err := userimpl.ProvideService(sql, nil, cfg, teamSvc, nil, quotatest.New(false, nil), supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	license := licensingtest.NewFakeLicensing()
+	license.On("FeatureEnabled", "accesscontrol.enforcement").Return(true).Maybe()
+	service, err := New(
+		options, featuremgmt.WithFeatures(), routing.NewRouteRegister(), license,
+		acimpl.ProvideAccessControl(cfg), &actest.FakeService{}, sql, teamSvc, userSvc,
+	)
+	require.NoError(t, err)
+	return service
+}
+
+func insertLegacyDashboard(t *testing.T, sql db.DB, uid string, isFolder bool) int64 {
+	t.Helper()
+
+	dash := &dashboards.Dashboard{
+		UID: uid, OrgID: 1, Title: uid, Slug: uid, IsFolder: isFolder, HasACL: true,
+		Data: simplejson.New(), Created: time.Now(), Updated: time.Now(),
+	}
+	err := sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		_, err := sess.Insert(dash)
+		return err
+	})
+	require.NoError(t, err)
+	return dash.ID
+}
+
+func insertLegacyACL(t *testing.T, sql db.DB, entries ...dashboards.DashboardACL) {
+	t.Helper()
+
+	// unset assignees are stored as NULL, like the legacy dashboard permissions did
+	nullable := func(id int64) any {
+		if id == 0 {
+			return nil
+		}
+		return id
+	}
+	err := sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		for _, e := range entries {
+			_, err := sess.Exec(
+				"INSERT INTO dashboard_acl (org_id, dashboard_id, user_id, team_id, role, permission, created, updated) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				1, e.DashboardID, nullable(e.UserID), nullable(e.TeamID), e.Role, e.Permission, time.Now(), time.Now(),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// countLegacyACL returns the number of legacy entries of org 1, the default entries of the table are in org -1
+func countLegacyACL(t *testing.T, sql db.DB) int64 {
+	t.Helper()
+
+	var count int64
+	err := sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		var err error
+		count, err = sess.Table("dashboard_acl").Where("org_id = ?", 1).Count()
+		return err
+	})
+	require.NoError(t, err)
+	return count
+}
@@ -0,0 +1,231 @@
+package resourcepermissions
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// settingsLevelsKey is the key of the settings section of a resource holding its permission levels, as a json object
+// mapping each level to its actions, e.g. {"View": ["dashboards:read"], "Edit": ["dashboards:read", "dashboards:write"]}
+const settingsLevelsKey = "levels"
+
+// PermissionLevel is a permission level of a resource and the actions it grants
+type PermissionLevel struct {
+	Name    string
+	Actions []string
+}
+
+// permissionLevels is the permission levels configuration of a service. It is never modified once created, updates
+// replace it as a whole so that a reader holding it always sees a consistent mapping.
+type permissionLevels struct {
+	toActions map[string][]string
+	// names are sorted by number of actions, highest first. Will be used when mapping actions to permissions
+	names []string
+	// actions are the actions of all levels
+	actions []string
+}
+
+func newPermissionLevels(toActions map[string][]string) *permissionLevels {
+	names := make([]string, 0, len(toActions))
+	actionSet := make(map[string]struct{})
+	for permission, actions := range toActions {
+		names = append(names, permission)
+		for _, a := range actions {
+			actionSet[a] = struct{}{}
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return len(toActions[names[i]]) > len(toActions[names[j]])
+	})
+
+	actions := make([]string, 0, len(actionSet))
+	for action := range actionSet {
+		actions = append(actions, action)
+	}
+
+	return &permissionLevels{toActions: toActions, names: names, actions: actions}
+}
+
+// display returns the names of the levels from the lowest to the highest
+func (l *permissionLevels) display() []string {
+	names := make([]string, 0, len(l.names))
+	for i := len(l.names) - 1; i >= 0; i-- {
+		names = append(names, l.names[i])
+	}
+	return names
+}
+
+// licensedLevels returns the names of the levels that can be granted with the license of the instance, from the
+// lowest to the highest
+func (s *Service) licensedLevels() []string {
+	names := s.getLevels().display()
+	licensed := make([]string, 0, len(names))
+	for _, name := range names {
+		if feature, ok := s.options.PermissionLicenses[name]; ok && !s.license.FeatureEnabled(feature) {
+			continue
+		}
+		licensed = append(licensed, name)
+	}
+	return licensed
+}
+
+// getLevels returns the current permission levels of the service
+func (s *Service) getLevels() *permissionLevels {
+	s.levelsMu.RLock()
+	defer s.levelsMu.RUnlock()
+	return s.levels
+}
+
+// UpdatePermissions replaces the permission levels of the service. Each level must grant all the actions of the
+// levels with fewer actions, so that actions always map to a single highest level. Requests in flight keep the
+// levels they started with. Managed permissions already stored are not rewritten, permissions that no longer match
+// a level are reported as unmapped.
+func (s *Service) UpdatePermissions(levels []PermissionLevel) error {
+	toActions, err := validatePermissionLevels(levels)
+	if err != nil {
+		return err
+	}
+
+	updated := newPermissionLevels(toActions)
+	s.levelsMu.Lock()
+	s.levels = updated
+	s.levelsMu.Unlock()
+
+	s.log.Info("Updated permission levels", "resource", s.options.Resource, "levels", updated.display())
+	return nil
+}
+
+// validatePermissionLevels checks that levels have distinct names, grant at least one well formed action and that
+// each level grants a strict superset of the actions of the previous one once sorted by number of actions
+func validatePermissionLevels(levels []PermissionLevel) (map[string][]string, error) {
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("%w: at least one permission level is required", ErrInvalidPermission)
+	}
+
+	toActions := make(map[string][]string, len(levels))
+	sorted := make([]PermissionLevel, 0, len(levels))
+	for _, level := range levels {
+		if level.Name == "" {
+			return nil, fmt.Errorf("%w: permission level without a name", ErrInvalidPermission)
+		}
+		if _, ok := toActions[level.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate permission level %s", ErrInvalidPermission, level.Name)
+		}
+		if len(level.Actions) == 0 {
+			return nil, fmt.Errorf("%w: permission level %s has no actions", ErrInvalidPermission, level.Name)
+		}
+		for _, a := range level.Actions {
+			if !isValidAction(a) {
+				return nil, fmt.Errorf("%w: permission level %s has malformed action %q", ErrInvalidPermission, level.Name, a)
+			}
+		}
+		actions := append([]string{}, level.Actions...)
+		toActions[level.Name] = actions
+		sorted = append(sorted, PermissionLevel{Name: level.Name, Actions: actions})
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Actions) < len(sorted[j].Actions)
+	})
+	for i := 1; i < len(sorted); i++ {
+		lower, higher := sorted[i-1], sorted[i]
+		granted := make(map[string]struct{}, len(higher.Actions))
+		for _, a := range higher.Actions {
+			granted[a] = struct{}{}
+		}
+		if len(granted) == len(lower.Actions) {
+			return nil, fmt.Errorf("%w: permission levels %s and %s grant the same number of actions", ErrInvalidPermission, lower.Name, higher.Name)
+		}
+		for _, a := range lower.Actions {
+			if _, ok := granted[a]; !ok {
+				return nil, fmt.Errorf("%w: permission level %s does not grant %s of permission level %s", ErrInvalidPermission, higher.Name, a, lower.Name)
+			}
+		}
+	}
+
+	return toActions, nil
+}
+
+// isValidAction reports whether action has the <resource>:<verb> form of access control actions, e.g. dashboards:read
+// or dashboards.permissions:write. Actions are not registered with access control, any well formed action is accepted.
+func isValidAction(action string) bool {
+	if strings.IndexFunc(action, unicode.IsSpace) >= 0 {
+		return false
+	}
+	prefix, verb, ok := strings.Cut(action, ":")
+	return ok && prefix != "" && verb != ""
+}
+
+// Validate implements setting.ReloadHandler, it checks the permission levels and the default permissions of built-in
+// roles of the settings section of the resource
+func (s *Service) Validate(section setting.Section) error {
+	_, _, err := s.settingsReload(section)
+	return err
+}
+
+// Reload implements setting.ReloadHandler, it replaces the permission levels and the default permissions of built-in
+// roles with the ones of the settings section of the resource. The levels of Options.PermissionsToActions are restored
+// when the section has no levels.
+func (s *Service) Reload(section setting.Section) error {
+	toActions, defaults, err := s.settingsReload(section)
+	if err != nil {
+		return err
+	}
+
+	updated := newPermissionLevels(toActions)
+	s.levelsMu.Lock()
+	s.levels = updated
+	s.builtInDefaults = defaults
+	s.levelsMu.Unlock()
+
+	s.log.Info("Updated permission levels", "resource", s.options.Resource, "levels", updated.display())
+	return nil
+}
+
+// settingsReload returns the permission levels and the default permissions of built-in roles of a settings section,
+// the default permissions are checked against the levels of the same section
+func (s *Service) settingsReload(section setting.Section) (map[string][]string, *builtInDefaults, error) {
+	levels, err := s.settingsLevels(section)
+	if err != nil {
+		return nil, nil, err
+	}
+	toActions, err := validatePermissionLevels(levels)
+	if err != nil {
+		return nil, nil, err
+	}
+	defaults, err := s.settingsBuiltInDefaults(section, toActions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return toActions, defaults, nil
+}
+
+func (s *Service) settingsLevels(section setting.Section) ([]PermissionLevel, error) {
+	toActions := s.options.PermissionsToActions
+	if raw := section.KeyValue(settingsLevelsKey).Value(); raw != "" {
+		toActions = map[string][]string{}
+		if err := json.Unmarshal([]byte(raw), &toActions); err != nil {
+			return nil, fmt.Errorf("%w: invalid %s: %w", ErrInvalidPermission, settingsLevelsKey, err)
+		}
+	}
+
+	levels := make([]PermissionLevel, 0, len(toActions))
+	for name, actions := range toActions {
+		levels = append(levels, PermissionLevel{Name: name, Actions: actions})
+	}
+	if _, err := validatePermissionLevels(levels); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// settingsSection returns the settings section holding the permission levels of a resource
+func settingsSection(resource string) string {
+	return "resource_permissions." + resource
+}
@@ -0,0 +1,216 @@
+package resourcepermissions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+func TestService_UpdatePermissions(t *testing.T) {
+	type testCase struct {
+		desc        string
+		levels      []PermissionLevel
+		expectedErr bool
+	}
+
+	tests := []testCase{
+		{
+			desc: "should accept levels granting supersets of each other",
+			levels: []PermissionLevel{
+				{Name: "Edit", Actions: []string{"dashboards:read", "annotations:write", "dashboards:write"}},
+				{Name: "View", Actions: []string{"dashboards:read"}},
+				{Name: "Annotate", Actions: []string{"dashboards:read", "annotations:write"}},
+			},
+		},
+		{
+			desc:        "should reject no levels",
+			expectedErr: true,
+		},
+		{
+			desc: "should reject a level without name",
+			levels: []PermissionLevel{
+				{Actions: []string{"dashboards:read"}},
+			},
+			expectedErr: true,
+		},
+		{
+			desc: "should reject a level without actions",
+			levels: []PermissionLevel{
+				{Name: "View"},
+			},
+			expectedErr: true,
+		},
+		{
+			desc: "should reject duplicate levels",
+			levels: []PermissionLevel{
+				{Name: "View", Actions: []string{"dashboards:read"}},
+				{Name: "View", Actions: []string{"dashboards:read", "dashboards:write"}},
+			},
+			expectedErr: true,
+		},
+		{
+			desc: "should reject a level not granting the actions of a lower level",
+			levels: []PermissionLevel{
+				{Name: "View", Actions: []string{"dashboards:read"}},
+				{Name: "Annotate", Actions: []string{"annotations:read", "annotations:write"}},
+			},
+			expectedErr: true,
+		},
+		{
+			desc: "should reject levels with the same number of actions",
+			levels: []PermissionLevel{
+				{Name: "View", Actions: []string{"dashboards:read"}},
+				{Name: "Annotate", Actions: []string{"annotations:write"}},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			service, _, _ := setupTestEnvironment(t, testOptions)
+
+			err := service.UpdatePermissions(tt.levels)
+			if tt.expectedErr {
+				assert.ErrorIs(t, err, ErrInvalidPermission)
+				assert.Equal(t, []string{"View", "Edit"}, service.getLevels().display())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"View", "Annotate", "Edit"}, service.getLevels().display())
+		})
+	}
+}
+
+func TestService_UpdatePermissionsMapping(t *testing.T) {
+	service, _, _ := setupTestEnvironment(t, testOptions)
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+	})}}, service)
+
+	recorder := setPermission(t, server, "dashboards", "1", "Annotate", "builtInRoles", "Viewer")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	require.NoError(t, service.UpdatePermissions([]PermissionLevel{
+		{Name: "View", Actions: []string{"dashboards:read"}},
+		{Name: "Annotate", Actions: []string{"dashboards:read", "annotations:write"}},
+		{Name: "Edit", Actions: []string{"dashboards:read", "annotations:write", "dashboards:write", "dashboards:delete"}},
+	}))
+
+	recorder = setPermission(t, server, "dashboards", "1", "Annotate", "builtInRoles", "Viewer")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	permissions, recorder := getPermission(t, server, "dashboards", "1")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, permissions, 1)
+	assert.Equal(t, "Annotate", permissions[0].Permission)
+	assert.ElementsMatch(t, []string{"dashboards:read", "annotations:write"}, permissions[0].Actions)
+}
+
+func TestService_UpdatePermissionsConcurrentReads(t *testing.T) {
+	service, _, _ := setupTestEnvironment(t, testOptions)
+	// the server is set up without the template renderer of setupTestServer, it is not safe for concurrent requests
+	// in development mode and the api only renders json
+	server := web.New()
+	server.Use(contextProvider(&testContext{&user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read"},
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+	})}}}))
+	service.api.router.Register(server)
+	require.Equal(t, http.StatusOK, setPermission(t, server, "dashboards", "1", "Edit", "builtInRoles", "Viewer").Code)
+
+	initial := []PermissionLevel{
+		{Name: "View", Actions: []string{"dashboards:read"}},
+		{Name: "Edit", Actions: []string{"dashboards:read", "dashboards:write", "dashboards:delete"}},
+	}
+	annotate := []PermissionLevel{
+		{Name: "View", Actions: []string{"dashboards:read"}},
+		{Name: "Annotate", Actions: []string{"dashboards:read", "annotations:write"}},
+		{Name: "Edit", Actions: []string{"dashboards:read", "annotations:write", "dashboards:write", "dashboards:delete"}},
+	}
+	expectedDescriptions := [][]string{{"View", "Edit"}, {"View", "Annotate", "Edit"}}
+
+	var writer, readers sync.WaitGroup
+	done := make(chan struct{})
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			levels := initial
+			if i%2 == 0 {
+				levels = annotate
+			}
+			assert.NoError(t, service.UpdatePermissions(levels))
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for j := 0; j < 50; j++ {
+				req := httptest.NewRequest(http.MethodGet, "/api/access-control/dashboards/description", nil)
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, req)
+				var description Description
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&description))
+				assert.Contains(t, expectedDescriptions, description.Permissions)
+
+				req = httptest.NewRequest(http.MethodGet, "/api/access-control/dashboards/1", nil)
+				recorder = httptest.NewRecorder()
+				server.ServeHTTP(recorder, req)
+				var permissions []resourcePermissionDTO
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&permissions))
+				// the stored Edit actions map to Edit before the update and to View after it
+				if assert.Len(t, permissions, 1) {
+					assert.Contains(t, []string{"View", "Edit"}, permissions[0].Permission)
+				}
+			}
+		}()
+	}
+
+	readers.Wait()
+	close(done)
+	writer.Wait()
+}
+
+func TestService_Reload(t *testing.T) {
+	service, _, _ := setupTestEnvironment(t, testOptions)
+	cfg := setting.NewCfg()
+	provider := &setting.OSSImpl{Cfg: cfg}
+	section := provider.Section(settingsSection("dashboards"))
+
+	cfg.Raw.Section(settingsSection("dashboards")).Key(settingsLevelsKey).SetValue(`{"View": ["dashboards:read"], "Edit": ["annotations:write"]}`)
+	assert.ErrorIs(t, service.Validate(section), ErrInvalidPermission)
+	assert.ErrorIs(t, service.Reload(section), ErrInvalidPermission)
+	assert.Equal(t, []string{"View", "Edit"}, service.getLevels().display())
+
+	cfg.Raw.Section(settingsSection("dashboards")).Key(settingsLevelsKey).SetValue(`{"View": ["dashboards:read"], "Annotate": ["dashboards:read", "annotations:write"]}`)
+	require.NoError(t, service.Validate(section))
+	require.NoError(t, service.Reload(section))
+	assert.Equal(t, []string{"View", "Annotate"}, service.getLevels().display())
+
+	// without levels the levels of the options are restored
+	cfg.Raw.Section(settingsSection("dashboards")).Key(settingsLevelsKey).SetValue("")
+	require.NoError(t, service.Reload(section))
+	assert.Equal(t, []string{"View", "Edit"}, service.getLevels().display())
+}
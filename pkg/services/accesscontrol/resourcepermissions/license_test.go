@@ -0,0 +1,122 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/licensing/licensingtest"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+const testAdminFeature = "dashboards.permissions.admin"
+
+func setupLicensedLevelsEnvironment(t *testing.T, licensed bool) (*Service, *user.User) {
+	t.Helper()
+	options := testOptions
+	options.EnableOwner = true
+	options.PermissionsToActions = map[string][]string{
+		"View":  {"dashboards:read"},
+		"Edit":  {"dashboards:read", "dashboards:write"},
+		"Admin": {"dashboards:read", "dashboards:write", "dashboards.permissions:write"},
+	}
+	options.PermissionLicenses = map[string]string{"Admin": testAdminFeature}
+	service, sql, _ := setupTestEnvironment(t, options)
+
+	license := licensingtest.NewFakeLicensing()
+	license.On("FeatureEnabled", "accesscontrol.enforcement").Return(true).Maybe()
+	license.On("FeatureEnabled", testAdminFeature).Return(licensed).Maybe()
+	service.license = license
+
+	return service, createOwnerTestUser(t, sql, "user", false)
+}
+
+func TestService_PermissionLicenses(t *testing.T) {
+	reader := &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+	})}}
+
+	t.Run("should grant a licensed level", func(t *testing.T) {
+		service, usr := setupLicensedLevelsEnvironment(t, true)
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "Admin")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"View", "Edit", "Admin"}, service.licensedLevels())
+		assert.Equal(t, "Admin", service.ownerPermission())
+	})
+
+	t.Run("should reject an unlicensed level", func(t *testing.T) {
+		service, usr := setupLicensedLevelsEnvironment(t, false)
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "Admin")
+		var unlicensed *UnlicensedPermissionError
+		require.ErrorAs(t, err, &unlicensed)
+		assert.Equal(t, testAdminFeature, unlicensed.Feature)
+		assert.ErrorIs(t, err, ErrUnlicensedPermission)
+
+		_, err = service.SetPermissions(context.Background(), 1, "1", accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "Admin"})
+		assert.ErrorIs(t, err, ErrUnlicensedPermission)
+
+		assert.Equal(t, []string{"View", "Edit"}, service.licensedLevels())
+		assert.Equal(t, "Edit", service.ownerPermission(), "owners should be granted the highest licensed level")
+	})
+
+	t.Run("should read and remove a stored unlicensed level", func(t *testing.T) {
+		service, usr := setupLicensedLevelsEnvironment(t, true)
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "Admin")
+		require.NoError(t, err)
+
+		license := licensingtest.NewFakeLicensing()
+		license.On("FeatureEnabled", "accesscontrol.enforcement").Return(true).Maybe()
+		license.On("FeatureEnabled", testAdminFeature).Return(false).Maybe()
+		service.license = license
+
+		server := setupTestServer(t, reader, service)
+		permissions, recorder := getPermission(t, server, "dashboards", "1")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "Admin", permissions[0].Permission)
+
+		_, err = service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "")
+		require.NoError(t, err)
+		stored, err := service.GetPermissions(context.Background(), reader, "1")
+		require.NoError(t, err)
+		assert.Empty(t, stored)
+	})
+}
+
+func TestApi_PermissionLicenses(t *testing.T) {
+	service, usr := setupLicensedLevelsEnvironment(t, false)
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+	})}}, service)
+
+	t.Run("should only describe the licensed levels", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/api/access-control/dashboards/description", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var description Description
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&description))
+		assert.Equal(t, []string{"View", "Edit"}, description.Permissions)
+	})
+
+	t.Run("should forbid granting an unlicensed level", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/access-control/dashboards/1/users/%d", usr.ID), strings.NewReader(`{"permission": "Admin"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), testAdminFeature)
+	})
+}
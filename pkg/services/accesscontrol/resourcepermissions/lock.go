@@ -0,0 +1,49 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/infra/appcontext"
+)
+
+// LockPermissions locks the permissions of a resource. While locked, every Set* call on the resource fails with
+// ErrPermissionsLocked, unless it is made by a server admin, until the lock is removed with UnlockPermissions.
+func (s *Service) LockPermissions(ctx context.Context, orgID int64, resourceID string, lockedBy int64) error {
+	if err := s.validateResource(ctx, orgID, resourceID); err != nil {
+		return err
+	}
+
+	return s.store.LockResourcePermissions(ctx, orgID, PermissionsLock{
+		Resource:   s.options.Resource,
+		ResourceID: resourceID,
+		LockedBy:   lockedBy,
+	})
+}
+
+// UnlockPermissions removes the lock of a resource, unlocking a resource that is not locked does nothing
+func (s *Service) UnlockPermissions(ctx context.Context, orgID int64, resourceID string) error {
+	return s.store.UnlockResourcePermissions(ctx, orgID, s.options.Resource, resourceID)
+}
+
+// GetPermissionsLock returns the lock of a resource, or nil if its permissions are not locked
+func (s *Service) GetPermissionsLock(ctx context.Context, orgID int64, resourceID string) (*PermissionsLock, error) {
+	return s.store.GetResourcePermissionsLock(ctx, orgID, s.options.Resource, resourceID)
+}
+
+// checkUnlocked returns ErrPermissionsLocked if the permissions of a resource are locked and the signed in user of
+// ctx is not a server admin
+func (s *Service) checkUnlocked(ctx context.Context, orgID int64, resourceID string) error {
+	if usr, err := appcontext.User(ctx); err == nil && usr.GetIsGrafanaAdmin() {
+		return nil
+	}
+
+	lock, err := s.GetPermissionsLock(ctx, orgID, resourceID)
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		return fmt.Errorf("%w: %s %s", ErrPermissionsLocked, s.options.Resource, resourceID)
+	}
+	return nil
+}
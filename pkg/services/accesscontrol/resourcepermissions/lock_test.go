@@ -0,0 +1,119 @@
+package resourcepermissions
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/appcontext"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/org/orgimpl"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/services/supportbundles/supportbundlestest"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/services/user/userimpl"
+)
+
+func TestService_LockPermissions(t *testing.T) {
+	options := testOptions
+	options.DefaultPermissions = []DefaultPermission{{BuiltInRole: "Editor", Permission: "Edit"}}
+	service, sql, teamSvc := setupTestEnvironment(t, options)
+
+	orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	usr, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "user", OrgID: 1})
+	require.NoError(t, err)
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+
+	_, err = service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+	require.NoError(t, err)
+	require.NoError(t, service.LockPermissions(context.Background(), 1, "1", usr.ID))
+	// locking a locked resource keeps the lock
+	require.NoError(t, service.LockPermissions(context.Background(), 1, "1", usr.ID))
+
+	lock, err := service.GetPermissionsLock(context.Background(), 1, "1")
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	assert.Equal(t, usr.ID, lock.LockedBy)
+
+	t.Run("should reject writes to a locked resource", func(t *testing.T) {
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+		assert.ErrorIs(t, err, ErrPermissionsLocked)
+		_, err = service.SetTeamPermission(context.Background(), 1, tm.ID, "1", "Edit")
+		assert.ErrorIs(t, err, ErrPermissionsLocked)
+		_, err = service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "")
+		assert.ErrorIs(t, err, ErrPermissionsLocked)
+		_, err = service.SetAssignmentPermission(context.Background(), 1, "builtInRoles", "Editor", "1", "Edit")
+		assert.ErrorIs(t, err, ErrPermissionsLocked)
+		_, err = service.SetPermissions(context.Background(), 1, "1", accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "Edit"})
+		assert.ErrorIs(t, err, ErrPermissionsLocked)
+
+		snapshot, err := service.SnapshotPermissions(context.Background(), 1, "1")
+		require.NoError(t, err)
+		snapshot.Permissions = nil
+		_, err = service.RestorePermissions(context.Background(), 1, "1", *snapshot)
+		assert.ErrorIs(t, err, ErrPermissionsLocked)
+	})
+
+	t.Run("should allow the writes of server admins to a locked resource", func(t *testing.T) {
+		orgAdmin := appcontext.WithUser(context.Background(), &user.SignedInUser{OrgID: 1, UserID: usr.ID, OrgRole: org.RoleAdmin})
+		_, err := service.SetUserPermission(orgAdmin, 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+		assert.ErrorIs(t, err, ErrPermissionsLocked)
+		assert.Equal(t, http.StatusLocked, permissionErrorStatus(err))
+
+		serverAdmin := appcontext.WithUser(context.Background(), &user.SignedInUser{OrgID: 1, UserID: usr.ID, IsGrafanaAdmin: true})
+		_, err = service.SetUserPermission(serverAdmin, 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+		require.NoError(t, err)
+		_, err = service.SetUserPermission(serverAdmin, 1, accesscontrol.User{ID: usr.ID}, "1", "")
+		require.NoError(t, err)
+	})
+
+	t.Run("should reject bulk writes to a locked resource only", func(t *testing.T) {
+		results, err := service.SetUserPermissionForResources(context.Background(), 1, accesscontrol.User{ID: usr.ID}, []string{"1", "2"}, "View")
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.ErrorIs(t, results[0].Err, ErrPermissionsLocked)
+		assert.NoError(t, results[1].Err)
+		assert.NotNil(t, results[1].Permission)
+	})
+
+	t.Run("should reject provisioning writes to a locked resource", func(t *testing.T) {
+		_, err := service.SetDefaultPermissions(context.Background(), 1, "1", nil)
+		assert.ErrorIs(t, err, ErrPermissionsLocked)
+	})
+
+	t.Run("should keep the permissions of a locked resource", func(t *testing.T) {
+		snapshot, err := service.SnapshotPermissions(context.Background(), 1, "1")
+		require.NoError(t, err)
+		assert.Equal(t, []accesscontrol.SetResourcePermissionCommand{{BuiltinRole: "Viewer", Permission: "View"}}, snapshot.Permissions)
+	})
+
+	t.Run("should allow writes after unlock", func(t *testing.T) {
+		require.NoError(t, service.UnlockPermissions(context.Background(), 1, "1"))
+		lock, err := service.GetPermissionsLock(context.Background(), 1, "1")
+		require.NoError(t, err)
+		assert.Nil(t, lock)
+
+		_, err = service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+		require.NoError(t, err)
+		_, err = service.SetDefaultPermissions(context.Background(), 1, "1", nil)
+		require.NoError(t, err)
+		// unlocking a resource that is not locked does nothing
+		require.NoError(t, service.UnlockPermissions(context.Background(), 1, "1"))
+	})
+
+	t.Run("should remove the lock with the permissions of a resource", func(t *testing.T) {
+		require.NoError(t, service.LockPermissions(context.Background(), 1, "3", usr.ID))
+		require.NoError(t, service.DeleteResourcePermissions(context.Background(), 1, "3"))
+		lock, err := service.GetPermissionsLock(context.Background(), 1, "3")
+		require.NoError(t, err)
+		assert.Nil(t, lock)
+	})
+}
@@ -0,0 +1,43 @@
+package resourcepermissions
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+// RemoveUserAssignments removes the managed permissions of a user on the resources of the service in an organization
+// the user was removed from, in every organization when orgID is accesscontrol.GlobalOrgID. The services register it
+// with accesscontrol.Service, so that the permissions do not come back when the user is added to the organization
+// again. The permissions are removed in a transaction per batch of resources calling the hooks of a removed
+// permission. Removing a user from a team does not change the managed permissions of the team.
+func (s *Service) RemoveUserAssignments(ctx context.Context, orgID, userID int64) error {
+	removed, err := s.store.DeleteUserAssignments(ctx, orgID, DeleteUserAssignmentsCmd{
+		UserID:            userID,
+		Resource:          s.options.Resource,
+		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
+		BatchSize:         bulkWriteBatchSize,
+	}, s.userHook(s.setUserHook()))
+
+	orgs := map[int64]struct{}{}
+	for _, a := range removed {
+		// the assignments removed by the batches committed before an error are returned as well
+		s.queueSetUserHook(ctx, a.OrgID, accesscontrol.User{ID: a.UserID}, a.ResourceID, "")
+		if _, ok := orgs[a.OrgID]; ok {
+			continue
+		}
+		orgs[a.OrgID] = struct{}{}
+		s.service.ClearUsersPermissionCache(a.OrgID, userID)
+	}
+
+	if len(removed) > 0 {
+		s.log.Info("Removed permissions of user removed from organization", "resource", s.options.Resource, "userID", userID, "assignments", len(removed), "orgs", len(orgs))
+	}
+	return err
+}
+
+// registerUserRemovedHandler removes the managed permissions of users when they are removed from an organization
+func (s *Service) registerUserRemovedHandler() {
+	s.service.RegisterUserRemovedHandler(s.RemoveUserAssignments)
+}
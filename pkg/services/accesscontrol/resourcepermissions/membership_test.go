@@ -0,0 +1,110 @@
+package resourcepermissions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
+	acdb "github.com/grafana/grafana/pkg/services/accesscontrol/database"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/licensing/licensingtest"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/services/supportbundles/supportbundlestest"
+	"github.com/grafana/grafana/pkg/services/team/teamimpl"
+	"github.com/grafana/grafana/pkg/services/user/userimpl"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestService_RemoveUserAssignments(t *testing.T) {
+	sql := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	teamSvc := teamimpl.ProvideService(sql, cfg)
+	userSvc, err := userimpl.ProvideService(sql, nil, cfg, teamSvc, nil, quotatest.New(false, nil), supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	acService := acimpl.ProvideOSSService(cfg, acdb.ProvideService(sql), localcache.ProvideService(), featuremgmt.WithFeatures())
+	license := licensingtest.NewFakeLicensing()
+	license.On("FeatureEnabled", "accesscontrol.enforcement").Return(true).Maybe()
+
+	// removed records the resources whose user permissions were removed, by resource type
+	removed := map[string][]string{}
+	newService := func(resource string) *Service {
+		service, err := New(Options{
+			Resource:          resource,
+			ResourceAttribute: "uid",
+			Assignments:       Assignments{Users: true, Teams: true, BuiltInRoles: true},
+			PermissionsToActions: map[string][]string{
+				"View": {resource + ":read"},
+			},
+			OnSetUser: func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+				if permission == "" {
+					removed[resource] = append(removed[resource], resourceID)
+				}
+				return nil
+			},
+		}, featuremgmt.WithFeatures(), routing.NewRouteRegister(), license, acimpl.ProvideAccessControl(cfg), acService, sql, teamSvc, userSvc)
+		require.NoError(t, err)
+		return service
+	}
+	dashboards := newService("dashboards")
+	folders := newService("folders")
+
+	usr := createOwnerTestUser(t, sql, "user", false)
+	other := createOwnerTestUser(t, sql, "other", false)
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+	require.NoError(t, teamSvc.AddTeamMember(usr.ID, 1, tm.ID, false, 0))
+
+	ctx := context.Background()
+	for _, id := range []string{"1", "2"} {
+		_, err := dashboards.SetUserPermission(ctx, 1, accesscontrol.User{ID: usr.ID}, id, "View")
+		require.NoError(t, err)
+	}
+	_, err = folders.SetUserPermission(ctx, 1, accesscontrol.User{ID: usr.ID}, "1", "View")
+	require.NoError(t, err)
+	_, err = dashboards.SetUserPermission(ctx, 1, accesscontrol.User{ID: other.ID}, "1", "View")
+	require.NoError(t, err)
+	_, err = dashboards.SetTeamPermission(ctx, 1, tm.ID, "1", "View")
+	require.NoError(t, err)
+	// the user is not a member of the second organization, the permission is stored directly
+	_, err = dashboards.store.SetUserResourcePermission(ctx, 2, accesscontrol.User{ID: usr.ID}, SetResourcePermissionCommand{
+		Actions: []string{"dashboards:read"}, Resource: "dashboards", ResourceID: "1", ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+
+	// called when the user is removed from the organization
+	require.NoError(t, acService.DeleteUserPermissions(ctx, 1, usr.ID))
+
+	assert.ElementsMatch(t, []string{"1", "2"}, removed["dashboards"])
+	assert.ElementsMatch(t, []string{"1"}, removed["folders"])
+
+	userPermissions := func(service *Service, orgID int64) []SetResourcePermissionCommand {
+		var result []SetResourcePermissionCommand
+		for _, id := range []string{"1", "2"} {
+			managed, err := service.getManagedPermissions(ctx, orgID, id)
+			require.NoError(t, err)
+			for key := range managed {
+				if key.UserID == usr.ID {
+					result = append(result, SetResourcePermissionCommand{ResourceID: id})
+				}
+			}
+		}
+		return result
+	}
+	assert.Empty(t, userPermissions(dashboards, 1))
+	assert.Empty(t, userPermissions(folders, 1))
+	assert.Len(t, userPermissions(dashboards, 2), 1, "permissions in other organizations should be kept")
+
+	managed, err := dashboards.getManagedPermissions(ctx, 1, "1")
+	require.NoError(t, err)
+	assert.Equal(t, map[accesscontrol.SetResourcePermissionCommand]string{
+		{UserID: other.ID}: "View",
+		{TeamID: tm.ID}:    "View",
+	}, managed, "permissions of other users and of the teams of the user should be kept")
+}
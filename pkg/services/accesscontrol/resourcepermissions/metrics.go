@@ -0,0 +1,166 @@
+package resourcepermissions
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "grafana"
+	metricsSubSystem = "resource_permissions"
+)
+
+const (
+	operationGet           = "get"
+	operationGetPage       = "get_page"
+	operationGetAssignment = "get_assignment"
+	operationListUsers     = "list_users"
+	operationTeamsMembers  = "teams_members"
+	operationCount         = "count"
+	operationSetUser       = "set_user"
+	operationSetUserBulk   = "set_user_bulk"
+	operationSetTeam       = "set_team"
+	operationSetBuiltIn    = "set_builtin"
+	operationSetAssignment = "set_assignment"
+	operationSetPermission = "set_permissions"
+	operationDelete        = "delete"
+	operationReconcile     = "reconcile"
+	operationRemoveUser    = "remove_user"
+	operationLock          = "lock"
+	operationUnlock        = "unlock"
+	operationGetLock       = "get_lock"
+	operationSetOwner      = "set_owner"
+	operationGetOwner      = "get_owner"
+	operationNormalize     = "normalize_scopes"
+	operationTranslate     = "translate_ids"
+	operationRenameRoles   = "rename_roles"
+	operationUsageStats    = "usage_stats"
+)
+
+type storeMetrics struct {
+	operationDuration *prometheus.HistogramVec
+	rowsAffected      *prometheus.CounterVec
+}
+
+// newStoreMetrics creates the store metrics and registers them with reg. Every resource permission service
+// creates its own store, the collectors are shared when they are already registered by another store.
+func newStoreMetrics(reg prometheus.Registerer) *storeMetrics {
+	m := &storeMetrics{
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubSystem,
+			Name:      "store_operation_duration_seconds",
+			Help:      "Histogram of resource permission store operation duration",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, []string{"operation", "dialect", "outcome"}),
+		rowsAffected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubSystem,
+			Name:      "store_rows_affected_total",
+			Help:      "Number of permission rows inserted or deleted by bulk resource permission store operations",
+		}, []string{"operation", "dialect"}),
+	}
+
+	if reg != nil {
+		m.operationDuration = registerOrReuse(reg, m.operationDuration)
+		m.rowsAffected = registerOrReuse(reg, m.rowsAffected)
+	}
+
+	return m
+}
+
+type serviceMetrics struct {
+	unmappedPermissions *prometheus.CounterVec
+	hookDuration        *prometheus.HistogramVec
+	droppedHooks        *prometheus.CounterVec
+	hookFailures        *prometheus.CounterVec
+	hookRetries         *prometheus.CounterVec
+}
+
+// newServiceMetrics creates the service metrics and registers them with reg, sharing collectors between services
+// of different resources like newStoreMetrics does.
+func newServiceMetrics(reg prometheus.Registerer) *serviceMetrics {
+	m := &serviceMetrics{
+		unmappedPermissions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubSystem,
+			Name:      "unmapped_permissions_total",
+			Help:      "Number of permissions returned for a resource whose actions do not match any permission level",
+		}, []string{"resource"}),
+		hookDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubSystem,
+			Name:      "hook_duration_seconds",
+			Help:      "Histogram of the duration of the hooks called once resource permissions are committed",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, []string{"resource", "hook", "outcome"}),
+		droppedHooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubSystem,
+			Name:      "hooks_dropped_total",
+			Help:      "Number of asynchronous hook calls dropped because the hook queue was full",
+		}, []string{"resource", "hook"}),
+		hookFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubSystem,
+			Name:      "hook_failures_total",
+			Help:      "Number of hook calls that failed, after their retries for the hooks called once resource permissions are committed",
+		}, []string{"resource", "hook"}),
+		hookRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubSystem,
+			Name:      "hook_retries_total",
+			Help:      "Number of retries of the hooks called once resource permissions are committed",
+		}, []string{"resource", "hook"}),
+	}
+
+	if reg != nil {
+		m.unmappedPermissions = registerOrReuse(reg, m.unmappedPermissions)
+		m.hookDuration = registerOrReuse(reg, m.hookDuration)
+		m.droppedHooks = registerOrReuse(reg, m.droppedHooks)
+		m.hookFailures = registerOrReuse(reg, m.hookFailures)
+		m.hookRetries = registerOrReuse(reg, m.hookRetries)
+	}
+
+	return m
+}
+
+func registerOrReuse[T prometheus.Collector](reg prometheus.Registerer, collector T) T {
+	err := reg.Register(collector)
+	if err == nil {
+		return collector
+	}
+
+	var alreadyRegisterErr prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegisterErr) {
+		if existing, ok := alreadyRegisterErr.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+
+	panic(err)
+}
+
+func (m *storeMetrics) observe(operation, dialect string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.operationDuration.WithLabelValues(operation, dialect, outcome).Observe(time.Since(start).Seconds())
+}
+
+func (m *serviceMetrics) observeHook(resource, hook string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.hookDuration.WithLabelValues(resource, hook, outcome).Observe(time.Since(start).Seconds())
+}
+
+func (m *storeMetrics) addRowsAffected(operation, dialect string, rows int64) {
+	if rows > 0 {
+		m.rowsAffected.WithLabelValues(operation, dialect).Add(float64(rows))
+	}
+}
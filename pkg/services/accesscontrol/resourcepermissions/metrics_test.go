@@ -0,0 +1,95 @@
+package resourcepermissions
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestIntegrationStore_Metrics(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	sql := db.InitTestDB(t)
+	reg := prometheus.NewPedanticRegistry()
+
+	// stores of different resources share the same registerer
+	store := NewStore(sql, featuremgmt.WithFeatures())
+	store.metrics = newStoreMetrics(reg)
+	require.NotPanics(t, func() { newStoreMetrics(reg) })
+
+	cmd := SetResourcePermissionCommand{
+		Actions:           []string{"datasources:query", "datasources:read"},
+		Resource:          "datasources",
+		ResourceID:        "1",
+		ResourceAttribute: "uid",
+	}
+
+	_, err := store.SetResourcePermissions(context.Background(), 1, []SetResourcePermissionsCommand{
+		{BuiltinRole: "Viewer", SetResourcePermissionCommand: cmd},
+		{BuiltinRole: "Editor", SetResourcePermissionCommand: cmd},
+	}, ResourceHooks{})
+	require.NoError(t, err)
+	_, err = store.SetBuiltInResourcePermission(context.Background(), 1, "Admin", cmd, func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error {
+		return errors.New("hook failed")
+	})
+	require.Error(t, err)
+	_, err = store.GetResourcePermissions(context.Background(), 1, GetResourcePermissionsQuery{
+		User:              &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {}}},
+		Actions:           cmd.Actions,
+		Resource:          cmd.Resource,
+		ResourceID:        cmd.ResourceID,
+		ResourceAttribute: cmd.ResourceAttribute,
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteResourcePermissions(context.Background(), 1, &DeleteResourcePermissionsCmd{
+		Resource:          cmd.Resource,
+		ResourceID:        cmd.ResourceID,
+		ResourceAttribute: cmd.ResourceAttribute,
+	}, ResourceHooks{}))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	series := map[string][]map[string]string{}
+	values := map[string]map[string]float64{}
+	for _, f := range families {
+		values[f.GetName()] = map[string]float64{}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			series[f.GetName()] = append(series[f.GetName()], labels)
+			if m.GetCounter() != nil {
+				values[f.GetName()][labels["operation"]] = m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	dialect := sql.GetDialect().DriverName()
+	durations := series["grafana_resource_permissions_store_operation_duration_seconds"]
+	sort.Slice(durations, func(i, j int) bool { return durations[i]["operation"] < durations[j]["operation"] })
+	assert.Equal(t, []map[string]string{
+		{"operation": operationDelete, "dialect": dialect, "outcome": "success"},
+		{"operation": operationGet, "dialect": dialect, "outcome": "success"},
+		{"operation": operationSetBuiltIn, "dialect": dialect, "outcome": "error"},
+		{"operation": operationSetPermission, "dialect": dialect, "outcome": "success"},
+	}, durations)
+
+	// rows written by a failed operation are rolled back and not counted
+	assert.Equal(t, map[string]float64{
+		operationSetPermission: 4,
+		operationDelete:        4,
+	}, values["grafana_resource_permissions_store_rows_affected_total"])
+}
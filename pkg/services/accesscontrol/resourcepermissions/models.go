@@ -1,6 +1,8 @@
 package resourcepermissions
 
 import (
+	"time"
+
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/auth/identity"
 )
@@ -11,6 +13,17 @@ type SetResourcePermissionCommand struct {
 	ResourceID        string
 	ResourceAttribute string
 	Permission        string
+	// ResourceAliases are former names of Resource, the permissions stored with their scopes are replaced
+	ResourceAliases []string
+
+	// Reason is the justification of the permission, when empty the stored reason is kept with KeepReason and
+	// cleared otherwise
+	Reason     string
+	KeepReason bool
+
+	// grantedBy is recorded as the grantor of the permissions written by the command, it is set by the store from the
+	// context of the write
+	grantedBy int64
 }
 
 type SetResourcePermissionsCommand struct {
@@ -30,4 +43,244 @@ type GetResourcePermissionsQuery struct {
 	InheritedScopes      []string
 	EnforceAccessControl bool
 	User                 identity.Requester
+	// AnyAction restricts the result to the roles granting at least one of these actions on the same scope, the
+	// actions returned for those roles are still the ones of Actions
+	AnyAction []string
+	// ResourceAliases are former names of Resource, permissions on their scopes are returned with the scopes of Resource
+	ResourceAliases []string
+}
+
+type AssigneeKind string
+
+const (
+	AssigneeKindUser        AssigneeKind = "user"
+	AssigneeKindTeam        AssigneeKind = "team"
+	AssigneeKindBuiltInRole AssigneeKind = "builtInRole"
+)
+
+// ResourcePermissionsQueryOptions filters and paginates the assignees returned by GetResourcePermissionsPage.
+// Limit and Offset apply to assignees, not to the returned entries: a single assignee can have managed,
+// inherited and provisioned entries for the same resource.
+type ResourcePermissionsQueryOptions struct {
+	// Limit is the maximum number of assignees in a page, 0 returns every assignee
+	Limit int64
+	// Offset is the number of assignees to skip, it is ignored when Limit is 0
+	Offset int64
+	// Kind restricts the result to a single kind of assignee
+	Kind AssigneeKind
+	// Search is matched case-insensitively against user login and email, team name and email and built-in role
+	Search string
+}
+
+type ResourcePermissionsPage struct {
+	Permissions []accesscontrol.ResourcePermission
+	// TotalCount is the number of assignees matching the query, regardless of pagination
+	TotalCount int64
+}
+
+// ResourcePermissionResult is the outcome of setting a permission on one of the resources of a bulk operation
+type ResourcePermissionResult struct {
+	ResourceID string
+	Permission *accesscontrol.ResourcePermission
+	// Unchanged is set when the permission was already set and nothing was written
+	Unchanged bool
+	Err       error
+}
+
+// PermissionOutcome is what writing the managed permission of an assignee on a resource did to it
+type PermissionOutcome string
+
+const (
+	PermissionUnchanged PermissionOutcome = "unchanged"
+	PermissionCreated   PermissionOutcome = "created"
+	PermissionUpdated   PermissionOutcome = "updated"
+	PermissionRemoved   PermissionOutcome = "removed"
+)
+
+// PermissionOutcomeOf returns the outcome of replacing the actions before with the actions after
+func PermissionOutcomeOf(before, after []string) PermissionOutcome {
+	switch {
+	case len(before) == 0 && len(after) == 0:
+		return PermissionUnchanged
+	case len(before) == 0:
+		return PermissionCreated
+	case len(after) == 0:
+		return PermissionRemoved
+	}
+
+	set := make(map[string]struct{}, len(before))
+	for _, a := range before {
+		set[a] = struct{}{}
+	}
+	matched := make(map[string]struct{}, len(after))
+	for _, a := range after {
+		if _, ok := set[a]; !ok {
+			return PermissionUpdated
+		}
+		matched[a] = struct{}{}
+	}
+	if len(matched) != len(set) {
+		return PermissionUpdated
+	}
+	return PermissionUnchanged
+}
+
+// SetResourcePermissionsResult is the result of one of the commands of a SetResourcePermissions call
+type SetResourcePermissionsResult struct {
+	Permission accesscontrol.ResourcePermission
+	Outcome    PermissionOutcome
+}
+
+// ListUsersWithAccessQuery selects the users granted an action on a resource
+type ListUsersWithAccessQuery struct {
+	Action            string
+	Resource          string
+	ResourceID        string
+	ResourceAttribute string
+	InheritedScopes   []string
+	// ImplicitRoles are organization roles granted the action regardless of the stored permissions
+	ImplicitRoles []string
+	// ResourceAliases are former names of Resource whose scopes grant the action as well
+	ResourceAliases []string
+}
+
+// DeleteDanglingAssignmentsCmd selects the managed permissions on a kind of resource to reconcile
+type DeleteDanglingAssignmentsCmd struct {
+	Resource          string
+	ResourceAttribute string
+	// ResourceAliases are former names of Resource, the permissions on their scopes are reconciled as well
+	ResourceAliases []string
+}
+
+// CountResourcePermissionsQuery selects the managed permissions counted by CountResourcePermissions
+type CountResourcePermissionsQuery struct {
+	Actions           []string
+	Resource          string
+	ResourceAttribute string
+	ResourceAliases   []string
+	// ResourceID restricts the count to the assignees of a resource, including the ones with a permission on the
+	// wildcard scopes of Resource. The assignees of every resource of Resource are counted when empty
+	ResourceID string
+}
+
+// PermissionCounts are the numbers of assignees with a managed permission by kind of assignee. An assignee with
+// permissions on several scopes, such as the scope of a resource and a wildcard scope, is counted once.
+type PermissionCounts struct {
+	Users           int64 `json:"users"`
+	ServiceAccounts int64 `json:"serviceAccounts"`
+	Teams           int64 `json:"teams"`
+	BuiltInRoles    int64 `json:"builtInRoles"`
+	// Total also counts the assignees of custom assignment kinds
+	Total int64 `json:"total"`
+}
+
+// GetTeamsMembersQuery selects the members of teams of an organization
+type GetTeamsMembersQuery struct {
+	TeamIDs []int64
+	// User and EnforceAccessControl restrict the members to the users and service accounts User can read, like
+	// GetResourcePermissionsQuery does for the permissions of users
+	User                 identity.Requester
+	EnforceAccessControl bool
+}
+
+// TeamMember is a member of a team
+type TeamMember struct {
+	TeamID           int64  `xorm:"team_id"`
+	UserID           int64  `xorm:"user_id"`
+	Login            string `xorm:"login"`
+	Email            string `xorm:"email"`
+	IsServiceAccount bool   `xorm:"is_service_account"`
+}
+
+// DanglingAssignment is a managed permission on a resource of a user or a team that no longer exists
+type DanglingAssignment struct {
+	OrgID      int64  `xorm:"org_id" json:"orgId"`
+	UserID     int64  `xorm:"user_id" json:"userId,omitempty"`
+	TeamID     int64  `xorm:"team_id" json:"teamId,omitempty"`
+	ResourceID string `xorm:"-" json:"resourceId"`
+}
+
+// DeleteUserAssignmentsCmd selects the managed permissions of a user on a kind of resource to delete
+type DeleteUserAssignmentsCmd struct {
+	UserID            int64
+	Resource          string
+	ResourceAttribute string
+	// ResourceAliases are former names of Resource, the permissions on their scopes are deleted as well
+	ResourceAliases []string
+	// BatchSize is the number of resources whose permissions are deleted in a single transaction
+	BatchSize int
+}
+
+// UserAssignment is a managed permission of a user on a resource
+type UserAssignment struct {
+	OrgID      int64  `json:"orgId"`
+	UserID     int64  `json:"userId"`
+	ResourceID string `json:"resourceId"`
+}
+
+// PermissionsLock records that the permissions of a resource are locked. While a lock exists the permissions of
+// the resource can only be changed by server admins, until a server admin removes the lock.
+type PermissionsLock struct {
+	ID         int64     `xorm:"pk autoincr 'id'" json:"-"`
+	OrgID      int64     `xorm:"org_id" json:"-"`
+	Resource   string    `xorm:"resource" json:"-"`
+	ResourceID string    `xorm:"resource_id" json:"-"`
+	LockedBy   int64     `xorm:"locked_by" json:"lockedBy"`
+	Created    time.Time `xorm:"created" json:"created"`
+}
+
+func (PermissionsLock) TableName() string {
+	return "resource_permission_lock"
+}
+
+// ResourceOwner records the user or service account owning a resource. The owner is granted the highest permission
+// level of the resource, which cannot be changed or removed until the ownership is transferred.
+type ResourceOwner struct {
+	ID         int64     `xorm:"pk autoincr 'id'" json:"-"`
+	OrgID      int64     `xorm:"org_id" json:"-"`
+	Resource   string    `xorm:"resource" json:"-"`
+	ResourceID string    `xorm:"resource_id" json:"-"`
+	UserID     int64     `xorm:"user_id" json:"userId"`
+	Created    time.Time `xorm:"created" json:"created"`
+	Updated    time.Time `xorm:"updated" json:"updated"`
+}
+
+func (ResourceOwner) TableName() string {
+	return "resource_permission_owner"
+}
+
+// UsageStatsQuery selects the managed permissions on a kind of resource to compute usage statistics of
+type UsageStatsQuery struct {
+	Resource          string
+	ResourceAttribute string
+	// AssignmentKinds are the kinds of assignees whose assignments are counted, e.g. users or teams
+	AssignmentKinds []string
+}
+
+// UsageStats describes how managed permissions are used on a kind of resource, in every organization. An assignment
+// is the managed permission of a user, team, built-in role or assignee of a custom kind on a resource.
+type UsageStats struct {
+	// Resources is the number of resources with at least one assignment
+	Resources int64 `json:"resources"`
+	// Assignments is the number of assignments by kind of assignee
+	Assignments map[string]int64 `json:"assignments"`
+	// AssignmentsPerResourceP95 is the 95th percentile of the number of assignments of the resources with assignments
+	AssignmentsPerResourceP95 int64 `json:"assignmentsPerResourceP95"`
+}
+
+// TranslateResourceIDsCmd selects the resources of a kind whose ids are rewritten in the scopes of their permissions,
+// their owner and their lock
+type TranslateResourceIDsCmd struct {
+	Resource          string
+	ResourceAttribute string
+	// ResourceIDs restricts the rewrite to these resources, every resource of Resource is rewritten when empty
+	ResourceIDs []string
+	// Translate returns the id a resource of an organization is rewritten to, its own id to keep it
+	Translate func(orgID int64, resourceID string) (string, error)
+}
+
+// RenameManagedRolesCmd renames the managed roles named with the prefix From to the prefix To
+type RenameManagedRolesCmd struct {
+	From string
+	To   string
 }
@@ -0,0 +1,136 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+)
+
+// HandleResourceMoved applies policy to the managed permissions of a resource moved from oldParentScope to
+// newParentScope, e.g. a dashboard moved to another folder. It is called by the service owning the resource once the
+// resource is moved, so that Options.InheritedScopesSolver already resolves newParentScope as its nearest ancestor.
+// The permissions are read and removed with a single SetPermissions call in one transaction, calling the hooks of the
+// service, and the after-commit hooks once it commits. The permission of the owner of the resource is never removed.
+func (s *Service) HandleResourceMoved(ctx context.Context, orgID int64, resourceID, oldParentScope, newParentScope string, policy accesscontrol.ResourceMovePolicy) error {
+	switch policy {
+	case accesscontrol.MovePolicyKeep:
+		return nil
+	case accesscontrol.MovePolicyDropRedundant, accesscontrol.MovePolicyClear:
+	default:
+		return fmt.Errorf("%w: unknown move policy %q", ErrInvalidMove, policy)
+	}
+
+	if oldParentScope == newParentScope {
+		return nil
+	}
+
+	// the permissions are read and removed in the same transaction, so that no permission granted meanwhile is lost
+	return s.inTransaction(ctx, func(ctx context.Context) error {
+		return s.applyMovePolicy(ctx, orgID, resourceID, newParentScope, policy)
+	})
+}
+
+// applyMovePolicy removes the managed permissions of a moved resource according to policy
+func (s *Service) applyMovePolicy(ctx context.Context, orgID int64, resourceID, newParentScope string, policy accesscontrol.ResourceMovePolicy) error {
+	managed, err := s.getManagedPermissions(ctx, orgID, resourceID)
+	if err != nil || len(managed) == 0 {
+		return err
+	}
+
+	owner, err := s.GetOwner(ctx, orgID, resourceID)
+	if err != nil {
+		return err
+	}
+
+	var inherited map[accesscontrol.SetResourcePermissionCommand]map[string]struct{}
+	if policy == accesscontrol.MovePolicyDropRedundant {
+		inherited, err = s.getInheritedActions(ctx, orgID, resourceID, newParentScope)
+		if err != nil {
+			return err
+		}
+	}
+
+	var commands []accesscontrol.SetResourcePermissionCommand
+	for key, permission := range managed {
+		if owner != nil && key.UserID == owner.UserID {
+			continue
+		}
+		if policy == accesscontrol.MovePolicyDropRedundant {
+			actions, err := s.levelActions(permission)
+			if err != nil {
+				return err
+			}
+			if !grantsAll(inherited[key], actions) {
+				continue
+			}
+		}
+		commands = append(commands, key)
+	}
+
+	if len(commands) == 0 {
+		return nil
+	}
+
+	sortCommands(commands)
+	_, err = s.SetPermissions(withoutRequiredReason(ctx), orgID, resourceID, commands...)
+	return err
+}
+
+// getInheritedActions returns the actions of the permission levels every user, team and built-in role is granted on
+// the ancestors of a resource, which must have newParentScope as its nearest ancestor
+func (s *Service) getInheritedActions(ctx context.Context, orgID int64, resourceID, newParentScope string) (map[accesscontrol.SetResourcePermissionCommand]map[string]struct{}, error) {
+	inheritedScopes, err := s.InheritedScopes(ctx, orgID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(inheritedScopes) == 0 || inheritedScopes[0] != newParentScope {
+		return nil, fmt.Errorf("%w: %s %s is not under %s", ErrInvalidMove, s.options.Resource, resourceID, newParentScope)
+	}
+
+	// the inherited permissions of every assignee, regardless of the users and teams the caller can see
+	permissions, err := s.store.GetResourcePermissions(ctx, orgID, GetResourcePermissionsQuery{
+		User: accesscontrol.BackgroundUser("resource_permissions_move", orgID, org.RoleAdmin, []accesscontrol.Permission{
+			{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+			{Action: accesscontrol.ActionTeamsRead, Scope: accesscontrol.ScopeTeamsAll},
+			{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll},
+		}),
+		Actions:           s.getLevels().actions,
+		Resource:          s.options.Resource,
+		ResourceID:        resourceID,
+		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
+		OnlyManaged:       s.options.OnlyManaged,
+		InheritedScopes:   inheritedScopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inherited := map[accesscontrol.SetResourcePermissionCommand]map[string]struct{}{}
+	for _, p := range permissions {
+		if !p.IsInherited {
+			continue
+		}
+		key := accesscontrol.SetResourcePermissionCommand{UserID: p.UserId, TeamID: p.TeamId, BuiltinRole: p.BuiltInRole}
+		if inherited[key] == nil {
+			inherited[key] = map[string]struct{}{}
+		}
+		for _, a := range p.Actions {
+			inherited[key][a] = struct{}{}
+		}
+	}
+	return inherited, nil
+}
+
+// grantsAll reports whether granted contains every action of actions
+func grantsAll(granted map[string]struct{}, actions []string) bool {
+	for _, a := range actions {
+		if _, ok := granted[a]; !ok {
+			return false
+		}
+	}
+	return true
+}
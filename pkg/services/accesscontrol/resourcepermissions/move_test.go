@@ -0,0 +1,202 @@
+package resourcepermissions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+// moveTestOptions are the test options with every resource under the folder scope folders:uid:new
+func moveTestOptions() Options {
+	options := testOptions
+	options.InheritedScopesSolver = func(ctx context.Context, orgID int64, resourceID string) ([]string, error) {
+		return []string{"folders:uid:new", "folders:uid:root"}, nil
+	}
+	return options
+}
+
+func TestService_HandleResourceMoved(t *testing.T) {
+	type parentPermission struct {
+		userID      bool
+		teamID      bool
+		builtInRole string
+		scope       string
+		actions     []string
+	}
+
+	type testCase struct {
+		desc     string
+		policy   accesscontrol.ResourceMovePolicy
+		parent   []parentPermission
+		expected []string
+	}
+
+	tests := []testCase{
+		{
+			desc:     "keep should not change the permissions",
+			policy:   accesscontrol.MovePolicyKeep,
+			parent:   []parentPermission{{userID: true, scope: "new", actions: []string{"dashboards:read"}}},
+			expected: []string{"user:View", "team:Edit", "Viewer:View"},
+		},
+		{
+			desc:     "clear should remove every permission",
+			policy:   accesscontrol.MovePolicyClear,
+			expected: []string{},
+		},
+		{
+			desc:   "dropRedundant should remove the permissions granted by the new parent",
+			policy: accesscontrol.MovePolicyDropRedundant,
+			parent: []parentPermission{
+				{userID: true, scope: "new", actions: []string{"dashboards:read"}},
+				{teamID: true, scope: "root", actions: []string{"dashboards:read", "dashboards:write", "dashboards:delete"}},
+			},
+			expected: []string{"Viewer:View"},
+		},
+		{
+			desc:   "dropRedundant should keep the permissions partially granted by the new parent",
+			policy: accesscontrol.MovePolicyDropRedundant,
+			parent: []parentPermission{
+				{teamID: true, scope: "new", actions: []string{"dashboards:read"}},
+				{builtInRole: "Viewer", scope: "new", actions: []string{"dashboards:read"}},
+			},
+			expected: []string{"user:View", "team:Edit"},
+		},
+		{
+			desc:   "dropRedundant should keep the permissions granted to other assignees by the new parent",
+			policy: accesscontrol.MovePolicyDropRedundant,
+			parent: []parentPermission{
+				{builtInRole: "Editor", scope: "new", actions: []string{"dashboards:read", "dashboards:write", "dashboards:delete"}},
+			},
+			expected: []string{"user:View", "team:Edit", "Viewer:View"},
+		},
+		{
+			desc:     "dropRedundant should keep the permissions granted by the old parent",
+			policy:   accesscontrol.MovePolicyDropRedundant,
+			parent:   []parentPermission{{userID: true, scope: "old", actions: []string{"dashboards:read"}}},
+			expected: []string{"user:View", "team:Edit", "Viewer:View"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var removed []string
+			options := moveTestOptions()
+			options.OnSetUser = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+				if permission == "" {
+					removed = append(removed, "user")
+				}
+				return nil
+			}
+			service, sql, teamSvc := setupTestEnvironment(t, options)
+			usr := createOwnerTestUser(t, sql, "user", false)
+			tm, err := teamSvc.CreateTeam("team", "", 1)
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			_, err = service.SetPermissions(ctx, 1, "1",
+				accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "View"},
+				accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "Edit"},
+				accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View"},
+			)
+			require.NoError(t, err)
+
+			for _, p := range tt.parent {
+				cmd := SetResourcePermissionCommand{Actions: p.actions, Resource: "folders", ResourceID: p.scope, ResourceAttribute: "uid"}
+				switch {
+				case p.userID:
+					_, err = service.store.SetUserResourcePermission(ctx, 1, accesscontrol.User{ID: usr.ID}, cmd, nil)
+				case p.teamID:
+					_, err = service.store.SetTeamResourcePermission(ctx, 1, tm.ID, cmd, nil)
+				default:
+					_, err = service.store.SetBuiltInResourcePermission(ctx, 1, p.builtInRole, cmd, nil)
+				}
+				require.NoError(t, err)
+			}
+
+			err = service.HandleResourceMoved(ctx, 1, "1", "folders:uid:old", "folders:uid:new", tt.policy)
+			require.NoError(t, err)
+
+			managed, err := service.getManagedPermissions(ctx, 1, "1")
+			require.NoError(t, err)
+			actual := make([]string, 0, len(managed))
+			for key, permission := range managed {
+				switch {
+				case key.UserID == usr.ID:
+					actual = append(actual, "user:"+permission)
+				case key.TeamID == tm.ID:
+					actual = append(actual, "team:"+permission)
+				default:
+					actual = append(actual, key.BuiltinRole+":"+permission)
+				}
+			}
+			assert.ElementsMatch(t, tt.expected, actual)
+
+			_, userKept := managed[accesscontrol.SetResourcePermissionCommand{UserID: usr.ID}]
+			if userKept {
+				assert.Empty(t, removed)
+			} else {
+				assert.Equal(t, []string{"user"}, removed, "the removal of the user permission should call the hook")
+			}
+		})
+	}
+}
+
+func TestService_HandleResourceMovedOwner(t *testing.T) {
+	options := moveTestOptions()
+	options.EnableOwner = true
+	service, sql, _ := setupTestEnvironment(t, options)
+	owner := createOwnerTestUser(t, sql, "owner", false)
+	other := createOwnerTestUser(t, sql, "other", false)
+
+	ctx := context.Background()
+	require.NoError(t, service.SetOwner(ctx, 1, "1", accesscontrol.User{ID: owner.ID}))
+	_, err := service.SetUserPermission(ctx, 1, accesscontrol.User{ID: other.ID}, "1", "View")
+	require.NoError(t, err)
+
+	err = service.HandleResourceMoved(ctx, 1, "1", "folders:uid:old", "folders:uid:new", accesscontrol.MovePolicyClear)
+	require.NoError(t, err)
+
+	managed, err := service.getManagedPermissions(ctx, 1, "1")
+	require.NoError(t, err)
+	assert.Equal(t, map[accesscontrol.SetResourcePermissionCommand]string{{UserID: owner.ID}: "Edit"}, managed)
+}
+
+func TestService_HandleResourceMovedInvalid(t *testing.T) {
+	service, _, _ := setupTestEnvironment(t, moveTestOptions())
+	_, err := service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+	require.NoError(t, err)
+
+	t.Run("should reject unknown policies", func(t *testing.T) {
+		err := service.HandleResourceMoved(context.Background(), 1, "1", "folders:uid:old", "folders:uid:new", "unknown")
+		assert.ErrorIs(t, err, ErrInvalidMove)
+	})
+
+	t.Run("should reject resources that are not under their new parent", func(t *testing.T) {
+		err := service.HandleResourceMoved(context.Background(), 1, "1", "folders:uid:old", "folders:uid:other", accesscontrol.MovePolicyDropRedundant)
+		assert.ErrorIs(t, err, ErrInvalidMove)
+	})
+
+	t.Run("should keep the permissions of a locked resource", func(t *testing.T) {
+		require.NoError(t, service.LockPermissions(context.Background(), 1, "1", 1))
+		t.Cleanup(func() { require.NoError(t, service.UnlockPermissions(context.Background(), 1, "1")) })
+
+		err := service.HandleResourceMoved(context.Background(), 1, "1", "folders:uid:old", "folders:uid:new", accesscontrol.MovePolicyClear)
+		assert.ErrorIs(t, err, ErrPermissionsLocked)
+		managed, err := service.getManagedPermissions(context.Background(), 1, "1")
+		require.NoError(t, err)
+		assert.Len(t, managed, 1)
+	})
+
+	t.Run("should do nothing when the parent does not change", func(t *testing.T) {
+		err := service.HandleResourceMoved(context.Background(), 1, "1", "folders:uid:new", "folders:uid:new", accesscontrol.MovePolicyClear)
+		require.NoError(t, err)
+		managed, err := service.getManagedPermissions(context.Background(), 1, "1")
+		require.NoError(t, err)
+		assert.Len(t, managed, 1)
+	})
+}
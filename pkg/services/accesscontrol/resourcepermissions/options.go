@@ -2,20 +2,44 @@ package resourcepermissions
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/usagestats"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/web"
 )
 
 type ResourceValidator func(ctx context.Context, orgID int64, resourceID string) error
 type InheritedScopesSolver func(ctx context.Context, orgID int64, resourceID string) ([]string, error)
+type RootResourceChecker func(ctx context.Context, orgID int64, resourceID string) (bool, error)
+type AssignmentsResolver func(ctx context.Context, orgID int64) Assignments
 
 type Options struct {
 	// Resource is the action and scope prefix that is generated
 	Resource string
+	// ResourceAliases are former names of Resource. The endpoints are registered under each alias as well and
+	// permissions stored with the scopes of an alias are read and evaluated as the ones of Resource, see
+	// Service.NormalizeResourceAliases to rewrite them
+	ResourceAliases []string
 	// ResourceAttribute is the attribute the scope should be based on (e.g. id or uid)
 	ResourceAttribute string
+	// ScopeTranslator if configured translates the scopes of the resources and of their ancestors before they are
+	// stored or matched against the stored permissions, and back when they are read, e.g. to prefix the resource ids
+	// with a tenant. The HTTP API, the hooks and the methods of the service keep using the untranslated resource ids
+	ScopeTranslator ScopeTranslator
+	// MatchUntranslatedScopes makes the service read and write the permissions stored before ScopeTranslator was
+	// configured as well. They are rewritten to the translated scopes the first time the service accesses the
+	// permissions of their resource, see Service.TranslateStoredScopes to rewrite them all at once. The untranslated
+	// permissions keep granting access regardless, evaluation matches the untranslated scopes before resolving them
+	MatchUntranslatedScopes bool
 	// OnlyManaged will tell the service to return all permissions if set to false and only managed permissions if set to true
 	OnlyManaged bool
 	// ResourceValidator is a validator function that will be called before each assignment.
@@ -23,9 +47,19 @@ type Options struct {
 	ResourceValidator ResourceValidator
 	// Assignments decides what we can assign permissions to (users/teams/builtInRoles)
 	Assignments Assignments
+	// AssignmentsResolver if configured decides what we can assign permissions to in an organization. It can only
+	// disable assignment types enabled in Assignments, whose endpoints are registered for every organization
+	AssignmentsResolver AssignmentsResolver
 	// PermissionsToAction is a map of friendly named permissions and what access control actions they should generate.
 	// E.g. Edit permissions should generate dashboards:read, dashboards:write and dashboards:delete
 	PermissionsToActions map[string][]string
+	// PermissionLicenses maps permission levels to the license feature they require, e.g. an Admin level only available
+	// with an enterprise license. Levels whose feature is not enabled cannot be granted and are not listed by the
+	// description endpoint, the permissions already stored at those levels can still be read and removed
+	PermissionLicenses map[string]string
+	// Settings if configured reloads the permission levels from the settings section resource_permissions.<Resource>,
+	// see Service.Reload. PermissionsToActions are the levels until the section is reloaded
+	Settings setting.Provider
 	// ReaderRoleName is the display name for the generated fixed reader role
 	ReaderRoleName string
 	// WriterRoleName is the display name for the generated fixed writer role
@@ -38,8 +72,214 @@ type Options struct {
 	OnSetTeam func(session *db.Session, orgID, teamID int64, resourceID, permission string) error
 	// OnSetBuiltInRole if configured will be called each time a permission is set for a built-in role
 	OnSetBuiltInRole func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error
-	// InheritedScopesSolver if configured can generate additional scopes that will be used when fetching permissions for a resource
+	// OnUserPermissionRemoved if configured will be called each time the permission of a user is removed, either by
+	// setting an empty permission or by deleting the permissions of the resource. OnSetUser is still called with an
+	// empty permission when a permission is set empty
+	OnUserPermissionRemoved UserResourceRemovedHookFunc
+	// OnTeamPermissionRemoved if configured will be called each time the permission of a team is removed
+	OnTeamPermissionRemoved TeamResourceRemovedHookFunc
+	// OnBuiltInRolePermissionRemoved if configured will be called each time the permission of a built-in role is removed
+	OnBuiltInRolePermissionRemoved BuiltinResourceRemovedHookFunc
+	// OnBulkSet if configured will be called once after all permissions of a SetPermissions call have been stored.
+	// When set, OnSetUser, OnSetTeam and OnSetBuiltInRole are not called for SetPermissions, the removal hooks still are
+	OnBulkSet BulkResourceHookFunc
+	// AsyncHooks if configured calls OnBulkSet, OnSetUser, OnSetTeam and OnSetBuiltInRole from a bounded queue
+	// drained by workers of the service instead of in the request path. Hooks are synchronous when not configured
+	AsyncHooks *AsyncHooks
+	// HookRetry if configured retries OnBulkSet and the asynchronous set hooks when they fail. Their errors never fail
+	// the write since the permissions are already committed, the last one is logged. The errors of the hooks called in
+	// the transaction of a write, such as OnSetUser without AsyncHooks, roll the write back and are returned as a HookError
+	HookRetry *HookRetry
+	// InheritedScopesSolver if configured returns the scopes of all ancestors of a resource, ordered from the nearest ancestor to the root.
+	// Permissions on those scopes are returned as inherited permissions and allow managing the permissions of the resource
 	InheritedScopesSolver InheritedScopesSolver
 	// LicenseMV if configured is applied to endpoints that can modify permissions
 	LicenseMW web.Handler
+	// DefaultPermissions are assigned to new resources by SetDefaultPermissions
+	DefaultPermissions []DefaultPermission
+	// IsRootResource if configured reports whether a resource has no parent. Default permissions with RootOnly
+	// are only assigned to root resources, when not configured every resource is a root resource
+	IsRootResource RootResourceChecker
+	// AssignmentKinds registers additional kinds of assignees permissions can be granted to, next to users, teams and built-in roles
+	AssignmentKinds []AssignmentKind
+	// EnableOwner records an owner for each resource, see Service.SetOwner. The owner is granted the highest permission
+	// level, which cannot be changed or removed until the ownership is transferred
+	EnableOwner bool
+	// ReconciliationInterval if configured is the interval at which Service.Run removes the permissions of deleted
+	// users and teams, see Service.Reconcile
+	ReconciliationInterval time.Duration
+	// UsageStats if configured reports the usage statistics of the managed permissions on the resource, see Service.UsageStats
+	UsageStats usagestats.Service
+	// Registerer if configured registers the metrics of the service and its store, services of different resources
+	// can share the same registerer
+	Registerer prometheus.Registerer
+	// RequireReason rejects the permissions granted without a reason, such as a ticket reference, see WithReason.
+	// Removing a permission does not require a reason, nor do the permissions the service sets itself such as the
+	// default permissions
+	RequireReason bool
+	// ReasonPolicy decides whether a permission updated without a reason keeps its previous reason or loses it,
+	// ReasonPolicyKeep when not configured
+	ReasonPolicy ReasonPolicy
+	// Decorators wrap the permission reads and writes of the HTTP API and of Service.Manager, the first decorator is the outermost
+	Decorators []Decorator
+	// ManagedRolePrefix is the prefix of the names of the managed roles holding the permissions written by the service,
+	// accesscontrol.ManagedRolePrefix when not configured. Managed roles hold the permissions of every kind of resource,
+	// the services sharing a database should use the same prefix
+	ManagedRolePrefix string
+	// PreviousManagedRolePrefixes are the prefixes managed roles were named with before ManagedRolePrefix. Their roles are
+	// read and updated like the roles named with ManagedRolePrefix until they are renamed, see RenameManagedRoles
+	PreviousManagedRolePrefixes []string
+}
+
+// DefaultPermission describes a permission assigned to new resources, either to the creator of the resource
+// or to a built-in role.
+type DefaultPermission struct {
+	// Creator assigns Permission to the user creating the resource
+	Creator bool `json:"creator,omitempty"`
+	// BuiltInRole assigns Permission to a built-in role
+	BuiltInRole string `json:"builtInRole,omitempty"`
+	Permission  string `json:"permission"`
+	// RootOnly skips the permission for resources that have a parent, they inherit the permissions of the parent instead
+	RootOnly bool `json:"rootOnly,omitempty"`
+}
+
+// AssignmentKind describes a kind of assignee, such as a group provisioned by an external system, that is not
+// a user, a team or a built-in role.
+type AssignmentKind struct {
+	// Name identifies the kind. Permissions are set with POST /api/access-control/<resource>/:resourceID/<Name>/:assigneeID
+	// and stored in a managed role named managed:<Name>:<assigneeID>:permissions
+	Name string
+	// Resolve is called before each assignment and should return ErrAssigneeNotFound if the assignee does not exist
+	Resolve func(ctx context.Context, orgID int64, assigneeID string) error
+	// BindRole is called when the managed role of an assignee is created and should grant the role to the assignee
+	BindRole AssignmentRoleBinderFunc
+}
+
+// Validate checks the options of a resource before it is registered and returns every problem found, New fails with
+// it so that a misconfigured resource aborts the startup. The actions of the levels are checked once the fixed roles
+// are registered, see ValidateActions.
+func (o Options) Validate() error {
+	var errs []error
+
+	if !isScopeName(o.Resource) {
+		errs = append(errs, fmt.Errorf("invalid resource name %q", o.Resource))
+	}
+	if !isScopeName(o.ResourceAttribute) {
+		errs = append(errs, fmt.Errorf("invalid resource attribute %q", o.ResourceAttribute))
+	}
+
+	if len(o.PermissionsToActions) == 0 {
+		errs = append(errs, errors.New("at least one permission level is required"))
+	} else {
+		levels := make([]PermissionLevel, 0, len(o.PermissionsToActions))
+		for name, actions := range o.PermissionsToActions {
+			levels = append(levels, PermissionLevel{Name: name, Actions: actions})
+		}
+		if _, err := validatePermissionLevels(levels); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, d := range o.DefaultPermissions {
+		if _, ok := o.PermissionsToActions[d.Permission]; !ok {
+			errs = append(errs, fmt.Errorf("default permission %q is not a permission level", d.Permission))
+		}
+	}
+
+	for permission, feature := range o.PermissionLicenses {
+		if _, ok := o.PermissionsToActions[permission]; !ok || feature == "" {
+			errs = append(errs, fmt.Errorf("invalid license feature %q for permission %q", feature, permission))
+		}
+	}
+
+	names := map[string]struct{}{o.Resource: {}}
+	for _, alias := range o.ResourceAliases {
+		if _, ok := names[alias]; ok || !isScopeName(alias) {
+			errs = append(errs, fmt.Errorf("invalid resource alias %q", alias))
+		}
+		names[alias] = struct{}{}
+	}
+
+	if o.MatchUntranslatedScopes && o.ScopeTranslator == nil {
+		errs = append(errs, errors.New("MatchUntranslatedScopes requires a ScopeTranslator"))
+	}
+
+	if o.EnableOwner && (!o.Assignments.Users || len(o.PermissionsToActions) == 0) {
+		errs = append(errs, errors.New("EnableOwner requires user assignments and permission levels"))
+	}
+
+	if o.AsyncHooks != nil {
+		switch o.AsyncHooks.Overflow {
+		case "", HookOverflowBlock, HookOverflowDrop:
+		default:
+			errs = append(errs, fmt.Errorf("unknown hook overflow policy %q", o.AsyncHooks.Overflow))
+		}
+	}
+
+	if o.HookRetry != nil {
+		if o.HookRetry.Retries < 0 || o.HookRetry.Backoff < 0 || o.HookRetry.MaxBackoff < 0 {
+			errs = append(errs, errors.New("hook retries and backoffs cannot be negative"))
+		}
+	}
+
+	switch o.ReasonPolicy {
+	case "", ReasonPolicyKeep, ReasonPolicyClear:
+	default:
+		errs = append(errs, fmt.Errorf("unknown reason policy %q", o.ReasonPolicy))
+	}
+
+	if err := o.managedRoleNames().validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	hooks := []struct {
+		name       string
+		configured bool
+		enabled    bool
+	}{
+		{"OnSetUser", o.OnSetUser != nil, o.Assignments.Users},
+		{"OnUserPermissionRemoved", o.OnUserPermissionRemoved != nil, o.Assignments.Users},
+		{"OnSetTeam", o.OnSetTeam != nil, o.Assignments.Teams},
+		{"OnTeamPermissionRemoved", o.OnTeamPermissionRemoved != nil, o.Assignments.Teams},
+		{"OnSetBuiltInRole", o.OnSetBuiltInRole != nil, o.Assignments.BuiltInRoles},
+		{"OnBuiltInRolePermissionRemoved", o.OnBuiltInRolePermissionRemoved != nil, o.Assignments.BuiltInRoles},
+	}
+	for _, h := range hooks {
+		if h.configured && !h.enabled {
+			errs = append(errs, fmt.Errorf("%s is configured but its assignments are disabled", h.name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: resource %s: %w", ErrInvalidOptions, o.Resource, errors.Join(errs...))
+	}
+	return nil
+}
+
+// ValidateActions checks that the actions of the permission levels are registered with access control, that is granted
+// by a declared role. New registers it as an actions validator, so that RegisterFixedRoles fails on an unknown action.
+func (o Options) ValidateActions(registered func(action string) bool) error {
+	var errs []error
+	names := make([]string, 0, len(o.PermissionsToActions))
+	for name := range o.PermissionsToActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, action := range o.PermissionsToActions[name] {
+			if !registered(action) {
+				errs = append(errs, fmt.Errorf("action %q of permission %q is not registered", action, name))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: resource %s: %w", ErrInvalidOptions, o.Resource, errors.Join(errs...))
+	}
+	return nil
+}
+
+// isScopeName reports whether name can be a part of a scope, it cannot be empty or contain the scope separator
+func isScopeName(name string) bool {
+	return name != "" && !strings.Contains(name, ":")
 }
@@ -0,0 +1,246 @@
+package resourcepermissions
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/mock"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/licensing"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	type testCase struct {
+		desc        string
+		options     func(o *Options)
+		expectedErr string
+	}
+
+	tests := []testCase{
+		{
+			desc:    "should accept the test options",
+			options: func(o *Options) {},
+		},
+		{
+			desc:        "should reject options without permission levels",
+			options:     func(o *Options) { o.PermissionsToActions = nil },
+			expectedErr: "at least one permission level is required",
+		},
+		{
+			desc:        "should reject a resource attribute with a scope separator",
+			options:     func(o *Options) { o.ResourceAttribute = "id:v1" },
+			expectedErr: `invalid resource attribute "id:v1"`,
+		},
+		{
+			desc:        "should reject options without a resource attribute",
+			options:     func(o *Options) { o.ResourceAttribute = "" },
+			expectedErr: `invalid resource attribute ""`,
+		},
+		{
+			desc:        "should reject a resource alias equal to the resource",
+			options:     func(o *Options) { o.ResourceAliases = []string{"dashboards"} },
+			expectedErr: `invalid resource alias "dashboards"`,
+		},
+		{
+			desc:        "should reject a resource alias with a scope separator",
+			options:     func(o *Options) { o.ResourceAliases = []string{"dashboards:v1"} },
+			expectedErr: `invalid resource alias "dashboards:v1"`,
+		},
+		{
+			desc: "should reject a permission level without a name",
+			options: func(o *Options) {
+				o.PermissionsToActions = map[string][]string{"": {"dashboards:read"}}
+			},
+			expectedErr: "permission level without a name",
+		},
+		{
+			desc: "should reject a permission level without actions",
+			options: func(o *Options) {
+				o.PermissionsToActions = map[string][]string{"View": {}}
+			},
+			expectedErr: "permission level View has no actions",
+		},
+		{
+			desc: "should reject a malformed action",
+			options: func(o *Options) {
+				o.PermissionsToActions = map[string][]string{"View": {"dashboards.read"}}
+			},
+			expectedErr: `permission level View has malformed action "dashboards.read"`,
+		},
+		{
+			desc: "should reject an action with whitespace",
+			options: func(o *Options) {
+				o.PermissionsToActions = map[string][]string{"View": {"dashboards: read"}}
+			},
+			expectedErr: `permission level View has malformed action "dashboards: read"`,
+		},
+		{
+			desc: "should reject a level not granting the actions of a lower level",
+			options: func(o *Options) {
+				o.PermissionsToActions = map[string][]string{
+					"View": {"dashboards:read"},
+					"Edit": {"dashboards:write", "dashboards:delete"},
+				}
+			},
+			expectedErr: "permission level Edit does not grant dashboards:read of permission level View",
+		},
+		{
+			desc: "should reject a default permission that is not a level",
+			options: func(o *Options) {
+				o.DefaultPermissions = []DefaultPermission{{Creator: true, Permission: "Admin"}}
+			},
+			expectedErr: `default permission "Admin" is not a permission level`,
+		},
+		{
+			desc:        "should reject matching untranslated scopes without a translator",
+			options:     func(o *Options) { o.MatchUntranslatedScopes = true },
+			expectedErr: "MatchUntranslatedScopes requires a ScopeTranslator",
+		},
+		{
+			desc:        "should reject an unknown reason policy",
+			options:     func(o *Options) { o.ReasonPolicy = "drop" },
+			expectedErr: `unknown reason policy "drop"`,
+		},
+		{
+			desc:        "should reject a license feature for an unknown permission",
+			options:     func(o *Options) { o.PermissionLicenses = map[string]string{"Admin": "enterprise"} },
+			expectedErr: `invalid license feature "enterprise" for permission "Admin"`,
+		},
+		{
+			desc:        "should reject an unknown hook overflow policy",
+			options:     func(o *Options) { o.AsyncHooks = &AsyncHooks{Overflow: "ignore"} },
+			expectedErr: `unknown hook overflow policy "ignore"`,
+		},
+		{
+			desc:        "should reject negative hook retries",
+			options:     func(o *Options) { o.HookRetry = &HookRetry{Retries: -1} },
+			expectedErr: "hook retries and backoffs cannot be negative",
+		},
+		{
+			desc:        "should reject a managed role prefix without a trailing colon",
+			options:     func(o *Options) { o.ManagedRolePrefix = "rbac" },
+			expectedErr: `invalid managed role prefix "rbac"`,
+		},
+		{
+			desc:        "should reject a managed role prefix with a LIKE wildcard",
+			options:     func(o *Options) { o.ManagedRolePrefix = "blue_green:" },
+			expectedErr: `invalid managed role prefix "blue_green:"`,
+		},
+		{
+			desc:        "should reject a managed role prefix overlapping fixed roles",
+			options:     func(o *Options) { o.ManagedRolePrefix = "fixed:managed:" },
+			expectedErr: `it overlaps the reserved prefix "fixed:"`,
+		},
+		{
+			desc: "should reject a previous managed role prefix overlapping the current one",
+			options: func(o *Options) {
+				o.ManagedRolePrefix = "managed:blue:"
+				o.PreviousManagedRolePrefixes = []string{"managed:"}
+			},
+			expectedErr: `managed role prefixes "managed:blue:" and "managed:" overlap`,
+		},
+		{
+			desc: "should reject owners when users are disabled",
+			options: func(o *Options) {
+				o.EnableOwner = true
+				o.Assignments.Users = false
+			},
+			expectedErr: "EnableOwner requires user assignments and permission levels",
+		},
+		{
+			desc: "should reject a user hook when users are disabled",
+			options: func(o *Options) {
+				o.Assignments.Users = false
+				o.OnSetUser = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+					return nil
+				}
+			},
+			expectedErr: "OnSetUser is configured but its assignments are disabled",
+		},
+		{
+			desc: "should reject a team removal hook when teams are disabled",
+			options: func(o *Options) {
+				o.Assignments.Teams = false
+				o.OnTeamPermissionRemoved = func(session *db.Session, orgID, teamID int64, resourceID string) error { return nil }
+			},
+			expectedErr: "OnTeamPermissionRemoved is configured but its assignments are disabled",
+		},
+		{
+			desc: "should reject a built-in role hook when built-in roles are disabled",
+			options: func(o *Options) {
+				o.Assignments.BuiltInRoles = false
+				o.OnSetBuiltInRole = func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error { return nil }
+			},
+			expectedErr: "OnSetBuiltInRole is configured but its assignments are disabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			options := testOptions
+			tt.options(&options)
+
+			err := options.Validate()
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidOptions)
+			assert.Contains(t, err.Error(), "resource dashboards")
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestOptions_Validate_aggregatesErrors(t *testing.T) {
+	options := testOptions
+	options.ResourceAttribute = ""
+	options.ReasonPolicy = "drop"
+	options.DefaultPermissions = []DefaultPermission{{BuiltInRole: "Viewer", Permission: "Admin"}}
+
+	err := options.Validate()
+	require.ErrorIs(t, err, ErrInvalidOptions)
+	assert.Equal(t, "invalid resource permissions options: resource dashboards: invalid resource attribute \"\"\n"+
+		"default permission \"Admin\" is not a permission level\n"+
+		"unknown reason policy \"drop\"", err.Error())
+}
+
+func TestNew_invalidOptions(t *testing.T) {
+	options := testOptions
+	options.PermissionsToActions = map[string][]string{"View": {"dashboards.read"}}
+
+	_, err := New(options, featuremgmt.WithFeatures(), routing.NewRouteRegister(), nil, nil, nil, nil, nil, nil)
+	require.ErrorIs(t, err, ErrInvalidOptions)
+}
+
+func TestOptions_ValidateActions(t *testing.T) {
+	registered := func(actions ...string) func(action string) bool {
+		return func(action string) bool {
+			return slices.Contains(actions, action)
+		}
+	}
+
+	require.NoError(t, testOptions.ValidateActions(registered("dashboards:read", "dashboards:write", "dashboards:delete")))
+
+	err := testOptions.ValidateActions(registered("dashboards:read", "dashboards:write"))
+	require.ErrorIs(t, err, ErrInvalidOptions)
+	assert.Equal(t, "invalid resource permissions options: resource dashboards: "+
+		"action \"dashboards:delete\" of permission \"Edit\" is not registered", err.Error())
+}
+
+func TestNew_registersActionsValidator(t *testing.T) {
+	acService := mock.New()
+	_, err := New(testOptions, featuremgmt.WithFeatures(), routing.NewRouteRegister(), &licensing.OSSLicensingService{},
+		acService, acService, db.InitTestDB(t), nil, nil)
+	require.NoError(t, err)
+
+	require.Len(t, acService.Calls.RegisterActionsValidator, 1)
+	validator := acService.Calls.RegisterActionsValidator[0].([]interface{})[0].(accesscontrol.ActionsValidator)
+	assert.ErrorIs(t, validator(func(action string) bool { return false }), ErrInvalidOptions)
+}
@@ -0,0 +1,111 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/auth/identity"
+)
+
+// SetOwner makes a user or a service account the owner of a resource and grants it the highest permission level.
+// When the resource already has an owner, its permission is removed in the same transaction, so the ownership is
+// transferred atomically. The permission of the owner cannot be changed or removed until the ownership is
+// transferred again, see ErrOwnerPermission.
+func (s *Service) SetOwner(ctx context.Context, orgID int64, resourceID string, owner accesscontrol.User) error {
+	if !s.options.EnableOwner {
+		return fmt.Errorf("%w: %s have no owners", ErrInvalidAssignment, s.options.Resource)
+	}
+
+	permission := s.ownerPermission()
+	actions, err := s.mapPermission(permission)
+	if err != nil {
+		return err
+	}
+
+	if err := s.validateResource(ctx, orgID, resourceID); err != nil {
+		return err
+	}
+
+	if err := s.checkUnlocked(ctx, orgID, resourceID); err != nil {
+		return err
+	}
+
+	if err := s.validateUser(ctx, orgID, owner.ID); err != nil {
+		return err
+	}
+
+	previous, err := s.store.SetResourceOwner(ctx, orgID, owner, SetResourcePermissionCommand{
+		Actions:           actions,
+		Permission:        permission,
+		Resource:          s.options.Resource,
+		ResourceID:        resourceID,
+		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
+		KeepReason:        s.keepReason(),
+	}, s.userHook(s.setUserHook()))
+	if err != nil {
+		return err
+	}
+
+	userIDs := []int64{owner.ID}
+	if previous != nil && previous.UserID != owner.ID {
+		userIDs = append(userIDs, previous.UserID)
+		s.queueSetUserHook(ctx, orgID, accesscontrol.User{ID: previous.UserID}, resourceID, "")
+	}
+	s.queueSetUserHook(ctx, orgID, owner, resourceID, permission)
+	s.service.ClearUsersPermissionCache(orgID, userIDs...)
+	return nil
+}
+
+// GetOwner returns the owner of a resource, or nil if it has no owner or the service does not enable owners
+func (s *Service) GetOwner(ctx context.Context, orgID int64, resourceID string) (*ResourceOwner, error) {
+	if !s.options.EnableOwner {
+		return nil, nil
+	}
+	return s.store.GetResourceOwner(ctx, orgID, s.options.Resource, resourceID)
+}
+
+// ownerPermission returns the permission level granted to owners, the licensed level with the most actions
+func (s *Service) ownerPermission() string {
+	licensed := s.licensedLevels()
+	if len(licensed) == 0 {
+		return ""
+	}
+	return licensed[len(licensed)-1]
+}
+
+// checkOwnerPermission returns ErrOwnerPermission when setting permission for a user would change the permission
+// of the owner of a resource
+func (s *Service) checkOwnerPermission(ctx context.Context, orgID int64, resourceID string, userID int64, permission string) error {
+	if !s.options.EnableOwner || permission == s.ownerPermission() {
+		return nil
+	}
+
+	owner, err := s.GetOwner(ctx, orgID, resourceID)
+	if err != nil {
+		return err
+	}
+	if owner != nil && owner.UserID == userID {
+		return fmt.Errorf("%w: user %d owns %s %s", ErrOwnerPermission, userID, s.options.Resource, resourceID)
+	}
+	return nil
+}
+
+// creatorOwner returns the user or service account owning the resources created by creator, nil if creator cannot
+// own resources, e.g. for provisioned resources
+func creatorOwner(creator identity.Requester) *accesscontrol.User {
+	if creator == nil {
+		return nil
+	}
+
+	namespaceID, identifier := creator.GetNamespacedID()
+	if namespaceID != identity.NamespaceUser && namespaceID != identity.NamespaceServiceAccount {
+		return nil
+	}
+	userID, err := identity.IntIdentifier(namespaceID, identifier)
+	if err != nil || userID <= 0 {
+		return nil
+	}
+	return &accesscontrol.User{ID: userID}
+}
@@ -0,0 +1,212 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/org/orgimpl"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/supportbundles/supportbundlestest"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/services/user/userimpl"
+)
+
+func TestService_SetOwner(t *testing.T) {
+	options := testOptions
+	options.EnableOwner = true
+	service, sql, _ := setupTestEnvironment(t, options)
+	owner := createOwnerTestUser(t, sql, "owner", false)
+	sa := createOwnerTestUser(t, sql, "sa", true)
+
+	require.NoError(t, service.SetOwner(context.Background(), 1, "1", accesscontrol.User{ID: owner.ID}))
+	assertOwner(t, service, "1", owner.ID)
+	assertManagedPermissions(t, service, "1", map[int64]string{owner.ID: "Edit"})
+
+	t.Run("should reject changing the permission of the owner", func(t *testing.T) {
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: owner.ID}, "1", "View")
+		assert.ErrorIs(t, err, ErrOwnerPermission)
+		_, err = service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: owner.ID}, "1", "")
+		assert.ErrorIs(t, err, ErrOwnerPermission)
+		_, err = service.SetPermissions(context.Background(), 1, "1", accesscontrol.SetResourcePermissionCommand{UserID: owner.ID, Permission: ""})
+		assert.ErrorIs(t, err, ErrOwnerPermission)
+
+		results, err := service.SetUserPermissionForResources(context.Background(), 1, accesscontrol.User{ID: owner.ID}, []string{"1", "2"}, "View")
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.ErrorIs(t, results[0].Err, ErrOwnerPermission)
+		assert.NoError(t, results[1].Err)
+
+		// setting the owner level again changes nothing
+		_, err = service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: owner.ID}, "1", "Edit")
+		require.NoError(t, err)
+		assertManagedPermissions(t, service, "1", map[int64]string{owner.ID: "Edit"})
+	})
+
+	t.Run("should transfer the ownership to a service account", func(t *testing.T) {
+		require.NoError(t, service.SetOwner(context.Background(), 1, "1", accesscontrol.User{ID: sa.ID}))
+		assertOwner(t, service, "1", sa.ID)
+		assertManagedPermissions(t, service, "1", map[int64]string{sa.ID: "Edit"})
+
+		// the previous owner is a regular assignee again
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: owner.ID}, "1", "View")
+		require.NoError(t, err)
+		assertManagedPermissions(t, service, "1", map[int64]string{sa.ID: "Edit", owner.ID: "View"})
+	})
+
+	t.Run("should not transfer the ownership to a missing user", func(t *testing.T) {
+		err := service.SetOwner(context.Background(), 1, "1", accesscontrol.User{ID: 1000})
+		assert.ErrorIs(t, err, ErrAssigneeNotFound)
+		assertOwner(t, service, "1", sa.ID)
+		assertManagedPermissions(t, service, "1", map[int64]string{sa.ID: "Edit", owner.ID: "View"})
+	})
+
+	t.Run("should remove the owner with the permissions of a resource", func(t *testing.T) {
+		require.NoError(t, service.DeleteResourcePermissions(context.Background(), 1, "1"))
+		got, err := service.GetOwner(context.Background(), 1, "1")
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
+
+func TestService_SetOwnerDeletedUser(t *testing.T) {
+	options := testOptions
+	options.EnableOwner = true
+	service, sql, _ := setupTestEnvironment(t, options)
+	owner := createOwnerTestUser(t, sql, "owner", false)
+	other := createOwnerTestUser(t, sql, "other", false)
+
+	require.NoError(t, service.SetOwner(context.Background(), 1, "1", accesscontrol.User{ID: owner.ID}))
+
+	// delete the owner without cleaning up its permissions
+	err := sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		_, err := sess.Exec("DELETE FROM "+sql.GetDialect().Quote("user")+" WHERE id = ?", owner.ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	report, err := service.Reconcile(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []DanglingAssignment{{OrgID: 1, UserID: owner.ID, ResourceID: "1"}}, report.Removed)
+
+	got, err := service.GetOwner(context.Background(), 1, "1")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	require.NoError(t, service.SetOwner(context.Background(), 1, "1", accesscontrol.User{ID: other.ID}))
+	assertOwner(t, service, "1", other.ID)
+	assertManagedPermissions(t, service, "1", map[int64]string{other.ID: "Edit"})
+}
+
+func TestService_SetOwnerDisabled(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, testOptions)
+	owner := createOwnerTestUser(t, sql, "owner", false)
+
+	err := service.SetOwner(context.Background(), 1, "1", accesscontrol.User{ID: owner.ID})
+	assert.ErrorIs(t, err, ErrInvalidAssignment)
+
+	// without owners every permission can be changed
+	_, err = service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: owner.ID}, "1", "View")
+	require.NoError(t, err)
+	got, err := service.GetOwner(context.Background(), 1, "1")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestService_SetDefaultPermissionsOwner(t *testing.T) {
+	options := testOptions
+	options.EnableOwner = true
+	options.DefaultPermissions = []DefaultPermission{{Creator: true, Permission: "View"}, {BuiltInRole: "Viewer", Permission: "View"}}
+	service, sql, _ := setupTestEnvironment(t, options)
+	creator := createOwnerTestUser(t, sql, "creator", false)
+	sa := createOwnerTestUser(t, sql, "sa", true)
+
+	_, err := service.SetDefaultPermissions(context.Background(), 1, "1", &user.SignedInUser{OrgID: 1, UserID: creator.ID})
+	require.NoError(t, err)
+	assertOwner(t, service, "1", creator.ID)
+	assertManagedPermissions(t, service, "1", map[int64]string{creator.ID: "Edit"})
+
+	_, err = service.SetDefaultPermissions(context.Background(), 1, "2", &user.SignedInUser{OrgID: 1, UserID: sa.ID, IsServiceAccount: true})
+	require.NoError(t, err)
+	assertOwner(t, service, "2", sa.ID)
+
+	// provisioned resources have no owner
+	_, err = service.SetDefaultPermissions(context.Background(), 1, "3", nil)
+	require.NoError(t, err)
+	got, err := service.GetOwner(context.Background(), 1, "3")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestApi_getPermissionsOwner(t *testing.T) {
+	options := testOptions
+	options.EnableOwner = true
+	service, sql, _ := setupTestEnvironment(t, options)
+	owner := createOwnerTestUser(t, sql, "owner", false)
+	require.NoError(t, service.SetOwner(context.Background(), 1, "1", accesscontrol.User{ID: owner.ID}))
+	_, err := service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+	require.NoError(t, err)
+
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+	})}}, service)
+	req, err := http.NewRequest(http.MethodGet, "/api/access-control/dashboards/1", nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var permissions []map[string]any
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&permissions))
+	require.Len(t, permissions, 2)
+	for _, p := range permissions {
+		if p["userId"] == float64(owner.ID) {
+			assert.Equal(t, true, p["isOwner"])
+		} else {
+			// the responses of the permissions of non owners are unchanged
+			assert.NotContains(t, p, "isOwner")
+		}
+	}
+}
+
+func createOwnerTestUser(t *testing.T, sql *sqlstore.SQLStore, login string, serviceAccount bool) *user.User {
+	t.Helper()
+	orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	usr, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: login, OrgID: 1, IsServiceAccount: serviceAccount})
+	require.NoError(t, err)
+	return usr
+}
+
+func assertOwner(t *testing.T, service *Service, resourceID string, userID int64) {
+	t.Helper()
+	owner, err := service.GetOwner(context.Background(), 1, resourceID)
+	require.NoError(t, err)
+	require.NotNil(t, owner)
+	assert.Equal(t, userID, owner.UserID)
+}
+
+// assertManagedPermissions checks the managed permissions of users on a resource
+func assertManagedPermissions(t *testing.T, service *Service, resourceID string, expected map[int64]string) {
+	t.Helper()
+	snapshot, err := service.SnapshotPermissions(context.Background(), 1, resourceID)
+	require.NoError(t, err)
+	got := map[int64]string{}
+	for _, p := range snapshot.Permissions {
+		if p.UserID != 0 {
+			got[p.UserID] = p.Permission
+		}
+	}
+	assert.Equal(t, expected, got)
+}
@@ -0,0 +1,80 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxReasonLength is the maximum number of characters of the reason of a permission
+const maxReasonLength = 255
+
+// ReasonPolicy is what happens to the reason of a permission updated without a reason
+type ReasonPolicy string
+
+const (
+	// ReasonPolicyKeep keeps the reason of the permission, it is the policy when none is configured
+	ReasonPolicyKeep ReasonPolicy = "keep"
+	// ReasonPolicyClear removes the reason of the permission
+	ReasonPolicyClear ReasonPolicy = "clear"
+)
+
+type reasonKey struct{}
+
+type reasonExemptKey struct{}
+
+// WithReason returns a context giving reason as the reason of the permissions set with it, the commands of
+// SetPermissions with a reason of their own keep it
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+func reasonFromContext(ctx context.Context) string {
+	reason, _ := ctx.Value(reasonKey{}).(string)
+	return reason
+}
+
+// sanitizeReason replaces the control characters of a reason, such as line breaks, with spaces and trims it
+func sanitizeReason(reason string) (string, error) {
+	reason = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, reason))
+	if utf8.RuneCountInString(reason) > maxReasonLength {
+		return "", fmt.Errorf("%w: reasons are limited to %d characters", ErrInvalidReason, maxReasonLength)
+	}
+	return reason, nil
+}
+
+// withoutRequiredReason returns a context exempting the permissions set with it from Options.RequireReason, it is
+// used by the permissions the service sets itself, such as the default permissions of a resource
+func withoutRequiredReason(ctx context.Context) context.Context {
+	return context.WithValue(ctx, reasonExemptKey{}, true)
+}
+
+// reason returns the sanitized reason of permission set with ctx, reason if set or else the one of ctx. Granting
+// permission without a reason fails with Options.RequireReason, removing it does not
+func (s *Service) reason(ctx context.Context, permission, reason string) (string, error) {
+	if reason == "" {
+		reason = reasonFromContext(ctx)
+	}
+	reason, err := sanitizeReason(reason)
+	if err != nil {
+		return "", err
+	}
+	if s.options.RequireReason && permission != "" && reason == "" {
+		if exempt, _ := ctx.Value(reasonExemptKey{}).(bool); !exempt {
+			return "", &ReasonRequiredError{Resource: s.options.Resource, Permission: permission}
+		}
+	}
+	return reason, nil
+}
+
+// keepReason reports whether the permissions updated without a reason keep their reason
+func (s *Service) keepReason() bool {
+	return s.options.ReasonPolicy != ReasonPolicyClear
+}
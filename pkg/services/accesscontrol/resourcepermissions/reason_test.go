@@ -0,0 +1,232 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+// retrieveReasonsHelper returns the reasons of each managed role by scope
+func retrieveReasonsHelper(t *testing.T, store db.DB) map[string][]string {
+	t.Helper()
+	var rows []struct {
+		Name   string
+		Scope  string
+		Reason string
+	}
+	err := store.WithDbSession(context.Background(), func(sess *db.Session) error {
+		return sess.SQL("SELECT DISTINCT r.name, p.scope, p.reason FROM permission p INNER JOIN role r ON r.id = p.role_id WHERE r.name LIKE 'managed:%' ORDER BY p.reason").Find(&rows)
+	})
+	require.NoError(t, err)
+
+	result := map[string][]string{}
+	for _, r := range rows {
+		result[r.Name+" "+r.Scope] = append(result[r.Name+" "+r.Scope], r.Reason)
+	}
+	return result
+}
+
+func TestSanitizeReason(t *testing.T) {
+	reason, err := sanitizeReason("  JIRA-123:\nreviewed\tby security \x00")
+	require.NoError(t, err)
+	assert.Equal(t, "JIRA-123: reviewed by security", reason)
+
+	_, err = sanitizeReason(strings.Repeat("é", maxReasonLength))
+	require.NoError(t, err)
+
+	_, err = sanitizeReason(strings.Repeat("a", maxReasonLength+1))
+	assert.ErrorIs(t, err, ErrInvalidReason)
+}
+
+func TestService_Reason(t *testing.T) {
+	t.Run("should keep the reason of a permission updated without a reason", func(t *testing.T) {
+		service, sql, _ := setupTestEnvironment(t, testOptions)
+		usr := createOwnerTestUser(t, sql, "user", false)
+		key := accesscontrol.ManagedUserRoleName(usr.ID) + " dashboards:id:1"
+
+		permission, err := service.SetUserPermission(WithReason(context.Background(), "JIRA-1"), 1, accesscontrol.User{ID: usr.ID}, "1", "View")
+		require.NoError(t, err)
+		assert.Equal(t, "JIRA-1", permission.Reason)
+
+		_, err = service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"JIRA-1"}, retrieveReasonsHelper(t, sql)[key], "every action should keep the reason")
+
+		_, err = service.SetUserPermission(WithReason(context.Background(), "JIRA-2"), 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"JIRA-2"}, retrieveReasonsHelper(t, sql)[key], "an unchanged permission should be given the new reason")
+
+		permissions, err := service.GetPermissions(context.Background(), &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{
+			1: {accesscontrol.ActionOrgUsersRead: {accesscontrol.ScopeUsersAll}},
+		}}, "1")
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "JIRA-2", permissions[0].Reason)
+	})
+
+	t.Run("should clear the reason of a permission updated without a reason", func(t *testing.T) {
+		options := testOptions
+		options.ReasonPolicy = ReasonPolicyClear
+		service, sql, _ := setupTestEnvironment(t, options)
+		key := accesscontrol.ManagedBuiltInRoleName("Viewer") + " dashboards:id:1"
+
+		_, err := service.SetBuiltInRolePermission(WithReason(context.Background(), "JIRA-1"), 1, "Viewer", "1", "View")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"JIRA-1"}, retrieveReasonsHelper(t, sql)[key])
+
+		_, err = service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+		require.NoError(t, err)
+		assert.Equal(t, []string{""}, retrieveReasonsHelper(t, sql)[key])
+	})
+
+	t.Run("should store the reasons of bulk writes", func(t *testing.T) {
+		var bulk []accesscontrol.SetResourcePermissionCommand
+		options := testOptions
+		options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+			bulk = cmds
+			return nil
+		}
+		service, sql, _ := setupTestEnvironment(t, options)
+		usr := createOwnerTestUser(t, sql, "user", false)
+
+		_, err := service.SetPermissions(WithReason(context.Background(), "JIRA-1"), 1, "1",
+			accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "View", Reason: " JIRA-2\n"},
+			accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View"},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, []accesscontrol.SetResourcePermissionCommand{
+			{UserID: usr.ID, Permission: "View", Reason: "JIRA-2"},
+			{BuiltinRole: "Viewer", Permission: "View", Reason: "JIRA-1"},
+		}, bulk)
+
+		_, err = service.SetPermissions(context.Background(), 1, "1",
+			accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "Edit"},
+			accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View", Reason: "JIRA-3"},
+		)
+		require.NoError(t, err)
+
+		reasons := retrieveReasonsHelper(t, sql)
+		assert.Equal(t, []string{"JIRA-2"}, reasons[accesscontrol.ManagedUserRoleName(usr.ID)+" dashboards:id:1"])
+		assert.Equal(t, []string{"JIRA-3"}, reasons[accesscontrol.ManagedBuiltInRoleName("Viewer")+" dashboards:id:1"])
+	})
+
+	t.Run("should reject a reason that is too long", func(t *testing.T) {
+		service, _, _ := setupTestEnvironment(t, testOptions)
+		_, err := service.SetBuiltInRolePermission(WithReason(context.Background(), strings.Repeat("a", maxReasonLength+1)), 1, "Viewer", "1", "View")
+		assert.ErrorIs(t, err, ErrInvalidReason)
+	})
+}
+
+func TestService_RequireReason(t *testing.T) {
+	options := testOptions
+	options.RequireReason = true
+	options.DefaultPermissions = []DefaultPermission{{BuiltInRole: "Editor", Permission: "Edit"}}
+	service, sql, teamSvc := setupTestEnvironment(t, options)
+	usr := createOwnerTestUser(t, sql, "user", false)
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+
+	t.Run("should reject the permissions granted without a reason", func(t *testing.T) {
+		ctx := context.Background()
+		_, err := service.SetUserPermission(ctx, 1, accesscontrol.User{ID: usr.ID}, "1", "View")
+		var reasonErr *ReasonRequiredError
+		require.ErrorAs(t, err, &reasonErr)
+		assert.Equal(t, ReasonRequiredError{Resource: "dashboards", Permission: "View"}, *reasonErr)
+
+		_, err = service.SetTeamPermission(ctx, 1, tm.ID, "1", "View")
+		assert.ErrorIs(t, err, ErrReasonRequired)
+		_, err = service.SetBuiltInRolePermission(WithReason(ctx, " \n "), 1, "Viewer", "1", "View")
+		assert.ErrorIs(t, err, ErrReasonRequired)
+		_, err = service.SetPermissions(WithReason(ctx, "JIRA-1"), 1, "1",
+			accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "View"},
+			accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View", Reason: "JIRA-2"},
+		)
+		require.NoError(t, err, "the reason of the context should be used")
+		_, err = service.SetPermissions(ctx, 1, "1",
+			accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "View", Reason: "JIRA-1"},
+			accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "View"},
+		)
+		assert.ErrorIs(t, err, ErrReasonRequired)
+		assert.Equal(t, http.StatusBadRequest, permissionErrorStatus(err))
+	})
+
+	t.Run("should remove a permission without a reason", func(t *testing.T) {
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "")
+		require.NoError(t, err)
+	})
+
+	t.Run("should set the default permissions without a reason", func(t *testing.T) {
+		permissions, err := service.SetDefaultPermissions(context.Background(), 1, "2", nil)
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "Editor", permissions[0].BuiltInRole)
+	})
+}
+
+func TestApi_requireReason(t *testing.T) {
+	options := testOptions
+	options.RequireReason = true
+	service, sql, _ := setupTestEnvironment(t, options)
+	usr := createOwnerTestUser(t, sql, "user", false)
+	server := setupTestServer(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+		{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+	})}}, service)
+
+	setUserPermission := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/api/access-control/dashboards/1/users/%d", usr.ID), strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should advertise that reasons are required", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/api/access-control/dashboards/description", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var description Description
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&description))
+		assert.True(t, description.RequireReason)
+	})
+
+	t.Run("should reject a permission granted without a reason", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, setUserPermission(`{"permission": "View"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, setUserPermission(`{"permission": "View", "reason": " \n "}`).Code)
+
+		req, err := http.NewRequest(http.MethodPost, "/api/access-control/dashboards/1", strings.NewReader(`{"permissions": [{"builtInRole": "Viewer", "permission": "View"}]}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("should return the reason of a permission", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, setUserPermission(`{"permission": "View", "reason": "JIRA-1"}`).Code)
+		permissions, recorder := getPermission(t, server, "dashboards", "1")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, usr.ID, permissions[0].UserID)
+		assert.Equal(t, "JIRA-1", permissions[0].Reason)
+	})
+
+	t.Run("should remove a permission without a reason", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, setUserPermission(`{"permission": ""}`).Code)
+	})
+}
@@ -0,0 +1,91 @@
+package resourcepermissions
+
+import (
+	"context"
+	"time"
+)
+
+// ReconciliationReport lists the managed permissions a reconciliation removed from the resources of a service
+type ReconciliationReport struct {
+	Resource string               `json:"resource"`
+	Removed  []DanglingAssignment `json:"removed"`
+}
+
+// Reconcile removes the managed permissions on the resources of the service of users and teams that were deleted
+// without the service being notified, in every organization when orgID is 0. Service accounts and disabled users
+// still exist and keep their permissions. Removal hooks are not called since the assignees no longer exist.
+func (s *Service) Reconcile(ctx context.Context, orgID int64) (*ReconciliationReport, error) {
+	removed, err := s.store.DeleteDanglingAssignments(ctx, orgID, DeleteDanglingAssignmentsCmd{
+		Resource:          s.options.Resource,
+		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := map[int64]struct{}{}
+	for _, a := range removed {
+		if _, ok := orgs[a.OrgID]; ok {
+			continue
+		}
+		orgs[a.OrgID] = struct{}{}
+		// the members of a deleted team may still have its permissions cached
+		s.service.ClearOrgPermissionCache(a.OrgID)
+	}
+
+	if len(removed) > 0 {
+		s.log.Info("Removed permissions of deleted users and teams", "resource", s.options.Resource, "assignments", len(removed), "orgs", len(orgs))
+	}
+	return &ReconciliationReport{Resource: s.options.Resource, Removed: removed}, nil
+}
+
+// Run reconciles the permissions of every organization each Options.ReconciliationInterval until ctx is done. With
+// Options.AsyncHooks it then waits for the queued hooks up to AsyncHooks.DrainTimeout, otherwise it returns
+// immediately when no interval is configured
+func (s *Service) Run(ctx context.Context) error {
+	if s.hooks != nil {
+		defer s.drainHooks()
+	}
+
+	if s.options.ReconciliationInterval <= 0 {
+		if s.hooks == nil {
+			return nil
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(s.options.ReconciliationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := s.Reconcile(ctx, 0); err != nil {
+				s.log.Error("Failed to reconcile permissions", "resource", s.options.Resource, "error", err)
+			}
+		}
+	}
+}
+
+// IsDisabled reports whether Run has nothing to do, when neither Options.ReconciliationInterval nor Options.AsyncHooks
+// are configured
+func (s *Service) IsDisabled() bool {
+	return s.options.ReconciliationInterval <= 0 && s.hooks == nil
+}
+
+// drainHooks waits for the queued asynchronous hooks up to AsyncHooks.DrainTimeout
+func (s *Service) drainHooks() {
+	timeout := s.options.AsyncHooks.DrainTimeout
+	if timeout <= 0 {
+		timeout = defaultHookDrainTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := s.StopHooks(ctx); err != nil {
+		s.log.Warn("Stopped before the queued hooks were called", "resource", s.options.Resource, "error", err)
+	}
+}
@@ -0,0 +1,72 @@
+package resourcepermissions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_IsDisabled(t *testing.T) {
+	service, _, _ := setupTestEnvironment(t, testOptions)
+	assert.True(t, service.IsDisabled(), "Run has nothing to do without reconciliation nor asynchronous hooks")
+
+	options := testOptions
+	options.ReconciliationInterval = time.Minute
+	service, _, _ = setupTestEnvironment(t, options)
+	assert.False(t, service.IsDisabled())
+
+	options = testOptions
+	options.AsyncHooks = &AsyncHooks{}
+	service, _, _ = setupTestEnvironment(t, options)
+	assert.False(t, service.IsDisabled())
+}
+
+func TestService_Run(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	options := testOptions
+	options.ReconciliationInterval = 10 * time.Millisecond
+	service, sql, _ := setupTestEnvironment(t, options)
+
+	deleted := createOwnerTestUser(t, sql, "deleted", false)
+	_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: deleted.ID}, "1", "View")
+	require.NoError(t, err)
+	// delete the user without cleaning up its permissions
+	err = sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		_, err := sess.Exec("DELETE FROM "+sql.GetDialect().Quote("user")+" WHERE id = ?", deleted.ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	countPermissions := func() int {
+		var count int
+		_, err := sql.GetEngine().SQL("SELECT COUNT(*) FROM permission p INNER JOIN user_role ur ON ur.role_id = p.role_id WHERE ur.user_id = ?", deleted.ID).Get(&count)
+		require.NoError(t, err)
+		return count
+	}
+	require.NotZero(t, countPermissions())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- service.Run(ctx) }()
+
+	assert.Eventually(t, func() bool { return countPermissions() == 0 }, time.Second, 10*time.Millisecond,
+		"Run should reconcile the permissions of deleted users")
+	cancel()
+	assert.ErrorIs(t, <-stopped, context.Canceled)
+
+	permissions, err := service.GetPermissions(context.Background(), &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{
+		1: {accesscontrol.ActionOrgUsersRead: {accesscontrol.ScopeUsersAll}},
+	}}, "1")
+	require.NoError(t, err)
+	assert.Empty(t, permissions)
+}
@@ -0,0 +1,165 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
+	"github.com/grafana/grafana/pkg/services/auth/identity"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+var _ ResourceService = new(stubResourceService)
+
+// stubResourceService serves the API of a resource without a store, the Manager operations the tests do not call
+// are left to the nil Manager
+type stubResourceService struct {
+	Manager
+
+	options     Options
+	permissions []accesscontrol.ResourcePermission
+	// set records the permissions set by user id
+	set map[int64]string
+}
+
+func (s *stubResourceService) Options() Options {
+	return s.options
+}
+
+func (s *stubResourceService) Description(ctx context.Context, orgID int64) Description {
+	return Description{Resource: s.options.Resource, Assignments: s.options.Assignments, Permissions: []string{"View", "Edit"}}
+}
+
+func (s *stubResourceService) AssignmentKinds() []string {
+	return []string{assignmentKindUsers}
+}
+
+func (s *stubResourceService) AssignmentKindEnabled(ctx context.Context, orgID int64, kind string) bool {
+	return kind == assignmentKindUsers
+}
+
+func (s *stubResourceService) InheritedScopes(ctx context.Context, orgID int64, resourceID string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *stubResourceService) ImplicitPermissions(ctx context.Context, orgID int64) []accesscontrol.ResourcePermission {
+	return nil
+}
+
+func (s *stubResourceService) EffectiveDefaultPermissions(ctx context.Context, orgID int64) EffectiveDefaultPermissions {
+	return EffectiveDefaultPermissions{OrgID: orgID, Source: DefaultPermissionsSourceOptions}
+}
+
+func (s *stubResourceService) CountPermissions(ctx context.Context, orgID int64, resourceID string) (*PermissionCounts, error) {
+	return &PermissionCounts{Users: int64(len(s.permissions)), Total: int64(len(s.permissions))}, nil
+}
+
+func (s *stubResourceService) UsageStats(ctx context.Context) (*UsageStats, error) {
+	return &UsageStats{Resources: int64(len(s.permissions))}, nil
+}
+
+func (s *stubResourceService) GetPermissions(ctx context.Context, user identity.Requester, resourceID string) ([]accesscontrol.ResourcePermission, error) {
+	return s.permissions, nil
+}
+
+func (s *stubResourceService) GetPermissionsLock(ctx context.Context, orgID int64, resourceID string) (*PermissionsLock, error) {
+	return nil, nil
+}
+
+func (s *stubResourceService) GetOwner(ctx context.Context, orgID int64, resourceID string) (*ResourceOwner, error) {
+	return nil, nil
+}
+
+func (s *stubResourceService) MapActions(permission accesscontrol.ResourcePermission) string {
+	if len(permission.Actions) == 1 {
+		return "View"
+	}
+	return "Edit"
+}
+
+func (s *stubResourceService) SetUserPermission(ctx context.Context, orgID int64, user accesscontrol.User, resourceID, permission string) (*accesscontrol.ResourcePermission, error) {
+	if permission != "" && permission != "View" && permission != "Edit" {
+		return nil, ErrInvalidPermission
+	}
+	s.set[user.ID] = permission
+	return &accesscontrol.ResourcePermission{}, nil
+}
+
+func setupStubServer(t *testing.T, stub *stubResourceService) *web.Mux {
+	t.Helper()
+	router := routing.NewRouteRegister()
+	newApi(acimpl.ProvideAccessControl(setting.NewCfg()), router, stub, stub, nil, newServiceMetrics(nil)).registerEndpoints()
+	return setupTestRouter(t, &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: accesscontrol.GroupScopesByAction([]accesscontrol.Permission{
+		{Action: "dashboards.permissions:read", Scope: "dashboards:id:1"},
+		{Action: "dashboards.permissions:write", Scope: "dashboards:id:1"},
+	})}}, router)
+}
+
+func TestApi_ResourceService(t *testing.T) {
+	stub := &stubResourceService{
+		options: testOptions,
+		permissions: []accesscontrol.ResourcePermission{
+			{RoleName: "managed:users:1:permissions", UserId: 1, Actions: []string{"dashboards:read"}, IsManaged: true},
+			{RoleName: "managed:builtins:editor:permissions", BuiltInRole: "Editor", Actions: []string{"dashboards:read", "dashboards:write"}, IsManaged: true},
+		},
+		set: map[int64]string{},
+	}
+	server := setupStubServer(t, stub)
+
+	t.Run("should describe the resource of the service", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/api/access-control/dashboards/description", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var description Description
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&description))
+		assert.Equal(t, Description{Resource: "dashboards", Assignments: testOptions.Assignments, Permissions: []string{"View", "Edit"}}, description)
+	})
+
+	t.Run("should return the permissions of the service", func(t *testing.T) {
+		permissions, recorder := getPermission(t, server, "dashboards", "1")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Len(t, permissions, 2)
+		assert.Equal(t, "View", permissions[0].Permission)
+		assert.Equal(t, int64(1), permissions[0].UserID)
+		assert.Equal(t, "Edit", permissions[1].Permission)
+		assert.Equal(t, "Editor", permissions[1].BuiltInRole)
+	})
+
+	t.Run("should set permissions through the service", func(t *testing.T) {
+		setUserPermission := func(body string) int {
+			req, err := http.NewRequest(http.MethodPost, "/api/access-control/dashboards/1/users/2", strings.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			return recorder.Code
+		}
+
+		assert.Equal(t, http.StatusOK, setUserPermission(`{"permission": "Edit"}`))
+		assert.Equal(t, "Edit", stub.set[2])
+		assert.Equal(t, http.StatusBadRequest, setUserPermission(`{"permission": "Admin"}`))
+	})
+
+	t.Run("should only register the endpoints of the enabled assignment kinds", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/api/access-control/dashboards/1/teams/2", strings.NewReader(`{"permission": "View"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
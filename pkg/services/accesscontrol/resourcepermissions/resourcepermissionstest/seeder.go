@@ -0,0 +1,111 @@
+package resourcepermissionstest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/resourcepermissions"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/team"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+// Seeder seeds the managed permissions read by a service created with NewTestService. The permissions are written
+// through the sql store to the sqlite test database of the service, so they behave like the ones the service writes.
+// The users and teams the permissions are granted to are created when they do not exist.
+type Seeder struct {
+	t     testing.TB
+	sql   db.DB
+	store resourcepermissions.Store
+}
+
+func newSeeder(t testing.TB, sql db.DB) *Seeder {
+	return &Seeder{t: t, sql: sql, store: resourcepermissions.NewStore(sql, featuremgmt.WithFeatures())}
+}
+
+// Seed grants the actions of permissions on their scope to their user, team or built-in role in orgID
+func (s *Seeder) Seed(orgID int64, permissions ...accesscontrol.ResourcePermission) {
+	s.t.Helper()
+
+	ctx := context.Background()
+	for _, p := range permissions {
+		resource, attribute, resourceID := accesscontrol.Permission{Scope: p.Scope}.SplitScope()
+		cmd := resourcepermissions.SetResourcePermissionCommand{
+			Actions: p.Actions, Resource: resource, ResourceAttribute: attribute, ResourceID: resourceID,
+		}
+
+		var err error
+		switch {
+		case p.UserId != 0:
+			s.ensureUser(orgID, p.UserId, p.UserLogin, p.IsServiceAccount)
+			_, err = s.store.SetUserResourcePermission(ctx, orgID, accesscontrol.User{ID: p.UserId}, cmd, nil)
+		case p.TeamId != 0:
+			s.ensureTeam(orgID, p.TeamId, p.Team)
+			_, err = s.store.SetTeamResourcePermission(ctx, orgID, p.TeamId, cmd, nil)
+		default:
+			_, err = s.store.SetBuiltInResourcePermission(ctx, orgID, p.BuiltInRole, cmd, nil)
+		}
+		require.NoError(s.t, err)
+	}
+}
+
+// SeedUserPermission grants actions on scope to a user in orgID
+func (s *Seeder) SeedUserPermission(orgID, userID int64, scope string, actions ...string) {
+	s.t.Helper()
+	s.Seed(orgID, accesscontrol.ResourcePermission{UserId: userID, Scope: scope, Actions: actions})
+}
+
+// SeedTeamPermission grants actions on scope to a team in orgID
+func (s *Seeder) SeedTeamPermission(orgID, teamID int64, scope string, actions ...string) {
+	s.t.Helper()
+	s.Seed(orgID, accesscontrol.ResourcePermission{TeamId: teamID, Scope: scope, Actions: actions})
+}
+
+// SeedBuiltInRolePermission grants actions on scope to a built-in role in orgID
+func (s *Seeder) SeedBuiltInRolePermission(orgID int64, builtInRole, scope string, actions ...string) {
+	s.t.Helper()
+	s.Seed(orgID, accesscontrol.ResourcePermission{BuiltInRole: builtInRole, Scope: scope, Actions: actions})
+}
+
+// ensureUser creates the user with id in orgID unless it exists, login defaults to user-<id>
+func (s *Seeder) ensureUser(orgID, id int64, login string, serviceAccount bool) {
+	s.t.Helper()
+	if login == "" {
+		login = fmt.Sprintf("user-%d", id)
+	}
+	err := s.sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		if has, err := sess.ID(id).Exist(&user.User{}); has || err != nil {
+			return err
+		}
+		now := time.Now()
+		_, err := sess.Insert(&user.User{
+			ID: id, OrgID: orgID, Login: login, Email: login + "@example.org",
+			IsServiceAccount: serviceAccount, Created: now, Updated: now,
+		})
+		return err
+	})
+	require.NoError(s.t, err)
+}
+
+// ensureTeam creates the team with id in orgID unless it exists, name defaults to team-<id>
+func (s *Seeder) ensureTeam(orgID, id int64, name string) {
+	s.t.Helper()
+	if name == "" {
+		name = fmt.Sprintf("team-%d", id)
+	}
+	err := s.sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		if has, err := sess.ID(id).Exist(&team.Team{}); has || err != nil {
+			return err
+		}
+		now := time.Now()
+		_, err := sess.Insert(&team.Team{ID: id, UID: fmt.Sprintf("team-%d", id), OrgID: orgID, Name: name, Created: now, Updated: now})
+		return err
+	})
+	require.NoError(s.t, err)
+}
@@ -0,0 +1,40 @@
+package resourcepermissionstest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/actest"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/resourcepermissions"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/licensing"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/services/supportbundles/supportbundlestest"
+	"github.com/grafana/grafana/pkg/services/team/teamimpl"
+	"github.com/grafana/grafana/pkg/services/user/userimpl"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// NewTestService returns a resourcepermissions.Service configured with options and backed by the sql store on a
+// sqlite test database, with the real user and team services. The returned seeder writes permissions to that database.
+func NewTestService(t testing.TB, options resourcepermissions.Options) (*resourcepermissions.Service, *Seeder) {
+	t.Helper()
+
+	sql := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	teamService := teamimpl.ProvideService(sql, cfg)
+	userService, err := userimpl.ProvideService(sql, nil, cfg, teamService, nil, quotatest.New(false, nil), supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+
+	service, err := resourcepermissions.New(
+		options, featuremgmt.WithFeatures(), routing.NewRouteRegister(), &licensing.OSSLicensingService{},
+		acimpl.ProvideAccessControl(cfg), &actest.FakeService{}, sql, teamService, userService,
+	)
+	require.NoError(t, err)
+
+	return service, newSeeder(t, sql)
+}
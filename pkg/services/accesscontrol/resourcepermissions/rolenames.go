@@ -0,0 +1,150 @@
+package resourcepermissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+// reservedRolePrefixes are the prefixes of the roles that are not managed by the service
+var reservedRolePrefixes = []string{
+	accesscontrol.BasicRolePrefix, accesscontrol.FixedRolePrefix,
+	accesscontrol.ExternalServiceRolePrefix, accesscontrol.PluginRolePrefix,
+}
+
+// managedRoleNames names the managed roles holding the permissions of the assignees. The roles named with one of the
+// previous prefixes are read and updated like the roles named with the current prefix, new roles are named with the
+// current prefix
+type managedRoleNames struct {
+	prefix   string
+	previous []string
+}
+
+// defaultManagedRoleNames names the managed roles with accesscontrol.ManagedRolePrefix
+var defaultManagedRoleNames = managedRoleNames{prefix: accesscontrol.ManagedRolePrefix}
+
+func (o Options) managedRoleNames() managedRoleNames {
+	names := managedRoleNames{prefix: o.ManagedRolePrefix, previous: o.PreviousManagedRolePrefixes}
+	if names.prefix == "" {
+		names.prefix = accesscontrol.ManagedRolePrefix
+	}
+	return names
+}
+
+func (n managedRoleNames) validate() error {
+	var errs []error
+	prefixes := n.prefixes()
+	for i, prefix := range prefixes {
+		if err := validateManagedRolePrefix(prefix); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, other := range prefixes[:i] {
+			if strings.HasPrefix(prefix, other) || strings.HasPrefix(other, prefix) {
+				errs = append(errs, fmt.Errorf("managed role prefixes %q and %q overlap", other, prefix))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateManagedRolePrefix checks that the roles named with prefix can be told apart from other roles, it is
+// matched with LIKE so it cannot contain its wildcards
+func validateManagedRolePrefix(prefix string) error {
+	if len(prefix) < 2 || !strings.HasSuffix(prefix, ":") || strings.ContainsAny(prefix, "%_") {
+		return fmt.Errorf("invalid managed role prefix %q: it must end with a colon and cannot contain %% or _", prefix)
+	}
+	for _, reserved := range reservedRolePrefixes {
+		if strings.HasPrefix(prefix, reserved) || strings.HasPrefix(reserved, prefix) {
+			return fmt.Errorf("invalid managed role prefix %q: it overlaps the reserved prefix %q", prefix, reserved)
+		}
+	}
+	return nil
+}
+
+// prefixes returns the current prefix followed by the previous ones
+func (n managedRoleNames) prefixes() []string {
+	return append([]string{n.prefix}, n.previous...)
+}
+
+// user returns the name of the managed role of a user
+func (n managedRoleNames) user(userID int64) string {
+	return n.assignment("users", strconv.FormatInt(userID, 10))
+}
+
+// team returns the name of the managed role of a team
+func (n managedRoleNames) team(teamID int64) string {
+	return n.assignment("teams", strconv.FormatInt(teamID, 10))
+}
+
+// builtInRole returns the name of the managed role of a built-in role
+func (n managedRoleNames) builtInRole(builtInRole string) string {
+	return n.assignment("builtins", strings.ToLower(builtInRole))
+}
+
+// assignment returns the name of the managed role of an assignee of kind
+func (n managedRoleNames) assignment(kind, assigneeID string) string {
+	return fmt.Sprintf("%s%s:%s:permissions", n.prefix, kind, assigneeID)
+}
+
+// withPrevious returns name, the name of a managed role, followed by the names the role has with the previous prefixes
+func (n managedRoleNames) withPrevious(name string) []string {
+	names := []string{name}
+	for _, prefix := range n.previous {
+		names = append(names, prefix+strings.TrimPrefix(name, n.prefix))
+	}
+	return names
+}
+
+// isManaged reports whether roleName is the name of a managed role
+func (n managedRoleNames) isManaged(roleName string) bool {
+	for _, prefix := range n.prefixes() {
+		if strings.HasPrefix(roleName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAssignment returns the assignee of a managed role of kind
+func (n managedRoleNames) parseAssignment(kind, roleName string) (string, bool) {
+	for _, prefix := range n.prefixes() {
+		name, ok := strings.CutPrefix(roleName, prefix+kind+":")
+		if ok && strings.HasSuffix(name, ":permissions") {
+			return strings.TrimSuffix(name, ":permissions"), true
+		}
+	}
+	return "", false
+}
+
+// like returns a filter matching the role names of column starting with one of the prefixes followed by infix, and
+// its arguments
+func (n managedRoleNames) like(column, infix string) (string, []any) {
+	prefixes := n.prefixes()
+	args := make([]any, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		args = append(args, prefix+infix+"%")
+	}
+	return "(" + column + " LIKE ?" + strings.Repeat(" OR "+column+" LIKE ?", len(prefixes)-1) + ")", args
+}
+
+// RenameManagedRoles renames the managed roles named with the previous prefixes to the configured prefix, in every
+// organization, and returns the number of roles renamed. It is meant to be run once every instance sharing the
+// database reads both prefixes, see Options.PreviousManagedRolePrefixes, the roles grant the same access before and
+// after they are renamed.
+func (s *Service) RenameManagedRoles(ctx context.Context) (int64, error) {
+	names := s.options.managedRoleNames()
+	var renamed int64
+	for _, prefix := range names.previous {
+		rows, err := s.store.RenameManagedRoles(ctx, 0, RenameManagedRolesCmd{From: prefix, To: names.prefix})
+		renamed += rows
+		if err != nil {
+			return renamed, err
+		}
+	}
+	return renamed, nil
+}
@@ -0,0 +1,213 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestManagedRoleNames(t *testing.T) {
+	names := managedRoleNames{prefix: "rbac:", previous: []string{accesscontrol.ManagedRolePrefix}}
+
+	assert.Equal(t, "rbac:users:1:permissions", names.user(1))
+	assert.Equal(t, "rbac:teams:2:permissions", names.team(2))
+	assert.Equal(t, "rbac:builtins:editor:permissions", names.builtInRole("Editor"))
+	assert.Equal(t, []string{"rbac:users:1:permissions", "managed:users:1:permissions"}, names.withPrevious(names.user(1)))
+
+	assert.True(t, names.isManaged("rbac:teams:2:permissions"))
+	assert.True(t, names.isManaged("managed:teams:2:permissions"))
+	assert.False(t, names.isManaged("fixed:dashboards:reader"))
+
+	assigneeID, ok := names.parseAssignment("devices", "managed:devices:abc:permissions")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", assigneeID)
+	_, ok = names.parseAssignment("devices", "managed:users:1:permissions")
+	assert.False(t, ok)
+
+	filter, args := names.like("r.name", "users:")
+	assert.Equal(t, "(r.name LIKE ? OR r.name LIKE ?)", filter)
+	assert.Equal(t, []any{"rbac:users:%", "managed:users:%"}, args)
+
+	// the default names are the ones of the accesscontrol package, which other services use
+	assert.Equal(t, accesscontrol.ManagedUserRoleName(1), defaultManagedRoleNames.user(1))
+	assert.Equal(t, accesscontrol.ManagedTeamRoleName(2), defaultManagedRoleNames.team(2))
+	assert.Equal(t, accesscontrol.ManagedBuiltInRoleName("Editor"), defaultManagedRoleNames.builtInRole("Editor"))
+}
+
+func TestIntegrationStore_StagedManagedRoleRename(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx := context.Background()
+	oldStore, sql := setupTestEnv(t)
+	newStore := func(names managedRoleNames) *store {
+		s := NewStore(sql, featuremgmt.WithFeatures())
+		s.names = names
+		return s
+	}
+	staged := newStore(managedRoleNames{prefix: "rbac:", previous: []string{accesscontrol.ManagedRolePrefix}})
+	renamed := newStore(managedRoleNames{prefix: "rbac:"})
+
+	alice := createOwnerTestUser(t, sql, "alice", false)
+	bob := createOwnerTestUser(t, sql, "bob", false)
+	cmd := func(resourceID string, actions ...string) SetResourcePermissionCommand {
+		return SetResourcePermissionCommand{Actions: actions, Resource: "dashboards", ResourceID: resourceID, ResourceAttribute: "uid"}
+	}
+	setUser := func(s *store, userID int64, c SetResourcePermissionCommand) {
+		t.Helper()
+		_, err := s.SetUserResourcePermission(ctx, 1, accesscontrol.User{ID: userID}, c, nil)
+		require.NoError(t, err)
+	}
+
+	// written before the rename
+	setUser(oldStore, alice.ID, cmd("1", "dashboards:read"))
+	_, err := oldStore.SetBuiltInResourcePermission(ctx, 1, "Editor", cmd("1", "dashboards:read"), nil)
+	require.NoError(t, err)
+
+	t.Run("should read the roles of the previous prefix as managed roles", func(t *testing.T) {
+		assert.Equal(t, map[string][]string{
+			"managed:users:" + fmt.Sprint(alice.ID) + ":permissions": {"dashboards:read"},
+			"managed:builtins:editor:permissions":                    {"dashboards:read"},
+		}, managedRolesActions(t, staged, "1"))
+	})
+
+	t.Run("should update the roles of the previous prefix in place", func(t *testing.T) {
+		setUser(staged, alice.ID, cmd("1", "dashboards:read", "dashboards:write"))
+		_, err := staged.SetResourcePermissions(ctx, 1, []SetResourcePermissionsCommand{
+			{BuiltinRole: "Editor", SetResourcePermissionCommand: cmd("1", "dashboards:read", "dashboards:write")},
+		}, ResourceHooks{})
+		require.NoError(t, err)
+		setUser(staged, bob.ID, cmd("1", "dashboards:read"))
+
+		assert.Equal(t, map[string][]string{
+			"managed:users:" + fmt.Sprint(alice.ID) + ":permissions": {"dashboards:read", "dashboards:write"},
+			"managed:builtins:editor:permissions":                    {"dashboards:read", "dashboards:write"},
+			"rbac:users:" + fmt.Sprint(bob.ID) + ":permissions":      {"dashboards:read"},
+		}, managedRolesActions(t, staged, "1"))
+		// an instance that still reads the previous prefix only keeps reading the permissions it wrote
+		assert.Len(t, managedRolesActions(t, oldStore, "1"), 2)
+	})
+
+	// an instance that still writes the previous prefix creates a second role for bob, it is merged by the rename
+	setUser(oldStore, bob.ID, cmd("2", "dashboards:read"))
+
+	t.Run("should rename the roles of the previous prefix", func(t *testing.T) {
+		rows, err := staged.RenameManagedRoles(ctx, 0, RenameManagedRolesCmd{From: accesscontrol.ManagedRolePrefix, To: "rbac:"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), rows)
+
+		expected := map[string][]string{
+			"rbac:users:" + fmt.Sprint(alice.ID) + ":permissions": {"dashboards:read", "dashboards:write"},
+			"rbac:builtins:editor:permissions":                    {"dashboards:read", "dashboards:write"},
+			"rbac:users:" + fmt.Sprint(bob.ID) + ":permissions":   {"dashboards:read"},
+		}
+		assert.Equal(t, expected, managedRolesActions(t, staged, "1"))
+		assert.Equal(t, expected, managedRolesActions(t, renamed, "1"))
+		assert.Equal(t, map[string][]string{
+			"rbac:users:" + fmt.Sprint(bob.ID) + ":permissions": {"dashboards:read"},
+		}, managedRolesActions(t, renamed, "2"))
+		assert.Empty(t, managedRolesActions(t, oldStore, "1"))
+
+		assert.Equal(t, 1, countRoles(t, sql, "%users:"+fmt.Sprint(bob.ID)+":permissions"), "the roles of bob should be merged")
+		assert.Equal(t, 1, countUserRoles(t, sql, bob.ID), "the assignment of the merged role should be removed")
+	})
+
+	t.Run("should not rename anything once renamed", func(t *testing.T) {
+		rows, err := staged.RenameManagedRoles(ctx, 0, RenameManagedRolesCmd{From: accesscontrol.ManagedRolePrefix, To: "rbac:"})
+		require.NoError(t, err)
+		assert.Zero(t, rows)
+	})
+
+	t.Run("should reject overlapping prefixes", func(t *testing.T) {
+		_, err := staged.RenameManagedRoles(ctx, 0, RenameManagedRolesCmd{From: "rbac:", To: "rbac:blue:"})
+		assert.ErrorIs(t, err, ErrInvalidRoleRename)
+	})
+}
+
+func TestService_ManagedRolePrefix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	options := testOptions
+	service, sql, _ := setupTestEnvironment(t, options)
+	alice := createOwnerTestUser(t, sql, "alice", false)
+	_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: alice.ID}, "1", "View")
+	require.NoError(t, err)
+
+	options.ManagedRolePrefix = "rbac:"
+	options.PreviousManagedRolePrefixes = []string{accesscontrol.ManagedRolePrefix}
+	staged, err := New(
+		options, featuremgmt.WithFeatures(), routing.NewRouteRegister(), service.license,
+		service.ac, service.service, sql, service.teamService, service.userService,
+	)
+	require.NoError(t, err)
+
+	usr := &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {accesscontrol.ActionOrgUsersRead: {accesscontrol.ScopeUsersAll}}}}
+	assertAlice := func(roleName string) {
+		t.Helper()
+		permissions, err := staged.GetPermissions(context.Background(), usr, "1")
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, roleName, permissions[0].RoleName)
+		assert.True(t, permissions[0].IsManaged)
+		assert.Equal(t, "View", staged.MapActions(permissions[0]))
+	}
+
+	assertAlice(accesscontrol.ManagedUserRoleName(alice.ID))
+	rows, err := staged.RenameManagedRoles(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), rows)
+	assertAlice(fmt.Sprintf("rbac:users:%d:permissions", alice.ID))
+}
+
+// managedRolesActions returns the sorted actions of the managed permissions of the users and built-in roles on a
+// dashboard by role name
+func managedRolesActions(t *testing.T, s *store, resourceID string) map[string][]string {
+	t.Helper()
+	permissions, err := s.GetResourcePermissions(context.Background(), 1, GetResourcePermissionsQuery{
+		User:              &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {accesscontrol.ActionOrgUsersRead: {accesscontrol.ScopeUsersAll}}}},
+		Actions:           []string{"dashboards:read", "dashboards:write"},
+		Resource:          "dashboards",
+		ResourceID:        resourceID,
+		ResourceAttribute: "uid",
+		OnlyManaged:       true,
+	})
+	require.NoError(t, err)
+
+	result := map[string][]string{}
+	for _, p := range permissions {
+		require.True(t, p.IsManaged, "role %s", p.RoleName)
+		actions := append([]string{}, p.Actions...)
+		sort.Strings(actions)
+		result[p.RoleName] = actions
+	}
+	return result
+}
+
+func countRoles(t *testing.T, sql *sqlstore.SQLStore, name string) int {
+	t.Helper()
+	var count int
+	_, err := sql.GetEngine().SQL("SELECT COUNT(*) FROM role WHERE name LIKE ?", name).Get(&count)
+	require.NoError(t, err)
+	return count
+}
+
+func countUserRoles(t *testing.T, sql *sqlstore.SQLStore, userID int64) int {
+	t.Helper()
+	var count int
+	_, err := sql.GetEngine().SQL("SELECT COUNT(*) FROM user_role WHERE user_id = ?", userID).Get(&count)
+	require.NoError(t, err)
+	return count
+}
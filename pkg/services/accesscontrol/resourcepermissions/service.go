@@ -3,10 +3,12 @@ package resourcepermissions
 import (
 	"context"
 	"fmt"
-	"sort"
+	"strconv"
+	"sync"
 
 	"github.com/grafana/grafana/pkg/api/routing"
 	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/auth/identity"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
@@ -39,64 +41,189 @@ type Store interface {
 		hook BuiltinResourceHookFunc,
 	) (*accesscontrol.ResourcePermission, error)
 
+	// SetResourcePermissions sets the permissions of several managed roles in a single transaction and returns the
+	// stored permission and the outcome of each command, in order
 	SetResourcePermissions(
 		ctx context.Context, orgID int64,
 		commands []SetResourcePermissionsCommand,
 		hooks ResourceHooks,
-	) ([]accesscontrol.ResourcePermission, error)
+	) ([]SetResourcePermissionsResult, error)
+
+	// SetUserResourcePermissionForResources sets permission for managed user role on several resources in a single transaction
+	SetUserResourcePermissionForResources(
+		ctx context.Context, orgID int64,
+		user accesscontrol.User,
+		commands []SetResourcePermissionCommand,
+		hook UserResourceHookFunc,
+	) ([]ResourcePermissionResult, error)
 
 	// GetResourcePermissions will return all permission for supplied resource id
 	GetResourcePermissions(ctx context.Context, orgID int64, query GetResourcePermissionsQuery) ([]accesscontrol.ResourcePermission, error)
 
-	// DeleteResourcePermissions will delete all permissions for supplied resource id
-	DeleteResourcePermissions(ctx context.Context, orgID int64, cmd *DeleteResourcePermissionsCmd) error
+	// GetResourcePermissionsPage will return a filtered page of the permissions for supplied resource id
+	GetResourcePermissionsPage(
+		ctx context.Context, orgID int64,
+		query GetResourcePermissionsQuery,
+		opts ResourcePermissionsQueryOptions,
+	) (*ResourcePermissionsPage, error)
+
+	// SetAssignmentResourcePermission sets permission for the managed role of an assignee of a custom kind on a resource
+	SetAssignmentResourcePermission(
+		ctx context.Context, orgID int64, kind, assigneeID string,
+		cmd SetResourcePermissionCommand,
+		bind AssignmentRoleBinderFunc,
+	) (*accesscontrol.ResourcePermission, error)
+
+	// GetAssignmentResourcePermissions will return the permissions for supplied resource id granted to assignees of a custom kind
+	GetAssignmentResourcePermissions(ctx context.Context, orgID int64, kind string, query GetResourcePermissionsQuery) ([]accesscontrol.ResourcePermission, error)
+
+	// ListUsersWithAccess calls fn with the id of every user of the organization granted the action on a resource,
+	// directly, through a team or through their organization role, in id order
+	ListUsersWithAccess(ctx context.Context, orgID int64, query ListUsersWithAccessQuery, fn func(userID int64) error) error
+
+	// CountResourcePermissions returns the numbers of assignees with a managed permission on a resource, or on any
+	// resource of a kind, by kind of assignee
+	CountResourcePermissions(ctx context.Context, orgID int64, query CountResourcePermissionsQuery) (*PermissionCounts, error)
+
+	// GetTeamsMembers returns the members of teams of an organization by team id, in user id order
+	GetTeamsMembers(ctx context.Context, orgID int64, query GetTeamsMembersQuery) (map[int64][]TeamMember, error)
+
+	// DeleteResourcePermissions will delete all permissions, the lock and the owner for supplied resource id, the removal hooks
+	// are called for each user, team and built-in role whose managed permissions are deleted
+	DeleteResourcePermissions(ctx context.Context, orgID int64, cmd *DeleteResourcePermissionsCmd, hooks ResourceHooks) error
+
+	// DeleteDanglingAssignments deletes the managed permissions on resources of cmd.Resource of users and teams that
+	// no longer exist, in every organization when orgID is 0, and returns one entry per assignee and resource. Deleted
+	// users no longer own resources of cmd.Resource
+	DeleteDanglingAssignments(ctx context.Context, orgID int64, cmd DeleteDanglingAssignmentsCmd) ([]DanglingAssignment, error)
+
+	// DeleteUserAssignments deletes the managed permissions of a user on resources of cmd.Resource, in every
+	// organization when orgID is 0, with a transaction per cmd.BatchSize resources. hook is called with an empty
+	// permission for each resource before its permissions are deleted, the user no longer owns the resources. When a
+	// transaction fails, the assignments deleted by the previous ones are returned with the error.
+	DeleteUserAssignments(ctx context.Context, orgID int64, cmd DeleteUserAssignmentsCmd, hook UserResourceHookFunc) ([]UserAssignment, error)
+
+	// LockResourcePermissions locks the permissions of a resource, locking a locked resource keeps the existing lock
+	LockResourcePermissions(ctx context.Context, orgID int64, lock PermissionsLock) error
+
+	// UnlockResourcePermissions removes the lock of a resource, if any
+	UnlockResourcePermissions(ctx context.Context, orgID int64, resource, resourceID string) error
+
+	// GetResourcePermissionsLock returns the lock of a resource, or nil if its permissions are not locked
+	GetResourcePermissionsLock(ctx context.Context, orgID int64, resource, resourceID string) (*PermissionsLock, error)
+
+	// SetResourceOwner records owner as the owner of a resource and sets its managed permission, the permission of the
+	// previous owner is removed in the same transaction. The previous owner is returned, nil if there was none
+	SetResourceOwner(
+		ctx context.Context, orgID int64, owner accesscontrol.User,
+		cmd SetResourcePermissionCommand,
+		hook UserResourceHookFunc,
+	) (*ResourceOwner, error)
+
+	// GetResourceOwner returns the owner of a resource, or nil if it has no owner
+	GetResourceOwner(ctx context.Context, orgID int64, resource, resourceID string) (*ResourceOwner, error)
+
+	// NormalizeResourceScopes rewrites the scopes of the permissions stored under aliases of resource to the scopes
+	// of resource and returns the number of permissions rewritten
+	NormalizeResourceScopes(ctx context.Context, resource string, aliases []string) (int64, error)
+
+	// TranslateResourceIDs rewrites the ids of resources of cmd.Resource in the scopes of their permissions, their
+	// owner and their lock, in every organization when orgID is 0, and returns the number of permissions rewritten.
+	// A permission granting an action already granted on the rewritten scope is dropped.
+	TranslateResourceIDs(ctx context.Context, orgID int64, cmd TranslateResourceIDsCmd) (int64, error)
+
+	// RenameManagedRoles renames the managed roles named with cmd.From to cmd.To, in every organization when orgID
+	// is 0, and returns the number of roles renamed. A role whose new name is taken is merged into the role having it.
+	RenameManagedRoles(ctx context.Context, orgID int64, cmd RenameManagedRolesCmd) (int64, error)
+
+	// GetUsageStats returns the usage statistics of the managed permissions on a kind of resource, in every organization
+	GetUsageStats(ctx context.Context, query UsageStatsQuery) (*UsageStats, error)
 }
 
+// maxInheritanceDepth is the maximum number of ancestors a resource can inherit permissions from
+const maxInheritanceDepth = 16
+
+// bulkWriteBatchSize is the number of resources written in a single transaction by bulk operations
+const bulkWriteBatchSize = 100
+
+// maxUsersWithAccess is the number of users above which ListUsersWithAccess fails instead of returning them
+const maxUsersWithAccess = 10000
+
+// maxCacheInvalidationUsers is the number of affected users above which the permission cache
+// of the whole organization is cleared instead of the cache of each user
+const maxCacheInvalidationUsers = 100
+
 func New(
 	options Options, features featuremgmt.FeatureToggles, router routing.RouteRegister, license licensing.Licensing,
 	ac accesscontrol.AccessControl, service accesscontrol.Service, sqlStore db.DB,
 	teamService team.Service, userService user.Service,
 ) (*Service, error) {
-	permissions := make([]string, 0, len(options.PermissionsToActions))
-	actionSet := make(map[string]struct{})
-	for permission, actions := range options.PermissionsToActions {
-		permissions = append(permissions, permission)
-		for _, a := range actions {
-			actionSet[a] = struct{}{}
-		}
+	if err := options.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Sort all permissions based on action length. Will be used when mapping between actions to permissions
-	sort.Slice(permissions, func(i, j int) bool {
-		return len(options.PermissionsToActions[permissions[i]]) > len(options.PermissionsToActions[permissions[j]])
-	})
+	permissionStore := NewStore(sqlStore, features)
+	permissionStore.names = options.managedRoleNames()
+	permissionStore.metrics = newStoreMetrics(options.Registerer)
 
-	actions := make([]string, 0, len(actionSet))
-	for action := range actionSet {
-		actions = append(actions, action)
+	var store Store = permissionStore
+	if options.ScopeTranslator != nil {
+		store = newTranslatingStore(store, options)
 	}
 
 	s := &Service{
 		ac:          ac,
-		store:       NewStore(sqlStore, features),
+		store:       store,
+		log:         log.New("resourcepermissions"),
 		options:     options,
+		roleNames:   options.managedRoleNames(),
 		license:     license,
-		permissions: permissions,
-		actions:     actions,
-		sqlStore:    sqlStore,
+		levels:      newPermissionLevels(options.PermissionsToActions),
 		service:     service,
+		sql:         sqlStore,
 		teamService: teamService,
 		userService: userService,
+		metrics:     newServiceMetrics(options.Registerer),
 	}
 
-	s.api = newApi(ac, router, s)
+	if options.Settings != nil {
+		// the default permissions of the settings are checked at startup, the levels are only read on reload
+		defaults, err := s.settingsBuiltInDefaults(options.Settings.Section(settingsSection(options.Resource)), options.PermissionsToActions)
+		if err != nil {
+			return nil, fmt.Errorf("%w: resource %s: %w", ErrInvalidOptions, options.Resource, err)
+		}
+		s.builtInDefaults = defaults
+	}
+
+	if err := s.registerAssignmentKinds(); err != nil {
+		return nil, err
+	}
+
+	if options.AsyncHooks != nil {
+		s.hooks = s.newHookQueue(*options.AsyncHooks)
+	}
+
+	s.manager = Decorate(s, options.Decorators...)
+	s.api = newApi(ac, router, s, s.manager, userService, s.metrics)
 
 	if err := s.declareFixedRoles(); err != nil {
 		return nil, err
 	}
 
+	s.service.RegisterActionsValidator(options.ValidateActions)
+	s.registerScopeResolver()
+	s.registerUserRemovedHandler()
+	s.service.RegisterManagedRolePrefixes(s.roleNames.prefixes()...)
 	s.api.registerEndpoints()
 
+	if options.UsageStats != nil {
+		options.UsageStats.RegisterMetricsFunc(s.getUsageMetrics)
+	}
+
+	if options.Settings != nil {
+		options.Settings.RegisterReloadHandler(settingsSection(options.Resource), s)
+	}
+
 	return s, nil
 }
 
@@ -105,37 +232,116 @@ type Service struct {
 	ac      accesscontrol.AccessControl
 	service accesscontrol.Service
 	store   Store
+	log     log.Logger
 	api     *api
 	license licensing.Licensing
-
-	options     Options
-	permissions []string
-	actions     []string
-	sqlStore    db.DB
-	teamService team.Service
-	userService user.Service
+	metrics *serviceMetrics
+	manager Manager
+	// hooks queues the after-commit hooks when they are asynchronous, see Options.AsyncHooks
+	hooks *hookQueue
+	// sql opens the transactions of the set hooks called from the queue of asynchronous hooks
+	sql db.DB
+
+	options Options
+	// roleNames names the managed roles with the prefixes of the options
+	roleNames managedRoleNames
+	// levels are the permission levels of the resource, they can be replaced at runtime with UpdatePermissions
+	levelsMu sync.RWMutex
+	levels   *permissionLevels
+	// builtInDefaults are the default permissions of built-in roles read from the settings, guarded by levelsMu as
+	// they are reloaded with the levels
+	builtInDefaults *builtInDefaults
+	teamService     team.Service
+	userService     user.Service
+
+	assignmentKinds     map[string]AssignmentKind
+	assignmentKindNames []string
 }
 
+// GetPermissions returns the permissions of a resource, the permissions of teams are expanded into permissions of
+// their members when ctx is returned by WithExpandTeams
 func (s *Service) GetPermissions(ctx context.Context, user identity.Requester, resourceID string) ([]accesscontrol.ResourcePermission, error) {
-	var inheritedScopes []string
-	if s.options.InheritedScopesSolver != nil {
-		var err error
-		inheritedScopes, err = s.options.InheritedScopesSolver(ctx, user.GetOrgID(), resourceID)
-		if err != nil {
-			return nil, err
-		}
+	permissions, err := s.getPermissions(ctx, user, resourceID)
+	if err != nil || !expandTeamsFromContext(ctx) {
+		return permissions, err
+	}
+	return s.ExpandTeams(ctx, user, permissions)
+}
+
+func (s *Service) getPermissions(ctx context.Context, user identity.Requester, resourceID string) ([]accesscontrol.ResourcePermission, error) {
+	inheritedScopes, err := s.InheritedScopes(ctx, user.GetOrgID(), resourceID)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.store.GetResourcePermissions(ctx, user.GetOrgID(), GetResourcePermissionsQuery{
+	query := GetResourcePermissionsQuery{
 		User:                 user,
-		Actions:              s.actions,
+		Actions:              s.getLevels().actions,
 		Resource:             s.options.Resource,
 		ResourceID:           resourceID,
 		ResourceAttribute:    s.options.ResourceAttribute,
+		ResourceAliases:      s.options.ResourceAliases,
 		InheritedScopes:      inheritedScopes,
 		OnlyManaged:          s.options.OnlyManaged,
 		EnforceAccessControl: s.license.FeatureEnabled("accesscontrol.enforcement"),
+	}
+
+	permissions, err := s.store.GetResourcePermissions(ctx, user.GetOrgID(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	assignmentPermissions, err := s.getAssignmentPermissions(ctx, user.GetOrgID(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(permissions, assignmentPermissions...), nil
+}
+
+// ListUsersWithAccess returns the ids of the users of an organization granted action on a resource, directly,
+// through a team, through their organization role or on an ancestor of the resource. Every user is returned once,
+// in id order. Organizations with more than maxUsersWithAccess such users fail with ErrTooManyUsers and should be
+// listed with StreamUsersWithAccess.
+func (s *Service) ListUsersWithAccess(ctx context.Context, orgID int64, resourceID, action string) ([]int64, error) {
+	var userIDs []int64
+	err := s.StreamUsersWithAccess(ctx, orgID, resourceID, action, func(userID int64) error {
+		if len(userIDs) == maxUsersWithAccess {
+			return fmt.Errorf("%w: more than %d users can %s %s %s", ErrTooManyUsers, maxUsersWithAccess, action, s.options.Resource, resourceID)
+		}
+		userIDs = append(userIDs, userID)
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// StreamUsersWithAccess calls fn with the id of every user ListUsersWithAccess would return, without holding them in
+// memory. An error returned by fn stops the listing and is returned. Service accounts, disabled users and assignees
+// of custom assignment kinds are not listed.
+func (s *Service) StreamUsersWithAccess(ctx context.Context, orgID int64, resourceID, action string, fn func(userID int64) error) error {
+	inheritedScopes, err := s.InheritedScopes(ctx, orgID, resourceID)
+	if err != nil {
+		return err
+	}
+
+	// without enforcement organization admins are granted every action of the resource, see getPermissions
+	var implicitRoles []string
+	if s.assignments(ctx, orgID).BuiltInRoles && !s.license.FeatureEnabled("accesscontrol.enforcement") && containsAction(s.getLevels().actions, action) {
+		implicitRoles = append(implicitRoles, string(org.RoleAdmin))
+	}
+
+	return s.store.ListUsersWithAccess(ctx, orgID, ListUsersWithAccessQuery{
+		Action:            action,
+		Resource:          s.options.Resource,
+		ResourceID:        resourceID,
+		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
+		InheritedScopes:   inheritedScopes,
+		ImplicitRoles:     implicitRoles,
+	}, fn)
 }
 
 func (s *Service) SetUserPermission(ctx context.Context, orgID int64, user accesscontrol.User, resourceID, permission string) (*accesscontrol.ResourcePermission, error) {
@@ -144,21 +350,117 @@ func (s *Service) SetUserPermission(ctx context.Context, orgID int64, user acces
 		return nil, err
 	}
 
+	reason, err := s.reason(ctx, permission, "")
+	if err != nil {
+		return nil, err
+	}
+
 	if err := s.validateResource(ctx, orgID, resourceID); err != nil {
 		return nil, err
 	}
 
+	if err := s.checkUnlocked(ctx, orgID, resourceID); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwnerPermission(ctx, orgID, resourceID, user.ID, permission); err != nil {
+		return nil, err
+	}
+
 	if err := s.validateUser(ctx, orgID, user.ID); err != nil {
 		return nil, err
 	}
 
-	return s.store.SetUserResourcePermission(ctx, orgID, user, SetResourcePermissionCommand{
+	resourcePermission, err := s.store.SetUserResourcePermission(ctx, orgID, user, SetResourcePermissionCommand{
 		Actions:           actions,
 		Permission:        permission,
 		Resource:          s.options.Resource,
 		ResourceID:        resourceID,
 		ResourceAttribute: s.options.ResourceAttribute,
-	}, s.options.OnSetUser)
+		ResourceAliases:   s.options.ResourceAliases,
+		Reason:            reason,
+		KeepReason:        s.keepReason(),
+	}, s.userHook(s.setUserHook()))
+	if err != nil {
+		return nil, err
+	}
+
+	s.service.ClearUsersPermissionCache(orgID, user.ID)
+	s.queueSetUserHook(ctx, orgID, user, resourceID, permission)
+	return resourcePermission, nil
+}
+
+// SetUserPermissionForResources sets the permission of a user on several resources, writing them in batches of
+// bulkWriteBatchSize resources per transaction. The result of each resource is returned in the order of resourceIDs,
+// resources that fail validation, are locked or belong to a failed batch have their error set while the other resources are written.
+func (s *Service) SetUserPermissionForResources(ctx context.Context, orgID int64, user accesscontrol.User, resourceIDs []string, permission string) ([]ResourcePermissionResult, error) {
+	actions, err := s.mapPermission(permission)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := s.reason(ctx, permission, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.validateUser(ctx, orgID, user.ID); err != nil {
+		return nil, err
+	}
+
+	results := make([]ResourcePermissionResult, len(resourceIDs))
+	var batch []SetResourcePermissionCommand
+	var batchIndexes []int
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		stored, err := s.store.SetUserResourcePermissionForResources(ctx, orgID, user, batch, s.userHook(s.setUserHook()))
+		for i, idx := range batchIndexes {
+			if err != nil {
+				results[idx].Err = err
+				continue
+			}
+			results[idx] = stored[i]
+			s.queueSetUserHook(ctx, orgID, user, batch[i].ResourceID, permission)
+		}
+		batch, batchIndexes = nil, nil
+	}
+
+	for i, resourceID := range resourceIDs {
+		results[i].ResourceID = resourceID
+		if err := s.validateResource(ctx, orgID, resourceID); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if err := s.checkUnlocked(ctx, orgID, resourceID); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if err := s.checkOwnerPermission(ctx, orgID, resourceID, user.ID, permission); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		batch = append(batch, SetResourcePermissionCommand{
+			Actions:           actions,
+			Permission:        permission,
+			Resource:          s.options.Resource,
+			ResourceID:        resourceID,
+			ResourceAttribute: s.options.ResourceAttribute,
+			ResourceAliases:   s.options.ResourceAliases,
+			Reason:            reason,
+			KeepReason:        s.keepReason(),
+		})
+		batchIndexes = append(batchIndexes, i)
+		if len(batch) == bulkWriteBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	s.service.ClearUsersPermissionCache(orgID, user.ID)
+	return results, nil
 }
 
 func (s *Service) SetTeamPermission(ctx context.Context, orgID, teamID int64, resourceID, permission string) (*accesscontrol.ResourcePermission, error) {
@@ -167,6 +469,11 @@ func (s *Service) SetTeamPermission(ctx context.Context, orgID, teamID int64, re
 		return nil, err
 	}
 
+	reason, err := s.reason(ctx, permission, "")
+	if err != nil {
+		return nil, err
+	}
+
 	if err := s.validateTeam(ctx, orgID, teamID); err != nil {
 		return nil, err
 	}
@@ -175,13 +482,27 @@ func (s *Service) SetTeamPermission(ctx context.Context, orgID, teamID int64, re
 		return nil, err
 	}
 
-	return s.store.SetTeamResourcePermission(ctx, orgID, teamID, SetResourcePermissionCommand{
+	if err := s.checkUnlocked(ctx, orgID, resourceID); err != nil {
+		return nil, err
+	}
+
+	resourcePermission, err := s.store.SetTeamResourcePermission(ctx, orgID, teamID, SetResourcePermissionCommand{
 		Actions:           actions,
 		Permission:        permission,
 		Resource:          s.options.Resource,
 		ResourceID:        resourceID,
 		ResourceAttribute: s.options.ResourceAttribute,
-	}, s.options.OnSetTeam)
+		ResourceAliases:   s.options.ResourceAliases,
+		Reason:            reason,
+		KeepReason:        s.keepReason(),
+	}, s.teamHook(s.setTeamHook()))
+	if err != nil {
+		return nil, err
+	}
+
+	s.clearPermissionCache(ctx, orgID, nil, []int64{teamID}, false)
+	s.queueSetTeamHook(ctx, orgID, teamID, resourceID, permission)
+	return resourcePermission, nil
 }
 
 func (s *Service) SetBuiltInRolePermission(ctx context.Context, orgID int64, builtInRole, resourceID, permission string) (*accesscontrol.ResourcePermission, error) {
@@ -190,7 +511,12 @@ func (s *Service) SetBuiltInRolePermission(ctx context.Context, orgID int64, bui
 		return nil, err
 	}
 
-	if err := s.validateBuiltinRole(ctx, builtInRole); err != nil {
+	reason, err := s.reason(ctx, permission, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.validateBuiltinRole(ctx, orgID, builtInRole); err != nil {
 		return nil, err
 	}
 
@@ -198,13 +524,27 @@ func (s *Service) SetBuiltInRolePermission(ctx context.Context, orgID int64, bui
 		return nil, err
 	}
 
-	return s.store.SetBuiltInResourcePermission(ctx, orgID, builtInRole, SetResourcePermissionCommand{
+	if err := s.checkUnlocked(ctx, orgID, resourceID); err != nil {
+		return nil, err
+	}
+
+	resourcePermission, err := s.store.SetBuiltInResourcePermission(ctx, orgID, builtInRole, SetResourcePermissionCommand{
 		Actions:           actions,
 		Permission:        permission,
 		Resource:          s.options.Resource,
 		ResourceID:        resourceID,
 		ResourceAttribute: s.options.ResourceAttribute,
-	}, s.options.OnSetBuiltInRole)
+		ResourceAliases:   s.options.ResourceAliases,
+		Reason:            reason,
+		KeepReason:        s.keepReason(),
+	}, s.builtInRoleHook(s.setBuiltInRoleHook()))
+	if err != nil {
+		return nil, err
+	}
+
+	s.service.ClearOrgPermissionCache(orgID)
+	s.queueSetBuiltInRoleHook(ctx, orgID, builtInRole, resourceID, permission)
+	return resourcePermission, nil
 }
 
 func (s *Service) SetPermissions(
@@ -215,9 +555,24 @@ func (s *Service) SetPermissions(
 		return nil, err
 	}
 
+	if err := s.checkUnlocked(ctx, orgID, resourceID); err != nil {
+		return nil, err
+	}
+
+	// the teams of the commands are resolved once, for validation and cache invalidation
+	ctx = withTeamCache(ctx, orgID)
+	if err := s.prefetchTeams(ctx, orgID, commandTeamIDs(commands)); err != nil {
+		return nil, err
+	}
+
+	// the commands are passed to OnBulkSet with their sanitized reason
+	commands = append(make([]accesscontrol.SetResourcePermissionCommand, 0, len(commands)), commands...)
 	dbCommands := make([]SetResourcePermissionsCommand, 0, len(commands))
-	for _, cmd := range commands {
+	for i, cmd := range commands {
 		if cmd.UserID != 0 {
+			if err := s.checkOwnerPermission(ctx, orgID, resourceID, cmd.UserID, cmd.Permission); err != nil {
+				return nil, err
+			}
 			if err := s.validateUser(ctx, orgID, cmd.UserID); err != nil {
 				return nil, err
 			}
@@ -226,7 +581,7 @@ func (s *Service) SetPermissions(
 				return nil, err
 			}
 		} else {
-			if err := s.validateBuiltinRole(ctx, cmd.BuiltinRole); err != nil {
+			if err := s.validateBuiltinRole(ctx, orgID, cmd.BuiltinRole); err != nil {
 				return nil, err
 			}
 		}
@@ -235,6 +590,11 @@ func (s *Service) SetPermissions(
 		if err != nil {
 			return nil, err
 		}
+		reason, err := s.reason(ctx, cmd.Permission, cmd.Reason)
+		if err != nil {
+			return nil, err
+		}
+		commands[i].Reason = reason
 
 		dbCommands = append(dbCommands, SetResourcePermissionsCommand{
 			User:        accesscontrol.User{ID: cmd.UserID},
@@ -245,86 +605,320 @@ func (s *Service) SetPermissions(
 				Resource:          s.options.Resource,
 				ResourceID:        resourceID,
 				ResourceAttribute: s.options.ResourceAttribute,
+				ResourceAliases:   s.options.ResourceAliases,
 				Permission:        cmd.Permission,
+				Reason:            reason,
+				KeepReason:        s.keepReason(),
 			},
 		})
 	}
 
-	return s.store.SetResourcePermissions(ctx, orgID, dbCommands, ResourceHooks{
-		User:        s.options.OnSetUser,
-		Team:        s.options.OnSetTeam,
-		BuiltInRole: s.options.OnSetBuiltInRole,
-	})
+	hooks := ResourceHooks{
+		User:        s.userHook(s.setUserHook()),
+		Team:        s.teamHook(s.setTeamHook()),
+		BuiltInRole: s.builtInRoleHook(s.setBuiltInRoleHook()),
+	}
+	if s.options.OnBulkSet != nil {
+		hooks = ResourceHooks{User: s.userHook(nil), Team: s.teamHook(nil), BuiltInRole: s.builtInRoleHook(nil)}
+	}
+
+	results, err := s.store.SetResourcePermissions(ctx, orgID, dbCommands, hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]accesscontrol.ResourcePermission, 0, len(results))
+	for _, result := range results {
+		permissions = append(permissions, result.Permission)
+	}
+
+	var userIDs, teamIDs []int64
+	var builtInRoles bool
+	for _, cmd := range commands {
+		if cmd.UserID != 0 {
+			userIDs = append(userIDs, cmd.UserID)
+		} else if cmd.TeamID != 0 {
+			teamIDs = append(teamIDs, cmd.TeamID)
+		} else {
+			builtInRoles = true
+		}
+	}
+	s.clearPermissionCache(ctx, orgID, userIDs, teamIDs, builtInRoles)
+
+	if s.options.OnBulkSet == nil {
+		for _, cmd := range commands {
+			switch {
+			case cmd.UserID != 0:
+				s.queueSetUserHook(ctx, orgID, accesscontrol.User{ID: cmd.UserID}, resourceID, cmd.Permission)
+			case cmd.TeamID != 0:
+				s.queueSetTeamHook(ctx, orgID, cmd.TeamID, resourceID, cmd.Permission)
+			default:
+				s.queueSetBuiltInRoleHook(ctx, orgID, cmd.BuiltinRole, resourceID, cmd.Permission)
+			}
+		}
+	} else {
+		s.runAfterCommitHook(ctx, hookBulkSet, orgID, resourceID, commandAssignees(commands), func(ctx context.Context) error {
+			return s.options.OnBulkSet(ctx, orgID, resourceID, commands)
+		})
+	}
+
+	return permissions, nil
+}
+
+// commandAssignees describes the assignees of commands for the logs of the after-commit hooks
+func commandAssignees(commands []accesscontrol.SetResourcePermissionCommand) []string {
+	assignees := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		switch {
+		case cmd.UserID != 0:
+			assignees = append(assignees, "user:"+strconv.FormatInt(cmd.UserID, 10))
+		case cmd.TeamID != 0:
+			assignees = append(assignees, "team:"+strconv.FormatInt(cmd.TeamID, 10))
+		default:
+			assignees = append(assignees, "builtInRole:"+cmd.BuiltinRole)
+		}
+	}
+	return assignees
+}
+
+func commandTeamIDs(commands []accesscontrol.SetResourcePermissionCommand) []int64 {
+	var teamIDs []int64
+	for _, cmd := range commands {
+		if cmd.TeamID != 0 {
+			teamIDs = append(teamIDs, cmd.TeamID)
+		}
+	}
+	return teamIDs
+}
+
+// SetDefaultPermissions assigns the default permissions of Options to a new resource in a single transaction.
+// Permissions for the creator are skipped when creator is nil or not a user, e.g. for provisioned resources,
+// and permissions for assignment types disabled in the organization are always skipped. With Options.EnableOwner
+// the creator, user or service account, is then made the owner of the resource.
+func (s *Service) SetDefaultPermissions(ctx context.Context, orgID int64, resourceID string, creator identity.Requester) ([]accesscontrol.ResourcePermission, error) {
+	defaults := s.defaultPermissions(orgID, s.assignments(ctx, orgID))
+
+	root := true
+	if s.options.IsRootResource != nil {
+		for _, d := range defaults {
+			if !d.RootOnly {
+				continue
+			}
+			var err error
+			if root, err = s.options.IsRootResource(ctx, orgID, resourceID); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	var commands []accesscontrol.SetResourcePermissionCommand
+	for _, d := range defaults {
+		if d.RootOnly && !root {
+			continue
+		}
+
+		if d.BuiltInRole != "" {
+			commands = append(commands, accesscontrol.SetResourcePermissionCommand{BuiltinRole: d.BuiltInRole, Permission: d.Permission})
+			continue
+		}
+
+		if creator == nil {
+			continue
+		}
+		namespaceID, identifier := creator.GetNamespacedID()
+		userID, err := identity.IntIdentifier(namespaceID, identifier)
+		if err != nil {
+			s.log.Error("Could not assign default permission to creator", "resource", s.options.Resource, "resourceID", resourceID, "namespaceID", namespaceID, "userID", identifier, "error", err)
+			continue
+		}
+		if namespaceID == identity.NamespaceUser && userID > 0 {
+			commands = append(commands, accesscontrol.SetResourcePermissionCommand{UserID: userID, Permission: d.Permission})
+		}
+	}
+
+	var permissions []accesscontrol.ResourcePermission
+	if len(commands) > 0 {
+		var err error
+		if permissions, err = s.SetPermissions(withoutRequiredReason(ctx), orgID, resourceID, commands...); err != nil {
+			return nil, err
+		}
+	}
+
+	if owner := creatorOwner(creator); s.options.EnableOwner && owner != nil {
+		if err := s.SetOwner(ctx, orgID, resourceID, *owner); err != nil {
+			return nil, err
+		}
+	}
+
+	return permissions, nil
+}
+
+// Options returns the options the resource was registered with
+func (s *Service) Options() Options {
+	return s.options
+}
+
+// Description returns the access control properties of the resource in an organization
+func (s *Service) Description(ctx context.Context, orgID int64) Description {
+	assignments := s.assignments(ctx, orgID)
+	return Description{
+		Resource:           s.options.Resource,
+		Permissions:        s.licensedLevels(),
+		Assignments:        assignments,
+		AssignmentKinds:    s.customAssignmentKinds(),
+		DefaultPermissions: s.defaultPermissions(orgID, assignments),
+		RequireReason:      s.options.RequireReason,
+	}
+}
+
+// ImplicitPermissions returns the permissions granted on every resource of an organization without being stored.
+// Without access control enforcement organization admins are granted every action of the resource.
+func (s *Service) ImplicitPermissions(ctx context.Context, orgID int64) []accesscontrol.ResourcePermission {
+	if !s.assignments(ctx, orgID).BuiltInRoles || s.license.FeatureEnabled("accesscontrol.enforcement") {
+		return nil
+	}
+	return []accesscontrol.ResourcePermission{{
+		Actions:     s.getLevels().actions,
+		Scope:       "*",
+		BuiltInRole: string(org.RoleAdmin),
+	}}
 }
 
 func (s *Service) MapActions(permission accesscontrol.ResourcePermission) string {
-	for _, p := range s.permissions {
-		if permission.Contains(s.options.PermissionsToActions[p]) {
-			return p
+	p, _ := s.MapActionsExact(permission)
+	return p
+}
+
+// MapActionsExact returns the permission level with the most actions that are all granted by permission, and whether
+// permission grants exactly the actions of that level. An empty level is returned when permission does not grant
+// all the actions of any level, e.g. when a stored managed role predates an action added to the lowest level.
+func (s *Service) MapActionsExact(permission accesscontrol.ResourcePermission) (string, bool) {
+	granted := make(map[string]struct{}, len(permission.Actions))
+	for _, a := range permission.Actions {
+		granted[a] = struct{}{}
+	}
+
+	levels := s.getLevels()
+	for _, p := range levels.names {
+		if permission.Contains(levels.toActions[p]) {
+			return p, len(granted) == len(levels.toActions[p])
 		}
 	}
-	return ""
+	return "", false
 }
 
 func (s *Service) DeleteResourcePermissions(ctx context.Context, orgID int64, resourceID string) error {
-	return s.store.DeleteResourcePermissions(ctx, orgID, &DeleteResourcePermissionsCmd{
+	err := s.store.DeleteResourcePermissions(ctx, orgID, &DeleteResourcePermissionsCmd{
 		Resource:          s.options.Resource,
 		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
 		ResourceID:        resourceID,
-	})
+	}, s.removedHooks())
+	if err != nil {
+		return err
+	}
+
+	s.service.ClearOrgPermissionCache(orgID)
+	return nil
 }
 
-func (s *Service) mapPermission(permission string) ([]string, error) {
-	if permission == "" {
-		return []string{}, nil
+// clearPermissionCache drops the cached permissions of every identity affected by a write so that it takes effect
+// immediately. Changes to built-in roles or to teams with too many members clear the cache of the whole organization.
+func (s *Service) clearPermissionCache(ctx context.Context, orgID int64, userIDs, teamIDs []int64, builtInRoles bool) {
+	if builtInRoles {
+		s.service.ClearOrgPermissionCache(orgID)
+		return
 	}
 
-	for k, v := range s.options.PermissionsToActions {
-		if permission == k {
-			return v, nil
+	for _, teamID := range teamIDs {
+		members, err := s.getTeamMemberIDs(ctx, orgID, teamID)
+		if err != nil || len(userIDs)+len(members) > maxCacheInvalidationUsers {
+			s.service.ClearOrgPermissionCache(orgID)
+			return
 		}
+		userIDs = append(userIDs, members...)
 	}
-	return nil, ErrInvalidPermission
-}
 
-func (s *Service) validateResource(ctx context.Context, orgID int64, resourceID string) error {
-	if s.options.ResourceValidator != nil {
-		return s.options.ResourceValidator(ctx, orgID, resourceID)
+	if len(userIDs) > 0 {
+		s.service.ClearUsersPermissionCache(orgID, userIDs...)
 	}
-	return nil
 }
 
-func (s *Service) validateUser(ctx context.Context, orgID, userID int64) error {
-	if !s.options.Assignments.Users {
-		return ErrInvalidAssignment
+// InheritedScopes returns the scopes of all ancestors of a resource, ordered from the nearest ancestor to the root.
+// The chain is cut at maxInheritanceDepth ancestors and at the first ancestor that appears twice.
+func (s *Service) InheritedScopes(ctx context.Context, orgID int64, resourceID string) ([]string, error) {
+	if s.options.InheritedScopesSolver == nil {
+		return nil, nil
 	}
 
-	_, err := s.userService.GetSignedInUser(ctx, &user.GetSignedInUserQuery{OrgID: orgID, UserID: userID})
-	return err
+	scopes, err := s.options.InheritedScopesSolver(ctx, orgID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(scopes))
+	for i, scope := range scopes {
+		if i >= maxInheritanceDepth {
+			s.log.Warn("Resource ancestors exceed the maximum depth", "resource", s.options.Resource, "resourceID", resourceID, "depth", len(scopes))
+			return scopes[:i], nil
+		}
+		if _, ok := seen[scope]; ok {
+			s.log.Warn("Cycle detected in resource ancestors", "resource", s.options.Resource, "resourceID", resourceID, "scope", scope)
+			return scopes[:i], nil
+		}
+		seen[scope] = struct{}{}
+	}
+
+	return scopes, nil
 }
 
-func (s *Service) validateTeam(ctx context.Context, orgID, teamID int64) error {
-	if !s.options.Assignments.Teams {
-		return ErrInvalidAssignment
+// mapPermission returns the actions granted by a permission level, the empty permission removes every action. It fails
+// with an UnlicensedPermissionError for a level whose license feature is not enabled
+func (s *Service) mapPermission(permission string) ([]string, error) {
+	actions, err := s.levelActions(permission)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := s.teamService.GetTeamByID(ctx, &team.GetTeamByIDQuery{OrgID: orgID, ID: teamID}); err != nil {
-		return err
+	if feature, ok := s.options.PermissionLicenses[permission]; ok && !s.license.FeatureEnabled(feature) {
+		return nil, &UnlicensedPermissionError{Permission: permission, Feature: feature}
 	}
-	return nil
+	return actions, nil
 }
 
-func (s *Service) validateBuiltinRole(ctx context.Context, builtinRole string) error {
-	if !s.options.Assignments.BuiltInRoles {
-		return ErrInvalidAssignment
+// levelActions returns the actions granted by a permission level regardless of its license, to inspect permissions
+// that are already stored
+func (s *Service) levelActions(permission string) ([]string, error) {
+	if permission == "" {
+		return []string{}, nil
 	}
 
-	if err := accesscontrol.ValidateBuiltInRoles([]string{builtinRole}); err != nil {
-		return err
+	if actions, ok := s.getLevels().toActions[permission]; ok {
+		return actions, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrInvalidPermission, permission)
+}
+
+func (s *Service) validateResource(ctx context.Context, orgID int64, resourceID string) error {
+	if s.options.ResourceValidator != nil {
+		return s.options.ResourceValidator(ctx, orgID, resourceID)
 	}
 	return nil
 }
 
+func (s *Service) validateUser(ctx context.Context, orgID, userID int64) error {
+	return s.resolveAssignee(ctx, orgID, assignmentKindUsers, strconv.FormatInt(userID, 10))
+}
+
+func (s *Service) validateTeam(ctx context.Context, orgID, teamID int64) error {
+	return s.resolveAssignee(ctx, orgID, assignmentKindTeams, strconv.FormatInt(teamID, 10))
+}
+
+func (s *Service) validateBuiltinRole(ctx context.Context, orgID int64, builtinRole string) error {
+	return s.resolveAssignee(ctx, orgID, assignmentKindBuiltInRoles, builtinRole)
+}
+
 func (s *Service) declareFixedRoles() error {
 	scopeAll := accesscontrol.Scope(s.options.Resource, "*")
 	readerRole := accesscontrol.RoleRegistration{
@@ -2,6 +2,8 @@ package resourcepermissions
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,11 +11,15 @@ import (
 
 	"github.com/grafana/grafana/pkg/api/routing"
 	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/localcache"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
 	"github.com/grafana/grafana/pkg/services/accesscontrol/actest"
+	acdb "github.com/grafana/grafana/pkg/services/accesscontrol/database"
+	"github.com/grafana/grafana/pkg/services/auth/identity"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/licensing/licensingtest"
+	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/grafana/grafana/pkg/services/org/orgimpl"
 	"github.com/grafana/grafana/pkg/services/quota/quotatest"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
@@ -47,7 +53,8 @@ func TestService_SetUserPermission(t *testing.T) {
 			service, sql, _ := setupTestEnvironment(t, Options{
 				Resource:             "dashboards",
 				Assignments:          Assignments{Users: true},
-				PermissionsToActions: nil,
+				ResourceAttribute:    "id",
+				PermissionsToActions: testOptions.PermissionsToActions,
 			})
 
 			// seed user
@@ -95,7 +102,8 @@ func TestService_SetTeamPermission(t *testing.T) {
 			service, _, teamSvc := setupTestEnvironment(t, Options{
 				Resource:             "dashboards",
 				Assignments:          Assignments{Teams: true},
-				PermissionsToActions: nil,
+				ResourceAttribute:    "id",
+				PermissionsToActions: testOptions.PermissionsToActions,
 			})
 
 			// seed team
@@ -139,7 +147,8 @@ func TestService_SetBuiltInRolePermission(t *testing.T) {
 			service, _, _ := setupTestEnvironment(t, Options{
 				Resource:             "dashboards",
 				Assignments:          Assignments{BuiltInRoles: true},
-				PermissionsToActions: nil,
+				ResourceAttribute:    "id",
+				PermissionsToActions: testOptions.PermissionsToActions,
 			})
 
 			var hookCalled bool
@@ -169,7 +178,8 @@ func TestService_SetPermissions(t *testing.T) {
 		{
 			desc: "should set all permissions",
 			options: Options{
-				Resource: "dashboards",
+				Resource:          "dashboards",
+				ResourceAttribute: "id",
 				Assignments: Assignments{
 					Users:        true,
 					Teams:        true,
@@ -188,7 +198,8 @@ func TestService_SetPermissions(t *testing.T) {
 		{
 			desc: "should return error for invalid permission",
 			options: Options{
-				Resource: "dashboards",
+				Resource:          "dashboards",
+				ResourceAttribute: "id",
 				Assignments: Assignments{
 					Users:        true,
 					Teams:        true,
@@ -232,7 +243,840 @@ func TestService_SetPermissions(t *testing.T) {
 	}
 }
 
-func setupTestEnvironment(t *testing.T, ops Options) (*Service, *sqlstore.SQLStore, team.Service) {
+type serviceErrorsTest struct {
+	desc        string
+	options     Options
+	set         func(s *Service) error
+	expectedErr []error
+}
+
+func TestService_Errors(t *testing.T) {
+	tests := []serviceErrorsTest{
+		{
+			desc:    "should return invalid permission",
+			options: testOptions,
+			set: func(s *Service) error {
+				_, err := s.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "Unknown")
+				return err
+			},
+			expectedErr: []error{ErrInvalidPermission},
+		},
+		{
+			desc:    "should return disabled assignment",
+			options: Options{Resource: "dashboards", ResourceAttribute: "uid", PermissionsToActions: testOptions.PermissionsToActions},
+			set: func(s *Service) error {
+				_, err := s.SetTeamPermission(context.Background(), 1, 1, "1", "View")
+				return err
+			},
+			expectedErr: []error{ErrDisabledAssignment, ErrInvalidAssignment},
+		},
+		{
+			desc:    "should return invalid assignment for unknown built-in role",
+			options: testOptions,
+			set: func(s *Service) error {
+				_, err := s.SetBuiltInRolePermission(context.Background(), 1, "Unknown", "1", "View")
+				return err
+			},
+			expectedErr: []error{ErrInvalidAssignment},
+		},
+		{
+			desc:    "should return assignee not found for missing user",
+			options: testOptions,
+			set: func(s *Service) error {
+				_, err := s.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: 42}, "1", "View")
+				return err
+			},
+			expectedErr: []error{ErrAssigneeNotFound, user.ErrUserNotFound},
+		},
+		{
+			desc:    "should return assignee not found for missing team in bulk set",
+			options: testOptions,
+			set: func(s *Service) error {
+				_, err := s.SetPermissions(context.Background(), 1, "1", accesscontrol.SetResourcePermissionCommand{TeamID: 42, Permission: "View"})
+				return err
+			},
+			expectedErr: []error{ErrAssigneeNotFound, team.ErrTeamNotFound},
+		},
+		{
+			desc: "should return resource not found from validator",
+			options: Options{
+				Resource:             "dashboards",
+				ResourceAttribute:    "uid",
+				PermissionsToActions: testOptions.PermissionsToActions,
+				Assignments:          testOptions.Assignments,
+				ResourceValidator: func(ctx context.Context, orgID int64, resourceID string) error {
+					return fmt.Errorf("%w: %s", ErrResourceNotFound, resourceID)
+				},
+			},
+			set: func(s *Service) error {
+				_, err := s.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "View")
+				return err
+			},
+			expectedErr: []error{ErrResourceNotFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			service, _, _ := setupTestEnvironment(t, tt.options)
+			err := tt.set(service)
+			require.Error(t, err)
+			for _, expected := range tt.expectedErr {
+				assert.ErrorIs(t, err, expected)
+			}
+		})
+	}
+}
+
+func TestService_MapActionsExact(t *testing.T) {
+	tests := []struct {
+		desc               string
+		actions            []string
+		expectedPermission string
+		expectedExact      bool
+	}{
+		{
+			desc:               "should map exact action set",
+			actions:            []string{"dashboards:read", "dashboards:write", "dashboards:delete"},
+			expectedPermission: "Edit",
+			expectedExact:      true,
+		},
+		{
+			desc:               "should map strict superset to contained level",
+			actions:            []string{"dashboards:read", "dashboards:write", "dashboards:delete", "dashboards:create"},
+			expectedPermission: "Edit",
+			expectedExact:      false,
+		},
+		{
+			desc:               "should map strict subset to lower contained level",
+			actions:            []string{"dashboards:read", "dashboards:write"},
+			expectedPermission: "View",
+			expectedExact:      false,
+		},
+		{
+			desc:               "should not map strict subset of lowest level",
+			actions:            []string{"dashboards:write"},
+			expectedPermission: "",
+			expectedExact:      false,
+		},
+	}
+
+	service, _, _ := setupTestEnvironment(t, testOptions)
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			permission, exact := service.MapActionsExact(accesscontrol.ResourcePermission{Actions: tt.actions})
+			assert.Equal(t, tt.expectedPermission, permission)
+			assert.Equal(t, tt.expectedExact, exact)
+			assert.Equal(t, tt.expectedPermission, service.MapActions(accesscontrol.ResourcePermission{Actions: tt.actions}))
+		})
+	}
+}
+
+type setDefaultPermissionsTest struct {
+	desc        string
+	assignments Assignments
+	root        bool
+	creator     func(userID int64) *user.SignedInUser
+	expected    []string
+}
+
+func TestService_SetDefaultPermissions(t *testing.T) {
+	signedIn := func(userID int64) *user.SignedInUser {
+		return &user.SignedInUser{OrgID: 1, UserID: userID}
+	}
+
+	tests := []setDefaultPermissionsTest{
+		{
+			desc:        "should assign creator and built-in role permissions to root resource",
+			assignments: testOptions.Assignments,
+			root:        true,
+			creator:     signedIn,
+			expected:    []string{"user:Edit", "Editor:Edit", "Viewer:View"},
+		},
+		{
+			desc:        "should skip root only permissions for resource with a parent",
+			assignments: testOptions.Assignments,
+			root:        false,
+			creator:     signedIn,
+			expected:    []string{"user:Edit"},
+		},
+		{
+			desc:        "should skip creator permission without creator",
+			assignments: testOptions.Assignments,
+			root:        true,
+			expected:    []string{"Editor:Edit", "Viewer:View"},
+		},
+		{
+			desc:        "should skip creator permission for service accounts",
+			assignments: testOptions.Assignments,
+			root:        true,
+			creator: func(userID int64) *user.SignedInUser {
+				return &user.SignedInUser{OrgID: 1, UserID: userID, IsServiceAccount: true}
+			},
+			expected: []string{"Editor:Edit", "Viewer:View"},
+		},
+		{
+			desc:        "should skip disabled assignments",
+			assignments: Assignments{Users: true},
+			root:        true,
+			creator:     signedIn,
+			expected:    []string{"user:Edit"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			options := testOptions
+			options.Assignments = tt.assignments
+			options.DefaultPermissions = []DefaultPermission{
+				{Creator: true, Permission: "Edit"},
+				{BuiltInRole: "Editor", Permission: "Edit", RootOnly: true},
+				{BuiltInRole: "Viewer", Permission: "View", RootOnly: true},
+			}
+			options.IsRootResource = func(ctx context.Context, orgID int64, resourceID string) (bool, error) {
+				return tt.root, nil
+			}
+			service, sql, _ := setupTestEnvironment(t, options)
+
+			// seed user
+			orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+			require.NoError(t, err)
+			usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+			require.NoError(t, err)
+			creator, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "test", OrgID: 1})
+			require.NoError(t, err)
+
+			var requester identity.Requester
+			if tt.creator != nil {
+				requester = tt.creator(creator.ID)
+			}
+
+			permissions, err := service.SetDefaultPermissions(context.Background(), 1, "1", requester)
+			require.NoError(t, err)
+
+			assigned := make([]string, 0, len(permissions))
+			for _, p := range permissions {
+				if p.UserId != 0 {
+					assert.Equal(t, creator.ID, p.UserId)
+					assigned = append(assigned, "user:"+service.MapActions(p))
+				} else {
+					assigned = append(assigned, p.BuiltInRole+":"+service.MapActions(p))
+				}
+			}
+			assert.Equal(t, tt.expected, assigned)
+		})
+	}
+}
+
+type registerAssignmentKindsTest struct {
+	desc          string
+	kinds         []AssignmentKind
+	expectedErr   bool
+	expectedNames []string
+}
+
+func TestService_registerAssignmentKinds(t *testing.T) {
+	resolve := func(ctx context.Context, orgID int64, assigneeID string) error { return nil }
+	bind := func(session *db.Session, orgID int64, assigneeID string, roleID int64) error { return nil }
+
+	tests := []registerAssignmentKindsTest{
+		{
+			desc:          "should register built-in kinds in endpoint order",
+			expectedNames: []string{"users", "teams", "builtInRoles"},
+		},
+		{
+			desc:          "should register custom kinds after built-in kinds",
+			kinds:         []AssignmentKind{{Name: "groups", Resolve: resolve, BindRole: bind}},
+			expectedNames: []string{"users", "teams", "builtInRoles", "groups"},
+		},
+		{
+			desc:        "should reject reserved name",
+			kinds:       []AssignmentKind{{Name: "teams", Resolve: resolve, BindRole: bind}},
+			expectedErr: true,
+		},
+		{
+			desc:        "should reject name used by managed built-in roles",
+			kinds:       []AssignmentKind{{Name: "builtins", Resolve: resolve, BindRole: bind}},
+			expectedErr: true,
+		},
+		{
+			desc:        "should reject invalid name",
+			kinds:       []AssignmentKind{{Name: "scim/groups", Resolve: resolve, BindRole: bind}},
+			expectedErr: true,
+		},
+		{
+			desc:        "should reject duplicated kind",
+			kinds:       []AssignmentKind{{Name: "groups", Resolve: resolve, BindRole: bind}, {Name: "groups", Resolve: resolve, BindRole: bind}},
+			expectedErr: true,
+		},
+		{
+			desc:        "should reject kind without role binding",
+			kinds:       []AssignmentKind{{Name: "groups", Resolve: resolve}},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			options := testOptions
+			options.AssignmentKinds = tt.kinds
+			service := &Service{options: options}
+
+			err := service.registerAssignmentKinds()
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedNames, service.assignmentKindNames)
+		})
+	}
+}
+
+type setPermissionsHooksTest struct {
+	desc              string
+	bulkHook          bool
+	expectedBulkCalls int
+	expectedUserCalls int
+	expectedTeamCalls int
+	expectedRoleCalls int
+}
+
+func TestService_SetPermissionsHooks(t *testing.T) {
+	tests := []setPermissionsHooksTest{
+		{
+			desc:              "should call bulk hook once and skip per entry hooks",
+			bulkHook:          true,
+			expectedBulkCalls: 1,
+		},
+		{
+			desc:              "should call per entry hooks when no bulk hook is registered",
+			bulkHook:          false,
+			expectedUserCalls: 1,
+			expectedTeamCalls: 1,
+			expectedRoleCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			service, sql, teamSvc := setupTestEnvironment(t, Options{
+				Resource:          "dashboards",
+				ResourceAttribute: "id",
+				Assignments:       Assignments{Users: true, Teams: true, BuiltInRoles: true},
+				PermissionsToActions: map[string][]string{
+					"View": {"dashboards:read"},
+				},
+			})
+
+			// seed user and team
+			orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+			require.NoError(t, err)
+			usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+			require.NoError(t, err)
+			_, err = usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "user", OrgID: 1})
+			require.NoError(t, err)
+			_, err = teamSvc.CreateTeam("team", "", 1)
+			require.NoError(t, err)
+
+			var bulkCalls, userCalls, teamCalls, roleCalls int
+			var bulkCommands []accesscontrol.SetResourcePermissionCommand
+			service.options.OnSetUser = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+				userCalls++
+				return nil
+			}
+			service.options.OnSetTeam = func(session *db.Session, orgID, teamID int64, resourceID, permission string) error {
+				teamCalls++
+				return nil
+			}
+			service.options.OnSetBuiltInRole = func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error {
+				roleCalls++
+				return nil
+			}
+			if tt.bulkHook {
+				service.options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+					bulkCalls++
+					bulkCommands = cmds
+					return nil
+				}
+			}
+
+			commands := []accesscontrol.SetResourcePermissionCommand{
+				{UserID: 1, Permission: "View"},
+				{TeamID: 1, Permission: "View"},
+				{BuiltinRole: "Editor", Permission: "View"},
+				{BuiltinRole: "Viewer", Permission: ""},
+			}
+			_, err = service.SetPermissions(context.Background(), 1, "1", commands...)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedBulkCalls, bulkCalls)
+			assert.Equal(t, tt.expectedUserCalls, userCalls)
+			assert.Equal(t, tt.expectedTeamCalls, teamCalls)
+			assert.Equal(t, tt.expectedRoleCalls, roleCalls)
+			if tt.bulkHook {
+				assert.Equal(t, commands, bulkCommands)
+			}
+		})
+	}
+}
+
+func TestService_SnapshotAndRestorePermissions(t *testing.T) {
+	service, sql, teamSvc := setupTestEnvironment(t, testOptions)
+
+	// seed users and team
+	orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	var userIDs []int64
+	for i := 0; i < 3; i++ {
+		u, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: fmt.Sprintf("user%d", i), OrgID: 1})
+		require.NoError(t, err)
+		userIDs = append(userIDs, u.ID)
+	}
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+
+	_, err = service.SetPermissions(context.Background(), 1, "1",
+		accesscontrol.SetResourcePermissionCommand{UserID: userIDs[0], Permission: "Edit"},
+		accesscontrol.SetResourcePermissionCommand{UserID: userIDs[2], Permission: "View"},
+		accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "View"},
+		accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Editor", Permission: "Edit"},
+	)
+	require.NoError(t, err)
+
+	snapshot, err := service.SnapshotPermissions(context.Background(), 1, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "dashboards", snapshot.Resource)
+	assert.Equal(t, "1", snapshot.ResourceID)
+	assert.Equal(t, []accesscontrol.SetResourcePermissionCommand{
+		{BuiltinRole: "Editor", Permission: "Edit"},
+		{TeamID: tm.ID, Permission: "View"},
+		{UserID: userIDs[0], Permission: "Edit"},
+		{UserID: userIDs[2], Permission: "View"},
+	}, snapshot.Permissions)
+
+	// change the permissions and delete a user of the snapshot
+	_, err = service.SetPermissions(context.Background(), 1, "1",
+		accesscontrol.SetResourcePermissionCommand{UserID: userIDs[0], Permission: "View"},
+		accesscontrol.SetResourcePermissionCommand{UserID: userIDs[1], Permission: "Edit"},
+		accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: ""},
+	)
+	require.NoError(t, err)
+	require.NoError(t, usrSvc.Delete(context.Background(), &user.DeleteUserCommand{UserID: userIDs[2]}))
+
+	var userCalls, teamCalls, roleCalls int
+	service.options.OnSetUser = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+		userCalls++
+		return nil
+	}
+	service.options.OnSetTeam = func(session *db.Session, orgID, teamID int64, resourceID, permission string) error {
+		teamCalls++
+		return nil
+	}
+	service.options.OnSetBuiltInRole = func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error {
+		roleCalls++
+		return nil
+	}
+
+	_, err = service.RestorePermissions(context.Background(), 1, "1", *snapshot)
+	require.NoError(t, err)
+
+	// hooks are only called for the assignments that changed since the snapshot
+	assert.Equal(t, 2, userCalls)
+	assert.Equal(t, 1, teamCalls)
+	assert.Equal(t, 0, roleCalls)
+
+	restored, err := service.SnapshotPermissions(context.Background(), 1, "1")
+	require.NoError(t, err)
+	assert.Equal(t, []accesscontrol.SetResourcePermissionCommand{
+		{BuiltinRole: "Editor", Permission: "Edit"},
+		{TeamID: tm.ID, Permission: "View"},
+		{UserID: userIDs[0], Permission: "Edit"},
+	}, restored.Permissions)
+
+	_, err = service.RestorePermissions(context.Background(), 1, "2", *snapshot)
+	assert.ErrorIs(t, err, ErrInvalidSnapshot)
+}
+
+func TestService_OnPermissionRemoved(t *testing.T) {
+	service, sql, teamSvc := setupTestEnvironment(t, testOptions)
+
+	orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	usr, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "user", OrgID: 1})
+	require.NoError(t, err)
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+
+	removed := map[string]int{}
+	service.options.OnUserPermissionRemoved = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID string) error {
+		removed[fmt.Sprintf("user:%d:%s", user.ID, resourceID)]++
+		return nil
+	}
+	service.options.OnTeamPermissionRemoved = func(session *db.Session, orgID, teamID int64, resourceID string) error {
+		removed[fmt.Sprintf("team:%d:%s", teamID, resourceID)]++
+		return nil
+	}
+	service.options.OnBuiltInRolePermissionRemoved = func(session *db.Session, orgID int64, builtInRole, resourceID string) error {
+		removed[fmt.Sprintf("role:%s:%s", builtInRole, resourceID)]++
+		return nil
+	}
+	var setCalls int
+	service.options.OnSetUser = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+		setCalls++
+		return nil
+	}
+
+	userKey := func(resourceID string) string { return fmt.Sprintf("user:%d:%s", usr.ID, resourceID) }
+	teamKey := func(resourceID string) string { return fmt.Sprintf("team:%d:%s", tm.ID, resourceID) }
+	seed := func(t *testing.T, resourceIDs ...string) {
+		t.Helper()
+		for _, resourceID := range resourceIDs {
+			_, err := service.SetPermissions(context.Background(), 1, resourceID,
+				accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "View"},
+				accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "Edit"},
+				accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: "View"},
+			)
+			require.NoError(t, err)
+		}
+		clear(removed)
+		setCalls = 0
+	}
+
+	tests := []struct {
+		desc     string
+		remove   func(t *testing.T) error
+		expected map[string]int
+	}{
+		{
+			desc: "should call the user hook when a user permission is set empty",
+			remove: func(t *testing.T) error {
+				_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "")
+				return err
+			},
+			expected: map[string]int{userKey("1"): 1},
+		},
+		{
+			desc: "should call the team hook when a team permission is set empty",
+			remove: func(t *testing.T) error {
+				_, err := service.SetTeamPermission(context.Background(), 1, tm.ID, "1", "")
+				return err
+			},
+			expected: map[string]int{teamKey("1"): 1},
+		},
+		{
+			desc: "should call the built-in role hook when a built-in role permission is set empty",
+			remove: func(t *testing.T) error {
+				_, err := service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "1", "")
+				return err
+			},
+			expected: map[string]int{"role:Viewer:1": 1},
+		},
+		{
+			desc: "should call the hook when a permission is set empty through its assignment kind",
+			remove: func(t *testing.T) error {
+				_, err := service.SetAssignmentPermission(context.Background(), 1, "teams", strconv.FormatInt(tm.ID, 10), "1", "")
+				return err
+			},
+			expected: map[string]int{teamKey("1"): 1},
+		},
+		{
+			desc: "should call the hook once per resource when a user permission is removed from several resources",
+			remove: func(t *testing.T) error {
+				_, err := service.SetUserPermissionForResources(context.Background(), 1, accesscontrol.User{ID: usr.ID}, []string{"1", "2"}, "")
+				return err
+			},
+			expected: map[string]int{userKey("1"): 1, userKey("2"): 1},
+		},
+		{
+			desc: "should call the hooks of the entries removed by SetPermissions",
+			remove: func(t *testing.T) error {
+				_, err := service.SetPermissions(context.Background(), 1, "1",
+					accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: ""},
+					accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "View"},
+					accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Viewer", Permission: ""},
+				)
+				return err
+			},
+			expected: map[string]int{userKey("1"): 1, "role:Viewer:1": 1},
+		},
+		{
+			desc: "should call the hooks of the entries removed by SetPermissions with a bulk hook",
+			remove: func(t *testing.T) error {
+				service.options.OnBulkSet = func(ctx context.Context, orgID int64, resourceID string, cmds []accesscontrol.SetResourcePermissionCommand) error {
+					return nil
+				}
+				t.Cleanup(func() { service.options.OnBulkSet = nil })
+				_, err := service.SetPermissions(context.Background(), 1, "1", accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: ""})
+				return err
+			},
+			expected: map[string]int{teamKey("1"): 1},
+		},
+		{
+			desc: "should call the hooks of the entries removed by a restore",
+			remove: func(t *testing.T) error {
+				snapshot, err := service.SnapshotPermissions(context.Background(), 1, "1")
+				require.NoError(t, err)
+				snapshot.Permissions = []accesscontrol.SetResourcePermissionCommand{{BuiltinRole: "Viewer", Permission: "View"}}
+				_, err = service.RestorePermissions(context.Background(), 1, "1", *snapshot)
+				return err
+			},
+			expected: map[string]int{userKey("1"): 1, teamKey("1"): 1},
+		},
+		{
+			desc: "should call the hooks of every assignee when the permissions of a resource are deleted",
+			remove: func(t *testing.T) error {
+				return service.DeleteResourcePermissions(context.Background(), 1, "1")
+			},
+			expected: map[string]int{userKey("1"): 1, teamKey("1"): 1, "role:Viewer:1": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			seed(t, "1", "2")
+			require.NoError(t, tt.remove(t))
+			assert.Equal(t, tt.expected, removed)
+		})
+	}
+
+	t.Run("should keep calling the set hook with an empty permission", func(t *testing.T) {
+		seed(t, "1")
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "")
+		require.NoError(t, err)
+		assert.Equal(t, 1, setCalls)
+	})
+
+	t.Run("should not call the removal hooks when a permission is set", func(t *testing.T) {
+		seed(t, "1")
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+		require.NoError(t, err)
+		assert.Empty(t, removed)
+	})
+}
+
+func TestService_ListUsersWithAccess(t *testing.T) {
+	tests := []struct {
+		desc     string
+		licensed bool
+		expected []string
+	}{
+		{
+			desc:     "should list users granted access directly, through a team, a role or an ancestor",
+			licensed: true,
+			expected: []string{"direct-and-team", "team", "editor", "inherited"},
+		},
+		{
+			desc:     "should list organization admins without enforcement",
+			licensed: false,
+			expected: []string{"admin", "direct-and-team", "team", "editor", "inherited"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			options := testOptions
+			options.InheritedScopesSolver = func(ctx context.Context, orgID int64, resourceID string) ([]string, error) {
+				return []string{"folders:uid:parent"}, nil
+			}
+			service, sql, teamSvc := setupTestEnvironment(t, options)
+			license := licensingtest.NewFakeLicensing()
+			license.On("FeatureEnabled", "accesscontrol.enforcement").Return(tt.licensed).Maybe()
+			service.license = license
+
+			orgSvc, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+			require.NoError(t, err)
+			usrSvc, err := userimpl.ProvideService(sql, orgSvc, sql.Cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+			require.NoError(t, err)
+			ids := map[string]int64{}
+			logins := map[int64]string{}
+			create := func(login string, role org.RoleType, isServiceAccount bool) int64 {
+				u, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: login, OrgID: 1, DefaultOrgRole: string(role), IsServiceAccount: isServiceAccount})
+				require.NoError(t, err)
+				ids[login] = u.ID
+				logins[u.ID] = login
+				return u.ID
+			}
+			// the first user creates the organization and is its admin, the next ones join it
+			create("admin", org.RoleAdmin, false)
+			sql.Cfg.AutoAssignOrg = true
+			t.Cleanup(func() { sql.Cfg.AutoAssignOrg = false })
+			create("direct-and-team", org.RoleViewer, false)
+			create("team", org.RoleViewer, false)
+			create("editor", org.RoleEditor, false)
+			create("inherited", org.RoleViewer, false)
+			create("service-account", org.RoleViewer, true)
+			create("none", org.RoleViewer, false)
+
+			tm, err := teamSvc.CreateTeam("team", "", 1)
+			require.NoError(t, err)
+			require.NoError(t, teamSvc.AddTeamMember(ids["direct-and-team"], 1, tm.ID, false, 0))
+			require.NoError(t, teamSvc.AddTeamMember(ids["team"], 1, tm.ID, false, 0))
+
+			_, err = service.SetPermissions(context.Background(), 1, "1",
+				accesscontrol.SetResourcePermissionCommand{UserID: ids["direct-and-team"], Permission: "View"},
+				accesscontrol.SetResourcePermissionCommand{UserID: ids["service-account"], Permission: "View"},
+				accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "View"},
+				accesscontrol.SetResourcePermissionCommand{BuiltinRole: "Editor", Permission: "View"},
+			)
+			require.NoError(t, err)
+			_, err = service.store.SetUserResourcePermission(context.Background(), 1, accesscontrol.User{ID: ids["inherited"]}, SetResourcePermissionCommand{
+				Actions:           []string{"dashboards:read"},
+				Resource:          "folders",
+				ResourceID:        "parent",
+				ResourceAttribute: "uid",
+			}, nil)
+			require.NoError(t, err)
+
+			userIDs, err := service.ListUsersWithAccess(context.Background(), 1, "1", "dashboards:read")
+			require.NoError(t, err)
+			listed := make([]string, 0, len(userIDs))
+			for _, id := range userIDs {
+				listed = append(listed, logins[id])
+			}
+			assert.Equal(t, tt.expected, listed)
+
+			noAccess, err := service.ListUsersWithAccess(context.Background(), 1, "1", "dashboards:write")
+			require.NoError(t, err)
+			if tt.licensed {
+				assert.Empty(t, noAccess)
+			} else {
+				assert.Equal(t, []int64{ids["admin"]}, noAccess)
+			}
+
+			// an error returned while streaming stops the listing
+			var streamed int
+			errStop := fmt.Errorf("stop")
+			err = service.StreamUsersWithAccess(context.Background(), 1, "1", "dashboards:read", func(userID int64) error {
+				streamed++
+				return errStop
+			})
+			assert.ErrorIs(t, err, errStop)
+			assert.Equal(t, 1, streamed)
+		})
+	}
+}
+
+func TestService_ClearPermissionCache(t *testing.T) {
+	sql := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	cfg.RBACPermissionCache = true
+	teamSvc := teamimpl.ProvideService(sql, cfg)
+	userSvc, err := userimpl.ProvideService(sql, nil, cfg, teamSvc, nil, quotatest.New(false, nil), supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	acService := acimpl.ProvideOSSService(cfg, acdb.ProvideService(sql), localcache.ProvideService(), featuremgmt.WithFeatures())
+	license := licensingtest.NewFakeLicensing()
+	license.On("FeatureEnabled", "accesscontrol.enforcement").Return(true).Maybe()
+
+	service, err := New(Options{
+		Resource:          "dashboards",
+		ResourceAttribute: "uid",
+		Assignments:       Assignments{Users: true, Teams: true, BuiltInRoles: true},
+		PermissionsToActions: map[string][]string{
+			"View": {"dashboards:read"},
+		},
+	}, featuremgmt.WithFeatures(), routing.NewRouteRegister(), license, acimpl.ProvideAccessControl(cfg), acService, sql, teamSvc, userSvc)
+	require.NoError(t, err)
+
+	orgSvc, err := orgimpl.ProvideService(sql, cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgSvc, cfg, nil, nil, &quotatest.FakeQuotaService{}, supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	usr, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "user", OrgID: 1})
+	require.NoError(t, err)
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+	require.NoError(t, teamSvc.AddTeamMember(usr.ID, 1, tm.ID, false, 0))
+
+	signedInUser := &user.SignedInUser{UserID: usr.ID, OrgID: 1, OrgRole: org.RoleViewer, Teams: []int64{tm.ID}}
+	canRead := func(t *testing.T, uid string) bool {
+		permissions, err := acService.GetUserPermissions(context.Background(), signedInUser, accesscontrol.Options{})
+		require.NoError(t, err)
+		return accesscontrol.EvalPermission("dashboards:read", "dashboards:uid:"+uid).Evaluate(accesscontrol.GroupScopesByAction(permissions))
+	}
+
+	t.Run("user grant should take effect immediately", func(t *testing.T) {
+		require.False(t, canRead(t, "user"))
+		_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "user", "View")
+		require.NoError(t, err)
+		assert.True(t, canRead(t, "user"))
+	})
+
+	t.Run("team grant should take effect immediately for team members", func(t *testing.T) {
+		require.False(t, canRead(t, "team"))
+		_, err := service.SetTeamPermission(context.Background(), 1, tm.ID, "team", "View")
+		require.NoError(t, err)
+		assert.True(t, canRead(t, "team"))
+	})
+
+	t.Run("built-in role grant should take effect immediately", func(t *testing.T) {
+		require.False(t, canRead(t, "role"))
+		_, err := service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "role", "View")
+		require.NoError(t, err)
+		assert.True(t, canRead(t, "role"))
+	})
+
+	t.Run("bulk removal should take effect immediately", func(t *testing.T) {
+		require.True(t, canRead(t, "user"))
+		_, err := service.SetPermissions(context.Background(), 1, "user", accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: ""})
+		require.NoError(t, err)
+		assert.False(t, canRead(t, "user"))
+	})
+}
+
+func TestService_getInheritedScopes(t *testing.T) {
+	type testCase struct {
+		desc     string
+		scopes   []string
+		expected []string
+	}
+
+	deep := make([]string, 0, maxInheritanceDepth+2)
+	for i := 0; i < maxInheritanceDepth+2; i++ {
+		deep = append(deep, fmt.Sprintf("folders:uid:%d", i))
+	}
+
+	tests := []testCase{
+		{
+			desc:     "should return all ancestors",
+			scopes:   []string{"folders:uid:3", "folders:uid:2", "folders:uid:1"},
+			expected: []string{"folders:uid:3", "folders:uid:2", "folders:uid:1"},
+		},
+		{
+			desc:     "should stop at the first repeated ancestor",
+			scopes:   []string{"folders:uid:3", "folders:uid:2", "folders:uid:3", "folders:uid:2"},
+			expected: []string{"folders:uid:3", "folders:uid:2"},
+		},
+		{
+			desc:     "should bound the number of ancestors",
+			scopes:   deep,
+			expected: deep[:maxInheritanceDepth],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			service, _, _ := setupTestEnvironment(t, Options{
+				Resource:             "folders",
+				ResourceAttribute:    "uid",
+				PermissionsToActions: testOptions.PermissionsToActions,
+				InheritedScopesSolver: func(ctx context.Context, orgID int64, resourceID string) ([]string, error) {
+					return tt.scopes, nil
+				},
+			})
+
+			scopes, err := service.InheritedScopes(context.Background(), 1, "4")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, scopes)
+		})
+	}
+}
+
+func setupTestEnvironment(t testing.TB, ops Options) (*Service, *sqlstore.SQLStore, team.Service) {
 	t.Helper()
 
 	sql := db.InitTestDB(t)
@@ -0,0 +1,143 @@
+package resourcepermissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+)
+
+// PermissionsSnapshot holds the managed permissions of a resource at a point in time. It only covers users, teams
+// and built-in roles, permissions of custom assignment kinds are not captured.
+type PermissionsSnapshot struct {
+	Resource    string                                       `json:"resource"`
+	ResourceID  string                                       `json:"resourceId"`
+	Created     time.Time                                    `json:"created"`
+	Permissions []accesscontrol.SetResourcePermissionCommand `json:"permissions"`
+}
+
+// SnapshotPermissions returns the managed permissions assigned directly on a resource, permissions inherited from
+// ancestors and permissions that do not map to a permission level are left out
+func (s *Service) SnapshotPermissions(ctx context.Context, orgID int64, resourceID string) (*PermissionsSnapshot, error) {
+	permissions, err := s.getManagedPermissions(ctx, orgID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &PermissionsSnapshot{
+		Resource:    s.options.Resource,
+		ResourceID:  resourceID,
+		Created:     time.Now(),
+		Permissions: make([]accesscontrol.SetResourcePermissionCommand, 0, len(permissions)),
+	}
+	for key, permission := range permissions {
+		cmd := key
+		cmd.Permission = permission
+		snapshot.Permissions = append(snapshot.Permissions, cmd)
+	}
+
+	sortCommands(snapshot.Permissions)
+	return snapshot, nil
+}
+
+// RestorePermissions replaces the managed permissions of a resource with the ones of a snapshot in a single transaction.
+// Only the assignments that differ from the snapshot are written, so hooks are called for the changes only.
+// Users and teams of the snapshot that no longer exist are skipped.
+func (s *Service) RestorePermissions(ctx context.Context, orgID int64, resourceID string, snapshot PermissionsSnapshot) ([]accesscontrol.ResourcePermission, error) {
+	if snapshot.Resource != s.options.Resource || snapshot.ResourceID != resourceID {
+		return nil, fmt.Errorf("%w: snapshot of %s %s cannot be restored on %s %s", ErrInvalidSnapshot, snapshot.Resource, snapshot.ResourceID, s.options.Resource, resourceID)
+	}
+
+	current, err := s.getManagedPermissions(ctx, orgID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the teams of the snapshot are resolved once, for the snapshot and for SetPermissions
+	ctx = withTeamCache(ctx, orgID)
+	if err := s.prefetchTeams(ctx, orgID, commandTeamIDs(snapshot.Permissions)); err != nil {
+		return nil, err
+	}
+
+	var commands []accesscontrol.SetResourcePermissionCommand
+	restored := make(map[accesscontrol.SetResourcePermissionCommand]struct{}, len(snapshot.Permissions))
+	for _, cmd := range snapshot.Permissions {
+		if _, err := s.mapPermission(cmd.Permission); err != nil {
+			return nil, err
+		}
+
+		key := accesscontrol.SetResourcePermissionCommand{UserID: cmd.UserID, TeamID: cmd.TeamID, BuiltinRole: cmd.BuiltinRole}
+		restored[key] = struct{}{}
+		if permission, ok := current[key]; ok && permission == cmd.Permission {
+			continue
+		}
+
+		if err := s.validateSnapshotAssignee(ctx, orgID, cmd); err != nil {
+			if !errors.Is(err, ErrAssigneeNotFound) {
+				return nil, err
+			}
+			s.log.Warn("Skipping permission of deleted assignee", "resource", s.options.Resource, "resourceID", resourceID, "userID", cmd.UserID, "teamID", cmd.TeamID, "error", err)
+			continue
+		}
+		commands = append(commands, cmd)
+	}
+
+	for key := range current {
+		if _, ok := restored[key]; !ok {
+			commands = append(commands, key)
+		}
+	}
+
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	return s.SetPermissions(withoutRequiredReason(ctx), orgID, resourceID, commands...)
+}
+
+// getManagedPermissions returns the permission level of every user, team and built-in role with managed permissions
+// assigned directly on a resource
+func (s *Service) getManagedPermissions(ctx context.Context, orgID int64, resourceID string) (map[accesscontrol.SetResourcePermissionCommand]string, error) {
+	// the snapshot covers every assignee, regardless of the users and teams the caller can see
+	permissions, err := s.store.GetResourcePermissions(ctx, orgID, GetResourcePermissionsQuery{
+		User: accesscontrol.BackgroundUser("resource_permissions_snapshot", orgID, org.RoleAdmin, []accesscontrol.Permission{
+			{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+			{Action: accesscontrol.ActionTeamsRead, Scope: accesscontrol.ScopeTeamsAll},
+			{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll},
+		}),
+		Actions:           s.getLevels().actions,
+		Resource:          s.options.Resource,
+		ResourceID:        resourceID,
+		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
+		OnlyManaged:       true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	managed := make(map[accesscontrol.SetResourcePermissionCommand]string, len(permissions))
+	for _, p := range permissions {
+		if !p.IsManaged || p.IsInherited {
+			continue
+		}
+		permission := s.MapActions(p)
+		if permission == "" {
+			continue
+		}
+		managed[accesscontrol.SetResourcePermissionCommand{UserID: p.UserId, TeamID: p.TeamId, BuiltinRole: p.BuiltInRole}] = permission
+	}
+	return managed, nil
+}
+
+func (s *Service) validateSnapshotAssignee(ctx context.Context, orgID int64, cmd accesscontrol.SetResourcePermissionCommand) error {
+	if cmd.UserID != 0 {
+		return s.validateUser(ctx, orgID, cmd.UserID)
+	} else if cmd.TeamID != 0 {
+		return s.validateTeam(ctx, orgID, cmd.TeamID)
+	}
+	return s.validateBuiltinRole(ctx, orgID, cmd.BuiltinRole)
+}
@@ -0,0 +1,46 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+)
+
+// UsageStats returns the usage statistics of the managed permissions on the resource, in every organization
+func (s *Service) UsageStats(ctx context.Context) (*UsageStats, error) {
+	return s.store.GetUsageStats(ctx, UsageStatsQuery{
+		Resource:          s.options.Resource,
+		ResourceAttribute: s.options.ResourceAttribute,
+		AssignmentKinds:   s.assignmentKindNames,
+	})
+}
+
+// CountPermissions returns the numbers of assignees with a managed permission on a resource of an organization by kind
+// of assignee, the assignees with a permission on every resource of the kind included. The assignees of every resource
+// of the kind are counted when resourceID is empty.
+func (s *Service) CountPermissions(ctx context.Context, orgID int64, resourceID string) (*PermissionCounts, error) {
+	return s.store.CountResourcePermissions(ctx, orgID, CountResourcePermissionsQuery{
+		Actions:           s.getLevels().actions,
+		Resource:          s.options.Resource,
+		ResourceAttribute: s.options.ResourceAttribute,
+		ResourceAliases:   s.options.ResourceAliases,
+		ResourceID:        resourceID,
+	})
+}
+
+func (s *Service) getUsageMetrics(ctx context.Context) (map[string]any, error) {
+	usage, err := s.UsageStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("stats.resource_permissions.%s", s.options.Resource)
+	stats := map[string]any{
+		prefix + ".resources.count":              usage.Resources,
+		prefix + ".assignments_per_resource.p95": usage.AssignmentsPerResourceP95,
+	}
+	for kind, count := range usage.Assignments {
+		stats[fmt.Sprintf("%s.assignments.%s.count", prefix, kind)] = count
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,101 @@
+package resourcepermissions
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/usagestats"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_UsageStats(t *testing.T) {
+	usageStats := &usagestats.UsageStatsMock{T: t}
+	options := testOptions
+	options.UsageStats = usageStats
+	service, sql, _ := setupTestEnvironment(t, options)
+
+	seedPermissions(t, "1", sql, service)
+	_, err := service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "2", "View")
+	require.NoError(t, err)
+
+	stats, err := service.UsageStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &UsageStats{
+		Resources:                 2,
+		Assignments:               map[string]int64{"users": 1, "teams": 1, "builtInRoles": 2},
+		AssignmentsPerResourceP95: 3,
+	}, stats)
+
+	report, err := usageStats.GetUsageReport(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"stats.resource_permissions.dashboards.resources.count":                int64(2),
+		"stats.resource_permissions.dashboards.assignments.users.count":        int64(1),
+		"stats.resource_permissions.dashboards.assignments.teams.count":        int64(1),
+		"stats.resource_permissions.dashboards.assignments.builtInRoles.count": int64(2),
+		"stats.resource_permissions.dashboards.assignments_per_resource.p95":   int64(3),
+	}, report.Metrics)
+}
+
+func TestApi_getUsageStats(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, testOptions)
+	seedPermissions(t, "1", sql, service)
+
+	getUsageStats := func(signedInUser *user.SignedInUser) *httptest.ResponseRecorder {
+		server := setupTestServer(t, signedInUser, service)
+		req, err := http.NewRequest(http.MethodGet, "/api/admin/access-control/dashboards/stats", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should only allow server admins to read usage stats", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, getUsageStats(&user.SignedInUser{OrgID: 1, UserID: 1}).Code)
+	})
+
+	t.Run("should return usage stats", func(t *testing.T) {
+		recorder := getUsageStats(&user.SignedInUser{OrgID: 1, UserID: 2, IsGrafanaAdmin: true})
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var stats UsageStats
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&stats))
+		assert.Equal(t, UsageStats{
+			Resources:                 1,
+			Assignments:               map[string]int64{"users": 1, "teams": 1, "builtInRoles": 1},
+			AssignmentsPerResourceP95: 3,
+		}, stats)
+	})
+}
+
+func TestService_CountPermissions(t *testing.T) {
+	options := testOptions
+	options.ResourceAliases = []string{"boards"}
+	service, sql, teamSvc := setupTestEnvironment(t, options)
+	usr := createOwnerTestUser(t, sql, "user", false)
+	tm, err := teamSvc.CreateTeam("team", "", 1)
+	require.NoError(t, err)
+
+	_, err = service.SetPermissions(context.Background(), 1, "1",
+		accesscontrol.SetResourcePermissionCommand{UserID: usr.ID, Permission: "Edit"},
+		accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "View"},
+	)
+	require.NoError(t, err)
+	_, err = service.SetBuiltInRolePermission(context.Background(), 1, "Viewer", "2", "View")
+	require.NoError(t, err)
+
+	counts, err := service.CountPermissions(context.Background(), 1, "1")
+	require.NoError(t, err)
+	assert.Equal(t, &PermissionCounts{Users: 1, Teams: 1, Total: 2}, counts)
+
+	counts, err = service.CountPermissions(context.Background(), 1, "")
+	require.NoError(t, err)
+	assert.Equal(t, &PermissionCounts{Users: 1, Teams: 1, BuiltInRoles: 1, Total: 3}, counts)
+}
@@ -3,6 +3,7 @@ package resourcepermissions
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"strings"
 	"time"
 
@@ -11,18 +12,30 @@ import (
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
 	"github.com/grafana/grafana/pkg/services/team"
 	"github.com/grafana/grafana/pkg/services/user"
 	"github.com/grafana/grafana/pkg/util"
 )
 
 func NewStore(sql db.DB, features featuremgmt.FeatureToggles) *store {
-	return &store{sql, features}
+	return &store{sql: sql, features: features, metrics: newStoreMetrics(nil), names: defaultManagedRoleNames}
 }
 
 type store struct {
 	sql      db.DB
 	features featuremgmt.FeatureToggles
+	metrics  *storeMetrics
+	names    managedRoleNames
+}
+
+// storedPermission is a permission of a managed role as read before it is replaced
+type storedPermission struct {
+	ID     int64 `xorm:"id"`
+	RoleID int64 `xorm:"role_id"`
+	Action string
+	Scope  string
+	Reason string
 }
 
 type flatResourcePermission struct {
@@ -37,35 +50,47 @@ type flatResourcePermission struct {
 	TeamEmail        string
 	Team             string
 	BuiltInRole      string
-	IsServiceAccount bool `xorm:"is_service_account"`
+	IsServiceAccount bool  `xorm:"is_service_account"`
+	GrantedBy        int64 `xorm:"granted_by"`
+	Reason           string
 	Created          time.Time
 	Updated          time.Time
 }
 
-func (p *flatResourcePermission) IsManaged(scope string) bool {
-	return strings.HasPrefix(p.RoleName, accesscontrol.ManagedRolePrefix) && p.Scope == scope
+func (p *flatResourcePermission) IsManaged(names managedRoleNames, scope string) bool {
+	return names.isManaged(p.RoleName) && p.Scope == scope
 }
 
 // IsInherited returns true for scopes from managed permissions that don't directly match the required scope
 // (ie, managed permissions on a parent resource)
-func (p *flatResourcePermission) IsInherited(scope string) bool {
-	return strings.HasPrefix(p.RoleName, accesscontrol.ManagedRolePrefix) && p.Scope != scope
+func (p *flatResourcePermission) IsInherited(names managedRoleNames, scope string) bool {
+	return names.isManaged(p.RoleName) && p.Scope != scope
 }
 
 type DeleteResourcePermissionsCmd struct {
 	Resource          string
 	ResourceAttribute string
 	ResourceID        string
+	// ResourceAliases are former names of Resource, the permissions on their scopes are deleted as well
+	ResourceAliases []string
 }
 
-func (s *store) DeleteResourcePermissions(ctx context.Context, orgID int64, cmd *DeleteResourcePermissionsCmd) error {
-	scope := accesscontrol.Scope(cmd.Resource, cmd.ResourceAttribute, cmd.ResourceID)
+func (s *store) DeleteResourcePermissions(ctx context.Context, orgID int64, cmd *DeleteResourcePermissionsCmd, hooks ResourceHooks) error {
+	start := time.Now()
+	scopes := append([]string{accesscontrol.Scope(cmd.Resource, cmd.ResourceAttribute, cmd.ResourceID)},
+		aliasScopes(cmd.ResourceAliases, cmd.ResourceAttribute, cmd.ResourceID)...)
+
+	var rows int64
+	err := s.inTransaction(ctx, func(sess *db.Session) error {
+		if err := s.callRemovedHooks(sess, orgID, scopes, cmd.ResourceID, hooks); err != nil {
+			return err
+		}
 
-	err := s.sql.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
 		var permissionIDs []int64
 		err := sess.SQL(
-			"SELECT permission.id FROM permission INNER JOIN role ON permission.role_id = role.id WHERE permission.scope = ? AND role.org_id = ?",
-			scope, orgID).Find(&permissionIDs)
+			"SELECT permission.id FROM permission INNER JOIN role ON permission.role_id = role.id WHERE permission.scope IN (?"+
+				strings.Repeat(",?", len(scopes)-1)+") AND role.org_id = ?",
+			append(stringArgs(scopes), orgID)...).Find(&permissionIDs)
 		if err != nil {
 			return err
 		}
@@ -73,47 +98,337 @@ func (s *store) DeleteResourcePermissions(ctx context.Context, orgID int64, cmd
 		if err := deletePermissions(sess, permissionIDs); err != nil {
 			return err
 		}
+		rows = int64(len(permissionIDs))
+
+		_, err = sess.Where("org_id = ? AND resource = ? AND resource_id = ?", orgID, cmd.Resource, cmd.ResourceID).Delete(&PermissionsLock{})
+		if err != nil {
+			return err
+		}
+
+		_, err = sess.Where("org_id = ? AND resource = ? AND resource_id = ?", orgID, cmd.Resource, cmd.ResourceID).Delete(&ResourceOwner{})
 		return err
 	})
 
+	s.metrics.observe(operationDelete, s.dialect(), start, err)
+	if err == nil {
+		s.metrics.addRowsAffected(operationDelete, s.dialect(), rows)
+	}
 	return err
 }
 
+func (s *store) DeleteDanglingAssignments(ctx context.Context, orgID int64, cmd DeleteDanglingAssignmentsCmd) ([]DanglingAssignment, error) {
+	start := time.Now()
+	prefix := accesscontrol.Scope(cmd.Resource, cmd.ResourceAttribute, "")
+
+	// the permissions stored with the scopes of the aliases are dangling as well
+	names := append([]string{cmd.Resource}, cmd.ResourceAliases...)
+	scopeFilter := "(p.scope LIKE ?" + strings.Repeat(" OR p.scope LIKE ?", len(names)-1) + ")"
+	scopeArgs := make([]any, 0, len(names))
+	for _, name := range names {
+		scopeArgs = append(scopeArgs, accesscontrol.Scope(name, cmd.ResourceAttribute, "")+"%")
+	}
+
+	// the user table holds service accounts and disabled users as well, only deleted users are missing from it
+	orgFilter := ""
+	usersFilter, args := s.names.like("r.name", "users:")
+	args = append(args, scopeArgs...)
+	if orgID != 0 {
+		orgFilter = " AND r.org_id = ?"
+		args = append(args, orgID)
+	}
+	teamsFilter, teamsArgs := s.names.like("r.name", "teams:")
+	args = append(append(args, teamsArgs...), scopeArgs...)
+	if orgID != 0 {
+		args = append(args, orgID)
+	}
+	rawSQL := `
+		SELECT p.id, r.org_id, ur.user_id, 0 AS team_id, p.scope
+		FROM permission p
+			INNER JOIN role r ON r.id = p.role_id
+			INNER JOIN user_role ur ON ur.role_id = r.id
+		WHERE ` + usersFilter + ` AND ` + scopeFilter + orgFilter + `
+			AND NOT EXISTS (SELECT 1 FROM ` + s.sql.GetDialect().Quote("user") + ` u WHERE u.id = ur.user_id)
+		UNION ALL
+		SELECT p.id, r.org_id, 0 AS user_id, tr.team_id, p.scope
+		FROM permission p
+			INNER JOIN role r ON r.id = p.role_id
+			INNER JOIN team_role tr ON tr.role_id = r.id
+		WHERE ` + teamsFilter + ` AND ` + scopeFilter + orgFilter + `
+			AND NOT EXISTS (SELECT 1 FROM team t WHERE t.id = tr.team_id)`
+
+	type dangling struct {
+		ID     int64  `xorm:"id"`
+		OrgID  int64  `xorm:"org_id"`
+		UserID int64  `xorm:"user_id"`
+		TeamID int64  `xorm:"team_id"`
+		Scope  string `xorm:"scope"`
+	}
+
+	var removed []DanglingAssignment
+	var rows int64
+	err := s.inTransaction(ctx, func(sess *db.Session) error {
+		var permissions []dangling
+		if err := sess.SQL(rawSQL, args...).Find(&permissions); err != nil {
+			return err
+		}
+
+		removed = removed[:0]
+		seen := make(map[DanglingAssignment]struct{}, len(permissions))
+		ids := make([]int64, 0, len(permissions))
+		for _, p := range permissions {
+			ids = append(ids, p.ID)
+			a := DanglingAssignment{OrgID: p.OrgID, UserID: p.UserID, TeamID: p.TeamID, ResourceID: strings.TrimPrefix(canonicalScope(cmd.Resource, cmd.ResourceAliases, p.Scope), prefix)}
+			if _, ok := seen[a]; !ok {
+				seen[a] = struct{}{}
+				removed = append(removed, a)
+			}
+		}
+
+		for len(ids) > 0 {
+			n := min(len(ids), danglingDeleteBatchSize)
+			if err := deletePermissions(sess, ids[:n]); err != nil {
+				return err
+			}
+			ids = ids[n:]
+		}
+		rows = int64(len(permissions))
+
+		// resources owned by deleted users are left without owner
+		ownerSQL := "DELETE FROM resource_permission_owner WHERE resource = ?"
+		ownerArgs := []any{cmd.Resource}
+		if orgID != 0 {
+			ownerSQL += " AND org_id = ?"
+			ownerArgs = append(ownerArgs, orgID)
+		}
+		ownerSQL += " AND NOT EXISTS (SELECT 1 FROM " + s.sql.GetDialect().Quote("user") + " u WHERE u.id = resource_permission_owner.user_id)"
+		_, err := sess.Exec(append([]any{ownerSQL}, ownerArgs...)...)
+		return err
+	})
+
+	s.metrics.observe(operationReconcile, s.dialect(), start, err)
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.addRowsAffected(operationReconcile, s.dialect(), rows)
+	return removed, nil
+}
+
+func (s *store) DeleteUserAssignments(ctx context.Context, orgID int64, cmd DeleteUserAssignmentsCmd, hook UserResourceHookFunc) ([]UserAssignment, error) {
+	start := time.Now()
+
+	names := append([]string{cmd.Resource}, cmd.ResourceAliases...)
+	scopeFilter := "p.scope LIKE ?" + strings.Repeat(" OR p.scope LIKE ?", len(names)-1)
+	roleNames := s.names.withPrevious(s.names.user(cmd.UserID))
+	args := stringArgs(roleNames)
+	for _, name := range names {
+		args = append(args, accesscontrol.Scope(name, cmd.ResourceAttribute, "")+"%")
+	}
+	orgFilter := ""
+	if orgID != 0 {
+		orgFilter = " AND r.org_id = ?"
+		args = append(args, orgID)
+	}
+
+	type userPermission struct {
+		ID    int64  `xorm:"id"`
+		OrgID int64  `xorm:"org_id"`
+		Scope string `xorm:"scope"`
+	}
+	var permissions []userPermission
+	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		return sess.SQL(`
+			SELECT p.id, r.org_id, p.scope
+			FROM permission p
+				INNER JOIN role r ON r.id = p.role_id
+			WHERE r.name IN (?`+strings.Repeat(",?", len(roleNames)-1)+`) AND (`+scopeFilter+`)`+orgFilter+`
+			ORDER BY r.org_id, p.scope`, args...).Find(&permissions)
+	})
+	if err != nil {
+		s.metrics.observe(operationRemoveUser, s.dialect(), start, err)
+		return nil, err
+	}
+
+	// the permissions of a resource under its aliases are removed with the ones under its current name
+	prefix := accesscontrol.Scope(cmd.Resource, cmd.ResourceAttribute, "")
+	var assignments []UserAssignment
+	ids := map[UserAssignment][]int64{}
+	for _, p := range permissions {
+		a := UserAssignment{
+			OrgID:      p.OrgID,
+			UserID:     cmd.UserID,
+			ResourceID: strings.TrimPrefix(canonicalScope(cmd.Resource, cmd.ResourceAliases, p.Scope), prefix),
+		}
+		if _, ok := ids[a]; !ok {
+			assignments = append(assignments, a)
+		}
+		ids[a] = append(ids[a], p.ID)
+	}
+
+	var removed []UserAssignment
+	var rows int64
+	for _, batch := range chunks(assignments, cmd.BatchSize) {
+		var batchRows int64
+		err = s.inTransaction(ctx, func(sess *db.Session) error {
+			batchRows = 0
+			for _, a := range batch {
+				if hook != nil {
+					if err := hook(sess, a.OrgID, accesscontrol.User{ID: a.UserID}, a.ResourceID, ""); err != nil {
+						return err
+					}
+				}
+				for _, c := range chunks(ids[a], danglingDeleteBatchSize) {
+					if err := deletePermissions(sess, c); err != nil {
+						return err
+					}
+				}
+				batchRows += int64(len(ids[a]))
+
+				_, err := sess.Where("org_id = ? AND resource = ? AND resource_id = ? AND user_id = ?", a.OrgID, cmd.Resource, a.ResourceID, a.UserID).Delete(&ResourceOwner{})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			break
+		}
+		rows += batchRows
+		removed = append(removed, batch...)
+	}
+
+	s.metrics.observe(operationRemoveUser, s.dialect(), start, err)
+	s.metrics.addRowsAffected(operationRemoveUser, s.dialect(), rows)
+	return removed, err
+}
+
+// callRemovedHooks calls the removal hooks for each user, team and built-in role with a managed permission on scopes
+func (s *store) callRemovedHooks(sess *db.Session, orgID int64, scopes []string, resourceID string, hooks ResourceHooks) error {
+	if hooks.UserRemoved == nil && hooks.TeamRemoved == nil && hooks.BuiltInRoleRemoved == nil {
+		return nil
+	}
+
+	type assignee struct {
+		UserID      int64  `xorm:"user_id"`
+		TeamID      int64  `xorm:"team_id"`
+		BuiltInRole string `xorm:"built_in_role"`
+	}
+	var assignees []assignee
+	managedFilter, managedArgs := s.names.like("r.name", "")
+	err := sess.SQL(`
+		SELECT DISTINCT COALESCE(ur.user_id, 0) AS user_id, COALESCE(tr.team_id, 0) AS team_id, COALESCE(br.role, '') AS built_in_role
+		FROM permission p
+			INNER JOIN role r ON r.id = p.role_id
+			LEFT JOIN user_role ur ON ur.role_id = r.id
+			LEFT JOIN team_role tr ON tr.role_id = r.id
+			LEFT JOIN builtin_role br ON br.role_id = r.id
+		WHERE p.scope IN (?`+strings.Repeat(",?", len(scopes)-1)+`) AND r.org_id = ? AND `+managedFilter,
+		append(append(stringArgs(scopes), orgID), managedArgs...)...).Find(&assignees)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range assignees {
+		switch {
+		case a.UserID != 0 && hooks.UserRemoved != nil:
+			err = hooks.UserRemoved(sess, orgID, accesscontrol.User{ID: a.UserID}, resourceID)
+		case a.TeamID != 0 && hooks.TeamRemoved != nil:
+			err = hooks.TeamRemoved(sess, orgID, a.TeamID, resourceID)
+		case a.BuiltInRole != "" && hooks.BuiltInRoleRemoved != nil:
+			err = hooks.BuiltInRoleRemoved(sess, orgID, a.BuiltInRole, resourceID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *store) SetUserResourcePermission(
 	ctx context.Context, orgID int64, usr accesscontrol.User,
 	cmd SetResourcePermissionCommand,
 	hook UserResourceHookFunc,
 ) (*accesscontrol.ResourcePermission, error) {
 	if usr.ID == 0 {
-		return nil, user.ErrUserNotFound
+		return nil, fmt.Errorf("%w: %w", ErrAssigneeNotFound, user.ErrUserNotFound)
 	}
 
+	start := time.Now()
+	cmd.grantedBy = grantor(ctx)
 	var err error
 	var permission *accesscontrol.ResourcePermission
-	err = s.sql.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
-		permission, err = s.setUserResourcePermission(sess, orgID, usr, cmd, hook)
+	err = s.inTransaction(ctx, func(sess *db.Session) error {
+		permission, _, err = s.setUserResourcePermission(sess, orgID, usr, cmd, hook)
 		return err
 	})
 
+	s.metrics.observe(operationSetUser, s.dialect(), start, err)
 	return permission, err
 }
 func (s *store) setUserResourcePermission(
 	sess *db.Session, orgID int64, user accesscontrol.User,
 	cmd SetResourcePermissionCommand,
 	hook UserResourceHookFunc,
-) (*accesscontrol.ResourcePermission, error) {
-	permission, err := s.setResourcePermission(sess, orgID, accesscontrol.ManagedUserRoleName(user.ID), s.userAdder(sess, orgID, user.ID), cmd)
+) (*accesscontrol.ResourcePermission, int64, error) {
+	permission, rows, err := s.setResourcePermission(sess, orgID, s.names.user(user.ID), s.userAdder(sess, orgID, user.ID), cmd)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if hook != nil {
 		if err := hook(sess, orgID, user, cmd.ResourceID, cmd.Permission); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 	}
 
-	return permission, nil
+	return permission, rows, nil
+}
+
+func (s *store) SetUserResourcePermissionForResources(
+	ctx context.Context, orgID int64, usr accesscontrol.User,
+	commands []SetResourcePermissionCommand,
+	hook UserResourceHookFunc,
+) ([]ResourcePermissionResult, error) {
+	if usr.ID == 0 {
+		return nil, fmt.Errorf("%w: %w", ErrAssigneeNotFound, user.ErrUserNotFound)
+	}
+
+	start := time.Now()
+	grantedBy := grantor(ctx)
+	var err error
+	var results []ResourcePermissionResult
+	var rows int64
+
+	err = s.inTransaction(ctx, func(sess *db.Session) error {
+		results = make([]ResourcePermissionResult, 0, len(commands))
+		rows = 0
+		for _, cmd := range commands {
+			cmd.grantedBy = grantedBy
+			permission, affected, err := s.setResourcePermission(sess, orgID, s.names.user(usr.ID), s.userAdder(sess, orgID, usr.ID), cmd)
+			if err != nil {
+				return err
+			}
+
+			// the hook is only called when the permission changed, an unchanged permission is already in effect
+			if affected > 0 && hook != nil {
+				if err := hook(sess, orgID, usr, cmd.ResourceID, cmd.Permission); err != nil {
+					return err
+				}
+			}
+
+			results = append(results, ResourcePermissionResult{ResourceID: cmd.ResourceID, Permission: permission, Unchanged: affected == 0})
+			rows += affected
+		}
+		return nil
+	})
+
+	s.metrics.observe(operationSetUserBulk, s.dialect(), start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	s.metrics.addRowsAffected(operationSetUserBulk, s.dialect(), rows)
+	return results, nil
 }
 
 func (s *store) SetTeamResourcePermission(
@@ -122,17 +437,20 @@ func (s *store) SetTeamResourcePermission(
 	hook TeamResourceHookFunc,
 ) (*accesscontrol.ResourcePermission, error) {
 	if teamID == 0 {
-		return nil, team.ErrTeamNotFound
+		return nil, fmt.Errorf("%w: %w", ErrAssigneeNotFound, team.ErrTeamNotFound)
 	}
 
+	start := time.Now()
+	cmd.grantedBy = grantor(ctx)
 	var err error
 	var permission *accesscontrol.ResourcePermission
 
-	err = s.sql.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
-		permission, err = s.setTeamResourcePermission(sess, orgID, teamID, cmd, hook)
+	err = s.inTransaction(ctx, func(sess *db.Session) error {
+		permission, _, err = s.setTeamResourcePermission(sess, orgID, teamID, cmd, hook)
 		return err
 	})
 
+	s.metrics.observe(operationSetTeam, s.dialect(), start, err)
 	return permission, err
 }
 
@@ -140,19 +458,19 @@ func (s *store) setTeamResourcePermission(
 	sess *db.Session, orgID, teamID int64,
 	cmd SetResourcePermissionCommand,
 	hook TeamResourceHookFunc,
-) (*accesscontrol.ResourcePermission, error) {
-	permission, err := s.setResourcePermission(sess, orgID, accesscontrol.ManagedTeamRoleName(teamID), s.teamAdder(sess, orgID, teamID), cmd)
+) (*accesscontrol.ResourcePermission, int64, error) {
+	permission, rows, err := s.setResourcePermission(sess, orgID, s.names.team(teamID), s.teamAdder(sess, orgID, teamID), cmd)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if hook != nil {
 		if err := hook(sess, orgID, teamID, cmd.ResourceID, cmd.Permission); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 	}
 
-	return permission, nil
+	return permission, rows, nil
 }
 
 func (s *store) SetBuiltInResourcePermission(
@@ -161,17 +479,20 @@ func (s *store) SetBuiltInResourcePermission(
 	hook BuiltinResourceHookFunc,
 ) (*accesscontrol.ResourcePermission, error) {
 	if !org.RoleType(builtInRole).IsValid() || builtInRole == accesscontrol.RoleGrafanaAdmin {
-		return nil, fmt.Errorf("invalid role: %s", builtInRole)
+		return nil, fmt.Errorf("%w: invalid role %s", ErrInvalidAssignment, builtInRole)
 	}
 
+	start := time.Now()
+	cmd.grantedBy = grantor(ctx)
 	var err error
 	var permission *accesscontrol.ResourcePermission
 
-	err = s.sql.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
-		permission, err = s.setBuiltInResourcePermission(sess, orgID, builtInRole, cmd, hook)
+	err = s.inTransaction(ctx, func(sess *db.Session) error {
+		permission, _, err = s.setBuiltInResourcePermission(sess, orgID, builtInRole, cmd, hook)
 		return err
 	})
 
+	s.metrics.observe(operationSetBuiltIn, s.dialect(), start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -183,18 +504,46 @@ func (s *store) setBuiltInResourcePermission(
 	sess *db.Session, orgID int64, builtInRole string,
 	cmd SetResourcePermissionCommand,
 	hook BuiltinResourceHookFunc,
-) (*accesscontrol.ResourcePermission, error) {
-	permission, err := s.setResourcePermission(sess, orgID, accesscontrol.ManagedBuiltInRoleName(builtInRole), s.builtInRoleAdder(sess, orgID, builtInRole), cmd)
+) (*accesscontrol.ResourcePermission, int64, error) {
+	permission, rows, err := s.setResourcePermission(sess, orgID, s.names.builtInRole(builtInRole), s.builtInRoleAdder(sess, orgID, builtInRole), cmd)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if hook != nil {
 		if err := hook(sess, orgID, builtInRole, cmd.ResourceID, cmd.Permission); err != nil {
-			return nil, err
+			return nil, 0, err
+		}
+	}
+
+	return permission, rows, nil
+}
+
+func (s *store) SetAssignmentResourcePermission(
+	ctx context.Context, orgID int64, kind, assigneeID string,
+	cmd SetResourcePermissionCommand,
+	bind AssignmentRoleBinderFunc,
+) (*accesscontrol.ResourcePermission, error) {
+	start := time.Now()
+	cmd.grantedBy = grantor(ctx)
+	var err error
+	var permission *accesscontrol.ResourcePermission
+
+	err = s.inTransaction(ctx, func(sess *db.Session) error {
+		adder := func(roleID int64) error {
+			return bind(sess, orgID, assigneeID, roleID)
 		}
+		permission, _, err = s.setResourcePermission(sess, orgID, s.names.assignment(kind, assigneeID), adder, cmd)
+		return err
+	})
+
+	s.metrics.observe(operationSetAssignment, s.dialect(), start, err)
+	if err != nil {
+		return nil, err
 	}
 
+	permission.AssigneeKind = kind
+	permission.AssigneeID = assigneeID
 	return permission, nil
 }
 
@@ -202,50 +551,110 @@ func (s *store) SetResourcePermissions(
 	ctx context.Context, orgID int64,
 	commands []SetResourcePermissionsCommand,
 	hooks ResourceHooks,
-) ([]accesscontrol.ResourcePermission, error) {
+) ([]SetResourcePermissionsResult, error) {
+	start := time.Now()
+	grantedBy := grantor(ctx)
+	commands = append(make([]SetResourcePermissionsCommand, 0, len(commands)), commands...)
+	for i := range commands {
+		commands[i].grantedBy = grantedBy
+	}
 	var err error
-	var permissions []accesscontrol.ResourcePermission
-
-	err = s.sql.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
-		for _, cmd := range commands {
-			var p *accesscontrol.ResourcePermission
-			if cmd.User.ID != 0 {
-				p, err = s.setUserResourcePermission(sess, orgID, cmd.User, cmd.SetResourcePermissionCommand, hooks.User)
-			} else if cmd.TeamID != 0 {
-				p, err = s.setTeamResourcePermission(sess, orgID, cmd.TeamID, cmd.SetResourcePermissionCommand, hooks.Team)
-			} else if org.RoleType(cmd.BuiltinRole).IsValid() || cmd.BuiltinRole == accesscontrol.RoleGrafanaAdmin {
-				p, err = s.setBuiltInResourcePermission(sess, orgID, cmd.BuiltinRole, cmd.SetResourcePermissionCommand, hooks.BuiltInRole)
-			}
-			if err != nil {
-				return err
-			}
-			if p != nil {
-				permissions = append(permissions, *p)
-			}
-		}
+	var results []SetResourcePermissionsResult
+	var rows int64
 
-		return nil
+	err = s.inTransaction(ctx, func(sess *db.Session) error {
+		results, rows, err = s.setResourcePermissions(sess, orgID, commands, hooks)
+		return err
 	})
 
-	return permissions, err
+	s.metrics.observe(operationSetPermission, s.dialect(), start, err)
+	if err == nil {
+		s.metrics.addRowsAffected(operationSetPermission, s.dialect(), rows)
+	}
+	return results, err
 }
 
 type roleAdder func(roleID int64) error
 
+// danglingDeleteBatchSize is the number of dangling permissions deleted per statement, it keeps the number of
+// parameters of a statement below the limits of the databases
+const danglingDeleteBatchSize = 500
+
+// teamsMembersBatchSize is the number of teams whose members are read in a single query by GetTeamsMembers
+const teamsMembersBatchSize = 500
+
+func (s *store) dialect() string {
+	return s.sql.GetDialect().DriverName()
+}
+
+// forUpdate returns the locking clause for reads inside write transactions. Sqlite does not support it
+// but only allows a single write transaction at a time.
+func (s *store) forUpdate() string {
+	if s.sql.GetDialect().DriverName() == migrator.SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// maxWriteRetries is the number of times a write transaction is retried after failing because of a concurrent write
+const maxWriteRetries = 3
+
+// writeRetryBackoff is the base delay before retrying a write transaction, it doubles with each retry
+var writeRetryBackoff = 20 * time.Millisecond
+
+// inTransaction runs fn in a transaction. When the transaction fails because of a concurrent write, such as a deadlock
+// or a serialization failure, the whole transaction is run again after a jittered backoff, up to maxWriteRetries times.
+// The error of the last attempt is returned as an ErrConcurrentWrite.
+func (s *store) inTransaction(ctx context.Context, fn func(sess *db.Session) error) error {
+	for attempt := 0; ; attempt++ {
+		err := s.sql.WithTransactionalDbSession(ctx, fn)
+		if err == nil || !s.isConcurrentWriteError(err) {
+			return err
+		}
+		if attempt == maxWriteRetries {
+			return fmt.Errorf("%w: %w", ErrConcurrentWrite, err)
+		}
+
+		backoff := writeRetryBackoff << attempt
+		backoff += time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrConcurrentWrite, err)
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// isConcurrentWriteError reports whether a write failed because of a concurrent write and can be retried
+func (s *store) isConcurrentWriteError(err error) bool {
+	dialect := s.sql.GetDialect()
+	return dialect.IsDeadlock(err) || dialect.IsSerializationFailure(err) || dialect.IsLockTimeout(err) || dialect.IsUniqueConstraintViolation(err)
+}
+
 func (s *store) setResourcePermission(
 	sess *db.Session, orgID int64, roleName string, adder roleAdder, cmd SetResourcePermissionCommand,
-) (*accesscontrol.ResourcePermission, error) {
+) (*accesscontrol.ResourcePermission, int64, error) {
 	role, err := s.getOrCreateManagedRole(sess, orgID, roleName, adder)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	rawSQL := `SELECT p.* FROM permission as p INNER JOIN role r on r.id = p.role_id WHERE r.id = ? AND p.scope = ?`
+	// lock the managed role so concurrent writers of the same role wait for each other instead of
+	// inserting duplicated permissions, the current permissions are read with a locking read as well
+	// so they include permissions committed while waiting for the lock
+	if _, err := sess.SQL("SELECT id FROM role WHERE id = ?"+s.forUpdate(), role.ID).Get(new(int64)); err != nil {
+		return nil, 0, err
+	}
 
-	var current []accesscontrol.Permission
+	// the permissions stored with the scopes of aliases of the resource are replaced by the ones of the command
 	scope := accesscontrol.Scope(cmd.Resource, cmd.ResourceAttribute, cmd.ResourceID)
-	if err := sess.SQL(rawSQL, role.ID, scope).Find(&current); err != nil {
-		return nil, err
+	scopes := append([]string{scope}, aliasScopes(cmd.ResourceAliases, cmd.ResourceAttribute, cmd.ResourceID)...)
+	rawSQL := `SELECT p.* FROM permission as p INNER JOIN role r on r.id = p.role_id WHERE r.id = ? AND p.scope IN (?` +
+		strings.Repeat(",?", len(scopes)-1) + `)` + s.forUpdate()
+
+	var current []storedPermission
+	if err := sess.SQL(rawSQL, append([]any{role.ID}, stringArgs(scopes)...)...).Find(&current); err != nil {
+		return nil, 0, err
 	}
 
 	missing := make(map[string]struct{}, len(cmd.Actions))
@@ -254,36 +663,54 @@ func (s *store) setResourcePermission(
 	}
 
 	var remove []int64
+	reason := cmd.Reason
 	for _, p := range current {
-		if _, ok := missing[p.Action]; ok {
+		if _, ok := missing[p.Action]; ok && p.Scope == scope {
 			delete(missing, p.Action)
-		} else if !ok {
+		} else {
 			remove = append(remove, p.ID)
 		}
+		if cmd.Reason == "" && cmd.KeepReason && p.Reason != "" {
+			reason = p.Reason
+		}
 	}
 
 	if err := deletePermissions(sess, remove); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	if err := s.createPermissions(sess, role.ID, cmd.Resource, cmd.ResourceID, cmd.ResourceAttribute, missing); err != nil {
-		return nil, err
+	if err := s.createPermissions(sess, role.ID, cmd.Resource, cmd.ResourceID, cmd.ResourceAttribute, missing, cmd.grantedBy, reason); err != nil {
+		return nil, 0, err
+	}
+
+	// the grantor of a changed permission is the one of the command, an unchanged permission keeps its grantor
+	if len(cmd.Actions) > 0 && len(remove)+len(missing) > 0 {
+		if _, err := sess.Exec("UPDATE permission SET granted_by = ? WHERE role_id = ? AND scope = ?", cmd.grantedBy, role.ID, scope); err != nil {
+			return nil, 0, err
+		}
+	}
+	if len(cmd.Actions) > 0 {
+		if _, err := sess.Exec("UPDATE permission SET reason = ? WHERE role_id = ? AND scope = ?", reason, role.ID, scope); err != nil {
+			return nil, 0, err
+		}
 	}
 
 	permissions, err := s.getPermissions(sess, cmd.Resource, cmd.ResourceID, cmd.ResourceAttribute, role.ID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	permission := flatPermissionsToResourcePermission(scope, permissions)
+	rows := int64(len(remove) + len(missing))
+	permission := flatPermissionsToResourcePermission(s.names, scope, permissions)
 	if permission == nil {
-		return &accesscontrol.ResourcePermission{}, nil
+		return &accesscontrol.ResourcePermission{}, rows, nil
 	}
 
-	return permission, nil
+	return permission, rows, nil
 }
 
 func (s *store) GetResourcePermissions(ctx context.Context, orgID int64, query GetResourcePermissionsQuery) ([]accesscontrol.ResourcePermission, error) {
+	start := time.Now()
 	var result []accesscontrol.ResourcePermission
 
 	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
@@ -292,14 +719,738 @@ func (s *store) GetResourcePermissions(ctx context.Context, orgID int64, query G
 		return err
 	})
 
+	s.metrics.observe(operationGet, s.dialect(), start, err)
 	return result, err
 }
 
+func (s *store) GetResourcePermissionsPage(
+	ctx context.Context, orgID int64,
+	query GetResourcePermissionsQuery,
+	opts ResourcePermissionsQueryOptions,
+) (*ResourcePermissionsPage, error) {
+	result := &ResourcePermissionsPage{}
+	if len(query.Actions) == 0 {
+		return result, nil
+	}
+
+	start := time.Now()
+	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		permissionsSQL, args, err := s.resourcePermissionsSQL(orgID, query)
+		if err != nil {
+			return err
+		}
+
+		filter, filterArgs := s.resourcePermissionsFilter(opts)
+		from := " FROM (" + permissionsSQL + ") a" + filter
+		args = append(args, filterArgs...)
+
+		countSQL := "SELECT COUNT(*) FROM (SELECT user_id, team_id, built_in_role" + from + " GROUP BY user_id, team_id, built_in_role) c"
+		if _, err := sess.SQL(countSQL, args...).Get(&result.TotalCount); err != nil {
+			return err
+		}
+
+		// assignees are sorted by kind and then by name so pages are stable
+		assigneesSQL := "SELECT user_id, team_id, built_in_role" + from + `
+			GROUP BY user_id, team_id, built_in_role, user_login, team
+			ORDER BY CASE WHEN user_id <> 0 THEN 0 WHEN team_id <> 0 THEN 1 ELSE 2 END, user_login, team, built_in_role`
+		if opts.Limit > 0 {
+			assigneesSQL += s.sql.GetDialect().LimitOffset(opts.Limit, opts.Offset)
+		}
+
+		assignees := make([]flatResourcePermission, 0)
+		if err := sess.SQL(assigneesSQL, args...).Find(&assignees); err != nil {
+			return err
+		}
+		if len(assignees) == 0 {
+			return nil
+		}
+
+		var userIDs, teamIDs []any
+		var builtInRoles []any
+		for _, a := range assignees {
+			if a.UserId != 0 {
+				userIDs = append(userIDs, a.UserId)
+			} else if a.TeamId != 0 {
+				teamIDs = append(teamIDs, a.TeamId)
+			} else {
+				builtInRoles = append(builtInRoles, a.BuiltInRole)
+			}
+		}
+
+		var assigneeFilters []string
+		if len(userIDs) > 0 {
+			assigneeFilters = append(assigneeFilters, "user_id IN (?"+strings.Repeat(",?", len(userIDs)-1)+")")
+			args = append(args, userIDs...)
+		}
+		if len(teamIDs) > 0 {
+			assigneeFilters = append(assigneeFilters, "team_id IN (?"+strings.Repeat(",?", len(teamIDs)-1)+")")
+			args = append(args, teamIDs...)
+		}
+		if len(builtInRoles) > 0 {
+			assigneeFilters = append(assigneeFilters, "(user_id = 0 AND team_id = 0 AND built_in_role IN (?"+strings.Repeat(",?", len(builtInRoles)-1)+"))")
+			args = append(args, builtInRoles...)
+		}
+
+		pageSQL := "SELECT *" + from + " AND (" + strings.Join(assigneeFilters, " OR ") + ")"
+		queryResults := make([]flatResourcePermission, 0)
+		if err := sess.SQL(pageSQL, args...).Find(&queryResults); err != nil {
+			return err
+		}
+		queryResults = canonicalScopes(query.Resource, query.ResourceAliases, queryResults)
+
+		scope := accesscontrol.Scope(query.Resource, query.ResourceAttribute, query.ResourceID)
+		users, teams, builtins := groupPermissionsByAssignment(queryResults)
+		for _, a := range assignees {
+			var permissions []flatResourcePermission
+			if a.UserId != 0 {
+				permissions = users[a.UserId]
+			} else if a.TeamId != 0 {
+				permissions = teams[a.TeamId]
+			} else {
+				permissions = builtins[a.BuiltInRole]
+			}
+			result.Permissions = append(result.Permissions, flatPermissionsToResourcePermissions(s.names, scope, query.InheritedScopes, permissions)...)
+		}
+
+		return nil
+	})
+
+	s.metrics.observe(operationGetPage, s.dialect(), start, err)
+	return result, err
+}
+
+// resourcePermissionsFilter returns a where clause, applied on the rows returned by resourcePermissionsSQL,
+// matching the kind and search options
+func (s *store) GetAssignmentResourcePermissions(ctx context.Context, orgID int64, kind string, query GetResourcePermissionsQuery) ([]accesscontrol.ResourcePermission, error) {
+	if len(query.Actions) == 0 {
+		return nil, nil
+	}
+
+	start := time.Now()
+	var result []accesscontrol.ResourcePermission
+
+	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		scope := accesscontrol.Scope(query.Resource, query.ResourceAttribute, query.ResourceID)
+		scopes := append(resourceScopes(query.Resource, query.ResourceAliases, query.ResourceAttribute, query.ResourceID), query.InheritedScopes...)
+
+		kindFilter, kindArgs := s.names.like("r.name", kind+":")
+		rawSQL := `
+		SELECT
+			p.*,
+			r.name AS role_name
+		FROM permission p
+			INNER JOIN role r ON p.role_id = r.id
+		WHERE r.org_id = ? AND ` + kindFilter + `
+			AND p.scope IN (?` + strings.Repeat(",?", len(scopes)-1) + `)
+			AND p.action IN (?` + strings.Repeat(",?", len(query.Actions)-1) + `)`
+		args := append([]any{orgID}, kindArgs...)
+		for _, scope := range scopes {
+			args = append(args, scope)
+		}
+		for _, action := range query.Actions {
+			args = append(args, action)
+		}
+		if len(query.AnyAction) > 0 {
+			filter, filterArgs := anyActionFilter(query.AnyAction)
+			rawSQL += filter
+			args = append(args, filterArgs...)
+		}
+		rawSQL += " ORDER BY r.name, p.id"
+
+		queryResults := make([]flatResourcePermission, 0)
+		if err := sess.SQL(rawSQL, args...).Find(&queryResults); err != nil {
+			return err
+		}
+		queryResults = canonicalScopes(query.Resource, query.ResourceAliases, queryResults)
+
+		var assignees []string
+		byAssignee := make(map[string][]flatResourcePermission)
+		for _, p := range queryResults {
+			assigneeID, ok := s.names.parseAssignment(kind, p.RoleName)
+			if !ok {
+				continue
+			}
+			if _, ok := byAssignee[assigneeID]; !ok {
+				assignees = append(assignees, assigneeID)
+			}
+			byAssignee[assigneeID] = append(byAssignee[assigneeID], p)
+		}
+
+		for _, assigneeID := range assignees {
+			for _, p := range flatPermissionsToResourcePermissions(s.names, scope, query.InheritedScopes, byAssignee[assigneeID]) {
+				p.AssigneeKind = kind
+				p.AssigneeID = assigneeID
+				result = append(result, p)
+			}
+		}
+		return nil
+	})
+
+	s.metrics.observe(operationGetAssignment, s.dialect(), start, err)
+	return result, err
+}
+
+type userWithAccess struct {
+	UserID int64 `xorm:"user_id"`
+}
+
+func (s *store) ListUsersWithAccess(ctx context.Context, orgID int64, query ListUsersWithAccessQuery, fn func(userID int64) error) error {
+	start := time.Now()
+
+	scopes := append(resourceScopes(query.Resource, query.ResourceAliases, query.ResourceAttribute, query.ResourceID), query.InheritedScopes...)
+
+	// permissionFilter selects the roles granting the action on one of the scopes
+	permissionFilter := `r.org_id IN (?, 0) AND p.action = ? AND p.scope IN (?` + strings.Repeat(",?", len(scopes)-1) + `)`
+	permissionArgs := []any{orgID, query.Action}
+	for _, scope := range scopes {
+		permissionArgs = append(permissionArgs, scope)
+	}
+
+	// users are resolved from their organization membership, direct, team and role assignments are matched as
+	// sets so neither the members of the organization nor the assignments are loaded in memory
+	rawSQL := `
+	SELECT ou.user_id
+	FROM org_user ou
+		INNER JOIN ` + s.sql.GetDialect().Quote("user") + ` u ON ou.user_id = u.id
+	WHERE ou.org_id = ? AND u.is_service_account = ? AND u.is_disabled = ? AND (
+		ou.user_id IN (
+			SELECT ur.user_id FROM user_role ur
+				INNER JOIN role r ON ur.role_id = r.id
+				INNER JOIN permission p ON p.role_id = r.id
+			WHERE ur.org_id IN (0, ?) AND ` + permissionFilter + `
+		)
+		OR ou.user_id IN (
+			SELECT tm.user_id FROM team_member tm
+				INNER JOIN team_role tr ON tm.team_id = tr.team_id
+				INNER JOIN role r ON tr.role_id = r.id
+				INNER JOIN permission p ON p.role_id = r.id
+			WHERE tm.org_id = ? AND tr.org_id IN (0, ?) AND ` + permissionFilter + `
+		)
+		OR ou.role IN (
+			SELECT br.role FROM builtin_role br
+				INNER JOIN role r ON br.role_id = r.id
+				INNER JOIN permission p ON p.role_id = r.id
+			WHERE br.org_id IN (0, ?) AND ` + permissionFilter + `
+		)
+		OR (u.is_admin = ? AND EXISTS (
+			SELECT 1 FROM builtin_role br
+				INNER JOIN role r ON br.role_id = r.id
+				INNER JOIN permission p ON p.role_id = r.id
+			WHERE br.role = ? AND br.org_id IN (0, ?) AND ` + permissionFilter + `
+		))`
+
+	args := []any{orgID, s.sql.GetDialect().BooleanStr(false), s.sql.GetDialect().BooleanStr(false)}
+	args = append(append(args, orgID), permissionArgs...)
+	args = append(append(args, orgID, orgID), permissionArgs...)
+	args = append(append(args, orgID), permissionArgs...)
+	args = append(append(args, s.sql.GetDialect().BooleanStr(true), accesscontrol.RoleGrafanaAdmin, orgID), permissionArgs...)
+
+	if len(query.ImplicitRoles) > 0 {
+		rawSQL += `
+		OR ou.role IN (?` + strings.Repeat(",?", len(query.ImplicitRoles)-1) + `)`
+		for _, role := range query.ImplicitRoles {
+			args = append(args, role)
+		}
+	}
+	rawSQL += `
+	)
+	ORDER BY ou.user_id`
+
+	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		row := new(userWithAccess)
+		rows, err := sess.SQL(rawSQL, args...).Rows(row)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rows.Close() }()
+
+		// rows are streamed so large organizations are not buffered in memory
+		for rows.Next() {
+			if err := rows.Scan(row); err != nil {
+				return err
+			}
+			if err := fn(row.UserID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	s.metrics.observe(operationListUsers, s.dialect(), start, err)
+	return err
+}
+
+// CountResourcePermissions counts the assignees of the managed roles granting query.Actions on the scopes of a
+// resource, or of every resource of query.Resource, with a single aggregate query grouped by kind of assignee
+func (s *store) CountResourcePermissions(ctx context.Context, orgID int64, query CountResourcePermissionsQuery) (*PermissionCounts, error) {
+	start := time.Now()
+
+	var scopeFilter string
+	var scopeArgs []any
+	if query.ResourceID != "" {
+		scopes := resourceScopes(query.Resource, query.ResourceAliases, query.ResourceAttribute, query.ResourceID)
+		scopeFilter = "p.scope IN (?" + strings.Repeat(",?", len(scopes)-1) + ")"
+		scopeArgs = stringArgs(scopes)
+	} else {
+		// the scopes of every resource of the kind are matched by prefix, the wildcard scopes are the ones of
+		// resourceScopes without the scope of a resource
+		scopes := []string{"*"}
+		var prefixes []string
+		for _, name := range append([]string{query.Resource}, query.ResourceAliases...) {
+			scopes = append(scopes, accesscontrol.Scope(name, "*"), accesscontrol.Scope(name, query.ResourceAttribute, "*"))
+			prefixes = append(prefixes, accesscontrol.Scope(name, query.ResourceAttribute, "%"))
+		}
+		scopeFilter = "(p.scope IN (?" + strings.Repeat(",?", len(scopes)-1) + ")" + strings.Repeat(" OR p.scope LIKE ?", len(prefixes)) + ")"
+		scopeArgs = append(stringArgs(scopes), stringArgs(prefixes)...)
+	}
+
+	// every managed role belongs to a single assignee, counting distinct roles counts assignees
+	managedFilter, managedArgs := s.names.like("r.name", "")
+	rawSQL := `
+	SELECT a.kind, COUNT(DISTINCT a.role_id) AS assignees
+	FROM (
+		SELECT r.id AS role_id, CASE
+			WHEN u.is_service_account = ? THEN 'serviceAccounts'
+			WHEN ur.user_id IS NOT NULL THEN 'users'
+			WHEN tr.team_id IS NOT NULL THEN 'teams'
+			WHEN br.role IS NOT NULL THEN 'builtInRoles'
+			ELSE ''
+		END AS kind
+		FROM permission p
+			INNER JOIN role r ON r.id = p.role_id
+			LEFT JOIN user_role ur ON ur.role_id = r.id
+			LEFT JOIN ` + s.sql.GetDialect().Quote("user") + ` u ON u.id = ur.user_id
+			LEFT JOIN team_role tr ON tr.role_id = r.id
+			LEFT JOIN builtin_role br ON br.role_id = r.id
+		WHERE r.org_id = ? AND ` + managedFilter + ` AND p.action IN (?` + strings.Repeat(",?", len(query.Actions)-1) + `) AND ` + scopeFilter + `
+	) a
+	GROUP BY a.kind`
+	args := append([]any{s.sql.GetDialect().BooleanStr(true), orgID}, managedArgs...)
+	args = append(append(args, stringArgs(query.Actions)...), scopeArgs...)
+
+	type row struct {
+		Kind      string `xorm:"kind"`
+		Assignees int64  `xorm:"assignees"`
+	}
+
+	counts := &PermissionCounts{}
+	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		var rows []row
+		if err := sess.SQL(rawSQL, args...).Find(&rows); err != nil {
+			return err
+		}
+		for _, r := range rows {
+			switch r.Kind {
+			case "users":
+				counts.Users = r.Assignees
+			case "serviceAccounts":
+				counts.ServiceAccounts = r.Assignees
+			case "teams":
+				counts.Teams = r.Assignees
+			case "builtInRoles":
+				counts.BuiltInRoles = r.Assignees
+			}
+			counts.Total += r.Assignees
+		}
+		return nil
+	})
+
+	s.metrics.observe(operationCount, s.dialect(), start, err)
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// GetTeamsMembers returns the members of teams by team id with a query per teamsMembersBatchSize teams
+func (s *store) GetTeamsMembers(ctx context.Context, orgID int64, query GetTeamsMembersQuery) (map[int64][]TeamMember, error) {
+	start := time.Now()
+	members := make(map[int64][]TeamMember, len(query.TeamIDs))
+	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		for _, batch := range chunks(query.TeamIDs, teamsMembersBatchSize) {
+			rawSQL := `
+			SELECT tm.team_id, u.id AS user_id, u.login, u.email, u.is_service_account
+			FROM team_member tm
+				INNER JOIN ` + s.sql.GetDialect().Quote("user") + ` u ON tm.user_id = u.id
+			WHERE tm.org_id = ? AND tm.team_id IN (?` + strings.Repeat(",?", len(batch)-1) + `)`
+			args := append([]any{orgID}, int64Args(batch)...)
+
+			if query.EnforceAccessControl {
+				userFilter, err := accesscontrol.Filter(query.User, "u.id", "users:id:", accesscontrol.ActionOrgUsersRead)
+				if err != nil {
+					return err
+				}
+				saFilter, err := accesscontrol.Filter(query.User, "u.id", "serviceaccounts:id:", serviceaccounts.ActionRead)
+				if err != nil {
+					return err
+				}
+				rawSQL += " AND ((" + userFilter.Where + " AND NOT u.is_service_account) OR (" + saFilter.Where + " AND u.is_service_account))"
+				args = append(append(args, userFilter.Args...), saFilter.Args...)
+			}
+			rawSQL += " ORDER BY tm.team_id, u.id"
+
+			var rows []TeamMember
+			if err := sess.SQL(rawSQL, args...).Find(&rows); err != nil {
+				return err
+			}
+			for _, m := range rows {
+				members[m.TeamID] = append(members[m.TeamID], m)
+			}
+		}
+		return nil
+	})
+
+	s.metrics.observe(operationTeamsMembers, s.dialect(), start, err)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (s *store) LockResourcePermissions(ctx context.Context, orgID int64, lock PermissionsLock) error {
+	start := time.Now()
+	err := s.inTransaction(ctx, func(sess *db.Session) error {
+		exists, err := sess.Where("org_id = ? AND resource = ? AND resource_id = ?", orgID, lock.Resource, lock.ResourceID).Exist(&PermissionsLock{})
+		if err != nil || exists {
+			return err
+		}
+
+		lock.ID = 0
+		lock.OrgID = orgID
+		lock.Created = time.Now()
+		_, err = sess.Insert(&lock)
+		return err
+	})
+
+	s.metrics.observe(operationLock, s.dialect(), start, err)
+	return err
+}
+
+func (s *store) UnlockResourcePermissions(ctx context.Context, orgID int64, resource, resourceID string) error {
+	start := time.Now()
+	err := s.inTransaction(ctx, func(sess *db.Session) error {
+		_, err := sess.Where("org_id = ? AND resource = ? AND resource_id = ?", orgID, resource, resourceID).Delete(&PermissionsLock{})
+		return err
+	})
+
+	s.metrics.observe(operationUnlock, s.dialect(), start, err)
+	return err
+}
+
+func (s *store) GetResourcePermissionsLock(ctx context.Context, orgID int64, resource, resourceID string) (*PermissionsLock, error) {
+	start := time.Now()
+	var lock *PermissionsLock
+	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		var result PermissionsLock
+		exists, err := sess.Where("org_id = ? AND resource = ? AND resource_id = ?", orgID, resource, resourceID).Get(&result)
+		if exists {
+			lock = &result
+		}
+		return err
+	})
+
+	s.metrics.observe(operationGetLock, s.dialect(), start, err)
+	return lock, err
+}
+
+func (s *store) SetResourceOwner(
+	ctx context.Context, orgID int64, owner accesscontrol.User,
+	cmd SetResourcePermissionCommand,
+	hook UserResourceHookFunc,
+) (*ResourceOwner, error) {
+	if owner.ID == 0 {
+		return nil, fmt.Errorf("%w: %w", ErrAssigneeNotFound, user.ErrUserNotFound)
+	}
+
+	start := time.Now()
+	cmd.grantedBy = grantor(ctx)
+	var previous *ResourceOwner
+	err := s.inTransaction(ctx, func(sess *db.Session) error {
+		previous = nil
+		var current ResourceOwner
+		exists, err := sess.SQL(
+			"SELECT * FROM resource_permission_owner WHERE org_id = ? AND resource = ? AND resource_id = ?"+s.forUpdate(),
+			orgID, cmd.Resource, cmd.ResourceID).Get(&current)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if exists {
+			prev := current
+			previous = &prev
+			// the permission of the previous owner is revoked in the same transaction as the new owner is granted
+			if current.UserID != owner.ID {
+				revoke := cmd
+				revoke.Actions = []string{}
+				revoke.Permission = ""
+				if _, _, err := s.setUserResourcePermission(sess, orgID, accesscontrol.User{ID: current.UserID}, revoke, hook); err != nil {
+					return err
+				}
+			}
+			current.UserID = owner.ID
+			current.Updated = now
+			_, err = sess.ID(current.ID).Cols("user_id", "updated").Update(&current)
+		} else {
+			_, err = sess.Insert(&ResourceOwner{
+				OrgID:      orgID,
+				Resource:   cmd.Resource,
+				ResourceID: cmd.ResourceID,
+				UserID:     owner.ID,
+				Created:    now,
+				Updated:    now,
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		_, _, err = s.setUserResourcePermission(sess, orgID, owner, cmd, hook)
+		return err
+	})
+
+	s.metrics.observe(operationSetOwner, s.dialect(), start, err)
+	return previous, err
+}
+
+func (s *store) GetResourceOwner(ctx context.Context, orgID int64, resource, resourceID string) (*ResourceOwner, error) {
+	start := time.Now()
+	var owner *ResourceOwner
+	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		var result ResourceOwner
+		exists, err := sess.Where("org_id = ? AND resource = ? AND resource_id = ?", orgID, resource, resourceID).Get(&result)
+		if exists {
+			owner = &result
+		}
+		return err
+	})
+
+	s.metrics.observe(operationGetOwner, s.dialect(), start, err)
+	return owner, err
+}
+
+// NormalizeResourceScopes rewrites the scopes of the permissions stored under aliases of resource to the scopes of
+// resource, in every organization. A permission whose role is granted the same action on the rewritten scope already
+// is deleted instead. It returns the number of permissions rewritten or deleted.
+func (s *store) NormalizeResourceScopes(ctx context.Context, resource string, aliases []string) (int64, error) {
+	start := time.Now()
+	var rows int64
+	err := s.inTransaction(ctx, func(sess *db.Session) error {
+		rows = 0
+		for _, alias := range aliases {
+			var permissions []accesscontrol.Permission
+			if err := sess.SQL("SELECT id, role_id, action, scope FROM permission WHERE scope LIKE ?"+s.forUpdate(), alias+":%").Find(&permissions); err != nil {
+				return err
+			}
+			if len(permissions) == 0 {
+				continue
+			}
+
+			roleIDs := make([]int64, 0, len(permissions))
+			for _, p := range permissions {
+				roleIDs = append(roleIDs, p.RoleID)
+			}
+			existing := make(map[accesscontrol.Permission]struct{}, len(permissions))
+			for _, chunk := range chunks(roleIDs, s.maxPlaceholders()-1) {
+				var stored []accesscontrol.Permission
+				rawSQL := "SELECT role_id, action, scope FROM permission WHERE scope LIKE ? AND role_id IN (?" + strings.Repeat(",?", len(chunk)-1) + ")"
+				if err := sess.SQL(rawSQL, append([]any{resource + ":%"}, int64Args(chunk)...)...).Find(&stored); err != nil {
+					return err
+				}
+				for _, p := range stored {
+					existing[accesscontrol.Permission{RoleID: p.RoleID, Action: p.Action, Scope: p.Scope}] = struct{}{}
+				}
+			}
+
+			var remove []int64
+			now := time.Now()
+			for _, p := range permissions {
+				normalized := accesscontrol.Permission{RoleID: p.RoleID, Action: p.Action, Scope: canonicalScope(resource, []string{alias}, p.Scope)}
+				if _, ok := existing[normalized]; ok {
+					remove = append(remove, p.ID)
+					continue
+				}
+				existing[normalized] = struct{}{}
+
+				if s.features.IsEnabledGlobally(featuremgmt.FlagSplitScopes) {
+					normalized.Kind, normalized.Attribute, normalized.Identifier = normalized.SplitScope()
+				}
+				if _, err := sess.Exec("UPDATE permission SET scope = ?, kind = ?, attribute = ?, identifier = ?, updated = ? WHERE id = ?",
+					normalized.Scope, normalized.Kind, normalized.Attribute, normalized.Identifier, now, p.ID); err != nil {
+					return err
+				}
+			}
+
+			for _, chunk := range chunks(remove, s.maxPlaceholders()) {
+				if err := deletePermissions(sess, chunk); err != nil {
+					return err
+				}
+			}
+			rows += int64(len(permissions))
+		}
+		return nil
+	})
+
+	s.metrics.observe(operationNormalize, s.dialect(), start, err)
+	if err == nil {
+		s.metrics.addRowsAffected(operationNormalize, s.dialect(), rows)
+	}
+	return rows, err
+}
+
+// GetUsageStats computes the usage statistics of the managed permissions on query.Resource with aggregate queries, the
+// number of assignments per resource is read as a histogram the percentile is computed from
+func (s *store) GetUsageStats(ctx context.Context, query UsageStatsQuery) (*UsageStats, error) {
+	start := time.Now()
+
+	// one row per managed role with a permission on a resource
+	managedFilter, assignmentsArgs := s.names.like("r.name", "")
+	assignmentsSQL := `
+		SELECT r.org_id, r.name, p.scope
+		FROM permission p
+			INNER JOIN role r ON r.id = p.role_id
+		WHERE ` + managedFilter + ` AND p.scope LIKE ?
+		GROUP BY r.org_id, r.name, p.scope`
+	assignmentsArgs = append(assignmentsArgs, accesscontrol.Scope(query.Resource, query.ResourceAttribute, "%"))
+
+	type bucket struct {
+		Assignments int64 `xorm:"assignments"`
+		Resources   int64 `xorm:"resources"`
+	}
+
+	stats := &UsageStats{Assignments: make(map[string]int64, len(query.AssignmentKinds))}
+	err := s.sql.WithDbSession(ctx, func(sess *db.Session) error {
+		for _, kind := range query.AssignmentKinds {
+			var count int64
+			kindFilter, kindArgs := s.names.like("a.name", managedRoleKind(kind)+":")
+			rawSQL := "SELECT COUNT(*) FROM (" + assignmentsSQL + ") a WHERE " + kindFilter
+			if _, err := sess.SQL(rawSQL, append(append([]any{}, assignmentsArgs...), kindArgs...)...).Get(&count); err != nil {
+				return err
+			}
+			stats.Assignments[kind] = count
+		}
+
+		var histogram []bucket
+		rawSQL := `
+			SELECT c.assignments, COUNT(*) AS resources
+			FROM (SELECT a.org_id, a.scope, COUNT(*) AS assignments FROM (` + assignmentsSQL + `) a GROUP BY a.org_id, a.scope) c
+			GROUP BY c.assignments
+			ORDER BY c.assignments`
+		if err := sess.SQL(rawSQL, assignmentsArgs...).Find(&histogram); err != nil {
+			return err
+		}
+
+		for _, b := range histogram {
+			stats.Resources += b.Resources
+		}
+		// nearest rank percentile, the smallest number of assignments of at least 95% of the resources
+		rank := (stats.Resources*95 + 99) / 100
+		var seen int64
+		for _, b := range histogram {
+			seen += b.Resources
+			if seen >= rank {
+				stats.AssignmentsPerResourceP95 = b.Assignments
+				break
+			}
+		}
+		return nil
+	})
+
+	s.metrics.observe(operationUsageStats, s.dialect(), start, err)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// managedRoleKind returns the kind of the assignees of kind in the names of their managed roles
+func managedRoleKind(kind string) string {
+	if kind == assignmentKindBuiltInRoles {
+		return "builtins"
+	}
+	return kind
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern with a backslash
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *store) resourcePermissionsFilter(opts ResourcePermissionsQueryOptions) (string, []any) {
+	filter := " WHERE 1 = 1"
+	var args []any
+
+	switch opts.Kind {
+	case AssigneeKindUser:
+		filter += " AND user_id <> 0"
+	case AssigneeKindTeam:
+		filter += " AND team_id <> 0"
+	case AssigneeKindBuiltInRole:
+		filter += " AND user_id = 0 AND team_id = 0"
+	}
+
+	if opts.Search != "" {
+		// LikeStr is ILIKE for postgres, mysql and sqlite compare case-insensitively with LIKE. The wildcards typed
+		// in the search match themselves, sqlite has no default escape character and mysql string literals need
+		// the backslash escaped
+		escape := `'\'`
+		if s.dialect() == migrator.MySQL {
+			escape = `'\\'`
+		}
+		like := " " + s.sql.GetDialect().LikeStr() + " ? ESCAPE " + escape
+		filter += " AND (user_login" + like + " OR user_email" + like + " OR team" + like + " OR team_email" + like + " OR built_in_role" + like + ")"
+		search := "%" + likeEscaper.Replace(opts.Search) + "%"
+		args = append(args, search, search, search, search, search)
+	}
+
+	return filter, args
+}
+
 func (s *store) getResourcePermissions(sess *db.Session, orgID int64, query GetResourcePermissionsQuery) ([]accesscontrol.ResourcePermission, error) {
 	if len(query.Actions) == 0 {
 		return nil, nil
 	}
 
+	sql, args, err := s.resourcePermissionsSQL(orgID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	queryResults := make([]flatResourcePermission, 0)
+	if err := sess.SQL(sql, args...).Find(&queryResults); err != nil {
+		return nil, err
+	}
+	queryResults = canonicalScopes(query.Resource, query.ResourceAliases, queryResults)
+
+	scope := accesscontrol.Scope(query.Resource, query.ResourceAttribute, query.ResourceID)
+	var result []accesscontrol.ResourcePermission
+	users, teams, builtins := groupPermissionsByAssignment(queryResults)
+	for _, p := range users {
+		result = append(result, flatPermissionsToResourcePermissions(s.names, scope, query.InheritedScopes, p)...)
+	}
+	for _, p := range teams {
+		result = append(result, flatPermissionsToResourcePermissions(s.names, scope, query.InheritedScopes, p)...)
+	}
+	for _, p := range builtins {
+		result = append(result, flatPermissionsToResourcePermissions(s.names, scope, query.InheritedScopes, p)...)
+	}
+
+	return result, nil
+}
+
+// anyActionFilter returns a condition on the permissions p restricting them to the roles granting at least one of
+// actions on the same scope. An EXISTS subquery is used so that roles granting several of the actions are not
+// returned several times, it is answered by the unique index on role_id, action and scope of the permission table.
+func anyActionFilter(actions []string) (string, []any) {
+	args := make([]any, 0, len(actions))
+	for _, a := range actions {
+		args = append(args, a)
+	}
+	return ` AND EXISTS (SELECT 1 FROM permission pa WHERE pa.role_id = p.role_id AND pa.scope = p.scope AND pa.action IN (?` +
+		strings.Repeat(",?", len(actions)-1) + `))`, args
+}
+
+// resourcePermissionsSQL returns a query selecting one row per action of every user, team and built-in role permission
+// matching query
+func (s *store) resourcePermissionsSQL(orgID int64, query GetResourcePermissionsQuery) (string, []any, error) {
 	rawSelect := `
 	SELECT
 		p.*,
@@ -339,50 +1490,49 @@ func (s *store) getResourcePermissions(sess *db.Session, orgID int64, query GetR
 		br.role AS built_in_role
 	`
 
+	// the lookup starts from the permissions on the resource scopes (IDX_permission_scope_action) and joins the
+	// roles granting them and their assignments by role id, instead of scanning every assignment of the organization
 	rawFrom := `
 	FROM permission p
 		INNER JOIN role r ON p.role_id = r.id
     `
 	userFrom := rawFrom + `
-		INNER JOIN user_role ur ON r.id = ur.role_id AND (ur.org_id = 0 OR ur.org_id = ?)
+		INNER JOIN user_role ur ON r.id = ur.role_id AND ur.org_id IN (0, ?)
 		INNER JOIN ` + s.sql.GetDialect().Quote("user") + ` u ON ur.user_id = u.id
 	`
 	teamFrom := rawFrom + `
-		INNER JOIN team_role tr ON r.id = tr.role_id AND (tr.org_id = 0 OR tr.org_id = ?)
+		INNER JOIN team_role tr ON r.id = tr.role_id AND tr.org_id IN (0, ?)
 		INNER JOIN team t ON tr.team_id = t.id
 	`
 
 	builtinFrom := rawFrom + `
-		INNER JOIN builtin_role br ON r.id = br.role_id AND (br.org_id = 0 OR br.org_id = ?)
+		INNER JOIN builtin_role br ON r.id = br.role_id AND br.org_id IN (0, ?)
 	`
 
-	where := `WHERE (r.org_id = ? OR r.org_id = 0) AND (p.scope = '*' OR p.scope = ? OR p.scope = ? OR p.scope = ?`
+	scopes := append(resourceScopes(query.Resource, query.ResourceAliases, query.ResourceAttribute, query.ResourceID), query.InheritedScopes...)
 
-	scope := accesscontrol.Scope(query.Resource, query.ResourceAttribute, query.ResourceID)
-
-	args := []any{
-		orgID,
-		orgID,
-		accesscontrol.Scope(query.Resource, "*"),
-		accesscontrol.Scope(query.Resource, query.ResourceAttribute, "*"),
-		scope,
+	where := `WHERE r.org_id IN (?, 0) AND p.scope IN (?` + strings.Repeat(",?", len(scopes)-1) + `)`
+	args := []any{orgID, orgID}
+	for _, scope := range scopes {
+		args = append(args, scope)
 	}
 
-	if len(query.InheritedScopes) > 0 {
-		where += ` OR p.scope IN(?` + strings.Repeat(",?", len(query.InheritedScopes)-1) + `)`
-		for _, scope := range query.InheritedScopes {
-			args = append(args, scope)
-		}
-	}
+	where += ` AND p.action IN (?` + strings.Repeat(",?", len(query.Actions)-1) + `)`
 
-	where += `) AND p.action IN (?` + strings.Repeat(",?", len(query.Actions)-1) + `)`
+	for _, a := range query.Actions {
+		args = append(args, a)
+	}
 
 	if query.OnlyManaged {
-		where += `AND r.name LIKE 'managed:%'`
+		managedFilter, managedArgs := s.names.like("r.name", "")
+		where += ` AND ` + managedFilter
+		args = append(args, managedArgs...)
 	}
 
-	for _, a := range query.Actions {
-		args = append(args, a)
+	if len(query.AnyAction) > 0 {
+		filter, filterArgs := anyActionFilter(query.AnyAction)
+		where += filter
+		args = append(args, filterArgs...)
 	}
 
 	initialLength := len(args)
@@ -390,14 +1540,14 @@ func (s *store) getResourcePermissions(sess *db.Session, orgID int64, query GetR
 	if query.EnforceAccessControl {
 		userFilter, err := accesscontrol.Filter(query.User, "u.id", "users:id:", accesscontrol.ActionOrgUsersRead)
 		if err != nil {
-			return nil, err
+			return "", nil, err
 		}
 
 		filter := "((" + userFilter.Where + " AND NOT u.is_service_account)"
 
 		saFilter, err := accesscontrol.Filter(query.User, "u.id", "serviceaccounts:id:", serviceaccounts.ActionRead)
 		if err != nil {
-			return nil, err
+			return "", nil, err
 		}
 
 		filter += " OR (" + saFilter.Where + " AND u.is_service_account))"
@@ -409,7 +1559,7 @@ func (s *store) getResourcePermissions(sess *db.Session, orgID int64, query GetR
 
 	teamFilter, err := accesscontrol.Filter(query.User, "t.id", "teams:id:", accesscontrol.ActionTeamsRead)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
 	team := teamSelect + teamFrom + where + " AND " + teamFilter.Where
@@ -419,25 +1569,68 @@ func (s *store) getResourcePermissions(sess *db.Session, orgID int64, query GetR
 	builtin := builtinSelect + builtinFrom + where
 	args = append(args, args[:initialLength]...)
 
-	sql := userQuery + " UNION " + team + " UNION " + builtin
-	queryResults := make([]flatResourcePermission, 0)
-	if err := sess.SQL(sql, args...).Find(&queryResults); err != nil {
-		return nil, err
+	return userQuery + " UNION " + team + " UNION " + builtin, args, nil
+}
+
+// resourceScopes returns the scopes granting access to a resource: the wildcard scopes and the scope of the resource
+// under its name and under each of its aliases
+func resourceScopes(resource string, aliases []string, resourceAttribute, resourceID string) []string {
+	scopes := make([]string, 0, 1+3*(1+len(aliases)))
+	scopes = append(scopes, "*")
+	for _, name := range append([]string{resource}, aliases...) {
+		scopes = append(scopes,
+			accesscontrol.Scope(name, "*"),
+			accesscontrol.Scope(name, resourceAttribute, "*"),
+			accesscontrol.Scope(name, resourceAttribute, resourceID),
+		)
 	}
+	return scopes
+}
 
-	var result []accesscontrol.ResourcePermission
-	users, teams, builtins := groupPermissionsByAssignment(queryResults)
-	for _, p := range users {
-		result = append(result, flatPermissionsToResourcePermissions(scope, p)...)
+// aliasScopes returns the scopes of a resource under each of its aliases
+func aliasScopes(aliases []string, resourceAttribute, resourceID string) []string {
+	scopes := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		scopes = append(scopes, accesscontrol.Scope(alias, resourceAttribute, resourceID))
 	}
-	for _, p := range teams {
-		result = append(result, flatPermissionsToResourcePermissions(scope, p)...)
+	return scopes
+}
+
+// canonicalScope returns scope with the prefix of an alias replaced by resource, scope is returned unchanged when
+// it has no alias prefix
+func canonicalScope(resource string, aliases []string, scope string) string {
+	for _, alias := range aliases {
+		if strings.HasPrefix(scope, alias+":") {
+			return resource + strings.TrimPrefix(scope, alias)
+		}
 	}
-	for _, p := range builtins {
-		result = append(result, flatPermissionsToResourcePermissions(scope, p)...)
+	return scope
+}
+
+// canonicalScopes rewrites the scopes of the permissions stored under an alias of resource. A permission granting
+// an assignee an action it is granted on the rewritten scope already is dropped.
+func canonicalScopes(resource string, aliases []string, permissions []flatResourcePermission) []flatResourcePermission {
+	if len(aliases) == 0 {
+		return permissions
 	}
 
-	return result, nil
+	type key struct {
+		roleName, builtInRole, action, scope string
+		userID, teamID                       int64
+	}
+	for i := range permissions {
+		permissions[i].Scope = canonicalScope(resource, aliases, permissions[i].Scope)
+	}
+	seen := make(map[key]struct{}, len(permissions))
+	result := make([]flatResourcePermission, 0, len(permissions))
+	for _, p := range permissions {
+		k := key{p.RoleName, p.BuiltInRole, p.Action, p.Scope, p.UserId, p.TeamId}
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			result = append(result, p)
+		}
+	}
+	return result
 }
 
 func groupPermissionsByAssignment(permissions []flatResourcePermission) (map[int64][]flatResourcePermission, map[int64][]flatResourcePermission, map[string][]flatResourcePermission) {
@@ -458,33 +1651,44 @@ func groupPermissionsByAssignment(permissions []flatResourcePermission) (map[int
 	return users, teams, builtins
 }
 
-func flatPermissionsToResourcePermissions(scope string, permissions []flatResourcePermission) []accesscontrol.ResourcePermission {
-	var managed, inherited, provisioned []flatResourcePermission
+// flatPermissionsToResourcePermissions groups the permissions of a single assignee into managed, inherited and provisioned permissions.
+// Inherited permissions are grouped by the ancestor they originate from, ordered like inheritedScopes (nearest ancestor first).
+func flatPermissionsToResourcePermissions(names managedRoleNames, scope string, inheritedScopes []string, permissions []flatResourcePermission) []accesscontrol.ResourcePermission {
+	var managed, provisioned []flatResourcePermission
+	inherited := make(map[string][]flatResourcePermission)
 	for _, p := range permissions {
-		if p.IsManaged(scope) {
+		if p.IsManaged(names, scope) {
 			managed = append(managed, p)
-		} else if p.IsInherited(scope) {
-			inherited = append(inherited, p)
+		} else if p.IsInherited(names, scope) {
+			inherited[p.Scope] = append(inherited[p.Scope], p)
 		} else {
 			provisioned = append(provisioned, p)
 		}
 	}
 
 	var result []accesscontrol.ResourcePermission
-	if g := flatPermissionsToResourcePermission(scope, managed); g != nil {
+	if g := flatPermissionsToResourcePermission(names, scope, managed); g != nil {
 		result = append(result, *g)
 	}
-	if g := flatPermissionsToResourcePermission(scope, inherited); g != nil {
-		result = append(result, *g)
+	for _, inheritedScope := range inheritedScopes {
+		if g := flatPermissionsToResourcePermission(names, scope, inherited[inheritedScope]); g != nil {
+			result = append(result, *g)
+		}
+		delete(inherited, inheritedScope)
 	}
-	if g := flatPermissionsToResourcePermission(scope, provisioned); g != nil {
+	for _, p := range inherited {
+		if g := flatPermissionsToResourcePermission(names, scope, p); g != nil {
+			result = append(result, *g)
+		}
+	}
+	if g := flatPermissionsToResourcePermission(names, scope, provisioned); g != nil {
 		result = append(result, *g)
 	}
 
 	return result
 }
 
-func flatPermissionsToResourcePermission(scope string, permissions []flatResourcePermission) *accesscontrol.ResourcePermission {
+func flatPermissionsToResourcePermission(names managedRoleNames, scope string, permissions []flatResourcePermission) *accesscontrol.ResourcePermission {
 	if len(permissions) == 0 {
 		return nil
 	}
@@ -509,9 +1713,11 @@ func flatPermissionsToResourcePermission(scope string, permissions []flatResourc
 		BuiltInRole:      first.BuiltInRole,
 		Created:          first.Created,
 		Updated:          first.Updated,
-		IsManaged:        first.IsManaged(scope),
-		IsInherited:      first.IsInherited(scope),
+		IsManaged:        first.IsManaged(names, scope),
+		IsInherited:      first.IsInherited(names, scope),
 		IsServiceAccount: first.IsServiceAccount,
+		GrantedBy:        first.GrantedBy,
+		Reason:           first.Reason,
 	}
 }
 
@@ -577,11 +1783,19 @@ func (s *store) builtInRoleAdder(sess *db.Session, orgID int64, builtinRole stri
 }
 
 func (s *store) getOrCreateManagedRole(sess *db.Session, orgID int64, name string, add roleAdder) (*accesscontrol.Role, error) {
-	role := accesscontrol.Role{OrgID: orgID, Name: name}
-	has, err := sess.Where("org_id = ? AND name = ?", orgID, name).Get(&role)
+	var role accesscontrol.Role
+	var has bool
+	var err error
+	// a role named with a previous prefix is updated in place until it is renamed
+	for _, n := range s.names.withPrevious(name) {
+		role = accesscontrol.Role{OrgID: orgID, Name: n}
+		if has, err = sess.Where("org_id = ? AND name = ?", orgID, n).Get(&role); has || err != nil {
+			break
+		}
+	}
 
 	// If managed role does not exist, create it and add it to user/team/builtin
-	if !has {
+	if !has && err == nil {
 		uid, err := generateNewRoleUID(sess, orgID)
 		if err != nil {
 			return nil, err
@@ -657,28 +1871,77 @@ func (s *store) getPermissions(sess *db.Session, resource, resourceID, resourceA
 	return result, nil
 }
 
-func (s *store) createPermissions(sess *db.Session, roleID int64, resource, resourceID, resourceAttribute string, actions map[string]struct{}) error {
-	if len(actions) == 0 {
-		return nil
-	}
-	permissions := make([]accesscontrol.Permission, 0, len(actions))
+// createPermissions upserts the permissions of a managed role keyed on (role_id, action, scope), so a permission
+// inserted by a concurrent write is updated instead of failing on the unique index
+func (s *store) createPermissions(sess *db.Session, roleID int64, resource, resourceID, resourceAttribute string, actions map[string]struct{}, grantedBy int64, reason string) error {
+	permissions := make([]grantedPermission, 0, len(actions))
 	for action := range actions {
 		p := managedPermission(action, resource, resourceID, resourceAttribute)
 		p.RoleID = roleID
-		p.Created = time.Now()
-		p.Updated = time.Now()
-		if s.features.IsEnabledGlobally(featuremgmt.FlagSplitScopes) {
-			p.Kind, p.Attribute, p.Identifier = p.SplitScope()
-		}
-		permissions = append(permissions, p)
+		permissions = append(permissions, grantedPermission{Permission: p, grantedBy: grantedBy, reason: reason})
 	}
+	return s.upsertPermissions(sess, permissions)
+}
 
-	if _, err := sess.InsertMulti(&permissions); err != nil {
-		return err
+// grantedPermission is a permission written together with its grant metadata
+type grantedPermission struct {
+	accesscontrol.Permission
+	grantedBy int64
+	reason    string
+}
+
+var (
+	// upsertPermissionColumns are the columns written when a permission is inserted
+	upsertPermissionColumns = []string{"role_id", "action", "scope", "kind", "attribute", "identifier", "granted_by", "reason", "created", "updated"}
+	// upsertPermissionUpdateColumns are the columns written when the permission exists already, it keeps its created time
+	upsertPermissionUpdateColumns = []string{"kind", "attribute", "identifier", "granted_by", "reason", "updated"}
+)
+
+// upsertPermissions upserts permissions keyed on (role_id, action, scope) with multi-row statements, each holding
+// at most as many rows as the placeholder limit of the dialect allows
+func (s *store) upsertPermissions(sess *db.Session, permissions []grantedPermission) error {
+	now := time.Now()
+	for _, chunk := range chunks(permissions, s.maxPlaceholders()/len(upsertPermissionColumns)) {
+		args := make([]any, 0, 1+len(upsertPermissionColumns)*len(chunk))
+		args = append(args, s.upsertPermissionsSQL(len(chunk)))
+		for _, p := range chunk {
+			if s.features.IsEnabledGlobally(featuremgmt.FlagSplitScopes) {
+				p.Kind, p.Attribute, p.Identifier = p.SplitScope()
+			}
+			args = append(args, p.RoleID, p.Action, p.Scope, p.Kind, p.Attribute, p.Identifier, p.grantedBy, p.reason, now, now)
+		}
+
+		if _, err := sess.Exec(args...); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// upsertPermissionsSQL returns the statement upserting count permissions. The upsert statements of the dialects
+// update the columns they insert, which would reset the created time of a permission granted again
+func (s *store) upsertPermissionsSQL(count int) string {
+	dialect := s.sql.GetDialect()
+	columns := make([]string, 0, len(upsertPermissionColumns))
+	for _, c := range upsertPermissionColumns {
+		columns = append(columns, dialect.Quote(c))
+	}
+	row := "(?" + strings.Repeat(", ?", len(columns)-1) + ")"
+	rawSQL := "INSERT INTO permission (" + strings.Join(columns, ", ") + ") VALUES " + row + strings.Repeat(", "+row, count-1)
+
+	set := make([]string, 0, len(upsertPermissionUpdateColumns))
+	if dialect.DriverName() == migrator.MySQL {
+		for _, c := range upsertPermissionUpdateColumns {
+			set = append(set, dialect.Quote(c)+" = VALUES("+dialect.Quote(c)+")")
+		}
+		return rawSQL + " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+	}
+	for _, c := range upsertPermissionUpdateColumns {
+		set = append(set, dialect.Quote(c)+" = excluded."+dialect.Quote(c))
+	}
+	return rawSQL + " ON CONFLICT (role_id, action, scope) DO UPDATE SET " + strings.Join(set, ", ")
+}
+
 func deletePermissions(sess *db.Session, ids []int64) error {
 	if len(ids) == 0 {
 		return nil
@@ -49,6 +49,17 @@ func BenchmarkDSPermissions1000_100(b *testing.B) { benchmarkDSPermissions(b, 10
 
 func BenchmarkDSPermissions1000_1000(b *testing.B) { benchmarkDSPermissions(b, 1000, 1000) }
 
+// BenchmarkDSPermissionsLargeResource reads a single data source with 5000 user and 500 team permissions
+func BenchmarkDSPermissionsLargeResource(b *testing.B) {
+	ac, sql := setupTestEnv(b)
+	dataSources := GenerateDatasourcePermissions(b, sql, ac, 1, 5000, 5000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		getDSPermissions(b, ac, dataSources)
+	}
+}
+
 func benchmarkDSPermissions(b *testing.B, dsNum, usersNum int) {
 	ac, dataSources := setupResourceBenchmark(b, dsNum, usersNum)
 	// We don't want to measure DB initialization
@@ -176,3 +187,143 @@ func generateTeamsAndUsers(b *testing.B, db *sqlstore.SQLStore, users int) ([]in
 
 	return userIds, teamIds
 }
+
+// BenchmarkStore_SetResourcePermissions100 changes the level of 100 user permissions on a data source in a single
+// write, every iteration removes the actions of the previous level and adds the ones of the new level
+func BenchmarkStore_SetResourcePermissions100(b *testing.B) {
+	ac, sql := setupTestEnv(b)
+	userIds, _ := generateTeamsAndUsers(b, sql, 100)
+
+	levels := [][]string{
+		{"datasources:query", "datasources:read"},
+		{"datasources:query", "datasources:read", "datasources:write", "datasources:delete"},
+	}
+
+	commands := func(actions []string) []SetResourcePermissionsCommand {
+		cmds := make([]SetResourcePermissionsCommand, 0, len(userIds))
+		for _, id := range userIds {
+			cmds = append(cmds, SetResourcePermissionsCommand{
+				User: accesscontrol.User{ID: id},
+				SetResourcePermissionCommand: SetResourcePermissionCommand{
+					Actions:           actions,
+					Resource:          dsResource,
+					ResourceID:        "1",
+					ResourceAttribute: "id",
+				},
+			})
+		}
+		return cmds
+	}
+	cmds := [][]SetResourcePermissionsCommand{commands(levels[0]), commands(levels[1])}
+
+	_, err := ac.SetResourcePermissions(context.Background(), accesscontrol.GlobalOrgID, cmds[0], ResourceHooks{})
+	require.NoError(b, err)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := ac.SetResourcePermissions(context.Background(), accesscontrol.GlobalOrgID, cmds[(i+1)%2], ResourceHooks{})
+		require.NoError(b, err)
+	}
+}
+
+// BenchmarkStore_GetResourcePermissionsAnyAction compares filtering the permissions of a data source shared with 1000
+// users, 100 of which can write it, by action in the store with fetching every permission and filtering them in Go
+func BenchmarkStore_GetResourcePermissionsAnyAction(b *testing.B) {
+	ac, sql := setupTestEnv(b)
+	userIds, _ := generateTeamsAndUsers(b, sql, 1000)
+
+	cmds := make([]SetResourcePermissionsCommand, 0, len(userIds))
+	for i, id := range userIds {
+		actions := []string{"datasources:query", "datasources:read"}
+		if i%10 == 0 {
+			actions = append(actions, "datasources:write")
+		}
+		cmds = append(cmds, SetResourcePermissionsCommand{
+			User: accesscontrol.User{ID: id},
+			SetResourcePermissionCommand: SetResourcePermissionCommand{
+				Actions:           actions,
+				Resource:          dsResource,
+				ResourceID:        "1",
+				ResourceAttribute: "id",
+			},
+		})
+	}
+	_, err := ac.SetResourcePermissions(context.Background(), accesscontrol.GlobalOrgID, cmds, ResourceHooks{})
+	require.NoError(b, err)
+
+	query := GetResourcePermissionsQuery{
+		User:              &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {"org.users:read": {"users:*"}, "teams:read": {"teams:*"}}}},
+		Actions:           []string{"datasources:query", "datasources:read", "datasources:write"},
+		Resource:          dsResource,
+		ResourceID:        "1",
+		ResourceAttribute: "id",
+	}
+
+	b.Run("store", func(b *testing.B) {
+		query := query
+		query.AnyAction = []string{"datasources:write"}
+		for i := 0; i < b.N; i++ {
+			permissions, err := ac.GetResourcePermissions(context.Background(), accesscontrol.GlobalOrgID, query)
+			require.NoError(b, err)
+			require.Len(b, permissions, 100)
+		}
+	})
+
+	b.Run("memory", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			permissions, err := ac.GetResourcePermissions(context.Background(), accesscontrol.GlobalOrgID, query)
+			require.NoError(b, err)
+			filtered := make([]accesscontrol.ResourcePermission, 0, len(permissions))
+			for _, p := range permissions {
+				for _, a := range p.Actions {
+					if a == "datasources:write" {
+						filtered = append(filtered, p)
+						break
+					}
+				}
+			}
+			require.Len(b, filtered, 100)
+		}
+	})
+}
+
+// BenchmarkStore_SetResourcePermissions1000 sets the permissions of 1000 users on a data source at once and one
+// by one
+func BenchmarkStore_SetResourcePermissions1000(b *testing.B) {
+	commands := make([]SetResourcePermissionsCommand, 0, 1000)
+	for i := 1; i <= 1000; i++ {
+		commands = append(commands, SetResourcePermissionsCommand{
+			User: accesscontrol.User{ID: int64(i)},
+			SetResourcePermissionCommand: SetResourcePermissionCommand{
+				Actions:           []string{dsAction, "datasources:write"},
+				Resource:          dsResource,
+				ResourceID:        "1",
+				ResourceAttribute: "uid",
+			},
+		})
+	}
+
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			ac, _ := setupTestEnv(b)
+			b.StartTimer()
+
+			_, err := ac.SetResourcePermissions(context.Background(), 1, commands, ResourceHooks{})
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("per_entry", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			ac, _ := setupTestEnv(b)
+			b.StartTimer()
+
+			for _, cmd := range commands {
+				_, err := ac.SetUserResourcePermission(context.Background(), 1, cmd.User, cmd.SetResourcePermissionCommand, nil)
+				require.NoError(b, err)
+			}
+		}
+	})
+}
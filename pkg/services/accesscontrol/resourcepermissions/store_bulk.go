@@ -0,0 +1,529 @@
+package resourcepermissions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// roleColumns is the number of columns written when inserting a role
+const roleColumns = 10
+
+// bulkEntry is a command of SetResourcePermissions resolved to the managed role of its assignee
+type bulkEntry struct {
+	cmd      SetResourcePermissionsCommand
+	roleName string
+	scope    string
+}
+
+// roleScope identifies the managed permission of a role on a resource
+type roleScope struct {
+	roleID int64
+	scope  string
+}
+
+// setResourcePermissions writes the permissions of commands with a bounded number of statements: the managed roles
+// are read and created, the current permissions read, then removed and added, with multi-row statements. Commands
+// are applied in order, so when several commands target the same assignee and resource the last one is stored and
+// each of them reports its outcome relative to the previous one. The returned permissions are the stored ones.
+// Commands for invalid built-in roles are skipped.
+func (s *store) setResourcePermissions(
+	sess *db.Session, orgID int64,
+	commands []SetResourcePermissionsCommand,
+	hooks ResourceHooks,
+) ([]SetResourcePermissionsResult, int64, error) {
+	entries := make([]bulkEntry, 0, len(commands))
+	for _, cmd := range commands {
+		var roleName string
+		if cmd.User.ID != 0 {
+			roleName = s.names.user(cmd.User.ID)
+		} else if cmd.TeamID != 0 {
+			roleName = s.names.team(cmd.TeamID)
+		} else if org.RoleType(cmd.BuiltinRole).IsValid() || cmd.BuiltinRole == accesscontrol.RoleGrafanaAdmin {
+			roleName = s.names.builtInRole(cmd.BuiltinRole)
+		} else {
+			continue
+		}
+		entries = append(entries, bulkEntry{
+			cmd:      cmd,
+			roleName: roleName,
+			scope:    accesscontrol.Scope(cmd.Resource, cmd.ResourceAttribute, cmd.ResourceID),
+		})
+	}
+	if len(entries) == 0 {
+		return nil, 0, nil
+	}
+
+	roleIDs, err := s.getOrCreateManagedRoles(sess, orgID, entries)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the permissions are locked in role order, like concurrent writers of the same roles do
+	ids := make([]int64, 0, len(roleIDs))
+	for _, id := range roleIDs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// the permissions stored with the scopes of aliases of the resources are read as well to be replaced, canonical
+	// maps every scope read to the scope of its resource
+	var scopes, lockScopes []string
+	canonical := map[string]string{}
+	for _, e := range entries {
+		if _, ok := canonical[e.scope]; !ok {
+			canonical[e.scope] = e.scope
+			scopes = append(scopes, e.scope)
+			lockScopes = append(lockScopes, e.scope)
+		}
+		for _, alias := range aliasScopes(e.cmd.ResourceAliases, e.cmd.ResourceAttribute, e.cmd.ResourceID) {
+			if _, ok := canonical[alias]; !ok {
+				canonical[alias] = e.scope
+				lockScopes = append(lockScopes, alias)
+			}
+		}
+	}
+
+	current := make(map[roleScope][]storedPermission, len(entries))
+	for _, scopeChunk := range chunks(lockScopes, s.maxPlaceholders()/2) {
+		for _, idChunk := range chunks(ids, s.maxPlaceholders()-len(scopeChunk)) {
+			var permissions []storedPermission
+			rawSQL := "SELECT id, role_id, action, scope, reason FROM permission WHERE role_id IN (?" + strings.Repeat(",?", len(idChunk)-1) + ")" +
+				" AND scope IN (?" + strings.Repeat(",?", len(scopeChunk)-1) + ")" + s.forUpdate()
+			if err := sess.SQL(rawSQL, append(int64Args(idChunk), stringArgs(scopeChunk)...)...).Find(&permissions); err != nil {
+				return nil, 0, err
+			}
+			for _, p := range permissions {
+				key := roleScope{p.RoleID, canonical[p.Scope]}
+				current[key] = append(current[key], p)
+			}
+		}
+	}
+
+	// the commands are applied in memory, in order, then only the difference with the stored permissions is written
+	state := make(map[roleScope][]string, len(entries))
+	var keys []roleScope
+	for _, e := range entries {
+		key := roleScope{roleIDs[e.roleName], e.scope}
+		if _, ok := state[key]; !ok {
+			keys = append(keys, key)
+			state[key] = make([]string, 0, len(current[key]))
+			for _, p := range current[key] {
+				state[key] = append(state[key], p.Action)
+			}
+		}
+	}
+	// the reason of a permission is the stored one until a command replaces or clears it
+	reasons := make(map[roleScope]any, len(keys))
+	for _, key := range keys {
+		reason := ""
+		for _, p := range current[key] {
+			if p.Reason != "" {
+				reason = p.Reason
+			}
+		}
+		reasons[key] = reason
+	}
+	outcomes := make([]PermissionOutcome, len(entries))
+	for i, e := range entries {
+		key := roleScope{roleIDs[e.roleName], e.scope}
+		outcomes[i] = PermissionOutcomeOf(state[key], e.cmd.Actions)
+		state[key] = dedupeActions(e.cmd.Actions)
+		if e.cmd.Reason != "" || !e.cmd.KeepReason {
+			reasons[key] = e.cmd.Reason
+		}
+	}
+
+	// the grantor of a changed permission is the one of the last command setting it
+	grantors := make(map[roleScope]any, len(keys))
+	for _, e := range entries {
+		grantors[roleScope{roleIDs[e.roleName], e.scope}] = e.cmd.grantedBy
+	}
+
+	var remove []int64
+	var create []grantedPermission
+	changed := make(map[roleScope]any, len(keys))
+	for _, key := range keys {
+		removed, created := len(remove), len(create)
+		wanted := make(map[string]struct{}, len(state[key]))
+		for _, a := range state[key] {
+			wanted[a] = struct{}{}
+		}
+		for _, p := range current[key] {
+			if _, ok := wanted[p.Action]; ok && p.Scope == key.scope {
+				delete(wanted, p.Action)
+			} else {
+				remove = append(remove, p.ID)
+			}
+		}
+		for _, a := range state[key] {
+			if _, ok := wanted[a]; ok {
+				grantedBy, _ := grantors[key].(int64)
+				reason, _ := reasons[key].(string)
+				create = append(create, grantedPermission{
+					Permission: accesscontrol.Permission{RoleID: key.roleID, Action: a, Scope: key.scope},
+					grantedBy:  grantedBy,
+					reason:     reason,
+				})
+			}
+		}
+		if len(state[key]) == 0 {
+			delete(reasons, key)
+		} else if len(remove) > removed || len(create) > created {
+			changed[key] = grantors[key]
+		}
+	}
+
+	for _, chunk := range chunks(remove, s.maxPlaceholders()) {
+		if err := deletePermissions(sess, chunk); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := s.upsertPermissions(sess, create); err != nil {
+		return nil, 0, err
+	}
+	if err := s.setPermissionColumn(sess, "granted_by", changed); err != nil {
+		return nil, 0, err
+	}
+	if err := s.setPermissionColumn(sess, "reason", reasons); err != nil {
+		return nil, 0, err
+	}
+
+	stored := make(map[string][]flatResourcePermission, len(keys))
+	for _, scopeChunk := range chunks(scopes, s.maxPlaceholders()/2) {
+		for _, idChunk := range chunks(ids, s.maxPlaceholders()-len(scopeChunk)) {
+			permissions, err := s.getRolePermissions(sess, idChunk, scopeChunk)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, p := range permissions {
+				stored[p.RoleName+" "+p.Scope] = append(stored[p.RoleName+" "+p.Scope], p)
+			}
+		}
+	}
+
+	results := make([]SetResourcePermissionsResult, 0, len(entries))
+	for i, e := range entries {
+		result := SetResourcePermissionsResult{Outcome: outcomes[i]}
+		if p := flatPermissionsToResourcePermission(s.names, e.scope, stored[e.roleName+" "+e.scope]); p != nil {
+			result.Permission = *p
+		}
+		results = append(results, result)
+
+		var err error
+		if e.cmd.User.ID != 0 {
+			if hooks.User != nil {
+				err = hooks.User(sess, orgID, e.cmd.User, e.cmd.ResourceID, e.cmd.Permission)
+			}
+		} else if e.cmd.TeamID != 0 {
+			if hooks.Team != nil {
+				err = hooks.Team(sess, orgID, e.cmd.TeamID, e.cmd.ResourceID, e.cmd.Permission)
+			}
+		} else if hooks.BuiltInRole != nil {
+			err = hooks.BuiltInRole(sess, orgID, e.cmd.BuiltinRole, e.cmd.ResourceID, e.cmd.Permission)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return results, int64(len(remove) + len(create)), nil
+}
+
+// getOrCreateManagedRoles returns the ids of the managed roles of entries by name, the existing roles are locked and
+// the missing ones are created and granted to their assignee
+func (s *store) getOrCreateManagedRoles(sess *db.Session, orgID int64, entries []bulkEntry) (map[string]int64, error) {
+	var names []string
+	assignees := make(map[string]SetResourcePermissionsCommand, len(entries))
+	for _, e := range entries {
+		if _, ok := assignees[e.roleName]; !ok {
+			assignees[e.roleName] = e.cmd
+			names = append(names, e.roleName)
+		}
+	}
+
+	roleIDs, err := s.getRoleIDs(sess, orgID, names, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.getPreviousRoleIDs(sess, orgID, names, roleIDs); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, name := range names {
+		if _, ok := roleIDs[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return roleIDs, nil
+	}
+
+	uids, err := generateNewRoleUIDs(sess, orgID, len(missing), s.maxPlaceholders()-1)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	roles := make([]accesscontrol.Role, 0, len(missing))
+	for i, name := range missing {
+		roles = append(roles, accesscontrol.Role{OrgID: orgID, Name: name, UID: uids[i], Created: now, Updated: now})
+	}
+	for _, chunk := range chunks(roles, s.maxPlaceholders()/roleColumns) {
+		if _, err := sess.InsertMulti(&chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	// multi-row inserts do not return the ids of the rows
+	created, err := s.getRoleIDs(sess, orgID, missing, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var userRoles []accesscontrol.UserRole
+	var teamRoles []accesscontrol.TeamRole
+	var builtInRoles []accesscontrol.BuiltinRole
+	for _, name := range missing {
+		roleID, ok := created[name]
+		if !ok {
+			return nil, fmt.Errorf("managed role %s was not created", name)
+		}
+		roleIDs[name] = roleID
+
+		cmd := assignees[name]
+		switch {
+		case cmd.User.ID != 0:
+			userRoles = append(userRoles, accesscontrol.UserRole{OrgID: orgID, UserID: cmd.User.ID, RoleID: roleID, Created: now})
+		case cmd.TeamID != 0:
+			teamRoles = append(teamRoles, accesscontrol.TeamRole{OrgID: orgID, TeamID: cmd.TeamID, RoleID: roleID, Created: now})
+		default:
+			builtInRoles = append(builtInRoles, accesscontrol.BuiltinRole{OrgID: orgID, Role: cmd.BuiltinRole, RoleID: roleID, Created: now, Updated: now})
+		}
+	}
+
+	// user_role and team_role have 4 columns written, builtin_role 5
+	for _, chunk := range chunks(userRoles, s.maxPlaceholders()/4) {
+		if _, err := sess.InsertMulti(&chunk); err != nil {
+			return nil, err
+		}
+	}
+	for _, chunk := range chunks(teamRoles, s.maxPlaceholders()/4) {
+		if _, err := sess.InsertMulti(&chunk); err != nil {
+			return nil, err
+		}
+	}
+	for _, chunk := range chunks(builtInRoles, s.maxPlaceholders()/5) {
+		if _, err := sess.Table("builtin_role").InsertMulti(&chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	return roleIDs, nil
+}
+
+// getPreviousRoleIDs adds to roleIDs the ids of the roles of names missing from it that are named with a previous
+// prefix, such a role is updated in place until it is renamed
+func (s *store) getPreviousRoleIDs(sess *db.Session, orgID int64, names []string, roleIDs map[string]int64) error {
+	if len(s.names.previous) == 0 {
+		return nil
+	}
+
+	renamed := make(map[string]string)
+	var previous []string
+	for _, name := range names {
+		if _, ok := roleIDs[name]; ok {
+			continue
+		}
+		for _, p := range s.names.withPrevious(name)[1:] {
+			renamed[p] = name
+			previous = append(previous, p)
+		}
+	}
+	if len(previous) == 0 {
+		return nil
+	}
+
+	found, err := s.getRoleIDs(sess, orgID, previous, true)
+	if err != nil {
+		return err
+	}
+	// the previous names are ordered like the previous prefixes, the first one found wins
+	for _, p := range previous {
+		if id, ok := found[p]; ok {
+			if _, ok := roleIDs[renamed[p]]; !ok {
+				roleIDs[renamed[p]] = id
+			}
+		}
+	}
+	return nil
+}
+
+// getRoleIDs returns the ids of the roles of an organization by name, locking them when forUpdate is set
+func (s *store) getRoleIDs(sess *db.Session, orgID int64, names []string, forUpdate bool) (map[string]int64, error) {
+	lock := ""
+	if forUpdate {
+		lock = s.forUpdate()
+	}
+
+	roleIDs := make(map[string]int64, len(names))
+	for _, chunk := range chunks(names, s.maxPlaceholders()-1) {
+		var roles []accesscontrol.Role
+		rawSQL := "SELECT id, name FROM role WHERE org_id = ? AND name IN (?" + strings.Repeat(",?", len(chunk)-1) + ")" + lock
+		if err := sess.SQL(rawSQL, append([]any{orgID}, stringArgs(chunk)...)...).Find(&roles); err != nil {
+			return nil, err
+		}
+		for _, r := range roles {
+			roleIDs[r.Name] = r.ID
+		}
+	}
+	return roleIDs, nil
+}
+
+// getRolePermissions returns the permissions of roles on scopes like getPermissions does for a single role
+func (s *store) getRolePermissions(sess *db.Session, roleIDs []int64, scopes []string) ([]flatResourcePermission, error) {
+	var result []flatResourcePermission
+	rawSQL := `
+	SELECT
+		p.*,
+		ur.user_id AS user_id,
+		u.login AS user_login,
+		u.email AS user_email,
+		tr.team_id AS team_id,
+		t.name AS team,
+		t.email AS team_email,
+		r.name as role_name,
+		br.role AS built_in_role
+	FROM permission p
+		INNER JOIN role r ON p.role_id = r.id
+		LEFT JOIN team_role tr ON r.id = tr.role_id
+		LEFT JOIN team t ON tr.team_id = t.id
+		LEFT JOIN user_role ur ON r.id = ur.role_id
+		LEFT JOIN ` + s.sql.GetDialect().Quote("user") + ` u ON ur.user_id = u.id
+		LEFT JOIN builtin_role br ON r.id = br.role_id
+	WHERE r.id IN (?` + strings.Repeat(",?", len(roleIDs)-1) + `) AND p.scope IN (?` + strings.Repeat(",?", len(scopes)-1) + `)
+	ORDER BY p.id
+	`
+	if err := sess.SQL(rawSQL, append(int64Args(roleIDs), stringArgs(scopes)...)...).Find(&result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// generateNewRoleUIDs returns n role uids unused in the organization, checking them batchSize at a time
+func generateNewRoleUIDs(sess *db.Session, orgID int64, n, batchSize int) ([]string, error) {
+	uids := make([]string, 0, n)
+	for attempt := 0; attempt < 3 && len(uids) < n; attempt++ {
+		candidates := make([]string, 0, n-len(uids))
+		for len(candidates) < cap(candidates) {
+			candidates = append(candidates, util.GenerateShortUID())
+		}
+
+		taken := map[string]struct{}{}
+		for _, chunk := range chunks(candidates, batchSize) {
+			var existing []string
+			rawSQL := "SELECT uid FROM role WHERE org_id = ? AND uid IN (?" + strings.Repeat(",?", len(chunk)-1) + ")"
+			if err := sess.SQL(rawSQL, append([]any{orgID}, stringArgs(chunk)...)...).Find(&existing); err != nil {
+				return nil, err
+			}
+			for _, uid := range existing {
+				taken[uid] = struct{}{}
+			}
+		}
+
+		for _, uid := range candidates {
+			if _, ok := taken[uid]; !ok {
+				taken[uid] = struct{}{}
+				uids = append(uids, uid)
+			}
+		}
+	}
+
+	if len(uids) < n {
+		return nil, fmt.Errorf("failed to generate uid")
+	}
+	return uids, nil
+}
+
+// maxPlaceholders is the number of placeholders a bulk statement of the store holds at most. Sqlite builds older than
+// 3.32 accept 999 parameters per statement, mysql and postgres accept 65535
+func (s *store) maxPlaceholders() int {
+	if s.sql.GetDialect().DriverName() == migrator.SQLite {
+		return 999
+	}
+	return 65535
+}
+
+// chunks splits items in slices of at most size items, and at least one
+func chunks[T any](items []T, size int) [][]T {
+	size = max(size, 1)
+	var result [][]T
+	for len(items) > 0 {
+		n := min(len(items), size)
+		result = append(result, items[:n:n])
+		items = items[n:]
+	}
+	return result
+}
+
+// dedupeActions returns actions without duplicates, sorted
+func dedupeActions(actions []string) []string {
+	result := make([]string, 0, len(actions))
+	seen := make(map[string]struct{}, len(actions))
+	for _, a := range actions {
+		if _, ok := seen[a]; !ok {
+			seen[a] = struct{}{}
+			result = append(result, a)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func int64Args(values []int64) []any {
+	args := make([]any, 0, len(values))
+	for _, v := range values {
+		args = append(args, v)
+	}
+	return args
+}
+
+func stringArgs(values []string) []any {
+	args := make([]any, 0, len(values))
+	for _, v := range values {
+		args = append(args, v)
+	}
+	return args
+}
+
+// setPermissionColumn sets a column of the permissions of roles on scopes to the value of their role and scope,
+// the permissions sharing a value and a scope are updated with a single statement
+func (s *store) setPermissionColumn(sess *db.Session, column string, values map[roleScope]any) error {
+	groups := map[any]map[string][]int64{}
+	for key, value := range values {
+		if groups[value] == nil {
+			groups[value] = map[string][]int64{}
+		}
+		groups[value][key.scope] = append(groups[value][key.scope], key.roleID)
+	}
+
+	for value, scopes := range groups {
+		for scope, roleIDs := range scopes {
+			for _, chunk := range chunks(roleIDs, s.maxPlaceholders()-2) {
+				rawSQL := "UPDATE permission SET " + column + " = ? WHERE scope = ? AND role_id IN (?" + strings.Repeat(",?", len(chunk)-1) + ")"
+				if _, err := sess.Exec(append([]any{rawSQL, value, scope}, int64Args(chunk)...)...); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+// renamedRole is a managed role with its organization
+type renamedRole struct {
+	ID    int64  `xorm:"id"`
+	OrgID int64  `xorm:"org_id"`
+	Name  string `xorm:"name"`
+}
+
+func (s *store) RenameManagedRoles(ctx context.Context, orgID int64, cmd RenameManagedRolesCmd) (int64, error) {
+	if err := (managedRoleNames{prefix: cmd.To, previous: []string{cmd.From}}).validate(); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidRoleRename, err)
+	}
+
+	start := time.Now()
+	var rows int64
+	err := s.inTransaction(ctx, func(sess *db.Session) error {
+		rows = 0
+		rawSQL := "SELECT id, org_id, name FROM role WHERE name LIKE ?"
+		args := []any{cmd.From + "%"}
+		if orgID != 0 {
+			rawSQL += " AND org_id = ?"
+			args = append(args, orgID)
+		}
+
+		var roles []renamedRole
+		if err := sess.SQL(rawSQL+" ORDER BY id"+s.forUpdate(), args...).Find(&roles); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, role := range roles {
+			name := cmd.To + strings.TrimPrefix(role.Name, cmd.From)
+			var existing renamedRole
+			has, err := sess.SQL("SELECT id, org_id, name FROM role WHERE org_id = ? AND name = ?"+s.forUpdate(), role.OrgID, name).Get(&existing)
+			if err != nil {
+				return err
+			}
+
+			if has {
+				if err := s.mergeManagedRole(sess, role.ID, existing.ID); err != nil {
+					return err
+				}
+			} else if _, err := sess.Exec("UPDATE role SET name = ?, updated = ? WHERE id = ?", name, now, role.ID); err != nil {
+				return err
+			}
+			rows++
+		}
+		return nil
+	})
+
+	s.metrics.observe(operationRenameRoles, s.dialect(), start, err)
+	if err == nil {
+		s.metrics.addRowsAffected(operationRenameRoles, s.dialect(), rows)
+	}
+	return rows, err
+}
+
+// mergeManagedRole moves the permissions of the managed role from to the managed role into and deletes from, both
+// roles belong to the same assignee. A permission granted by into already is dropped
+func (s *store) mergeManagedRole(sess *db.Session, from, into int64) error {
+	var stored []accesscontrol.Permission
+	if err := sess.SQL("SELECT action, scope FROM permission WHERE role_id = ?", into).Find(&stored); err != nil {
+		return err
+	}
+	existing := make(map[accesscontrol.Permission]struct{}, len(stored))
+	for _, p := range stored {
+		existing[accesscontrol.Permission{Action: p.Action, Scope: p.Scope}] = struct{}{}
+	}
+
+	var moved []storedPermission
+	if err := sess.SQL("SELECT id, role_id, action, scope, reason FROM permission WHERE role_id = ?", from).Find(&moved); err != nil {
+		return err
+	}
+	var remove, move []int64
+	for _, p := range moved {
+		if _, ok := existing[accesscontrol.Permission{Action: p.Action, Scope: p.Scope}]; ok {
+			remove = append(remove, p.ID)
+		} else {
+			move = append(move, p.ID)
+		}
+	}
+
+	for _, chunk := range chunks(remove, s.maxPlaceholders()) {
+		if err := deletePermissions(sess, chunk); err != nil {
+			return err
+		}
+	}
+	for _, chunk := range chunks(move, s.maxPlaceholders()-1) {
+		rawSQL := "UPDATE permission SET role_id = ? WHERE id IN (?" + strings.Repeat(",?", len(chunk)-1) + ")"
+		if _, err := sess.Exec(append([]any{rawSQL, into}, int64Args(chunk)...)...); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"user_role", "team_role", "builtin_role"} {
+		if _, err := sess.Exec("DELETE FROM "+table+" WHERE role_id = ?", from); err != nil {
+			return err
+		}
+	}
+	_, err := sess.Exec("DELETE FROM role WHERE id = ?", from)
+	return err
+}
@@ -2,7 +2,12 @@ package resourcepermissions
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,7 +23,10 @@ import (
 	"github.com/grafana/grafana/pkg/services/quota/quotatest"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
 	"github.com/grafana/grafana/pkg/services/supportbundles/supportbundlestest"
+	"github.com/grafana/grafana/pkg/services/team"
+	"github.com/grafana/grafana/pkg/services/team/teamimpl"
 	"github.com/grafana/grafana/pkg/services/user"
 	"github.com/grafana/grafana/pkg/services/user/userimpl"
 )
@@ -119,6 +127,56 @@ type setTeamResourcePermissionTest struct {
 	seeds             []SetResourcePermissionCommand
 }
 
+func TestIntegrationStore_SetUserResourcePermissionForResources(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, _ := setupTestEnv(t)
+	usr := accesscontrol.User{ID: 1}
+
+	commands := func(actions []string, resourceIDs ...string) []SetResourcePermissionCommand {
+		cmds := make([]SetResourcePermissionCommand, 0, len(resourceIDs))
+		for _, id := range resourceIDs {
+			cmds = append(cmds, SetResourcePermissionCommand{Actions: actions, Resource: "datasources", ResourceID: id, ResourceAttribute: "uid"})
+		}
+		return cmds
+	}
+
+	var hookCalls []string
+	hook := func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+		hookCalls = append(hookCalls, resourceID)
+		return nil
+	}
+
+	_, err := store.SetUserResourcePermission(context.Background(), 1, usr, commands([]string{"datasources:query"}, "1")[0], nil)
+	require.NoError(t, err)
+
+	results, err := store.SetUserResourcePermissionForResources(context.Background(), 1, usr, commands([]string{"datasources:query"}, "1", "2", "3"), hook)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for i, id := range []string{"1", "2", "3"} {
+		assert.Equal(t, id, results[i].ResourceID)
+		assert.Equal(t, id == "1", results[i].Unchanged)
+		assert.Equal(t, []string{"datasources:query"}, results[i].Permission.Actions)
+	}
+	assert.Equal(t, []string{"2", "3"}, hookCalls)
+
+	// a failing hook rolls back every resource of the call
+	_, err = store.SetUserResourcePermissionForResources(context.Background(), 1, usr, commands([]string{"datasources:query", "datasources:write"}, "1", "2"), func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+		if resourceID == "2" {
+			return errors.New("hook failed")
+		}
+		return nil
+	})
+	require.Error(t, err)
+
+	results, err = store.SetUserResourcePermissionForResources(context.Background(), 1, usr, commands([]string{"datasources:query"}, "1", "2"), nil)
+	require.NoError(t, err)
+	assert.True(t, results[0].Unchanged)
+	assert.True(t, results[1].Unchanged)
+}
+
 func TestIntegrationStore_SetTeamResourcePermission(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -336,25 +394,231 @@ func TestIntegrationStore_SetResourcePermissions(t *testing.T) {
 		t.Run(tt.desc, func(t *testing.T) {
 			store, _ := setupTestEnv(t)
 
-			permissions, err := store.SetResourcePermissions(context.Background(), tt.orgID, tt.commands, ResourceHooks{})
+			results, err := store.SetResourcePermissions(context.Background(), tt.orgID, tt.commands, ResourceHooks{})
 			require.NoError(t, err)
 
-			require.Len(t, permissions, len(tt.commands))
+			require.Len(t, results, len(tt.commands))
 			for i, c := range tt.commands {
 				if len(c.Actions) == 0 {
-					assert.Equal(t, accesscontrol.ResourcePermission{}, permissions[i])
+					assert.Equal(t, accesscontrol.ResourcePermission{}, results[i].Permission)
+					assert.Equal(t, PermissionUnchanged, results[i].Outcome)
 				} else {
-					assert.Len(t, permissions[i].Actions, len(c.Actions))
-					assert.Equal(t, c.TeamID, permissions[i].TeamId)
-					assert.Equal(t, c.User.ID, permissions[i].UserId)
-					assert.Equal(t, c.BuiltinRole, permissions[i].BuiltInRole)
-					assert.Equal(t, accesscontrol.Scope(c.Resource, tt.resourceAttribute, c.ResourceID), permissions[i].Scope)
+					assert.Len(t, results[i].Permission.Actions, len(c.Actions))
+					assert.Equal(t, c.TeamID, results[i].Permission.TeamId)
+					assert.Equal(t, c.User.ID, results[i].Permission.UserId)
+					assert.Equal(t, c.BuiltinRole, results[i].Permission.BuiltInRole)
+					assert.Equal(t, accesscontrol.Scope(c.Resource, tt.resourceAttribute, c.ResourceID), results[i].Permission.Scope)
+					assert.Equal(t, PermissionCreated, results[i].Outcome)
 				}
 			}
 		})
 	}
 }
 
+func TestIntegrationStore_Errors(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	cmd := SetResourcePermissionCommand{
+		Actions:           []string{"datasources:query"},
+		Resource:          "datasources",
+		ResourceID:        "1",
+		ResourceAttribute: "uid",
+	}
+
+	store, _ := setupTestEnv(t)
+
+	t.Run("should return assignee not found for missing user", func(t *testing.T) {
+		_, err := store.SetUserResourcePermission(context.Background(), 1, accesscontrol.User{}, cmd, nil)
+		assert.ErrorIs(t, err, ErrAssigneeNotFound)
+		assert.ErrorIs(t, err, user.ErrUserNotFound)
+	})
+
+	t.Run("should return assignee not found for missing team", func(t *testing.T) {
+		_, err := store.SetTeamResourcePermission(context.Background(), 1, 0, cmd, nil)
+		assert.ErrorIs(t, err, ErrAssigneeNotFound)
+		assert.ErrorIs(t, err, team.ErrTeamNotFound)
+	})
+
+	t.Run("should return invalid assignment for invalid built-in role", func(t *testing.T) {
+		_, err := store.SetBuiltInResourcePermission(context.Background(), 1, "Unknown", cmd, nil)
+		assert.ErrorIs(t, err, ErrInvalidAssignment)
+	})
+
+	t.Run("should return hook errors through the transaction", func(t *testing.T) {
+		hookErr := fmt.Errorf("hook failed: %w", ErrResourceNotFound)
+		_, err := store.SetResourcePermissions(context.Background(), 1, []SetResourcePermissionsCommand{
+			{BuiltinRole: "Viewer", SetResourcePermissionCommand: cmd},
+			{TeamID: 1, SetResourcePermissionCommand: cmd},
+		}, ResourceHooks{
+			Team: func(session *db.Session, orgID, teamID int64, resourceID, permission string) error {
+				return hookErr
+			},
+		})
+		assert.ErrorIs(t, err, ErrResourceNotFound)
+
+		_, err = store.SetTeamResourcePermission(context.Background(), 1, 1, cmd, func(session *db.Session, orgID, teamID int64, resourceID, permission string) error {
+			return hookErr
+		})
+		assert.ErrorIs(t, err, ErrResourceNotFound)
+	})
+}
+
+func TestIntegrationStore_ConcurrentWrites(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, sql := setupTestEnv(t)
+
+	const writers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			actions := []string{"datasources:query"}
+			if i%2 == 0 {
+				actions = append(actions, "datasources:read")
+			}
+			for attempt := 1; ; attempt++ {
+				_, err := store.SetBuiltInResourcePermission(context.Background(), 1, "Viewer", SetResourcePermissionCommand{
+					Actions:           actions,
+					Resource:          "datasources",
+					ResourceID:        "1",
+					ResourceAttribute: "uid",
+				}, nil)
+				// concurrent writes can fail with a retryable error but must never corrupt the managed role
+				if !errors.Is(err, ErrConcurrentWrite) || attempt == 50 {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	var duplicates []struct {
+		RoleID int64
+		Action string
+		Scope  string
+	}
+	err := sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		return sess.SQL(`SELECT role_id, action, scope FROM permission GROUP BY role_id, action, scope HAVING COUNT(*) > 1`).Find(&duplicates)
+	})
+	require.NoError(t, err)
+	assert.Empty(t, duplicates)
+}
+
+var errInjectedDeadlock = errors.New("injected deadlock")
+
+// faultyDB fails the first failures write transactions with a deadlock after their body ran, rolling them back
+type faultyDB struct {
+	db.DB
+	failures int
+	attempts int
+}
+
+func (f *faultyDB) WithTransactionalDbSession(ctx context.Context, callback sqlstore.DBTransactionFunc) error {
+	return f.DB.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+		f.attempts++
+		if err := callback(sess); err != nil {
+			return err
+		}
+		if f.attempts <= f.failures {
+			return errInjectedDeadlock
+		}
+		return nil
+	})
+}
+
+func (f *faultyDB) GetDialect() migrator.Dialect {
+	return faultyDialect{f.DB.GetDialect()}
+}
+
+type faultyDialect struct {
+	migrator.Dialect
+}
+
+func (d faultyDialect) IsDeadlock(err error) bool {
+	return errors.Is(err, errInjectedDeadlock) || d.Dialect.IsDeadlock(err)
+}
+
+func TestIntegrationStore_RetryConcurrentWrites(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	backoff := writeRetryBackoff
+	writeRetryBackoff = time.Millisecond
+	t.Cleanup(func() { writeRetryBackoff = backoff })
+
+	cmd := SetResourcePermissionCommand{
+		Actions:           []string{"datasources:query", "datasources:read"},
+		Resource:          "datasources",
+		ResourceID:        "1",
+		ResourceAttribute: "uid",
+	}
+
+	t.Run("should retry the transaction after a deadlock", func(t *testing.T) {
+		sql := db.InitTestDB(t)
+		faulty := &faultyDB{DB: sql, failures: 2}
+		store := NewStore(faulty, featuremgmt.WithFeatures())
+
+		permission, err := store.SetBuiltInResourcePermission(context.Background(), 1, "Viewer", cmd, nil)
+		require.NoError(t, err)
+		require.NotNil(t, permission)
+		assert.Equal(t, 3, faulty.attempts)
+
+		var count int64
+		err = sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+			count, err = sess.Table("permission").Where("scope = ?", "datasources:uid:1").Count()
+			return err
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("should return ErrConcurrentWrite when retries are exhausted", func(t *testing.T) {
+		sql := db.InitTestDB(t)
+		faulty := &faultyDB{DB: sql, failures: maxWriteRetries + 1}
+		store := NewStore(faulty, featuremgmt.WithFeatures())
+
+		_, err := store.SetBuiltInResourcePermission(context.Background(), 1, "Viewer", cmd, nil)
+		require.ErrorIs(t, err, ErrConcurrentWrite)
+		assert.Equal(t, maxWriteRetries+1, faulty.attempts)
+
+		var count int64
+		err = sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+			count, err = sess.Table("permission").Where("scope = ?", "datasources:uid:1").Count()
+			return err
+		})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("should not retry other errors", func(t *testing.T) {
+		sql := db.InitTestDB(t)
+		faulty := &faultyDB{DB: sql}
+		store := NewStore(faulty, featuremgmt.WithFeatures())
+
+		hookErr := errors.New("hook failed")
+		_, err := store.SetBuiltInResourcePermission(context.Background(), 1, "Viewer", cmd, func(*db.Session, int64, string, string, string) error {
+			return hookErr
+		})
+		require.ErrorIs(t, err, hookErr)
+		assert.NotErrorIs(t, err, ErrConcurrentWrite)
+		assert.Equal(t, 1, faulty.attempts)
+	})
+}
+
 type getResourcePermissionsTest struct {
 	desc               string
 	user               *user.SignedInUser
@@ -513,6 +777,304 @@ func TestIntegrationStore_GetResourcePermissions(t *testing.T) {
 	}
 }
 
+type getResourcePermissionsPageTest struct {
+	desc               string
+	opts               ResourcePermissionsQueryOptions
+	expectedTotalCount int64
+	expectedAssignees  []string
+}
+
+func TestIntegrationStore_GetResourcePermissionsPage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tests := []getResourcePermissionsPageTest{
+		{
+			desc:               "should return every assignee without options",
+			expectedTotalCount: 8,
+			expectedAssignees:  []string{"user:1:0", "user:1:1", "user:1:2", "user:1:3", "Alpha", "Beta", "Editor", "Viewer"},
+		},
+		{
+			desc:               "should return first page",
+			opts:               ResourcePermissionsQueryOptions{Limit: 3},
+			expectedTotalCount: 8,
+			expectedAssignees:  []string{"user:1:0", "user:1:1", "user:1:2"},
+		},
+		{
+			desc:               "should return last page",
+			opts:               ResourcePermissionsQueryOptions{Limit: 3, Offset: 6},
+			expectedTotalCount: 8,
+			expectedAssignees:  []string{"Editor", "Viewer"},
+		},
+		{
+			desc:               "should return empty page after last assignee",
+			opts:               ResourcePermissionsQueryOptions{Limit: 3, Offset: 9},
+			expectedTotalCount: 8,
+		},
+		{
+			desc:               "should filter by kind",
+			opts:               ResourcePermissionsQueryOptions{Kind: AssigneeKindTeam},
+			expectedTotalCount: 2,
+			expectedAssignees:  []string{"Alpha", "Beta"},
+		},
+		{
+			desc:               "should search case-insensitively",
+			opts:               ResourcePermissionsQueryOptions{Search: "ALPHA"},
+			expectedTotalCount: 1,
+			expectedAssignees:  []string{"Alpha"},
+		},
+		{
+			desc:               "should search user login",
+			opts:               ResourcePermissionsQueryOptions{Search: "user:1:3"},
+			expectedTotalCount: 1,
+			expectedAssignees:  []string{"user:1:3"},
+		},
+		{
+			desc:               "should search email case-insensitively",
+			opts:               ResourcePermissionsQueryOptions{Search: "beta@example.org"},
+			expectedTotalCount: 1,
+			expectedAssignees:  []string{"Beta"},
+		},
+		{
+			desc:               "should search underscore literally",
+			opts:               ResourcePermissionsQueryOptions{Search: "user_1"},
+			expectedTotalCount: 0,
+		},
+		{
+			desc:               "should search percent literally",
+			opts:               ResourcePermissionsQueryOptions{Search: "%"},
+			expectedTotalCount: 0,
+		},
+		{
+			desc:               "should combine kind, search and pagination",
+			opts:               ResourcePermissionsQueryOptions{Kind: AssigneeKindBuiltInRole, Search: "I", Limit: 1, Offset: 1},
+			expectedTotalCount: 2,
+			expectedAssignees:  []string{"Viewer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			store, sql := setupTestEnv(t)
+			orgService, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+			require.NoError(t, err)
+
+			cmd := SetResourcePermissionCommand{
+				Actions:           []string{"datasources:query"},
+				Resource:          "datasources",
+				ResourceID:        "1",
+				ResourceAttribute: "uid",
+			}
+			seedResourcePermissions(t, store, sql, orgService, cmd.Actions, cmd.Resource, cmd.ResourceID, cmd.ResourceAttribute, 4, 0)
+
+			for _, name := range []string{"Beta", "Alpha"} {
+				tm := &team.Team{OrgID: 1, UID: name, Name: name, Email: name + "@Example.ORG", Created: time.Now(), Updated: time.Now()}
+				err := sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+					_, err := sess.Insert(tm)
+					return err
+				})
+				require.NoError(t, err)
+				_, err = store.SetTeamResourcePermission(context.Background(), 1, tm.ID, cmd, nil)
+				require.NoError(t, err)
+			}
+			for _, role := range []string{"Viewer", "Editor"} {
+				_, err := store.SetBuiltInResourcePermission(context.Background(), 1, role, cmd, nil)
+				require.NoError(t, err)
+			}
+
+			page, err := store.GetResourcePermissionsPage(context.Background(), 1, GetResourcePermissionsQuery{
+				User: &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{
+					1: {accesscontrol.ActionTeamsRead: {accesscontrol.ScopeTeamsAll}},
+				}},
+				Actions:           cmd.Actions,
+				Resource:          cmd.Resource,
+				ResourceID:        cmd.ResourceID,
+				ResourceAttribute: cmd.ResourceAttribute,
+				OnlyManaged:       true,
+			}, tt.opts)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedTotalCount, page.TotalCount)
+			var assignees []string
+			for _, p := range page.Permissions {
+				assignees = append(assignees, p.UserLogin+p.Team+p.BuiltInRole)
+			}
+			assert.Equal(t, tt.expectedAssignees, assignees)
+		})
+	}
+}
+
+func TestIntegrationStore_GetResourcePermissions_NestedInheritance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, sql := setupTestEnv(t)
+	orgService, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgService, sql.Cfg, nil, nil, quotatest.New(false, nil), supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+
+	// folder tree: level1 > level2 > level3 > level4
+	folders := []string{"level1", "level2", "level3", "level4"}
+	usr, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "user", OrgID: 1})
+	require.NoError(t, err)
+	for _, uid := range folders {
+		_, err := store.SetUserResourcePermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, SetResourcePermissionCommand{
+			Actions:           []string{"folders:read"},
+			Resource:          "folders",
+			ResourceID:        uid,
+			ResourceAttribute: "uid",
+		}, nil)
+		require.NoError(t, err)
+	}
+	_, err = store.SetBuiltInResourcePermission(context.Background(), 1, "Viewer", SetResourcePermissionCommand{
+		Actions:           []string{"folders:read"},
+		Resource:          "folders",
+		ResourceID:        "level2",
+		ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+
+	permissions, err := store.GetResourcePermissions(context.Background(), 1, GetResourcePermissionsQuery{
+		User:              &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {}}},
+		Actions:           []string{"folders:read"},
+		Resource:          "folders",
+		ResourceID:        "level4",
+		ResourceAttribute: "uid",
+		OnlyManaged:       true,
+		InheritedScopes:   []string{"folders:uid:level3", "folders:uid:level2", "folders:uid:level1"},
+	})
+	require.NoError(t, err)
+	require.Len(t, permissions, 5)
+
+	var userPermissions []accesscontrol.ResourcePermission
+	for _, p := range permissions {
+		if p.UserId == usr.ID {
+			userPermissions = append(userPermissions, p)
+		} else {
+			assert.Equal(t, "Viewer", p.BuiltInRole)
+			assert.Equal(t, "folders:uid:level2", p.Scope)
+			assert.True(t, p.IsInherited)
+		}
+	}
+
+	require.Len(t, userPermissions, 4)
+	assert.True(t, userPermissions[0].IsManaged)
+	assert.False(t, userPermissions[0].IsInherited)
+	assert.Equal(t, "folders:uid:level4", userPermissions[0].Scope)
+	for i, scope := range []string{"folders:uid:level3", "folders:uid:level2", "folders:uid:level1"} {
+		assert.False(t, userPermissions[i+1].IsManaged)
+		assert.True(t, userPermissions[i+1].IsInherited)
+		assert.Equal(t, scope, userPermissions[i+1].Scope)
+	}
+}
+
+func TestIntegrationStore_GetResourcePermissionsAnyAction(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, sql := setupTestEnv(t)
+	orgService, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgService, sql.Cfg, nil, nil, quotatest.New(false, nil), supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+
+	editor, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "editor", OrgID: 1})
+	require.NoError(t, err)
+	viewer, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "viewer", OrgID: 1})
+	require.NoError(t, err)
+
+	set := func(userID int64, resourceID string, actions ...string) {
+		_, err := store.SetUserResourcePermission(context.Background(), 1, accesscontrol.User{ID: userID}, SetResourcePermissionCommand{
+			Actions:           actions,
+			Resource:          "dashboards",
+			ResourceID:        resourceID,
+			ResourceAttribute: "uid",
+		}, nil)
+		require.NoError(t, err)
+	}
+	set(editor.ID, "abc", "dashboards:read", "dashboards:write")
+	set(viewer.ID, "abc", "dashboards:read")
+	// the viewer is granted write on another dashboard only, it must not match on abc
+	set(viewer.ID, "other", "dashboards:write")
+	_, err = store.SetBuiltInResourcePermission(context.Background(), 1, "Viewer", SetResourcePermissionCommand{
+		Actions:           []string{"dashboards:read"},
+		Resource:          "dashboards",
+		ResourceID:        "abc",
+		ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+
+	type testCase struct {
+		desc      string
+		actions   []string
+		anyAction []string
+		expected  map[string][]string
+	}
+
+	tests := []testCase{
+		{
+			desc:     "should return every role without filter",
+			actions:  []string{"dashboards:read", "dashboards:write"},
+			expected: map[string][]string{"editor": {"dashboards:read", "dashboards:write"}, "viewer": {"dashboards:read"}, "Viewer": {"dashboards:read"}},
+		},
+		{
+			desc:      "should only return the roles granting the action",
+			actions:   []string{"dashboards:read", "dashboards:write"},
+			anyAction: []string{"dashboards:write"},
+			expected:  map[string][]string{"editor": {"dashboards:read", "dashboards:write"}},
+		},
+		{
+			desc:      "should restrict the actions of the matching roles to the queried actions",
+			actions:   []string{"dashboards:read"},
+			anyAction: []string{"dashboards:write"},
+			expected:  map[string][]string{"editor": {"dashboards:read"}},
+		},
+		{
+			desc:      "should return each role once when it grants several of the actions",
+			actions:   []string{"dashboards:read", "dashboards:write"},
+			anyAction: []string{"dashboards:read", "dashboards:write"},
+			expected:  map[string][]string{"editor": {"dashboards:read", "dashboards:write"}, "viewer": {"dashboards:read"}, "Viewer": {"dashboards:read"}},
+		},
+		{
+			desc:      "should return nothing when no role grants the action",
+			actions:   []string{"dashboards:read", "dashboards:write"},
+			anyAction: []string{"dashboards:delete"},
+			expected:  map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			permissions, err := store.GetResourcePermissions(context.Background(), 1, GetResourcePermissionsQuery{
+				User:              &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {accesscontrol.ActionOrgUsersRead: {accesscontrol.ScopeUsersAll}}}},
+				Actions:           tt.actions,
+				AnyAction:         tt.anyAction,
+				Resource:          "dashboards",
+				ResourceID:        "abc",
+				ResourceAttribute: "uid",
+			})
+			require.NoError(t, err)
+
+			got := map[string][]string{}
+			for _, p := range permissions {
+				key := p.UserLogin
+				if p.BuiltInRole != "" {
+					key = p.BuiltInRole
+				}
+				require.NotContains(t, got, key, "role returned several times")
+				actions := append([]string{}, p.Actions...)
+				sort.Strings(actions)
+				got[key] = actions
+			}
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
 func seedResourcePermissions(
 	t *testing.T, store *store, sql *sqlstore.SQLStore, orgService org.Service,
 	actions []string, resource, resourceID, resourceAttribute string, numUsers, numServiceAccounts int,
@@ -606,7 +1168,7 @@ func TestStore_IsInherited(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.description, func(t *testing.T) {
-			isInherited := tc.permission.IsInherited(tc.requiredScope)
+			isInherited := tc.permission.IsInherited(defaultManagedRoleNames, tc.requiredScope)
 			assert.Equal(t, tc.expected, isInherited)
 		})
 	}
@@ -717,7 +1279,7 @@ func TestIntegrationStore_DeleteResourcePermissions(t *testing.T) {
 			}, ResourceHooks{})
 			require.NoError(t, err)
 
-			err = store.DeleteResourcePermissions(context.Background(), tt.orgID, &tt.command)
+			err = store.DeleteResourcePermissions(context.Background(), tt.orgID, &tt.command, ResourceHooks{})
 			require.NoError(t, err)
 
 			permissions := retrievePermissionsHelper(store, t)
@@ -733,6 +1295,181 @@ func TestIntegrationStore_DeleteResourcePermissions(t *testing.T) {
 	}
 }
 
+func TestIntegrationStore_DeleteDanglingAssignments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, sql := setupTestEnv(t)
+	orgService, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+	usrSvc, err := userimpl.ProvideService(sql, orgService, sql.Cfg, nil, nil, quotatest.New(false, nil), supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	teamSvc := teamimpl.ProvideService(sql, sql.Cfg)
+
+	createUser := func(cmd user.CreateUserCommand) int64 {
+		cmd.OrgID = 1
+		u, err := usrSvc.Create(context.Background(), &cmd)
+		require.NoError(t, err)
+		return u.ID
+	}
+	active := createUser(user.CreateUserCommand{Login: "active"})
+	disabled := createUser(user.CreateUserCommand{Login: "disabled", IsDisabled: true})
+	serviceAccount := createUser(user.CreateUserCommand{Login: "sa", IsServiceAccount: true})
+	deleted := createUser(user.CreateUserCommand{Login: "deleted"})
+	existingTeam, err := teamSvc.CreateTeam("existing", "", 1)
+	require.NoError(t, err)
+	deletedTeam, err := teamSvc.CreateTeam("deleted", "", 1)
+	require.NoError(t, err)
+
+	setUser := func(orgID, userID int64, resource, resourceID string) {
+		_, err := store.SetUserResourcePermission(context.Background(), orgID, accesscontrol.User{ID: userID}, SetResourcePermissionCommand{
+			Actions: []string{resource + ":read"}, Resource: resource, ResourceID: resourceID, ResourceAttribute: "uid",
+		}, nil)
+		require.NoError(t, err)
+	}
+	for _, id := range []int64{active, disabled, serviceAccount, deleted} {
+		setUser(1, id, "dashboards", "1")
+	}
+	setUser(1, deleted, "dashboards", "2")
+	// permissions stored with the scopes of an alias are reconciled as well
+	setUser(1, deleted, "reports", "3")
+	// permissions of other resources and of other organizations are kept
+	setUser(1, deleted, "folders", "1")
+	setUser(2, deleted, "dashboards", "1")
+	for _, id := range []int64{existingTeam.ID, deletedTeam.ID} {
+		_, err := store.SetTeamResourcePermission(context.Background(), 1, id, SetResourcePermissionCommand{
+			Actions: []string{"dashboards:read", "dashboards:write"}, Resource: "dashboards", ResourceID: "1", ResourceAttribute: "uid",
+		}, nil)
+		require.NoError(t, err)
+	}
+	_, err = store.SetTeamResourcePermission(context.Background(), 1, deletedTeam.ID, SetResourcePermissionCommand{
+		Actions: []string{"reports:read"}, Resource: "reports", ResourceID: "4", ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+
+	// delete the user and the team without cleaning up their permissions
+	err = sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		if _, err := sess.Exec("DELETE FROM "+sql.GetDialect().Quote("user")+" WHERE id = ?", deleted); err != nil {
+			return err
+		}
+		_, err := sess.Exec("DELETE FROM team WHERE id = ?", deletedTeam.ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	removed, err := store.DeleteDanglingAssignments(context.Background(), 1, DeleteDanglingAssignmentsCmd{
+		Resource: "dashboards", ResourceAttribute: "uid", ResourceAliases: []string{"reports"},
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []DanglingAssignment{
+		{OrgID: 1, UserID: deleted, ResourceID: "1"},
+		{OrgID: 1, UserID: deleted, ResourceID: "2"},
+		{OrgID: 1, UserID: deleted, ResourceID: "3"},
+		{OrgID: 1, TeamID: deletedTeam.ID, ResourceID: "1"},
+		{OrgID: 1, TeamID: deletedTeam.ID, ResourceID: "4"},
+	}, removed)
+
+	var remaining []DanglingAssignment
+	err = sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		return sess.SQL(`
+			SELECT r.org_id, COALESCE(ur.user_id, 0) AS user_id, COALESCE(tr.team_id, 0) AS team_id
+			FROM permission p
+				INNER JOIN role r ON r.id = p.role_id
+				LEFT JOIN user_role ur ON ur.role_id = r.id
+				LEFT JOIN team_role tr ON tr.role_id = r.id
+			WHERE p.scope LIKE 'dashboards:%'`).Find(&remaining)
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []DanglingAssignment{
+		{OrgID: 1, UserID: active},
+		{OrgID: 1, UserID: disabled},
+		{OrgID: 1, UserID: serviceAccount},
+		{OrgID: 1, TeamID: existingTeam.ID},
+		{OrgID: 1, TeamID: existingTeam.ID},
+		{OrgID: 2, UserID: deleted},
+	}, remaining)
+	permissions := retrievePermissionsHelper(store, t)
+	assert.Contains(t, permissions, orgPermission{OrgID: 1, Action: "folders:read", Scope: "folders:uid:1"})
+	assert.NotContains(t, permissions, orgPermission{OrgID: 1, Action: "reports:read", Scope: "reports:uid:3"})
+	assert.NotContains(t, permissions, orgPermission{OrgID: 1, Action: "reports:read", Scope: "reports:uid:4"})
+
+	// every organization is reconciled without an organization
+	removed, err = store.DeleteDanglingAssignments(context.Background(), 0, DeleteDanglingAssignmentsCmd{Resource: "dashboards", ResourceAttribute: "uid"})
+	require.NoError(t, err)
+	assert.Equal(t, []DanglingAssignment{{OrgID: 2, UserID: deleted, ResourceID: "1"}}, removed)
+}
+
+func TestIntegrationStore_DeleteUserAssignments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, _ := setupTestEnv(t)
+	ctx := context.Background()
+
+	const userID, otherUserID, teamID = 1, 2, 1
+	setUser := func(orgID, userID int64, resource, resourceID string) {
+		_, err := store.SetUserResourcePermission(ctx, orgID, accesscontrol.User{ID: userID}, SetResourcePermissionCommand{
+			Actions: []string{resource + ":read"}, Resource: resource, ResourceID: resourceID, ResourceAttribute: "uid",
+		}, nil)
+		require.NoError(t, err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		setUser(1, userID, "dashboards", id)
+	}
+	// stored under a former name of the resource
+	setUser(1, userID, "dashboard", "4")
+	// permissions of other users, teams, resources and organizations are kept
+	setUser(1, otherUserID, "dashboards", "1")
+	setUser(1, userID, "folders", "1")
+	setUser(2, userID, "dashboards", "1")
+	_, err := store.SetTeamResourcePermission(ctx, 1, teamID, SetResourcePermissionCommand{
+		Actions: []string{"dashboards:read"}, Resource: "dashboards", ResourceID: "1", ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+	_, err = store.SetResourceOwner(ctx, 1, accesscontrol.User{ID: userID}, SetResourcePermissionCommand{
+		Actions: []string{"dashboards:read"}, Resource: "dashboards", ResourceID: "2", ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+
+	var hooked []string
+	removed, err := store.DeleteUserAssignments(ctx, 1, DeleteUserAssignmentsCmd{
+		UserID: userID, Resource: "dashboards", ResourceAttribute: "uid", ResourceAliases: []string{"dashboard"}, BatchSize: 2,
+	}, func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+		require.NotNil(t, session)
+		assert.Equal(t, int64(userID), user.ID)
+		assert.Empty(t, permission)
+		hooked = append(hooked, resourceID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []UserAssignment{
+		{OrgID: 1, UserID: userID, ResourceID: "1"},
+		{OrgID: 1, UserID: userID, ResourceID: "2"},
+		{OrgID: 1, UserID: userID, ResourceID: "3"},
+		{OrgID: 1, UserID: userID, ResourceID: "4"},
+	}, removed)
+	assert.ElementsMatch(t, []string{"1", "2", "3", "4"}, hooked)
+
+	permissions := retrievePermissionsHelper(store, t)
+	assert.ElementsMatch(t, []orgPermission{
+		{OrgID: 1, Action: "dashboards:read", Scope: "dashboards:uid:1"},
+		{OrgID: 1, Action: "dashboards:read", Scope: "dashboards:uid:1"},
+		{OrgID: 1, Action: "folders:read", Scope: "folders:uid:1"},
+		{OrgID: 2, Action: "dashboards:read", Scope: "dashboards:uid:1"},
+	}, permissions)
+
+	owner, err := store.GetResourceOwner(ctx, 1, "dashboards", "2")
+	require.NoError(t, err)
+	assert.Nil(t, owner)
+
+	// every organization is cleaned up without an organization
+	removed, err = store.DeleteUserAssignments(ctx, 0, DeleteUserAssignmentsCmd{UserID: userID, Resource: "dashboards", ResourceAttribute: "uid"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []UserAssignment{{OrgID: 2, UserID: userID, ResourceID: "1"}}, removed)
+}
+
 func retrievePermissionsHelper(store *store, t *testing.T) []orgPermission {
 	permissions := []orgPermission{}
 	err := store.sql.WithDbSession(context.Background(), func(sess *db.Session) error {
@@ -748,3 +1485,451 @@ func retrievePermissionsHelper(store *store, t *testing.T) []orgPermission {
 	require.NoError(t, err)
 	return permissions
 }
+
+func TestIntegrationStore_ResourcePermissionsQueryPlan(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, sql := setupTestEnv(t)
+	if sql.GetDialect().DriverName() != migrator.SQLite {
+		t.Skip("query plans are only recorded for sqlite")
+	}
+
+	for _, anyAction := range [][]string{nil, {"dashboards:write"}} {
+		query, args, err := store.resourcePermissionsSQL(1, GetResourcePermissionsQuery{
+			User:              &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {"teams:read": {"teams:*"}}}},
+			Actions:           []string{"dashboards:read", "dashboards:write"},
+			AnyAction:         anyAction,
+			Resource:          "dashboards",
+			ResourceID:        "abc",
+			ResourceAttribute: "uid",
+			InheritedScopes:   []string{"folders:uid:a", "folders:uid:b"},
+			OnlyManaged:       true,
+		})
+		require.NoError(t, err)
+
+		var plan []map[string]string
+		err = sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+			plan, err = sess.SQL("EXPLAIN QUERY PLAN "+query, args...).QueryString()
+			return err
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, plan)
+
+		// every table of the lookup must be searched through an index, a full scan grows with the number of permissions
+		for _, step := range plan {
+			assert.False(t, strings.HasPrefix(step["detail"], "SCAN "), "unexpected full scan: %s", step["detail"])
+		}
+	}
+}
+
+func TestIntegrationStore_CreatePermissionsUpsert(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, sql := setupTestEnv(t)
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err := sql.WithTransactionalDbSession(context.Background(), func(sess *db.Session) error {
+		// a permission committed by a concurrent write is updated instead of violating the unique index
+		if err := store.createPermissions(sess, 1, "datasources", "1", "uid", map[string]struct{}{"datasources:query": {}}, 1, "first"); err != nil {
+			return err
+		}
+		if _, err := sess.Exec("UPDATE permission SET created = ?, updated = ?", created, created); err != nil {
+			return err
+		}
+		return store.createPermissions(sess, 1, "datasources", "1", "uid", map[string]struct{}{"datasources:query": {}, "datasources:read": {}}, 2, "second")
+	})
+	require.NoError(t, err)
+
+	type upsertedPermission struct {
+		Action    string
+		Scope     string
+		GrantedBy int64 `xorm:"granted_by"`
+		Reason    string
+		Created   time.Time
+		Updated   time.Time
+	}
+	var permissions []upsertedPermission
+	err = sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		return sess.SQL("SELECT action, scope, granted_by, reason, created, updated FROM permission WHERE role_id = ? ORDER BY action", 1).Find(&permissions)
+	})
+	require.NoError(t, err)
+	require.Len(t, permissions, 2)
+	assert.Equal(t, "datasources:query", permissions[0].Action)
+	assert.Equal(t, "datasources:read", permissions[1].Action)
+	assert.Equal(t, "datasources:uid:1", permissions[0].Scope)
+
+	t.Run("should keep the created time of a permission granted again", func(t *testing.T) {
+		assert.True(t, created.Equal(permissions[0].Created), "created %s, want %s", permissions[0].Created, created)
+		assert.True(t, permissions[0].Updated.After(created))
+		assert.True(t, permissions[1].Created.After(created))
+	})
+
+	t.Run("should update the grant metadata of a permission granted again", func(t *testing.T) {
+		for _, p := range permissions {
+			assert.Equal(t, int64(2), p.GrantedBy, p.Action)
+			assert.Equal(t, "second", p.Reason, p.Action)
+		}
+	})
+}
+
+// storedAssignment is a managed permission row together with the assignment of its role
+type storedAssignment struct {
+	RoleName    string `xorm:"role_name"`
+	UserID      int64  `xorm:"user_id"`
+	TeamID      int64  `xorm:"team_id"`
+	BuiltinRole string `xorm:"builtin_role"`
+	Action      string `xorm:"action"`
+	Scope       string `xorm:"scope"`
+}
+
+func retrieveAssignmentsHelper(t *testing.T, sql *sqlstore.SQLStore) []storedAssignment {
+	t.Helper()
+	var assignments []storedAssignment
+	err := sql.WithDbSession(context.Background(), func(sess *db.Session) error {
+		return sess.SQL(`
+    SELECT r.name AS role_name, COALESCE(ur.user_id, 0) AS user_id, COALESCE(tr.team_id, 0) AS team_id,
+        COALESCE(br.role, '') AS builtin_role, p.action, p.scope
+    FROM permission p
+    INNER JOIN role r ON r.id = p.role_id
+    LEFT JOIN user_role ur ON ur.role_id = r.id
+    LEFT JOIN team_role tr ON tr.role_id = r.id
+    LEFT JOIN builtin_role br ON br.role_id = r.id
+    ORDER BY r.name, p.scope, p.action
+`).Find(&assignments)
+	})
+	require.NoError(t, err)
+	return assignments
+}
+
+func TestIntegrationStore_SetResourcePermissionsMatchesPerEntry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	command := func(resourceID string, actions ...string) SetResourcePermissionCommand {
+		return SetResourcePermissionCommand{Actions: actions, Resource: "datasources", ResourceID: resourceID, ResourceAttribute: "uid"}
+	}
+
+	seeds := []SetResourcePermissionsCommand{
+		{User: accesscontrol.User{ID: 1}, SetResourcePermissionCommand: command("1", "datasources:query")},
+		{TeamID: 1, SetResourcePermissionCommand: command("1", "datasources:query", "datasources:write")},
+		{BuiltinRole: "Viewer", SetResourcePermissionCommand: command("2", "datasources:query")},
+	}
+	commands := []SetResourcePermissionsCommand{
+		// unchanged, updated and removed permissions of seeded assignments
+		{User: accesscontrol.User{ID: 1}, SetResourcePermissionCommand: command("1", "datasources:query")},
+		{TeamID: 1, SetResourcePermissionCommand: command("1", "datasources:query")},
+		{BuiltinRole: "Viewer", SetResourcePermissionCommand: command("2")},
+		// new assignments and roles
+		{User: accesscontrol.User{ID: 2}, SetResourcePermissionCommand: command("1", "datasources:query", "datasources:write")},
+		{TeamID: 2, SetResourcePermissionCommand: command("2", "datasources:query")},
+		{BuiltinRole: "Editor", SetResourcePermissionCommand: command("1", "datasources:query", "datasources:query")},
+		// removing a permission that does not exist
+		{User: accesscontrol.User{ID: 3}, SetResourcePermissionCommand: command("1")},
+		// commands for the same assignee and resource are applied in order
+		{User: accesscontrol.User{ID: 2}, SetResourcePermissionCommand: command("1", "datasources:query")},
+		{TeamID: 2, SetResourcePermissionCommand: command("2")},
+		{TeamID: 2, SetResourcePermissionCommand: command("2", "datasources:write")},
+	}
+
+	batched, batchedSQL := setupTestEnv(t)
+	perEntry, perEntrySQL := setupTestEnv(t)
+	for _, s := range []*store{batched, perEntry} {
+		_, err := s.SetResourcePermissions(context.Background(), 1, seeds, ResourceHooks{})
+		require.NoError(t, err)
+	}
+
+	var hookCalls []string
+	hooks := ResourceHooks{
+		User: func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+			hookCalls = append(hookCalls, fmt.Sprintf("user %d %s", user.ID, resourceID))
+			return nil
+		},
+		Team: func(session *db.Session, orgID, teamID int64, resourceID, permission string) error {
+			hookCalls = append(hookCalls, fmt.Sprintf("team %d %s", teamID, resourceID))
+			return nil
+		},
+		BuiltInRole: func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error {
+			hookCalls = append(hookCalls, fmt.Sprintf("builtin %s %s", builtInRole, resourceID))
+			return nil
+		},
+	}
+	results, err := batched.SetResourcePermissions(context.Background(), 1, commands, hooks)
+	require.NoError(t, err)
+	require.Len(t, results, len(commands))
+
+	for _, c := range commands {
+		var err error
+		switch {
+		case c.User.ID != 0:
+			_, err = perEntry.SetUserResourcePermission(context.Background(), 1, c.User, c.SetResourcePermissionCommand, nil)
+		case c.TeamID != 0:
+			_, err = perEntry.SetTeamResourcePermission(context.Background(), 1, c.TeamID, c.SetResourcePermissionCommand, nil)
+		default:
+			_, err = perEntry.SetBuiltInResourcePermission(context.Background(), 1, c.BuiltinRole, c.SetResourcePermissionCommand, nil)
+		}
+		require.NoError(t, err)
+	}
+
+	expected := retrieveAssignmentsHelper(t, perEntrySQL)
+	assert.Contains(t, expected, storedAssignment{RoleName: accesscontrol.ManagedTeamRoleName(2), TeamID: 2, Action: "datasources:write", Scope: "datasources:uid:2"})
+	assert.Contains(t, expected, storedAssignment{RoleName: accesscontrol.ManagedBuiltInRoleName("Editor"), BuiltinRole: "Editor", Action: "datasources:query", Scope: "datasources:uid:1"})
+	assert.Equal(t, expected, retrieveAssignmentsHelper(t, batchedSQL))
+
+	outcomes := make([]PermissionOutcome, 0, len(results))
+	for _, r := range results {
+		outcomes = append(outcomes, r.Outcome)
+	}
+	assert.Equal(t, []PermissionOutcome{
+		PermissionUnchanged, PermissionUpdated, PermissionRemoved,
+		PermissionCreated, PermissionCreated, PermissionCreated,
+		PermissionUnchanged,
+		PermissionUpdated, PermissionRemoved, PermissionCreated,
+	}, outcomes)
+
+	// entries report the permission stored once every command was applied
+	assert.Equal(t, []string{"datasources:query"}, results[3].Permission.Actions)
+	assert.Equal(t, []string{"datasources:write"}, results[4].Permission.Actions)
+	assert.Equal(t, []string{"datasources:query"}, results[5].Permission.Actions)
+	assert.Equal(t, accesscontrol.ResourcePermission{}, results[2].Permission)
+
+	assert.Len(t, hookCalls, len(commands))
+
+	// every permission is written once, even when several commands create it
+	var count int64
+	err = batchedSQL.WithDbSession(context.Background(), func(sess *db.Session) error {
+		_, err := sess.SQL("SELECT COUNT(*) FROM permission").Get(&count)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(retrieveAssignmentsHelper(t, batchedSQL))), count)
+}
+
+func TestIntegrationStore_SetResourcePermissionsChunks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, sql := setupTestEnv(t)
+	// enough roles, assignments and permissions to exceed the placeholder limit of every dialect in one statement
+	n := store.maxPlaceholders()/roleColumns + 1
+	commands := make([]SetResourcePermissionsCommand, 0, n)
+	for i := 1; i <= n; i++ {
+		commands = append(commands, SetResourcePermissionsCommand{
+			User: accesscontrol.User{ID: int64(i)},
+			SetResourcePermissionCommand: SetResourcePermissionCommand{
+				Actions:           []string{"datasources:query", "datasources:write"},
+				Resource:          "datasources",
+				ResourceID:        "1",
+				ResourceAttribute: "uid",
+			},
+		})
+	}
+
+	results, err := store.SetResourcePermissions(context.Background(), 1, commands, ResourceHooks{})
+	require.NoError(t, err)
+	require.Len(t, results, n)
+	for i, r := range results {
+		assert.Equal(t, PermissionCreated, r.Outcome)
+		assert.Equal(t, int64(i+1), r.Permission.UserId)
+		assert.Len(t, r.Permission.Actions, 2)
+	}
+	assert.Len(t, retrieveAssignmentsHelper(t, sql), 2*n)
+
+	for i := range commands {
+		commands[i].Actions = nil
+	}
+	results, err = store.SetResourcePermissions(context.Background(), 1, commands, ResourceHooks{})
+	require.NoError(t, err)
+	for _, r := range results {
+		assert.Equal(t, PermissionRemoved, r.Outcome)
+	}
+	assert.Empty(t, retrieveAssignmentsHelper(t, sql))
+}
+
+func TestIntegrationStore_GetUsageStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, _ := setupTestEnv(t)
+	set := func(orgID int64, resource, resourceID string, commands ...SetResourcePermissionsCommand) {
+		for i := range commands {
+			commands[i].SetResourcePermissionCommand = SetResourcePermissionCommand{
+				Actions:           []string{resource + ":read", resource + ":write"},
+				Resource:          resource,
+				ResourceID:        resourceID,
+				ResourceAttribute: "uid",
+			}
+		}
+		_, err := store.SetResourcePermissions(context.Background(), orgID, commands, ResourceHooks{})
+		require.NoError(t, err)
+	}
+
+	set(1, "datasources", "1",
+		SetResourcePermissionsCommand{User: accesscontrol.User{ID: 1}},
+		SetResourcePermissionsCommand{User: accesscontrol.User{ID: 2}},
+		SetResourcePermissionsCommand{TeamID: 1},
+		SetResourcePermissionsCommand{BuiltinRole: "Viewer"},
+	)
+	set(1, "datasources", "2", SetResourcePermissionsCommand{User: accesscontrol.User{ID: 1}})
+	set(2, "datasources", "1", SetResourcePermissionsCommand{TeamID: 2})
+	// permissions on other resources are not counted
+	set(1, "folders", "1", SetResourcePermissionsCommand{User: accesscontrol.User{ID: 1}})
+
+	stats, err := store.GetUsageStats(context.Background(), UsageStatsQuery{
+		Resource:          "datasources",
+		ResourceAttribute: "uid",
+		AssignmentKinds:   []string{"users", "teams", "builtInRoles", "groups"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &UsageStats{
+		Resources:                 3,
+		Assignments:               map[string]int64{"users": 3, "teams": 2, "builtInRoles": 1, "groups": 0},
+		AssignmentsPerResourceP95: 4,
+	}, stats)
+
+	stats, err = store.GetUsageStats(context.Background(), UsageStatsQuery{
+		Resource:          "dashboards",
+		ResourceAttribute: "uid",
+		AssignmentKinds:   []string{"users"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &UsageStats{Assignments: map[string]int64{"users": 0}}, stats)
+}
+
+func TestIntegrationStore_GetUsageStatsPercentile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, _ := setupTestEnv(t)
+	// 20 resources, the first one with 3 assignments and every other one with a single assignment
+	for i := 1; i <= 20; i++ {
+		commands := []SetResourcePermissionsCommand{{User: accesscontrol.User{ID: 1}}}
+		if i == 1 {
+			commands = append(commands, SetResourcePermissionsCommand{User: accesscontrol.User{ID: 2}}, SetResourcePermissionsCommand{TeamID: 1})
+		}
+		for j := range commands {
+			commands[j].SetResourcePermissionCommand = SetResourcePermissionCommand{
+				Actions:           []string{"datasources:query"},
+				Resource:          "datasources",
+				ResourceID:        strconv.Itoa(i),
+				ResourceAttribute: "uid",
+			}
+		}
+		_, err := store.SetResourcePermissions(context.Background(), 1, commands, ResourceHooks{})
+		require.NoError(t, err)
+	}
+
+	query := UsageStatsQuery{Resource: "datasources", ResourceAttribute: "uid", AssignmentKinds: []string{"users", "teams"}}
+	stats, err := store.GetUsageStats(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), stats.Resources)
+	assert.Equal(t, map[string]int64{"users": 21, "teams": 1}, stats.Assignments)
+	// 19 of the 20 resources, 95%, have a single assignment
+	assert.Equal(t, int64(1), stats.AssignmentsPerResourceP95)
+
+	_, err = store.SetResourcePermissions(context.Background(), 1, []SetResourcePermissionsCommand{{
+		TeamID: 1,
+		SetResourcePermissionCommand: SetResourcePermissionCommand{
+			Actions:           []string{"datasources:query"},
+			Resource:          "datasources",
+			ResourceID:        "2",
+			ResourceAttribute: "uid",
+		},
+	}}, ResourceHooks{})
+	require.NoError(t, err)
+
+	stats, err = store.GetUsageStats(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.AssignmentsPerResourceP95)
+}
+
+func TestIntegrationStore_CountResourcePermissions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	store, sql := setupTestEnv(t)
+	orgService, err := orgimpl.ProvideService(sql, sql.Cfg, quotatest.New(false, nil))
+	require.NoError(t, err)
+
+	cmd := func(resourceID string) SetResourcePermissionCommand {
+		return SetResourcePermissionCommand{Actions: []string{"datasources:query"}, Resource: "datasources", ResourceID: resourceID, ResourceAttribute: "uid"}
+	}
+	seedResourcePermissions(t, store, sql, orgService, cmd("1").Actions, "datasources", "1", "uid", 3, 2)
+	usrSvc, err := userimpl.ProvideService(sql, orgService, sql.Cfg, nil, nil, quotatest.New(false, nil), supportbundlestest.NewFakeBundleService())
+	require.NoError(t, err)
+	usr, err := usrSvc.Create(context.Background(), &user.CreateUserCommand{Login: "user:2:0", OrgID: 1})
+	require.NoError(t, err)
+	_, err = store.SetUserResourcePermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, cmd("2"), nil)
+	require.NoError(t, err)
+
+	_, err = store.SetTeamResourcePermission(context.Background(), 1, 1, cmd("1"), nil)
+	require.NoError(t, err)
+	// Editor is granted the resource and every resource, it is counted once
+	_, err = store.SetBuiltInResourcePermission(context.Background(), 1, "Editor", cmd("1"), nil)
+	require.NoError(t, err)
+	for _, role := range []string{"Editor", "Viewer"} {
+		_, err = store.SetBuiltInResourcePermission(context.Background(), 1, role, cmd("*"), nil)
+		require.NoError(t, err)
+	}
+	// permissions of other actions are not counted
+	_, err = store.SetTeamResourcePermission(context.Background(), 1, 2, SetResourcePermissionCommand{
+		Actions: []string{"datasources:write"}, Resource: "datasources", ResourceID: "1", ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc       string
+		resourceID string
+		expected   PermissionCounts
+	}{
+		{
+			desc:       "should count the assignees of a resource and of the wildcard scope",
+			resourceID: "1",
+			expected:   PermissionCounts{Users: 3, ServiceAccounts: 2, Teams: 1, BuiltInRoles: 2, Total: 8},
+		},
+		{
+			desc:       "should count the assignees of the wildcard scope for another resource",
+			resourceID: "2",
+			expected:   PermissionCounts{Users: 1, BuiltInRoles: 2, Total: 3},
+		},
+		{
+			desc:       "should count the assignees of the wildcard scope for a resource without permissions",
+			resourceID: "3",
+			expected:   PermissionCounts{BuiltInRoles: 2, Total: 2},
+		},
+		{
+			desc:     "should count the assignees of every resource once",
+			expected: PermissionCounts{Users: 4, ServiceAccounts: 2, Teams: 1, BuiltInRoles: 2, Total: 9},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			counts, err := store.CountResourcePermissions(context.Background(), 1, CountResourcePermissionsQuery{
+				Actions:           []string{"datasources:query"},
+				Resource:          "datasources",
+				ResourceAttribute: "uid",
+				ResourceID:        tt.resourceID,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, *counts)
+		})
+	}
+
+	t.Run("should not count the assignees of another organization", func(t *testing.T) {
+		counts, err := store.CountResourcePermissions(context.Background(), 2, CountResourcePermissionsQuery{
+			Actions:           []string{"datasources:query"},
+			Resource:          "datasources",
+			ResourceAttribute: "uid",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, PermissionCounts{}, *counts)
+	})
+}
@@ -0,0 +1,224 @@
+package resourcepermissions
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+)
+
+// translatedPermission is a permission on a resource with the organization of its role
+type translatedPermission struct {
+	ID     int64  `xorm:"id"`
+	RoleID int64  `xorm:"role_id"`
+	OrgID  int64  `xorm:"org_id"`
+	Action string `xorm:"action"`
+	Scope  string `xorm:"scope"`
+}
+
+// resourceRow is a row of a table keyed by resource id, such as the owners and the locks of the resources
+type resourceRow struct {
+	ID         int64  `xorm:"id"`
+	OrgID      int64  `xorm:"org_id"`
+	ResourceID string `xorm:"resource_id"`
+}
+
+func (s *store) TranslateResourceIDs(ctx context.Context, orgID int64, cmd TranslateResourceIDsCmd) (int64, error) {
+	start := time.Now()
+	prefix := accesscontrol.Scope(cmd.Resource, cmd.ResourceAttribute, "")
+
+	var rows int64
+	err := s.inTransaction(ctx, func(sess *db.Session) error {
+		rows = 0
+		type resourceKey struct {
+			orgID      int64
+			resourceID string
+		}
+		translated := map[resourceKey]string{}
+		translate := func(orgID int64, resourceID string) (string, error) {
+			key := resourceKey{orgID, resourceID}
+			if id, ok := translated[key]; ok {
+				return id, nil
+			}
+			id, err := cmd.Translate(orgID, resourceID)
+			if err != nil {
+				return "", err
+			}
+			translated[key] = id
+			return id, nil
+		}
+
+		permissions, err := s.getTranslatedPermissions(sess, orgID, prefix, cmd.ResourceIDs)
+		if err != nil {
+			return err
+		}
+
+		var changed []translatedPermission
+		for _, p := range permissions {
+			resourceID := strings.TrimPrefix(p.Scope, prefix)
+			if resourceID == "*" {
+				continue
+			}
+			id, err := translate(p.OrgID, resourceID)
+			if err != nil {
+				return err
+			}
+			if id != resourceID {
+				p.Scope = prefix + id
+				changed = append(changed, p)
+			}
+		}
+
+		if err := s.rewritePermissionScopes(sess, prefix, changed); err != nil {
+			return err
+		}
+		rows = int64(len(changed))
+
+		for _, table := range []string{ResourceOwner{}.TableName(), PermissionsLock{}.TableName()} {
+			if err := s.translateResourceRows(sess, table, orgID, cmd, translate); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	s.metrics.observe(operationTranslate, s.dialect(), start, err)
+	if err == nil {
+		s.metrics.addRowsAffected(operationTranslate, s.dialect(), rows)
+	}
+	return rows, err
+}
+
+// getTranslatedPermissions returns the permissions on the resources with the scope prefix, restricted to the
+// resources of resourceIDs when it is not empty
+func (s *store) getTranslatedPermissions(sess *db.Session, orgID int64, prefix string, resourceIDs []string) ([]translatedPermission, error) {
+	rawSQL := "SELECT p.id, p.role_id, r.org_id, p.action, p.scope FROM permission p INNER JOIN role r ON r.id = p.role_id WHERE p.scope LIKE ?"
+	args := []any{prefix + "%"}
+	if orgID != 0 {
+		rawSQL += " AND r.org_id = ?"
+		args = append(args, orgID)
+	}
+
+	var permissions []translatedPermission
+	if len(resourceIDs) == 0 {
+		err := sess.SQL(rawSQL+s.forUpdate(), args...).Find(&permissions)
+		return permissions, err
+	}
+
+	for _, chunk := range chunks(resourceIDs, s.maxPlaceholders()-len(args)) {
+		scopes := make([]any, 0, len(chunk))
+		for _, id := range chunk {
+			scopes = append(scopes, prefix+id)
+		}
+		var found []translatedPermission
+		chunkSQL := rawSQL + " AND p.scope IN (?" + strings.Repeat(",?", len(chunk)-1) + ")" + s.forUpdate()
+		if err := sess.SQL(chunkSQL, append(append([]any{}, args...), scopes...)...).Find(&found); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, found...)
+	}
+	return permissions, nil
+}
+
+// rewritePermissionScopes stores the scopes of changed, a permission whose role is granted its action on its new
+// scope already is deleted
+func (s *store) rewritePermissionScopes(sess *db.Session, prefix string, changed []translatedPermission) error {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	roleIDs := make([]int64, 0, len(changed))
+	for _, p := range changed {
+		roleIDs = append(roleIDs, p.RoleID)
+	}
+	existing := make(map[accesscontrol.Permission]struct{}, len(changed))
+	for _, chunk := range chunks(roleIDs, s.maxPlaceholders()-1) {
+		var stored []accesscontrol.Permission
+		rawSQL := "SELECT role_id, action, scope FROM permission WHERE scope LIKE ? AND role_id IN (?" + strings.Repeat(",?", len(chunk)-1) + ")"
+		if err := sess.SQL(rawSQL, append([]any{prefix + "%"}, int64Args(chunk)...)...).Find(&stored); err != nil {
+			return err
+		}
+		for _, p := range stored {
+			existing[accesscontrol.Permission{RoleID: p.RoleID, Action: p.Action, Scope: p.Scope}] = struct{}{}
+		}
+	}
+
+	var remove []int64
+	now := time.Now()
+	for _, p := range changed {
+		rewritten := accesscontrol.Permission{RoleID: p.RoleID, Action: p.Action, Scope: p.Scope}
+		if _, ok := existing[rewritten]; ok {
+			remove = append(remove, p.ID)
+			continue
+		}
+		existing[rewritten] = struct{}{}
+
+		if s.features.IsEnabledGlobally(featuremgmt.FlagSplitScopes) {
+			rewritten.Kind, rewritten.Attribute, rewritten.Identifier = rewritten.SplitScope()
+		}
+		if _, err := sess.Exec("UPDATE permission SET scope = ?, kind = ?, attribute = ?, identifier = ?, updated = ? WHERE id = ?",
+			rewritten.Scope, rewritten.Kind, rewritten.Attribute, rewritten.Identifier, now, p.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, chunk := range chunks(remove, s.maxPlaceholders()) {
+		if err := deletePermissions(sess, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// translateResourceRows rewrites the resource ids of the rows of table, a row whose rewritten id has a row already
+// is deleted
+func (s *store) translateResourceRows(sess *db.Session, table string, orgID int64, cmd TranslateResourceIDsCmd, translate func(orgID int64, resourceID string) (string, error)) error {
+	rawSQL := "SELECT id, org_id, resource_id FROM " + table + " WHERE resource = ?"
+	args := []any{cmd.Resource}
+	if orgID != 0 {
+		rawSQL += " AND org_id = ?"
+		args = append(args, orgID)
+	}
+
+	var rows []resourceRow
+	if len(cmd.ResourceIDs) == 0 {
+		if err := sess.SQL(rawSQL+s.forUpdate(), args...).Find(&rows); err != nil {
+			return err
+		}
+	}
+	for _, chunk := range chunks(cmd.ResourceIDs, s.maxPlaceholders()-len(args)) {
+		var found []resourceRow
+		chunkSQL := rawSQL + " AND resource_id IN (?" + strings.Repeat(",?", len(chunk)-1) + ")" + s.forUpdate()
+		if err := sess.SQL(chunkSQL, append(append([]any{}, args...), stringArgs(chunk)...)...).Find(&found); err != nil {
+			return err
+		}
+		rows = append(rows, found...)
+	}
+
+	for _, row := range rows {
+		id, err := translate(row.OrgID, row.ResourceID)
+		if err != nil {
+			return err
+		}
+		if id == row.ResourceID {
+			continue
+		}
+
+		exists, err := sess.Table(table).Where("org_id = ? AND resource = ? AND resource_id = ?", row.OrgID, cmd.Resource, id).Exist()
+		if err != nil {
+			return err
+		}
+		if exists {
+			_, err = sess.Exec("DELETE FROM "+table+" WHERE id = ?", row.ID)
+		} else {
+			_, err = sess.Exec("UPDATE "+table+" SET resource_id = ? WHERE id = ?", id, row.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
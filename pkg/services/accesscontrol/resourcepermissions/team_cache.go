@@ -0,0 +1,151 @@
+package resourcepermissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/team"
+)
+
+// teamCache holds the teams of an organization resolved during a single call, so that each team is fetched at most
+// once even when a call validates the same teams several times, e.g. RestorePermissions and the SetPermissions call
+// it makes. It is carried on the context with withTeamCache and only lives as long as the call.
+type teamCache struct {
+	mu      sync.Mutex
+	orgID   int64
+	exists  map[int64]bool
+	members map[int64][]int64
+}
+
+type teamCacheKey struct{}
+
+// withTeamCache returns a context carrying a team cache for orgID, the context is returned as is if it already
+// carries one for the same organization
+func withTeamCache(ctx context.Context, orgID int64) context.Context {
+	if getTeamCache(ctx, orgID) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, teamCacheKey{}, &teamCache{
+		orgID:   orgID,
+		exists:  map[int64]bool{},
+		members: map[int64][]int64{},
+	})
+}
+
+// getTeamCache returns the team cache of the context for orgID, or nil
+func getTeamCache(ctx context.Context, orgID int64) *teamCache {
+	c, ok := ctx.Value(teamCacheKey{}).(*teamCache)
+	if !ok || c.orgID != orgID {
+		return nil
+	}
+	return c
+}
+
+// prefetchTeams resolves the existing teams of the cache of the context that are not resolved yet with a single query
+func (s *Service) prefetchTeams(ctx context.Context, orgID int64, teamIDs []int64) error {
+	c := getTeamCache(ctx, orgID)
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	missing := make([]int64, 0, len(teamIDs))
+	seen := make(map[int64]struct{}, len(teamIDs))
+	for _, id := range teamIDs {
+		if _, ok := c.exists[id]; ok {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		missing = append(missing, id)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	result, err := s.teamService.SearchTeams(ctx, &team.SearchTeamsQuery{
+		OrgID:   orgID,
+		TeamIds: missing,
+		SignedInUser: accesscontrol.BackgroundUser("resource_permissions_teams", orgID, org.RoleAdmin, []accesscontrol.Permission{
+			{Action: accesscontrol.ActionTeamsRead, Scope: accesscontrol.ScopeTeamsAll},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get teams: %w", err)
+	}
+
+	// teams missing from the result are left unresolved and looked up one by one, so that a search filtering
+	// or limiting its result never reports an existing team as deleted
+	for _, t := range result.Teams {
+		c.exists[t.ID] = true
+	}
+	return nil
+}
+
+// teamExists reports whether a team of the organization exists, through the cache of the context when there is one
+func (s *Service) teamExists(ctx context.Context, orgID, teamID int64) (bool, error) {
+	c := getTeamCache(ctx, orgID)
+	if c != nil {
+		c.mu.Lock()
+		exists, ok := c.exists[teamID]
+		c.mu.Unlock()
+		if ok {
+			return exists, nil
+		}
+	}
+
+	_, err := s.teamService.GetTeamByID(ctx, &team.GetTeamByIDQuery{OrgID: orgID, ID: teamID})
+	if err != nil && !errors.Is(err, team.ErrTeamNotFound) {
+		return false, err
+	}
+
+	if c != nil {
+		c.mu.Lock()
+		c.exists[teamID] = err == nil
+		c.mu.Unlock()
+	}
+	return err == nil, nil
+}
+
+// getTeamMemberIDs returns the ids of the members of a team, through the cache of the context when there is one
+func (s *Service) getTeamMemberIDs(ctx context.Context, orgID, teamID int64) ([]int64, error) {
+	c := getTeamCache(ctx, orgID)
+	if c != nil {
+		c.mu.Lock()
+		members, ok := c.members[teamID]
+		c.mu.Unlock()
+		if ok {
+			return members, nil
+		}
+	}
+
+	result, err := s.teamService.GetTeamMembers(ctx, &team.GetTeamMembersQuery{
+		OrgID:  orgID,
+		TeamID: teamID,
+		SignedInUser: accesscontrol.BackgroundUser("resource_permissions", orgID, org.RoleAdmin, []accesscontrol.Permission{
+			{Action: accesscontrol.ActionOrgUsersRead, Scope: accesscontrol.ScopeUsersAll},
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]int64, 0, len(result))
+	for _, m := range result {
+		members = append(members, m.UserID)
+	}
+	if c != nil {
+		c.mu.Lock()
+		c.members[teamID] = members
+		c.mu.Unlock()
+	}
+	return members, nil
+}
@@ -0,0 +1,159 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/team"
+)
+
+// countingTeamService counts the team lookups made by the service
+type countingTeamService struct {
+	team.Service
+	mu      sync.Mutex
+	byID    map[int64]int
+	members map[int64]int
+	search  int
+}
+
+func newCountingTeamService(svc team.Service) *countingTeamService {
+	return &countingTeamService{Service: svc, byID: map[int64]int{}, members: map[int64]int{}}
+}
+
+func (s *countingTeamService) GetTeamByID(ctx context.Context, query *team.GetTeamByIDQuery) (*team.TeamDTO, error) {
+	s.mu.Lock()
+	s.byID[query.ID]++
+	s.mu.Unlock()
+	return s.Service.GetTeamByID(ctx, query)
+}
+
+func (s *countingTeamService) GetTeamMembers(ctx context.Context, query *team.GetTeamMembersQuery) ([]*team.TeamMemberDTO, error) {
+	s.mu.Lock()
+	s.members[query.TeamID]++
+	s.mu.Unlock()
+	return s.Service.GetTeamMembers(ctx, query)
+}
+
+func (s *countingTeamService) SearchTeams(ctx context.Context, query *team.SearchTeamsQuery) (team.SearchTeamQueryResult, error) {
+	s.mu.Lock()
+	s.search++
+	s.mu.Unlock()
+	return s.Service.SearchTeams(ctx, query)
+}
+
+func (s *countingTeamService) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byID, s.members, s.search = map[int64]int{}, map[int64]int{}, 0
+}
+
+// noSearchTeamService finds no team on search, like a search limited to teams the caller cannot see
+type noSearchTeamService struct {
+	team.Service
+}
+
+func (s noSearchTeamService) SearchTeams(ctx context.Context, query *team.SearchTeamsQuery) (team.SearchTeamQueryResult, error) {
+	return team.SearchTeamQueryResult{}, nil
+}
+
+func TestService_TeamCache(t *testing.T) {
+	service, _, teamSvc := setupTestEnvironment(t, testOptions)
+	counting := newCountingTeamService(teamSvc)
+	service.teamService = counting
+
+	var commands []accesscontrol.SetResourcePermissionCommand
+	for i := 0; i < 5; i++ {
+		tm, err := teamSvc.CreateTeam(fmt.Sprintf("team-%d", i), "", 1)
+		require.NoError(t, err)
+		commands = append(commands, accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "View"})
+	}
+
+	t.Run("should fetch each team once when setting permissions", func(t *testing.T) {
+		counting.reset()
+		_, err := service.SetPermissions(context.Background(), 1, "1", commands...)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, counting.search)
+		assert.Empty(t, counting.byID)
+		for _, cmd := range commands {
+			assert.Equal(t, 1, counting.members[cmd.TeamID])
+		}
+	})
+
+	t.Run("should fetch each team once when restoring permissions", func(t *testing.T) {
+		snapshot, err := service.SnapshotPermissions(context.Background(), 1, "1")
+		require.NoError(t, err)
+		for i := range snapshot.Permissions {
+			snapshot.Permissions[i].Permission = "Edit"
+		}
+
+		counting.reset()
+		_, err = service.RestorePermissions(context.Background(), 1, "1", *snapshot)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, counting.search)
+		assert.Empty(t, counting.byID)
+		for _, cmd := range commands {
+			assert.Equal(t, 1, counting.members[cmd.TeamID])
+		}
+	})
+
+	t.Run("should report teams that do not exist", func(t *testing.T) {
+		_, err := service.SetPermissions(context.Background(), 1, "1", accesscontrol.SetResourcePermissionCommand{TeamID: 999, Permission: "View"})
+		assert.ErrorIs(t, err, ErrAssigneeNotFound)
+	})
+
+	t.Run("should look up teams missing from the search one by one", func(t *testing.T) {
+		service.teamService = noSearchTeamService{counting}
+		defer func() { service.teamService = counting }()
+
+		counting.reset()
+		_, err := service.SetPermissions(context.Background(), 1, "1", commands...)
+		require.NoError(t, err)
+		for _, cmd := range commands {
+			assert.Equal(t, 1, counting.byID[cmd.TeamID])
+		}
+	})
+
+	t.Run("should not share the cache between calls", func(t *testing.T) {
+		counting.reset()
+		_, err := service.SetTeamPermission(context.Background(), 1, commands[0].TeamID, "1", "Edit")
+		require.NoError(t, err)
+		_, err = service.SetTeamPermission(context.Background(), 1, commands[0].TeamID, "1", "View")
+		require.NoError(t, err)
+		assert.Equal(t, 2, counting.byID[commands[0].TeamID])
+	})
+}
+
+// BenchmarkService_SetPermissions200Teams sets the permissions of 200 teams on a folder at once, the teams are
+// resolved with a single query
+func BenchmarkService_SetPermissions200Teams(b *testing.B) {
+	service, _, teamSvc := setupTestEnvironment(b, testOptions)
+
+	commands := make([]accesscontrol.SetResourcePermissionCommand, 0, 200)
+	for i := 0; i < 200; i++ {
+		tm, err := teamSvc.CreateTeam(fmt.Sprintf("team-%d", i), "", 1)
+		require.NoError(b, err)
+		commands = append(commands, accesscontrol.SetResourcePermissionCommand{TeamID: tm.ID, Permission: "View"})
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		// alternate permissions so every iteration writes
+		permission := "View"
+		if i%2 == 0 {
+			permission = "Edit"
+		}
+		for j := range commands {
+			commands[j].Permission = permission
+		}
+		_, err := service.SetPermissions(context.Background(), 1, "1", commands...)
+		require.NoError(b, err)
+	}
+}
@@ -0,0 +1,565 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+// ScopeTranslator translates scopes between the form used by the HTTP API and the form they are stored with, e.g.
+// datasources:uid:abc and datasources:uid:tenant1/abc. It is called with the scopes of the resource and of its ancestors.
+type ScopeTranslator interface {
+	// ToStored returns the stored form of scope
+	ToStored(ctx context.Context, orgID int64, scope string) (string, error)
+	// FromStored returns the scope a stored scope was translated from. Scopes that were not translated, such as the
+	// ones stored before the translator was configured, must be returned unchanged
+	FromStored(ctx context.Context, orgID int64, scope string) (string, error)
+}
+
+// TranslateStoredScopes rewrites the permissions, owners and locks stored with untranslated resource ids to the
+// translated ones, in every organization, and returns the number of permissions rewritten. It is meant to be run
+// once, e.g. from a migration, after which MatchUntranslatedScopes can be disabled. Scopes that
+// Options.ScopeTranslator translates back and forth to themselves are already translated and are kept.
+func (s *Service) TranslateStoredScopes(ctx context.Context) (int64, error) {
+	t, ok := s.store.(*translatingStore)
+	if !ok {
+		return 0, nil
+	}
+
+	return t.Store.TranslateResourceIDs(ctx, 0, TranslateResourceIDsCmd{
+		Resource:          s.options.Resource,
+		ResourceAttribute: s.options.ResourceAttribute,
+		Translate: func(orgID int64, resourceID string) (string, error) {
+			untranslated, err := t.fromStoredID(ctx, orgID, s.options.Resource, s.options.ResourceAttribute, resourceID)
+			if err != nil {
+				return "", err
+			}
+			if stored, err := t.toStoredID(ctx, orgID, s.options.Resource, s.options.ResourceAttribute, untranslated); err != nil || stored == resourceID {
+				return resourceID, err
+			}
+			return t.toStoredID(ctx, orgID, s.options.Resource, s.options.ResourceAttribute, resourceID)
+		},
+	})
+}
+
+// translatingStore is the Store of a Service with an Options.ScopeTranslator. It translates the resource ids and
+// the inherited scopes before passing them to the store, and translates back the scopes, resource ids and hook
+// arguments the store returns, so that the Service only deals with untranslated resource ids.
+type translatingStore struct {
+	Store
+	translator ScopeTranslator
+	// resourceAttribute is the attribute of the scopes of the resources of the owners and the locks
+	resourceAttribute string
+	// matchUntranslated rewrites the permissions of a resource stored with its untranslated id before accessing them
+	matchUntranslated bool
+	// translated records the resources rewritten by the instance, they are rewritten once
+	translated sync.Map
+}
+
+func newTranslatingStore(store Store, options Options) *translatingStore {
+	return &translatingStore{
+		Store:             store,
+		translator:        options.ScopeTranslator,
+		resourceAttribute: options.ResourceAttribute,
+		matchUntranslated: options.MatchUntranslatedScopes,
+	}
+}
+
+// toStoredID returns the id of a resource in its stored scope
+func (t *translatingStore) toStoredID(ctx context.Context, orgID int64, resource, resourceAttribute, resourceID string) (string, error) {
+	prefix := accesscontrol.Scope(resource, resourceAttribute, "")
+	scope, err := t.translator.ToStored(ctx, orgID, prefix+resourceID)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(scope, prefix) {
+		return "", fmt.Errorf("%w: %s translated to %s", ErrInvalidScopeTranslation, prefix+resourceID, scope)
+	}
+	return strings.TrimPrefix(scope, prefix), nil
+}
+
+// fromStoredID returns the untranslated id of a resource from its id in its stored scope
+func (t *translatingStore) fromStoredID(ctx context.Context, orgID int64, resource, resourceAttribute, resourceID string) (string, error) {
+	prefix := accesscontrol.Scope(resource, resourceAttribute, "")
+	scope, err := t.translator.FromStored(ctx, orgID, prefix+resourceID)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(scope, prefix) {
+		return "", fmt.Errorf("%w: %s translated from %s", ErrInvalidScopeTranslation, prefix+resourceID, scope)
+	}
+	return strings.TrimPrefix(scope, prefix), nil
+}
+
+func (t *translatingStore) toStoredScopes(ctx context.Context, orgID int64, scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return scopes, nil
+	}
+	stored := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		s, err := t.translator.ToStored(ctx, orgID, scope)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, s)
+	}
+	return stored, nil
+}
+
+func (t *translatingStore) fromStoredPermissions(ctx context.Context, orgID int64, permissions []accesscontrol.ResourcePermission) error {
+	for i := range permissions {
+		scope, err := t.translator.FromStored(ctx, orgID, permissions[i].Scope)
+		if err != nil {
+			return err
+		}
+		permissions[i].Scope = scope
+	}
+	return nil
+}
+
+func (t *translatingStore) fromStoredPermission(ctx context.Context, orgID int64, permission *accesscontrol.ResourcePermission, err error) (*accesscontrol.ResourcePermission, error) {
+	if err != nil || permission == nil {
+		return permission, err
+	}
+	result := *permission
+	result.Scope, err = t.translator.FromStored(ctx, orgID, permission.Scope)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// migrate rewrites the permissions, owners and locks stored with the untranslated ids of resources once per
+// resource, when untranslated scopes are matched
+func (t *translatingStore) migrate(ctx context.Context, orgID int64, resource, resourceAttribute string, resourceIDs ...string) error {
+	if !t.matchUntranslated {
+		return nil
+	}
+
+	pending := make([]string, 0, len(resourceIDs))
+	for _, id := range resourceIDs {
+		if _, ok := t.translated.Load(migrationKey(orgID, resource, id)); !ok {
+			pending = append(pending, id)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	_, err := t.Store.TranslateResourceIDs(ctx, orgID, TranslateResourceIDsCmd{
+		Resource:          resource,
+		ResourceAttribute: resourceAttribute,
+		ResourceIDs:       pending,
+		Translate: func(orgID int64, resourceID string) (string, error) {
+			return t.toStoredID(ctx, orgID, resource, resourceAttribute, resourceID)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range pending {
+		t.translated.Store(migrationKey(orgID, resource, id), struct{}{})
+	}
+	return nil
+}
+
+func migrationKey(orgID int64, resource, resourceID string) string {
+	return fmt.Sprintf("%d:%s:%s", orgID, resource, resourceID)
+}
+
+// toStoredCommand migrates the resource of cmd and returns cmd with the stored id of the resource
+func (t *translatingStore) toStoredCommand(ctx context.Context, orgID int64, cmd SetResourcePermissionCommand) (SetResourcePermissionCommand, error) {
+	if err := t.migrate(ctx, orgID, cmd.Resource, cmd.ResourceAttribute, cmd.ResourceID); err != nil {
+		return cmd, err
+	}
+	id, err := t.toStoredID(ctx, orgID, cmd.Resource, cmd.ResourceAttribute, cmd.ResourceID)
+	cmd.ResourceID = id
+	return cmd, err
+}
+
+func (t *translatingStore) SetUserResourcePermission(
+	ctx context.Context, orgID int64, user accesscontrol.User,
+	cmd SetResourcePermissionCommand,
+	hook UserResourceHookFunc,
+) (*accesscontrol.ResourcePermission, error) {
+	stored, err := t.toStoredCommand(ctx, orgID, cmd)
+	if err != nil {
+		return nil, err
+	}
+	permission, err := t.Store.SetUserResourcePermission(ctx, orgID, user, stored, t.userHook(ctx, cmd, hook))
+	return t.fromStoredPermission(ctx, orgID, permission, err)
+}
+
+func (t *translatingStore) SetTeamResourcePermission(
+	ctx context.Context, orgID, teamID int64,
+	cmd SetResourcePermissionCommand,
+	hook TeamResourceHookFunc,
+) (*accesscontrol.ResourcePermission, error) {
+	stored, err := t.toStoredCommand(ctx, orgID, cmd)
+	if err != nil {
+		return nil, err
+	}
+	permission, err := t.Store.SetTeamResourcePermission(ctx, orgID, teamID, stored, t.teamHook(ctx, cmd, hook))
+	return t.fromStoredPermission(ctx, orgID, permission, err)
+}
+
+func (t *translatingStore) SetBuiltInResourcePermission(
+	ctx context.Context, orgID int64, builtinRole string,
+	cmd SetResourcePermissionCommand,
+	hook BuiltinResourceHookFunc,
+) (*accesscontrol.ResourcePermission, error) {
+	stored, err := t.toStoredCommand(ctx, orgID, cmd)
+	if err != nil {
+		return nil, err
+	}
+	permission, err := t.Store.SetBuiltInResourcePermission(ctx, orgID, builtinRole, stored, t.builtInRoleHook(ctx, cmd, hook))
+	return t.fromStoredPermission(ctx, orgID, permission, err)
+}
+
+func (t *translatingStore) SetResourcePermissions(
+	ctx context.Context, orgID int64,
+	commands []SetResourcePermissionsCommand,
+	hooks ResourceHooks,
+) ([]SetResourcePermissionsResult, error) {
+	if len(commands) == 0 {
+		return t.Store.SetResourcePermissions(ctx, orgID, commands, hooks)
+	}
+
+	stored := make([]SetResourcePermissionsCommand, 0, len(commands))
+	for _, cmd := range commands {
+		var err error
+		if cmd.SetResourcePermissionCommand, err = t.toStoredCommand(ctx, orgID, cmd.SetResourcePermissionCommand); err != nil {
+			return nil, err
+		}
+		stored = append(stored, cmd)
+	}
+
+	results, err := t.Store.SetResourcePermissions(ctx, orgID, stored, t.hooks(ctx, commands[0].SetResourcePermissionCommand, hooks))
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if results[i].Permission.Scope, err = t.translator.FromStored(ctx, orgID, results[i].Permission.Scope); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (t *translatingStore) SetUserResourcePermissionForResources(
+	ctx context.Context, orgID int64, user accesscontrol.User,
+	commands []SetResourcePermissionCommand,
+	hook UserResourceHookFunc,
+) ([]ResourcePermissionResult, error) {
+	if len(commands) == 0 {
+		return t.Store.SetUserResourcePermissionForResources(ctx, orgID, user, commands, hook)
+	}
+
+	untranslated := make(map[string]string, len(commands))
+	stored := make([]SetResourcePermissionCommand, 0, len(commands))
+	for _, cmd := range commands {
+		s, err := t.toStoredCommand(ctx, orgID, cmd)
+		if err != nil {
+			return nil, err
+		}
+		untranslated[s.ResourceID] = cmd.ResourceID
+		stored = append(stored, s)
+	}
+
+	results, err := t.Store.SetUserResourcePermissionForResources(ctx, orgID, user, stored, t.userHook(ctx, commands[0], hook))
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].ResourceID = untranslated[results[i].ResourceID]
+		if results[i].Permission, err = t.fromStoredPermission(ctx, orgID, results[i].Permission, nil); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// toStoredQuery migrates the resource of query and returns query with the stored id of the resource and the stored
+// inherited scopes
+func (t *translatingStore) toStoredQuery(ctx context.Context, orgID int64, query GetResourcePermissionsQuery) (GetResourcePermissionsQuery, error) {
+	if err := t.migrate(ctx, orgID, query.Resource, query.ResourceAttribute, query.ResourceID); err != nil {
+		return query, err
+	}
+	var err error
+	if query.ResourceID, err = t.toStoredID(ctx, orgID, query.Resource, query.ResourceAttribute, query.ResourceID); err != nil {
+		return query, err
+	}
+	query.InheritedScopes, err = t.toStoredScopes(ctx, orgID, query.InheritedScopes)
+	return query, err
+}
+
+func (t *translatingStore) GetResourcePermissions(ctx context.Context, orgID int64, query GetResourcePermissionsQuery) ([]accesscontrol.ResourcePermission, error) {
+	stored, err := t.toStoredQuery(ctx, orgID, query)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := t.Store.GetResourcePermissions(ctx, orgID, stored)
+	if err != nil {
+		return nil, err
+	}
+	return permissions, t.fromStoredPermissions(ctx, orgID, permissions)
+}
+
+func (t *translatingStore) GetResourcePermissionsPage(
+	ctx context.Context, orgID int64,
+	query GetResourcePermissionsQuery,
+	opts ResourcePermissionsQueryOptions,
+) (*ResourcePermissionsPage, error) {
+	stored, err := t.toStoredQuery(ctx, orgID, query)
+	if err != nil {
+		return nil, err
+	}
+	page, err := t.Store.GetResourcePermissionsPage(ctx, orgID, stored, opts)
+	if err != nil {
+		return nil, err
+	}
+	return page, t.fromStoredPermissions(ctx, orgID, page.Permissions)
+}
+
+func (t *translatingStore) SetAssignmentResourcePermission(
+	ctx context.Context, orgID int64, kind, assigneeID string,
+	cmd SetResourcePermissionCommand,
+	bind AssignmentRoleBinderFunc,
+) (*accesscontrol.ResourcePermission, error) {
+	stored, err := t.toStoredCommand(ctx, orgID, cmd)
+	if err != nil {
+		return nil, err
+	}
+	permission, err := t.Store.SetAssignmentResourcePermission(ctx, orgID, kind, assigneeID, stored, bind)
+	return t.fromStoredPermission(ctx, orgID, permission, err)
+}
+
+func (t *translatingStore) GetAssignmentResourcePermissions(ctx context.Context, orgID int64, kind string, query GetResourcePermissionsQuery) ([]accesscontrol.ResourcePermission, error) {
+	stored, err := t.toStoredQuery(ctx, orgID, query)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := t.Store.GetAssignmentResourcePermissions(ctx, orgID, kind, stored)
+	if err != nil {
+		return nil, err
+	}
+	return permissions, t.fromStoredPermissions(ctx, orgID, permissions)
+}
+
+func (t *translatingStore) ListUsersWithAccess(ctx context.Context, orgID int64, query ListUsersWithAccessQuery, fn func(userID int64) error) error {
+	if err := t.migrate(ctx, orgID, query.Resource, query.ResourceAttribute, query.ResourceID); err != nil {
+		return err
+	}
+	var err error
+	if query.ResourceID, err = t.toStoredID(ctx, orgID, query.Resource, query.ResourceAttribute, query.ResourceID); err != nil {
+		return err
+	}
+	if query.InheritedScopes, err = t.toStoredScopes(ctx, orgID, query.InheritedScopes); err != nil {
+		return err
+	}
+	return t.Store.ListUsersWithAccess(ctx, orgID, query, fn)
+}
+
+func (t *translatingStore) CountResourcePermissions(ctx context.Context, orgID int64, query CountResourcePermissionsQuery) (*PermissionCounts, error) {
+	if query.ResourceID == "" {
+		return t.Store.CountResourcePermissions(ctx, orgID, query)
+	}
+	if err := t.migrate(ctx, orgID, query.Resource, query.ResourceAttribute, query.ResourceID); err != nil {
+		return nil, err
+	}
+	var err error
+	if query.ResourceID, err = t.toStoredID(ctx, orgID, query.Resource, query.ResourceAttribute, query.ResourceID); err != nil {
+		return nil, err
+	}
+	return t.Store.CountResourcePermissions(ctx, orgID, query)
+}
+
+func (t *translatingStore) DeleteResourcePermissions(ctx context.Context, orgID int64, cmd *DeleteResourcePermissionsCmd, hooks ResourceHooks) error {
+	if err := t.migrate(ctx, orgID, cmd.Resource, cmd.ResourceAttribute, cmd.ResourceID); err != nil {
+		return err
+	}
+	stored := *cmd
+	var err error
+	if stored.ResourceID, err = t.toStoredID(ctx, orgID, cmd.Resource, cmd.ResourceAttribute, cmd.ResourceID); err != nil {
+		return err
+	}
+	resource := SetResourcePermissionCommand{Resource: cmd.Resource, ResourceAttribute: cmd.ResourceAttribute}
+	return t.Store.DeleteResourcePermissions(ctx, orgID, &stored, t.hooks(ctx, resource, hooks))
+}
+
+func (t *translatingStore) DeleteDanglingAssignments(ctx context.Context, orgID int64, cmd DeleteDanglingAssignmentsCmd) ([]DanglingAssignment, error) {
+	assignments, err := t.Store.DeleteDanglingAssignments(ctx, orgID, cmd)
+	for i := range assignments {
+		id, idErr := t.fromStoredID(ctx, assignments[i].OrgID, cmd.Resource, cmd.ResourceAttribute, assignments[i].ResourceID)
+		if idErr != nil {
+			return assignments, idErr
+		}
+		assignments[i].ResourceID = id
+	}
+	return assignments, err
+}
+
+func (t *translatingStore) DeleteUserAssignments(ctx context.Context, orgID int64, cmd DeleteUserAssignmentsCmd, hook UserResourceHookFunc) ([]UserAssignment, error) {
+	resource := SetResourcePermissionCommand{Resource: cmd.Resource, ResourceAttribute: cmd.ResourceAttribute}
+	assignments, err := t.Store.DeleteUserAssignments(ctx, orgID, cmd, t.userHook(ctx, resource, hook))
+	for i := range assignments {
+		id, idErr := t.fromStoredID(ctx, assignments[i].OrgID, cmd.Resource, cmd.ResourceAttribute, assignments[i].ResourceID)
+		if idErr != nil {
+			return assignments, idErr
+		}
+		assignments[i].ResourceID = id
+	}
+	return assignments, err
+}
+
+func (t *translatingStore) LockResourcePermissions(ctx context.Context, orgID int64, lock PermissionsLock) error {
+	if err := t.migrate(ctx, orgID, lock.Resource, t.resourceAttribute, lock.ResourceID); err != nil {
+		return err
+	}
+	var err error
+	if lock.ResourceID, err = t.toStoredID(ctx, orgID, lock.Resource, t.resourceAttribute, lock.ResourceID); err != nil {
+		return err
+	}
+	return t.Store.LockResourcePermissions(ctx, orgID, lock)
+}
+
+func (t *translatingStore) UnlockResourcePermissions(ctx context.Context, orgID int64, resource, resourceID string) error {
+	if err := t.migrate(ctx, orgID, resource, t.resourceAttribute, resourceID); err != nil {
+		return err
+	}
+	stored, err := t.toStoredID(ctx, orgID, resource, t.resourceAttribute, resourceID)
+	if err != nil {
+		return err
+	}
+	return t.Store.UnlockResourcePermissions(ctx, orgID, resource, stored)
+}
+
+func (t *translatingStore) GetResourcePermissionsLock(ctx context.Context, orgID int64, resource, resourceID string) (*PermissionsLock, error) {
+	if err := t.migrate(ctx, orgID, resource, t.resourceAttribute, resourceID); err != nil {
+		return nil, err
+	}
+	stored, err := t.toStoredID(ctx, orgID, resource, t.resourceAttribute, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := t.Store.GetResourcePermissionsLock(ctx, orgID, resource, stored)
+	if lock != nil {
+		lock.ResourceID = resourceID
+	}
+	return lock, err
+}
+
+func (t *translatingStore) SetResourceOwner(
+	ctx context.Context, orgID int64, owner accesscontrol.User,
+	cmd SetResourcePermissionCommand,
+	hook UserResourceHookFunc,
+) (*ResourceOwner, error) {
+	stored, err := t.toStoredCommand(ctx, orgID, cmd)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := t.Store.SetResourceOwner(ctx, orgID, owner, stored, t.userHook(ctx, cmd, hook))
+	if previous != nil {
+		previous.ResourceID = cmd.ResourceID
+	}
+	return previous, err
+}
+
+func (t *translatingStore) GetResourceOwner(ctx context.Context, orgID int64, resource, resourceID string) (*ResourceOwner, error) {
+	if err := t.migrate(ctx, orgID, resource, t.resourceAttribute, resourceID); err != nil {
+		return nil, err
+	}
+	stored, err := t.toStoredID(ctx, orgID, resource, t.resourceAttribute, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := t.Store.GetResourceOwner(ctx, orgID, resource, stored)
+	if owner != nil {
+		owner.ResourceID = resourceID
+	}
+	return owner, err
+}
+
+// hooks returns the hooks called with the stored ids of resources, they call hooks with the untranslated ids
+func (t *translatingStore) hooks(ctx context.Context, resource SetResourcePermissionCommand, hooks ResourceHooks) ResourceHooks {
+	fromStored := func(orgID int64, resourceID string) (string, error) {
+		return t.fromStoredID(ctx, orgID, resource.Resource, resource.ResourceAttribute, resourceID)
+	}
+
+	translated := ResourceHooks{
+		User:        t.userHook(ctx, resource, hooks.User),
+		Team:        t.teamHook(ctx, resource, hooks.Team),
+		BuiltInRole: t.builtInRoleHook(ctx, resource, hooks.BuiltInRole),
+	}
+	if hooks.UserRemoved != nil {
+		translated.UserRemoved = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID string) error {
+			id, err := fromStored(orgID, resourceID)
+			if err != nil {
+				return err
+			}
+			return hooks.UserRemoved(session, orgID, user, id)
+		}
+	}
+	if hooks.TeamRemoved != nil {
+		translated.TeamRemoved = func(session *db.Session, orgID, teamID int64, resourceID string) error {
+			id, err := fromStored(orgID, resourceID)
+			if err != nil {
+				return err
+			}
+			return hooks.TeamRemoved(session, orgID, teamID, id)
+		}
+	}
+	if hooks.BuiltInRoleRemoved != nil {
+		translated.BuiltInRoleRemoved = func(session *db.Session, orgID int64, builtInRole, resourceID string) error {
+			id, err := fromStored(orgID, resourceID)
+			if err != nil {
+				return err
+			}
+			return hooks.BuiltInRoleRemoved(session, orgID, builtInRole, id)
+		}
+	}
+	return translated
+}
+
+func (t *translatingStore) userHook(ctx context.Context, resource SetResourcePermissionCommand, hook UserResourceHookFunc) UserResourceHookFunc {
+	if hook == nil {
+		return nil
+	}
+	return func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+		id, err := t.fromStoredID(ctx, orgID, resource.Resource, resource.ResourceAttribute, resourceID)
+		if err != nil {
+			return err
+		}
+		return hook(session, orgID, user, id, permission)
+	}
+}
+
+func (t *translatingStore) teamHook(ctx context.Context, resource SetResourcePermissionCommand, hook TeamResourceHookFunc) TeamResourceHookFunc {
+	if hook == nil {
+		return nil
+	}
+	return func(session *db.Session, orgID, teamID int64, resourceID, permission string) error {
+		id, err := t.fromStoredID(ctx, orgID, resource.Resource, resource.ResourceAttribute, resourceID)
+		if err != nil {
+			return err
+		}
+		return hook(session, orgID, teamID, id, permission)
+	}
+}
+
+func (t *translatingStore) builtInRoleHook(ctx context.Context, resource SetResourcePermissionCommand, hook BuiltinResourceHookFunc) BuiltinResourceHookFunc {
+	if hook == nil {
+		return nil
+	}
+	return func(session *db.Session, orgID int64, builtInRole, resourceID, permission string) error {
+		id, err := t.fromStoredID(ctx, orgID, resource.Resource, resource.ResourceAttribute, resourceID)
+		if err != nil {
+			return err
+		}
+		return hook(session, orgID, builtInRole, id, permission)
+	}
+}
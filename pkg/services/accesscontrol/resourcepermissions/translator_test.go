@@ -0,0 +1,187 @@
+package resourcepermissions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+// tenantTranslator prefixes the ids of the scopes with the tenant of their organization, e.g. dashboards:uid:tenant1/abc
+type tenantTranslator struct{}
+
+func (tenantTranslator) ToStored(ctx context.Context, orgID int64, scope string) (string, error) {
+	parts := strings.SplitN(scope, ":", 3)
+	if len(parts) != 3 || parts[2] == "*" {
+		return scope, nil
+	}
+	return fmt.Sprintf("%s:%s:tenant%d/%s", parts[0], parts[1], orgID, parts[2]), nil
+}
+
+func (tenantTranslator) FromStored(ctx context.Context, orgID int64, scope string) (string, error) {
+	return strings.Replace(scope, fmt.Sprintf(":tenant%d/", orgID), ":", 1), nil
+}
+
+func translatorTestOptions(matchUntranslated bool) Options {
+	return Options{
+		Resource:          "dashboards",
+		ResourceAttribute: "uid",
+		Assignments:       Assignments{Users: true, Teams: true, BuiltInRoles: true},
+		PermissionsToActions: map[string][]string{
+			"View": {"dashboards:read"},
+			"Edit": {"dashboards:read", "dashboards:write"},
+		},
+		InheritedScopesSolver: func(ctx context.Context, orgID int64, resourceID string) ([]string, error) {
+			return []string{"folders:uid:parent"}, nil
+		},
+		ScopeTranslator:         tenantTranslator{},
+		MatchUntranslatedScopes: matchUntranslated,
+	}
+}
+
+// setStoredPermission writes a managed permission for a user with the scope resource:uid:resourceID as is, e.g. the
+// way it was written before the translator was configured
+func setStoredPermission(t *testing.T, service *Service, orgID, userID int64, resource, resourceID string, actions ...string) {
+	t.Helper()
+	_, err := service.store.(*translatingStore).Store.SetUserResourcePermission(context.Background(), orgID, accesscontrol.User{ID: userID}, SetResourcePermissionCommand{
+		Actions:           actions,
+		Resource:          resource,
+		ResourceID:        resourceID,
+		ResourceAttribute: "uid",
+	}, nil)
+	require.NoError(t, err)
+}
+
+func TestService_ScopeTranslator(t *testing.T) {
+	var hooked []string
+	options := translatorTestOptions(false)
+	options.OnSetUser = func(session *db.Session, orgID int64, user accesscontrol.User, resourceID, permission string) error {
+		hooked = append(hooked, resourceID)
+		return nil
+	}
+	service, sql, _ := setupTestEnvironment(t, options)
+	usr := createOwnerTestUser(t, sql, "user", false)
+	legacy := createOwnerTestUser(t, sql, "legacy", false)
+
+	_, err := service.SetUserPermission(context.Background(), 1, accesscontrol.User{ID: usr.ID}, "1", "Edit")
+	require.NoError(t, err)
+	setStoredPermission(t, service, 1, usr.ID, "folders", "tenant1/parent", "dashboards:read")
+	setStoredPermission(t, service, 1, legacy.ID, "dashboards", "1", "dashboards:read")
+
+	t.Run("should store the translated scopes", func(t *testing.T) {
+		assert.Equal(t, []string{"1"}, hooked, "hooks should be called with the untranslated resource id")
+		assert.Equal(t, []storedAssignment{
+			{RoleName: accesscontrol.ManagedUserRoleName(usr.ID), UserID: usr.ID, Action: "dashboards:read", Scope: "dashboards:uid:tenant1/1"},
+			{RoleName: accesscontrol.ManagedUserRoleName(usr.ID), UserID: usr.ID, Action: "dashboards:write", Scope: "dashboards:uid:tenant1/1"},
+			{RoleName: accesscontrol.ManagedUserRoleName(usr.ID), UserID: usr.ID, Action: "dashboards:read", Scope: "folders:uid:tenant1/parent"},
+			{RoleName: accesscontrol.ManagedUserRoleName(legacy.ID), UserID: legacy.ID, Action: "dashboards:read", Scope: "dashboards:uid:1"},
+		}, retrieveAssignmentsHelper(t, sql))
+	})
+
+	t.Run("should read the untranslated scopes", func(t *testing.T) {
+		permissions, err := service.GetPermissions(context.Background(), &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{
+			1: {accesscontrol.ActionOrgUsersRead: {accesscontrol.ScopeUsersAll}},
+		}}, "1")
+		require.NoError(t, err)
+
+		scopes := map[string]bool{}
+		for _, p := range permissions {
+			assert.Equal(t, usr.ID, p.UserId, "permissions stored with untranslated scopes should not be matched")
+			scopes[p.Scope] = p.IsInherited
+		}
+		assert.Equal(t, map[string]bool{"dashboards:uid:1": false, "folders:uid:parent": true}, scopes)
+		assertManagedPermissions(t, service, "1", map[int64]string{usr.ID: "Edit"})
+	})
+
+	t.Run("should evaluate the translated scopes", func(t *testing.T) {
+		translated := &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {"dashboards:read": {"dashboards:uid:tenant1/1"}}}}
+		ok, err := service.ac.Evaluate(context.Background(), translated, accesscontrol.EvalPermission("dashboards:read", "dashboards:uid:1"))
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		users, err := service.ListUsersWithAccess(context.Background(), 1, "1", "dashboards:read")
+		require.NoError(t, err)
+		assert.Equal(t, []int64{usr.ID}, users)
+	})
+
+	t.Run("should delete the translated scopes", func(t *testing.T) {
+		require.NoError(t, service.DeleteResourcePermissions(context.Background(), 1, "1"))
+		for _, a := range retrieveAssignmentsHelper(t, sql) {
+			assert.NotEqual(t, "dashboards:uid:tenant1/1", a.Scope)
+		}
+	})
+}
+
+func TestService_MatchUntranslatedScopes(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, translatorTestOptions(true))
+	usr := createOwnerTestUser(t, sql, "user", false)
+	other := createOwnerTestUser(t, sql, "other", false)
+
+	setStoredPermission(t, service, 1, usr.ID, "dashboards", "1", "dashboards:read", "dashboards:write")
+	setStoredPermission(t, service, 1, other.ID, "dashboards", "1", "dashboards:read")
+	// a permission stored with both scopes is kept once
+	setStoredPermission(t, service, 1, other.ID, "dashboards", "tenant1/1", "dashboards:read")
+	require.NoError(t, service.store.(*translatingStore).Store.LockResourcePermissions(context.Background(), 1, PermissionsLock{
+		Resource: "dashboards", ResourceID: "2", LockedBy: usr.ID,
+	}))
+
+	t.Run("should evaluate both scopes", func(t *testing.T) {
+		for _, scope := range []string{"dashboards:uid:1", "dashboards:uid:tenant1/1"} {
+			signedIn := &user.SignedInUser{OrgID: 1, Permissions: map[int64]map[string][]string{1: {"dashboards:read": {scope}}}}
+			ok, err := service.ac.Evaluate(context.Background(), signedIn, accesscontrol.EvalPermission("dashboards:read", "dashboards:uid:1"))
+			require.NoError(t, err)
+			assert.True(t, ok, scope)
+		}
+	})
+
+	t.Run("should rewrite the untranslated scopes of a resource when it is read", func(t *testing.T) {
+		assertManagedPermissions(t, service, "1", map[int64]string{usr.ID: "Edit", other.ID: "View"})
+		assert.Equal(t, []storedAssignment{
+			{RoleName: accesscontrol.ManagedUserRoleName(usr.ID), UserID: usr.ID, Action: "dashboards:read", Scope: "dashboards:uid:tenant1/1"},
+			{RoleName: accesscontrol.ManagedUserRoleName(usr.ID), UserID: usr.ID, Action: "dashboards:write", Scope: "dashboards:uid:tenant1/1"},
+			{RoleName: accesscontrol.ManagedUserRoleName(other.ID), UserID: other.ID, Action: "dashboards:read", Scope: "dashboards:uid:tenant1/1"},
+		}, retrieveAssignmentsHelper(t, sql))
+	})
+
+	t.Run("should rewrite the lock of a resource when it is read", func(t *testing.T) {
+		lock, err := service.GetPermissionsLock(context.Background(), 1, "2")
+		require.NoError(t, err)
+		require.NotNil(t, lock)
+		assert.Equal(t, "2", lock.ResourceID)
+
+		stored, err := service.store.(*translatingStore).Store.GetResourcePermissionsLock(context.Background(), 1, "dashboards", "tenant1/2")
+		require.NoError(t, err)
+		assert.NotNil(t, stored)
+	})
+}
+
+func TestService_TranslateStoredScopes(t *testing.T) {
+	service, sql, _ := setupTestEnvironment(t, translatorTestOptions(true))
+	usr := createOwnerTestUser(t, sql, "user", false)
+
+	setStoredPermission(t, service, 1, usr.ID, "dashboards", "1", "dashboards:read")
+	setStoredPermission(t, service, 1, usr.ID, "dashboards", "tenant1/2", "dashboards:read")
+	setStoredPermission(t, service, 1, usr.ID, "dashboards", "*", "dashboards:write")
+	setStoredPermission(t, service, 2, usr.ID, "dashboards", "3", "dashboards:read")
+
+	rows, err := service.TranslateStoredScopes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows)
+
+	var scopes []string
+	for _, a := range retrieveAssignmentsHelper(t, sql) {
+		scopes = append(scopes, a.Scope)
+	}
+	assert.ElementsMatch(t, []string{"dashboards:uid:tenant1/1", "dashboards:uid:tenant1/2", "dashboards:uid:*", "dashboards:uid:tenant2/3"}, scopes)
+
+	rows, err = service.TranslateStoredScopes(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, rows)
+}
@@ -17,7 +17,6 @@ import (
 	"github.com/grafana/grafana/pkg/services/alerting"
 	"github.com/grafana/grafana/pkg/services/auth/identity"
 	"github.com/grafana/grafana/pkg/services/dashboards"
-	"github.com/grafana/grafana/pkg/services/dashboards/dashboardaccess"
 	"github.com/grafana/grafana/pkg/services/datasources"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/folder"
@@ -401,6 +400,11 @@ func (dr *DashboardServiceImpl) SaveDashboard(ctx context.Context, dto *dashboar
 		return nil, err
 	}
 
+	previousFolderUID, err := dr.getPreviousFolderUID(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+
 	dash, err := dr.dashboardStore.SaveDashboard(ctx, *cmd)
 	if err != nil {
 		return nil, fmt.Errorf("saving dashboard failed: %w", err)
@@ -428,11 +432,52 @@ func (dr *DashboardServiceImpl) SaveDashboard(ctx context.Context, dto *dashboar
 	// new dashboard created
 	if dto.Dashboard.ID == 0 {
 		dr.setDefaultPermissions(ctx, dto, dash, false)
+	} else if previousFolderUID != nil && *previousFolderUID != dash.FolderUID {
+		if err := dr.handleDashboardMoved(ctx, dto.OrgID, dash, *previousFolderUID); err != nil {
+			return nil, err
+		}
 	}
 
 	return dash, nil
 }
 
+func (dr *DashboardServiceImpl) permissionsMovePolicy() accesscontrol.ResourceMovePolicy {
+	if dr.cfg == nil || dr.cfg.DashboardPermissionsOnMove == "" {
+		return accesscontrol.MovePolicyKeep
+	}
+	return accesscontrol.ResourceMovePolicy(dr.cfg.DashboardPermissionsOnMove)
+}
+
+// getPreviousFolderUID returns the folder of an existing dashboard before it is saved, or nil when the permissions of
+// moved dashboards are kept as is
+func (dr *DashboardServiceImpl) getPreviousFolderUID(ctx context.Context, dto *dashboards.SaveDashboardDTO) (*string, error) {
+	if dto.Dashboard.ID == 0 || dto.Dashboard.IsFolder || dr.permissionsMovePolicy() == accesscontrol.MovePolicyKeep {
+		return nil, nil
+	}
+
+	previous, err := dr.dashboardStore.GetDashboard(ctx, &dashboards.GetDashboardQuery{ID: dto.Dashboard.ID, OrgID: dto.OrgID})
+	if err != nil {
+		return nil, err
+	}
+	return &previous.FolderUID, nil
+}
+
+// handleDashboardMoved applies the configured policy to the permissions of a dashboard moved out of previousFolderUID
+func (dr *DashboardServiceImpl) handleDashboardMoved(ctx context.Context, orgID int64, dash *dashboards.Dashboard, previousFolderUID string) error {
+	folderScope := func(uid string) string {
+		if uid == "" {
+			uid = folder.GeneralFolderUID
+		}
+		return dashboards.ScopeFoldersProvider.GetResourceScopeUID(uid)
+	}
+
+	err := dr.dashboardPermissions.HandleResourceMoved(ctx, orgID, dash.UID, folderScope(previousFolderUID), folderScope(dash.FolderUID), dr.permissionsMovePolicy())
+	if err != nil {
+		return fmt.Errorf("updating permissions of moved dashboard failed: %w", err)
+	}
+	return nil
+}
+
 // DeleteDashboard removes dashboard from the DB. Errors out if the dashboard was provisioned. Should be used for
 // operations by the user where we want to make sure user does not delete provisioned dashboard.
 func (dr *DashboardServiceImpl) DeleteDashboard(ctx context.Context, dashboardId int64, orgId int64) error {
@@ -498,28 +543,12 @@ func (dr *DashboardServiceImpl) GetDashboardsByPluginID(ctx context.Context, que
 }
 
 func (dr *DashboardServiceImpl) setDefaultPermissions(ctx context.Context, dto *dashboards.SaveDashboardDTO, dash *dashboards.Dashboard, provisioned bool) {
-	// nolint:staticcheck
-	inFolder := dash.FolderID > 0
-	var permissions []accesscontrol.SetResourcePermissionCommand
-
+	// provisioned dashboards have no creator
+	var creator identity.Requester
 	if !provisioned {
-		namespaceID, userIDstr := dto.User.GetNamespacedID()
-		userID, err := identity.IntIdentifier(namespaceID, userIDstr)
-
-		if err != nil {
-			dr.log.Error("Could not make user admin", "dashboard", dash.Title, "namespaceID", namespaceID, "userID", userID, "error", err)
-		} else if namespaceID == identity.NamespaceUser && userID > 0 {
-			permissions = append(permissions, accesscontrol.SetResourcePermissionCommand{
-				UserID: userID, Permission: dashboardaccess.PERMISSION_ADMIN.String(),
-			})
-		}
-	}
-
-	if !inFolder {
-		permissions = append(permissions, []accesscontrol.SetResourcePermissionCommand{
-			{BuiltinRole: string(org.RoleEditor), Permission: dashboardaccess.PERMISSION_EDIT.String()},
-			{BuiltinRole: string(org.RoleViewer), Permission: dashboardaccess.PERMISSION_VIEW.String()},
-		}...)
+		creator = dto.User
+	} else {
+		ctx = accesscontrol.WithGrantor(ctx, accesscontrol.GrantorProvisioning)
 	}
 
 	svc := dr.dashboardPermissions
@@ -527,7 +556,7 @@ func (dr *DashboardServiceImpl) setDefaultPermissions(ctx context.Context, dto *
 		svc = dr.folderPermissions
 	}
 
-	if _, err := svc.SetPermissions(ctx, dto.OrgID, dash.UID, permissions...); err != nil {
+	if _, err := svc.SetDefaultPermissions(ctx, dto.OrgID, dash.UID, creator); err != nil {
 		dr.log.Error("Could not set default permissions", "dashboard", dash.Title, "error", err)
 	}
 }
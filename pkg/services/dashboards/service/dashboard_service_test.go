@@ -11,6 +11,8 @@ import (
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/infra/appcontext"
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	acmock "github.com/grafana/grafana/pkg/services/accesscontrol/mock"
 	"github.com/grafana/grafana/pkg/services/dashboards"
 	"github.com/grafana/grafana/pkg/services/folder"
 	"github.com/grafana/grafana/pkg/services/folder/foldertest"
@@ -185,6 +187,78 @@ func TestDashboardService(t *testing.T) {
 			})
 		})
 
+		t.Run("Move dashboard", func(t *testing.T) {
+			permissions := acmock.NewMockedPermissionsService()
+			service.dashboardPermissions = permissions
+			service.cfg.DashboardPermissionsOnMove = string(accesscontrol.MovePolicyDropRedundant)
+			t.Cleanup(func() {
+				service.dashboardPermissions = nil
+				service.cfg.DashboardPermissionsOnMove = ""
+			})
+
+			t.Run("Should apply the move policy to the permissions of a dashboard moved to another folder", func(t *testing.T) {
+				fakeStore.On("ValidateDashboardBeforeSave", mock.Anything, mock.Anything, mock.AnythingOfType("bool")).Return(true, nil).Once()
+				fakeStore.On("GetDashboard", mock.Anything, &dashboards.GetDashboardQuery{ID: 3, OrgID: 1}).Return(&dashboards.Dashboard{ID: 3, UID: "dash", FolderUID: "a"}, nil).Once()
+				fakeStore.On("SaveDashboard", mock.Anything, mock.AnythingOfType("dashboards.SaveDashboardCommand")).Return(&dashboards.Dashboard{ID: 3, UID: "dash", Data: simplejson.New()}, nil).Once()
+				permissions.On("HandleResourceMoved", mock.Anything, int64(1), "dash", "folders:uid:a", "folders:uid:general", accesscontrol.MovePolicyDropRedundant).Return(nil).Once()
+
+				dto := &dashboards.SaveDashboardDTO{OrgID: 1, Dashboard: dashboards.NewDashboard("Dash"), User: &user.SignedInUser{UserID: 1}}
+				dto.Dashboard.SetID(3)
+				_, err := service.SaveDashboard(context.Background(), dto, true)
+				require.NoError(t, err)
+				permissions.AssertExpectations(t)
+			})
+
+			t.Run("Should return the error of the move policy", func(t *testing.T) {
+				fakeStore.On("ValidateDashboardBeforeSave", mock.Anything, mock.Anything, mock.AnythingOfType("bool")).Return(true, nil).Once()
+				fakeStore.On("GetDashboard", mock.Anything, &dashboards.GetDashboardQuery{ID: 3, OrgID: 1}).Return(&dashboards.Dashboard{ID: 3, UID: "dash", FolderUID: "b"}, nil).Once()
+				fakeStore.On("SaveDashboard", mock.Anything, mock.AnythingOfType("dashboards.SaveDashboardCommand")).Return(&dashboards.Dashboard{ID: 3, UID: "dash", Data: simplejson.New()}, nil).Once()
+				moveErr := errors.New("resource permissions are locked")
+				permissions.On("HandleResourceMoved", mock.Anything, int64(1), "dash", "folders:uid:b", "folders:uid:general", accesscontrol.MovePolicyDropRedundant).Return(moveErr).Once()
+
+				dto := &dashboards.SaveDashboardDTO{OrgID: 1, Dashboard: dashboards.NewDashboard("Dash"), User: &user.SignedInUser{UserID: 1}}
+				dto.Dashboard.SetID(3)
+				_, err := service.SaveDashboard(context.Background(), dto, true)
+				require.ErrorIs(t, err, moveErr)
+			})
+
+			t.Run("Should not look up the previous folder of a dashboard when its permissions are kept", func(t *testing.T) {
+				service.cfg.DashboardPermissionsOnMove = string(accesscontrol.MovePolicyKeep)
+				t.Cleanup(func() { service.cfg.DashboardPermissionsOnMove = string(accesscontrol.MovePolicyDropRedundant) })
+				fakeStore.On("ValidateDashboardBeforeSave", mock.Anything, mock.Anything, mock.AnythingOfType("bool")).Return(true, nil).Once()
+				fakeStore.On("SaveDashboard", mock.Anything, mock.AnythingOfType("dashboards.SaveDashboardCommand")).Return(&dashboards.Dashboard{ID: 3, UID: "dash", Data: simplejson.New()}, nil).Once()
+
+				lookups := func() int {
+					n := 0
+					for _, call := range fakeStore.Calls {
+						if call.Method == "GetDashboard" {
+							n++
+						}
+					}
+					return n
+				}
+				before := lookups()
+
+				dto := &dashboards.SaveDashboardDTO{OrgID: 1, Dashboard: dashboards.NewDashboard("Dash"), User: &user.SignedInUser{UserID: 1}}
+				dto.Dashboard.SetID(3)
+				_, err := service.SaveDashboard(context.Background(), dto, true)
+				require.NoError(t, err)
+				require.Equal(t, before, lookups(), "the previous folder should not be looked up")
+			})
+
+			t.Run("Should not change the permissions of a dashboard saved in the same folder", func(t *testing.T) {
+				fakeStore.On("ValidateDashboardBeforeSave", mock.Anything, mock.Anything, mock.AnythingOfType("bool")).Return(true, nil).Once()
+				fakeStore.On("GetDashboard", mock.Anything, &dashboards.GetDashboardQuery{ID: 3, OrgID: 1}).Return(&dashboards.Dashboard{ID: 3, UID: "dash"}, nil).Once()
+				fakeStore.On("SaveDashboard", mock.Anything, mock.AnythingOfType("dashboards.SaveDashboardCommand")).Return(&dashboards.Dashboard{ID: 3, UID: "dash", Data: simplejson.New()}, nil).Once()
+
+				dto := &dashboards.SaveDashboardDTO{OrgID: 1, Dashboard: dashboards.NewDashboard("Dash"), User: &user.SignedInUser{UserID: 1}}
+				dto.Dashboard.SetID(3)
+				_, err := service.SaveDashboard(context.Background(), dto, true)
+				require.NoError(t, err)
+				permissions.AssertNotCalled(t, "HandleResourceMoved", mock.Anything, mock.Anything, mock.Anything, "folders:uid:general", "folders:uid:general", mock.Anything)
+			})
+		})
+
 		t.Run("Import dashboard validation", func(t *testing.T) {
 			dto := &dashboards.SaveDashboardDTO{}
 
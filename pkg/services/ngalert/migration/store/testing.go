@@ -13,6 +13,7 @@ import (
 	"github.com/grafana/grafana/pkg/infra/localcache"
 	"github.com/grafana/grafana/pkg/infra/log/logtest"
 	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
 	"github.com/grafana/grafana/pkg/services/accesscontrol/ossaccesscontrol"
 	legacyalerting "github.com/grafana/grafana/pkg/services/alerting"
@@ -73,10 +74,10 @@ func NewTestMigrationStore(t testing.TB, sqlStore *sqlstore.SQLStore, cfg *setti
 	require.NoError(t, err)
 
 	folderPermissions, err := ossaccesscontrol.ProvideFolderPermissions(
-		features, routeRegister, sqlStore, ac, license, dashboardStore, folderService, acSvc, teamSvc, userSvc)
+		features, routeRegister, sqlStore, ac, license, dashboardStore, folderService, acSvc, teamSvc, userSvc, nil, nil, nil)
 	require.NoError(t, err)
 	dashboardPermissions, err := ossaccesscontrol.ProvideDashboardPermissions(
-		features, routeRegister, sqlStore, ac, license, dashboardStore, folderService, acSvc, teamSvc, userSvc)
+		features, routeRegister, sqlStore, ac, license, dashboardStore, folderService, acSvc, teamSvc, userSvc, nil, nil, nil)
 	require.NoError(t, err)
 
 	dashboardService, err := dashboardservice.ProvideDashboardServiceImpl(
@@ -88,6 +89,19 @@ func NewTestMigrationStore(t testing.TB, sqlStore *sqlstore.SQLStore, cfg *setti
 	require.NoError(t, err)
 	guardian.InitAccessControlGuardian(setting.NewCfg(), ac, dashboardService)
 
+	// the roles granting the actions of the folder and dashboard permissions are declared by services the store does
+	// not run
+	var actions []accesscontrol.Permission
+	for _, levelActions := range [][]string{ossaccesscontrol.FolderAdminActions, ossaccesscontrol.DashboardAdminActions} {
+		for _, action := range levelActions {
+			actions = append(actions, accesscontrol.Permission{Action: action})
+		}
+	}
+	err = acSvc.DeclareFixedRoles(accesscontrol.RoleRegistration{
+		Role: accesscontrol.RoleDTO{Name: "fixed:migration.test:actions", Permissions: actions},
+	})
+	require.NoError(t, err)
+
 	err = acSvc.RegisterFixedRoles(context.Background())
 	require.NoError(t, err)
 
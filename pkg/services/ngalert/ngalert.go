@@ -111,6 +111,11 @@ func ProvideService(
 		return nil, err
 	}
 
+	// The roles are declared even if UA is disabled, the folder permissions grant the alert rule actions.
+	if err := DeclareFixedRoles(ng.accesscontrolService); err != nil {
+		return nil, err
+	}
+
 	if !ng.shouldRun() {
 		return ng, nil
 	}
@@ -354,7 +359,7 @@ func (ng *AlertNG) init() error {
 		return key.LogContext(), true
 	})
 
-	return DeclareFixedRoles(ng.accesscontrolService)
+	return nil
 }
 
 func subscribeToFolderChanges(logger log.Logger, bus bus.Bus, dbStore api.RuleStore) {
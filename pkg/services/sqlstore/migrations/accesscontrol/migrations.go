@@ -186,4 +186,68 @@ func AddMigration(mg *migrator.Migrator) {
 	mg.AddMigration("add permission identifier index", migrator.NewAddIndexMigration(permissionV1, &migrator.Index{
 		Cols: []string{"identifier"},
 	}))
+
+	// resource permission lookups start from the permissions on a set of scopes and join the roles granting them
+	mg.AddMigration("add permission scope_action index", migrator.NewAddIndexMigration(permissionV1, &migrator.Index{
+		Cols: []string{"scope", "action"},
+	}))
+
+	mg.AddMigration("add index user_role.role_id", migrator.NewAddIndexMigration(userRoleV1, &migrator.Index{
+		Cols: []string{"role_id"},
+	}))
+
+	mg.AddMigration("add index team_role.role_id", migrator.NewAddIndexMigration(teamRoleV1, &migrator.Index{
+		Cols: []string{"role_id"},
+	}))
+
+	resourcePermissionLockV1 := migrator.Table{
+		Name: "resource_permission_lock",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "resource", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "resource_id", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "locked_by", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "resource", "resource_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create resource_permission_lock table", migrator.NewAddTableMigration(resourcePermissionLockV1))
+
+	mg.AddMigration("add unique index resource_permission_lock.org_id_resource_resource_id", migrator.NewAddIndexMigration(resourcePermissionLockV1, resourcePermissionLockV1.Indices[0]))
+
+	resourcePermissionOwnerV1 := migrator.Table{
+		Name: "resource_permission_owner",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "resource", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "resource_id", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "user_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "resource", "resource_id"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"user_id"}},
+		},
+	}
+
+	mg.AddMigration("create resource_permission_owner table", migrator.NewAddTableMigration(resourcePermissionOwnerV1))
+
+	mg.AddMigration("add unique index resource_permission_owner.org_id_resource_resource_id", migrator.NewAddIndexMigration(resourcePermissionOwnerV1, resourcePermissionOwnerV1.Indices[0]))
+
+	mg.AddMigration("add index resource_permission_owner.user_id", migrator.NewAddIndexMigration(resourcePermissionOwnerV1, resourcePermissionOwnerV1.Indices[1]))
+
+	// the user who last granted a managed permission, existing permissions are recorded as granted by an unknown user (0)
+	mg.AddMigration("add column granted_by to permission table", migrator.NewAddColumnMigration(permissionV1, &migrator.Column{
+		Name: "granted_by", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add column reason to permission table", migrator.NewAddColumnMigration(permissionV1, &migrator.Column{
+		Name: "reason", Type: migrator.DB_NVarchar, Length: 255, Default: "''",
+	}))
 }
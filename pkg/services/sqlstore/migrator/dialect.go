@@ -70,6 +70,11 @@ type Dialect interface {
 	IsUniqueConstraintViolation(err error) bool
 	ErrorMessage(err error) string
 	IsDeadlock(err error) bool
+	// IsLockTimeout returns true when a statement gave up waiting for a lock held by another transaction
+	IsLockTimeout(err error) bool
+	// IsSerializationFailure returns true when a transaction was aborted because it could not be serialized with
+	// concurrent transactions and can be retried
+	IsSerializationFailure(err error) bool
 	Lock(LockCfg) error
 	Unlock(LockCfg) error
 
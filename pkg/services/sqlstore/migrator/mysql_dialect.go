@@ -219,6 +219,14 @@ func (db *MySQLDialect) IsDeadlock(err error) bool {
 	return db.isThisError(err, mysqlerr.ER_LOCK_DEADLOCK)
 }
 
+func (db *MySQLDialect) IsLockTimeout(err error) bool {
+	return db.isThisError(err, mysqlerr.ER_LOCK_WAIT_TIMEOUT)
+}
+
+func (db *MySQLDialect) IsSerializationFailure(err error) bool {
+	return false // Serialization failures are reported as deadlocks
+}
+
 // UpsertSQL returns the upsert sql statement for MySQL dialect
 func (db *MySQLDialect) UpsertSQL(tableName string, keyCols, updateCols []string) string {
 	q, _ := db.UpsertMultipleSQL(tableName, keyCols, updateCols, 1)
@@ -201,6 +201,14 @@ func (db *PostgresDialect) IsDeadlock(err error) bool {
 	return db.isThisError(err, "40P01")
 }
 
+func (db *PostgresDialect) IsLockTimeout(err error) bool {
+	return db.isThisError(err, "55P03")
+}
+
+func (db *PostgresDialect) IsSerializationFailure(err error) bool {
+	return db.isThisError(err, "40001")
+}
+
 func (db *PostgresDialect) PostInsertId(table string, sess *xorm.Session) error {
 	if table != "org" {
 		return nil
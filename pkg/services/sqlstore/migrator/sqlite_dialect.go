@@ -154,6 +154,18 @@ func (db *SQLite3) IsDeadlock(err error) bool {
 	return false // No deadlock
 }
 
+func (db *SQLite3) IsSerializationFailure(err error) bool {
+	return false // Write transactions are serialized by the database lock
+}
+
+func (db *SQLite3) IsLockTimeout(err error) bool {
+	var driverErr sqlite3.Error
+	if errors.As(err, &driverErr) {
+		return driverErr.Code == sqlite3.ErrBusy || driverErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
 // UpsertSQL returns the upsert sql statement for SQLite dialect
 func (db *SQLite3) UpsertSQL(tableName string, keyCols, updateCols []string) string {
 	str, _ := db.UpsertMultipleSQL(tableName, keyCols, updateCols, 1)
@@ -270,6 +270,8 @@ type Cfg struct {
 
 	// Dashboards
 	DefaultHomeDashboardPath string
+	// DashboardPermissionsOnMove is the policy applied to the permissions of dashboards moved to another folder
+	DashboardPermissionsOnMove string
 
 	// Auth
 	LoginCookieName              string
@@ -1078,6 +1080,12 @@ func (cfg *Cfg) Load(args CommandLineArgs) error {
 	MinRefreshInterval = valueAsString(dashboards, "min_refresh_interval", "5s")
 
 	cfg.DefaultHomeDashboardPath = dashboards.Key("default_home_dashboard_path").MustString("")
+	cfg.DashboardPermissionsOnMove = dashboards.Key("permissions_on_move").MustString("keep")
+	switch cfg.DashboardPermissionsOnMove {
+	case "keep", "dropRedundant", "clear":
+	default:
+		return fmt.Errorf("invalid [dashboards] permissions_on_move %q: must be one of keep, dropRedundant or clear", cfg.DashboardPermissionsOnMove)
+	}
 
 	if err := readUserSettings(iniFile, cfg); err != nil {
 		return err
@@ -75,6 +75,14 @@ func TestLoadingSettings(t *testing.T) {
 		require.Equal(t, filepath.Join(cfg.DataPath, "log"), cfg.LogsPath)
 	})
 
+	t.Run("Should reject an invalid dashboard permissions move policy", func(t *testing.T) {
+		t.Setenv("GF_DASHBOARDS_PERMISSIONS_ON_MOVE", "drop")
+
+		cfg := NewCfg()
+		err := cfg.Load(CommandLineArgs{HomePath: "../../"})
+		require.ErrorContains(t, err, "permissions_on_move")
+	})
+
 	t.Run("Should replace password when defined in environment", func(t *testing.T) {
 		t.Setenv("GF_SECURITY_ADMIN_PASSWORD", "supersecret")
 